@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -10,6 +11,7 @@ import (
 	"lazychef/internal/config"
 	"lazychef/internal/database"
 	"lazychef/internal/handlers"
+	"lazychef/internal/middleware"
 	"lazychef/internal/services"
 )
 
@@ -42,11 +44,42 @@ func main() {
 		}
 	}()
 
+	// Full-text recipe search index (requires the sqlite_fts5 build tag)
+	if err := services.NewRecipeSearchIndex(db).EnsureSchema(); err != nil {
+		log.Printf("Warning: full-text search unavailable, recipe search falls back to LIKE: %v", err)
+	}
+
+	// Ingredient price table for cost estimates
+	if err := services.NewIngredientPriceService(db, services.NewIngredientAggregator()).EnsureSchema(); err != nil {
+		log.Printf("Warning: ingredient prices unavailable, costs use flat estimates: %v", err)
+	}
+
+	// Allergen labels (特定原材料) stored on each recipe for search filtering
+	allergenService := services.NewAllergenService(db)
+	if err := allergenService.EnsureSchema(); err != nil {
+		log.Printf("Warning: ingredient allergens unavailable, allergens use keyword matching: %v", err)
+	}
+
+	// Dietary restriction compliance (vegetarian, vegan, halal, ...) stored on each recipe
+	dietaryService := services.NewDietaryRestrictionService(db)
+	if err := dietaryService.EnsureSchema(); err != nil {
+		log.Printf("Warning: ingredient dietary components unavailable, restrictions use keyword matching: %v", err)
+	}
+
+	// Both label sets are stored with one write per saved recipe
+	recipeLabeler := services.NewRecipeLabeler(db, allergenService, dietaryService)
+	db.OnRecipeSaved(recipeLabeler.HandleRecipeSaved)
+	if n, err := recipeLabeler.RefreshRecipes(); err != nil {
+		log.Printf("Warning: failed to refresh recipe allergens and dietary flags: %v", err)
+	} else if n > 0 {
+		log.Printf("Updated allergens and dietary flags on %d recipes", n)
+	}
+
 	// Load OpenAI configuration
 	openaiConfig, err := config.LoadOpenAIConfig()
 	if err != nil {
 		log.Printf("Warning: OpenAI configuration error: %v", err)
-		log.Println("Recipe generation will not be available")
+		log.Println("Recipe generation will not be available (set LLM_PROVIDER=local to run offline)")
 	}
 
 	// Initialize services
@@ -60,14 +93,94 @@ func main() {
 		if err != nil {
 			log.Printf("Warning: Failed to initialize recipe generator: %v", err)
 		} else {
+			// Advanced token rate limiter
+			tokenRateLimiter := services.NewTokenRateLimiter(
+				openaiConfig.RequestsPerMinute,
+				1000,   // tokens per second
+				100.0,  // daily budget USD
+				3000.0, // monthly budget USD
+			)
+			if openaiConfig.IsSelfHosted() {
+				tokenRateLimiter.SetZeroCostModels(openaiConfig.ConfiguredModels())
+			}
+
+			// Versioned model prices: built-in defaults, then MODEL_PRICING_FILE, then admin edits
+			pricingTable := services.NewPricingTable(db)
+			if pricingFile := os.Getenv("MODEL_PRICING_FILE"); pricingFile != "" {
+				if n, err := pricingTable.LoadFile(pricingFile); err != nil {
+					log.Printf("Warning: Failed to load model pricing file: %v", err)
+				} else {
+					log.Printf("Loaded %d model prices from %s", n, pricingFile)
+				}
+			}
+			if err := pricingTable.EnsureSchema(); err != nil {
+				log.Printf("Warning: Failed to prepare model price table: %v", err)
+			} else if err := pricingTable.LoadSaved(); err != nil {
+				log.Printf("Warning: Failed to load saved model prices: %v", err)
+			}
+			tokenRateLimiter.SetPricingTable(pricingTable)
+
+			// Persistent usage ledger; budgets are rebuilt from it so they survive restarts
+			usageLedger := services.NewUsageLedger(db)
+			if err := usageLedger.EnsureSchema(); err != nil {
+				log.Printf("Warning: Failed to prepare token usage ledger: %v", err)
+			} else if err := tokenRateLimiter.SetLedger(usageLedger); err != nil {
+				log.Printf("Warning: Failed to restore spend from token usage ledger: %v", err)
+			}
+
+			// Meter and budget-gate every provider call before other services take the provider
+			generatorService.UseProvider(services.NewMeteredProvider(generatorService.GetProvider(), tokenRateLimiter))
+
+			// Persistent generation cache tier behind the in-memory one, so
+			// identical requests stay cached across restarts and replicas
+			sqliteCache := services.NewSQLiteRecipeCache(db, 24*time.Hour)
+			if err := sqliteCache.EnsureSchema(); err != nil {
+				log.Printf("Warning: Failed to prepare generation cache table: %v", err)
+			} else {
+				if _, err := sqliteCache.PurgeExpired(); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+				generatorService.GetCache().AddTier(sqliteCache)
+			}
+
+			// Cache keys ignore ingredient order and resolve aliases through the ingredient hierarchy
+			cacheKeys := services.NewCacheKeyNormalizer(services.NewIngredientResolver(db))
+			generatorService.SetCacheKeyNormalizer(cacheKeys)
+			generatorService.SetDietaryRestrictionService(dietaryService)
+
 			// Initialize enhanced generator service
 			enhancedGeneratorService := services.NewEnhancedRecipeGeneratorService(
-				generatorService.GetClient(),
+				generatorService.GetProvider(),
 				openaiConfig,
 				generatorService.GetRateLimiter(),
 				generatorService.GetCache(),
 			)
 
+			enhancedGeneratorService.SetCacheKeyNormalizer(cacheKeys)
+			enhancedGeneratorService.SetDietaryRestrictionService(dietaryService)
+
+			// Bilingual food safety rules: built-in defaults, then FOOD_SAFETY_RULES_FILE
+			if rulesFile := os.Getenv("FOOD_SAFETY_RULES_FILE"); rulesFile != "" {
+				if n, err := enhancedGeneratorService.GetFoodSafetyValidator().LoadRulesFile(rulesFile); err != nil {
+					log.Printf("Warning: Failed to load food safety rules file: %v", err)
+				} else {
+					log.Printf("Loaded %d food safety rules from %s", n, rulesFile)
+				}
+			}
+			// Safety results describe temperatures in Celsius unless FOOD_SAFETY_TEMPERATURE_UNIT=fahrenheit
+			enhancedGeneratorService.GetFoodSafetyValidator().SetTemperatureUnit(
+				services.ParseTemperatureUnit(os.Getenv("FOOD_SAFETY_TEMPERATURE_UNIT")))
+
+			// Every generated recipe passes the safety gate before it can be saved: failures
+			// are sent back to the critique model, and quarantined if they still fail
+			recipeQuarantine := services.NewRecipeQuarantine(db)
+			if err := recipeQuarantine.EnsureSchema(); err != nil {
+				log.Printf("Warning: Failed to prepare recipe quarantine table: %v", err)
+				recipeQuarantine = nil
+			}
+			enhancedGeneratorService.SetRecipeQuarantine(recipeQuarantine)
+			generatorService.SetSafetyGate(enhancedGeneratorService)
+
 			recipeHandler = handlers.NewRecipeHandler(db, generatorService, enhancedGeneratorService)
 
 			// Initialize meal planner with database and generator
@@ -82,25 +195,34 @@ func main() {
 
 			// Batch generation service
 			batchService := services.NewBatchGenerationService(
-				generatorService.GetClient(),
+				generatorService.GetProvider(),
 				openaiConfig,
 				db.DB,
 				batchStoragePath,
 			)
+			batchService.SetTokenRateLimiter(tokenRateLimiter)
 
 			// Embedding deduplicator
 			embeddingService := services.NewEmbeddingDeduplicator(
-				generatorService.GetClient(),
+				generatorService.GetProvider(),
 				db.DB,
 			)
+			embeddingService.SetEmbeddingModel(openaiConfig.EmbeddingModel)
+			if err := embeddingService.LoadIndex(); err != nil {
+				log.Printf("Warning: Failed to load embedding index: %v", err)
+			}
+			db.OnRecipeSaved(embeddingService.HandleRecipeSaved)
+			recipeHandler.SetEmbeddingService(embeddingService)
+			if openaiConfig.LibraryMatchThreshold > 0 {
+				recipeHandler.SetLibraryLookup(services.NewRecipeLibraryLookup(
+					embeddingService, db, float64(openaiConfig.LibraryMatchThreshold)))
+			}
 
-			// Advanced token rate limiter
-			tokenRateLimiter := services.NewTokenRateLimiter(
-				openaiConfig.RequestsPerMinute,
-				1000,   // tokens per second
-				100.0,  // daily budget USD
-				3000.0, // monthly budget USD
-			)
+			// Duplicate resolution (merge / keep both / dismiss)
+			duplicateResolver := services.NewDuplicateResolutionService(db, embeddingService)
+			if err := duplicateResolver.EnsureSchema(); err != nil {
+				log.Printf("Warning: Failed to prepare duplicate resolution tables: %v", err)
+			}
 
 			// Diversity service (Issue #65)
 			diversityService := services.NewDiversityService(db, generatorService)
@@ -115,14 +237,22 @@ func main() {
 				tokenRateLimiter,
 				diversityService,
 				autoGenerationService,
+				duplicateResolver,
 			)
+			adminHandler.SetGenerationCache(generatorService.GetCache())
+			adminHandler.SetRecipeQuarantine(recipeQuarantine)
 
+			log.Printf("LLM provider: %s", generatorService.GetProvider().Name())
+			if openaiConfig.BaseURL != "" {
+				log.Printf("  - Base URL: %s", openaiConfig.BaseURL)
+			}
 			log.Printf("GPT-5 Enhanced Services Initialized:")
 			log.Printf("  - Ideation Model: %s", openaiConfig.IdeationModel)
 			log.Printf("  - Authoring Model: %s", openaiConfig.AuthoringModel)
 			log.Printf("  - Critique Model: %s", openaiConfig.CritiqueModel)
 			log.Printf("  - Structured Outputs: %t", openaiConfig.UseStructuredOutputs)
 			log.Printf("  - Food Safety Strict Mode: %t", openaiConfig.FoodSafetyStrictMode)
+			log.Printf("  - Safety Remediation Rounds: %d", openaiConfig.SafetyRemediationRounds)
 
 			log.Printf("Phase 1 Services Initialized:")
 			log.Printf("  - Batch API Service: enabled")
@@ -136,6 +266,9 @@ func main() {
 	// Setup Gin router
 	r := gin.Default()
 
+	// Attribute LLM token usage to the route that triggered it
+	r.Use(middleware.UsageSourceMiddleware())
+
 	// CORS middleware - permissive for local development
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
@@ -159,6 +292,10 @@ func main() {
 		}
 
 		if openaiConfig != nil {
+			health["llm_provider"] = openaiConfig.Provider
+			if openaiConfig.BaseURL != "" {
+				health["llm_base_url"] = openaiConfig.BaseURL
+			}
 			health["gpt5_features"] = gin.H{
 				"structured_outputs": openaiConfig.UseStructuredOutputs,
 				"food_safety_mode":   openaiConfig.FoodSafetyStrictMode,
@@ -184,6 +321,7 @@ func main() {
 			api.POST("/clear-cache", recipeHandler.ClearCache)
 			api.GET("/test", recipeHandler.TestRecipeGeneration)
 			api.GET("/search", recipeHandler.SearchRecipes)
+			api.GET("/semantic-search", recipeHandler.SemanticSearchRecipes)
 			api.GET("/ingredient-categories", recipeHandler.GetIngredientCategories)
 			api.GET("/test-ingredient-mapping", recipeHandler.TestIngredientMapping)
 
@@ -213,6 +351,52 @@ func main() {
 		})
 	}
 
+	// Recipe CRUD endpoints (available even without OpenAI)
+	recipeCRUDHandler := recipeHandler
+	if recipeCRUDHandler == nil {
+		recipeCRUDHandler = handlers.NewRecipeHandler(db, nil, nil)
+	}
+	recipeCRUDAPI := r.Group("/api/recipes")
+	{
+		recipeCRUDAPI.POST("", recipeCRUDHandler.CreateRecipe)
+		recipeCRUDAPI.POST("/match-ingredients", recipeCRUDHandler.MatchRecipes)
+		recipeCRUDAPI.GET("/:id", recipeCRUDHandler.GetRecipe)
+		recipeCRUDAPI.PUT("/:id", recipeCRUDHandler.UpdateRecipe)
+		recipeCRUDAPI.PATCH("/:id", recipeCRUDHandler.PatchRecipe)
+		recipeCRUDAPI.DELETE("/:id", recipeCRUDHandler.DeleteRecipe)
+	}
+
+	// User preferences endpoints (available even without OpenAI)
+	preferencesHandler := handlers.NewUserPreferencesHandler(db)
+	preferencesAPI := r.Group("/api/users/:user_id/preferences")
+	{
+		preferencesAPI.GET("", preferencesHandler.GetPreferences)
+		preferencesAPI.PUT("", preferencesHandler.UpdatePreferences)
+		preferencesAPI.PATCH("", preferencesHandler.PatchPreferences)
+	}
+
+	// Pantry endpoints (available even without OpenAI)
+	if err := services.NewPantryService(db, services.NewIngredientAggregator()).EnsureSchema(); err != nil {
+		log.Printf("Warning: Failed to prepare pantry table: %v", err)
+	}
+	pantryHandler := handlers.NewPantryHandler(db)
+	pantryAPI := r.Group("/api/users/:user_id/pantry")
+	{
+		pantryAPI.GET("", pantryHandler.ListItems)
+		pantryAPI.POST("", pantryHandler.AddItem)
+		pantryAPI.PUT("/:item_id", pantryHandler.UpdateItem)
+		pantryAPI.DELETE("/:item_id", pantryHandler.DeleteItem)
+	}
+
+	// Ingredient price endpoints (available even without OpenAI)
+	priceHandler := handlers.NewIngredientPriceHandler(db)
+	priceAPI := r.Group("/api/ingredient-prices")
+	{
+		priceAPI.GET("", priceHandler.ListPrices)
+		priceAPI.POST("/import", priceHandler.ImportPrices)
+		priceAPI.PUT("/:ingredient", priceHandler.SetPrice)
+	}
+
 	// Meal planning endpoints
 	if mealPlanHandler != nil {
 		mealPlanAPI := r.Group("/api/meal-plans")
@@ -220,6 +404,7 @@ func main() {
 			mealPlanAPI.POST("/create", mealPlanHandler.CreateMealPlan)
 			mealPlanAPI.POST("/shopping-list", mealPlanHandler.GenerateShoppingList)
 			mealPlanAPI.GET("/:id", mealPlanHandler.GetMealPlan)
+			mealPlanAPI.POST("/:id/days/:day/cooked", mealPlanHandler.MarkMealCooked)
 			mealPlanAPI.GET("/", mealPlanHandler.ListMealPlans)
 		}
 	} else {
@@ -252,6 +437,9 @@ func main() {
 				duplicateAPI.POST("/scan", adminHandler.ScanDuplicates)
 				duplicateAPI.GET("/results", adminHandler.GetDuplicateResults)
 				duplicateAPI.POST("/check", adminHandler.CheckRecipeDuplicates)
+				duplicateAPI.POST("/resolve", adminHandler.ResolveDuplicate)
+				duplicateAPI.POST("/auto-merge", adminHandler.AutoMergeDuplicates)
+				duplicateAPI.GET("/resolutions", adminHandler.GetDuplicateResolutions)
 			}
 
 			// Embedding endpoints
@@ -266,8 +454,22 @@ func main() {
 				metricsAPI.GET("/token-usage", adminHandler.GetTokenUsageMetrics)
 				metricsAPI.GET("/cost-efficiency", adminHandler.GetCostEfficiencyAnalysis)
 				metricsAPI.POST("/budgets", adminHandler.UpdateBudgets)
+				metricsAPI.GET("/pricing", adminHandler.GetModelPricing)
+				metricsAPI.POST("/pricing", adminHandler.SetModelPrice)
 			}
 
+			// Generation cache inspection and invalidation
+			cacheAPI := adminAPI.Group("/cache")
+			{
+				cacheAPI.GET("/stats", adminHandler.GetGenerationCacheStats)
+				cacheAPI.GET("/keys", adminHandler.ListGenerationCacheKeys)
+				cacheAPI.GET("/entry", adminHandler.GetGenerationCacheEntry)
+				cacheAPI.DELETE("/entry", adminHandler.InvalidateGenerationCacheEntry)
+			}
+
+			// Generated recipes quarantined by the food safety gate
+			adminAPI.GET("/safety/quarantine", adminHandler.ListQuarantinedRecipes)
+
 			// Diversity system endpoints (Issue #65)
 			diversityAPI := adminAPI.Group("/diversity")
 			{
@@ -307,6 +509,11 @@ func main() {
 		log.Printf("Safety validation: http://localhost:%s/api/recipes/validate-safety", port)
 		log.Printf("Quality validation: http://localhost:%s/api/recipes/validate-quality", port)
 	}
+	log.Printf("Recipe CRUD: http://localhost:%s/api/recipes/:id", port)
+	log.Printf("Cook from what you have: http://localhost:%s/api/recipes/match-ingredients", port)
+	log.Printf("User preferences: http://localhost:%s/api/users/:user_id/preferences", port)
+	log.Printf("Pantry: http://localhost:%s/api/users/:user_id/pantry", port)
+	log.Printf("Ingredient prices: http://localhost:%s/api/ingredient-prices", port)
 
 	if adminHandler != nil {
 		log.Printf("Admin endpoints available:")
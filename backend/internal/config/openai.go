@@ -2,14 +2,24 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"time"
 )
 
+// LLM provider backends selectable through LLM_PROVIDER
+const (
+	ProviderOpenAI           = "openai"            // OpenAI API (requires OPENAI_API_KEY)
+	ProviderOpenAICompatible = "openai-compatible" // Self-hosted server behind OPENAI_BASE_URL (Ollama, llama.cpp)
+	ProviderLocal            = "local"             // Deterministic offline stub for dev, CI and demos
+)
+
 // OpenAIConfig holds OpenAI API configuration
 type OpenAIConfig struct {
+	Provider                string // "openai", "openai-compatible" or "local"
 	APIKey                  string
+	BaseURL                 string // Overrides the API base URL (e.g. http://localhost:11434/v1)
 	Model                   string
 	MaxTokens               int
 	Temperature             float32
@@ -26,24 +36,41 @@ type OpenAIConfig struct {
 	ReasoningEffort string // "minimal", "low", "medium", "high"
 	Verbosity       string // "minimal", "low", "medium", "high"
 
+	// Embeddings
+	EmbeddingModel string // Model used for recipe embeddings
+
+	// LibraryMatchThreshold is the cosine similarity at which an existing
+	// library recipe is served instead of generating a new one; 0 disables
+	// the library lookup
+	LibraryMatchThreshold float32
+
 	// Structured Outputs
 	UseStructuredOutputs bool // Enable strict JSON schema validation
 	MaxCompletionTokens  int  // Limit completion tokens for cost control
 
+	// ProviderSupportsStructuredOutputs is false for servers that reject
+	// JSON-schema response formats; generation then falls back to tolerant parsing
+	ProviderSupportsStructuredOutputs bool
+
 	// Food Safety & Quality
-	FoodSafetyStrictMode bool // Enable strict food safety checks
-	USDATemperatureCheck bool // Enable USDA temperature validation
+	FoodSafetyStrictMode    bool // Enable strict food safety checks
+	USDATemperatureCheck    bool // Enable USDA temperature validation
+	SafetyRemediationRounds int  // Critique-model rounds to fix recipes failing food safety; 0 quarantines them at once
 }
 
 // LoadOpenAIConfig loads OpenAI configuration from environment variables
 func LoadOpenAIConfig() (*OpenAIConfig, error) {
+	provider := getEnvOrDefault("LLM_PROVIDER", ProviderOpenAI)
 	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
+	// Self-hosted servers usually ignore the key, so only OpenAI itself requires one
+	if apiKey == "" && provider == ProviderOpenAI {
 		return nil, errors.New("OPENAI_API_KEY environment variable is required")
 	}
 
 	config := &OpenAIConfig{
+		Provider:                provider,
 		APIKey:                  apiKey,
+		BaseURL:                 os.Getenv("OPENAI_BASE_URL"),
 		Model:                   getEnvOrDefault("OPENAI_MODEL", "gpt-3.5-turbo"),
 		MaxTokens:               getEnvAsIntOrDefault("OPENAI_MAX_TOKENS", 1000),
 		Temperature:             getEnvAsFloatOrDefault("OPENAI_TEMPERATURE", 0.7),
@@ -60,13 +87,20 @@ func LoadOpenAIConfig() (*OpenAIConfig, error) {
 		ReasoningEffort: getEnvOrDefault("OPENAI_REASONING_EFFORT", "low"),
 		Verbosity:       getEnvOrDefault("OPENAI_VERBOSITY", "minimal"),
 
+		// Embeddings
+		EmbeddingModel:        getEnvOrDefault("OPENAI_EMBEDDING_MODEL", "text-embedding-ada-002"),
+		LibraryMatchThreshold: getEnvAsFloatOrDefault("LIBRARY_MATCH_THRESHOLD", 0),
+
 		// Structured Outputs
 		UseStructuredOutputs: getEnvOrDefault("OPENAI_USE_STRUCTURED_OUTPUTS", "true") == "true",
 		MaxCompletionTokens:  getEnvAsIntOrDefault("OPENAI_MAX_COMPLETION_TOKENS", 800),
 
+		ProviderSupportsStructuredOutputs: getEnvOrDefault("LLM_SUPPORTS_STRUCTURED_OUTPUTS", defaultStructuredOutputSupport(provider)) == "true",
+
 		// Food Safety & Quality
-		FoodSafetyStrictMode: getEnvOrDefault("FOOD_SAFETY_STRICT_MODE", "true") == "true",
-		USDATemperatureCheck: getEnvOrDefault("USDA_TEMP_CHECK_ENABLED", "true") == "true",
+		FoodSafetyStrictMode:    getEnvOrDefault("FOOD_SAFETY_STRICT_MODE", "true") == "true",
+		USDATemperatureCheck:    getEnvOrDefault("USDA_TEMP_CHECK_ENABLED", "true") == "true",
+		SafetyRemediationRounds: getEnvAsIntOrDefault("SAFETY_REMEDIATION_ROUNDS", 2),
 	}
 
 	// Validate configuration
@@ -79,12 +113,26 @@ func LoadOpenAIConfig() (*OpenAIConfig, error) {
 
 // Validate validates the OpenAI configuration
 func (c *OpenAIConfig) Validate() error {
-	if c.APIKey == "" {
-		return errors.New("API key cannot be empty")
+	switch c.Provider {
+	case "", ProviderOpenAI:
+		if c.APIKey == "" {
+			return errors.New("API key cannot be empty")
+		}
+	case ProviderOpenAICompatible:
+		if c.BaseURL == "" {
+			return errors.New("base URL is required for an OpenAI-compatible provider")
+		}
+	case ProviderLocal:
+		// The local provider never calls out, so no API key is needed
+	default:
+		return fmt.Errorf("unknown LLM provider: %s", c.Provider)
 	}
 	if c.MaxTokens <= 0 {
 		return errors.New("max tokens must be positive")
 	}
+	if c.SafetyRemediationRounds < 0 {
+		return errors.New("safety remediation rounds cannot be negative")
+	}
 	if c.Temperature < 0 || c.Temperature > 2 {
 		return errors.New("temperature must be between 0 and 2")
 	}
@@ -97,6 +145,9 @@ func (c *OpenAIConfig) Validate() error {
 	if c.RequestsPerMinute <= 0 {
 		return errors.New("requests per minute must be positive")
 	}
+	if c.LibraryMatchThreshold < 0 || c.LibraryMatchThreshold > 1 {
+		return errors.New("library match threshold must be between 0 and 1")
+	}
 	return nil
 }
 
@@ -105,6 +156,30 @@ func (c *OpenAIConfig) GetRateLimitDelay() time.Duration {
 	return time.Minute / time.Duration(c.RequestsPerMinute)
 }
 
+// UsesLocalProvider reports whether generation runs against the offline stub
+func (c *OpenAIConfig) UsesLocalProvider() bool {
+	return c.Provider == ProviderLocal
+}
+
+// IsSelfHosted reports whether generation runs on infrastructure we pay no
+// per-token price for (a self-hosted server or the local stub)
+func (c *OpenAIConfig) IsSelfHosted() bool {
+	return c.Provider == ProviderOpenAICompatible || c.Provider == ProviderLocal
+}
+
+// ConfiguredModels returns the distinct model names used across all stages
+func (c *OpenAIConfig) ConfiguredModels() []string {
+	seen := make(map[string]bool)
+	var models []string
+	for _, model := range []string{c.Model, c.IdeationModel, c.AuthoringModel, c.CritiqueModel, c.EmbeddingModel} {
+		if model != "" && !seen[model] {
+			seen[model] = true
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
 // IsProduction checks if we're using production OpenAI model
 func (c *OpenAIConfig) IsProduction() bool {
 	return c.Model == "gpt-4" || c.Model == "gpt-4-turbo"
@@ -112,6 +187,14 @@ func (c *OpenAIConfig) IsProduction() bool {
 
 // Helper functions for environment variable parsing
 
+// defaultStructuredOutputSupport assumes JSON-schema support only where we know it exists
+func defaultStructuredOutputSupport(provider string) string {
+	if provider == ProviderOpenAICompatible {
+		return "false"
+	}
+	return "true"
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -80,6 +80,78 @@ func TestLoadOpenAIConfig_MissingAPIKey(t *testing.T) {
 	assert.Contains(t, err.Error(), "OPENAI_API_KEY")
 }
 
+func TestLoadOpenAIConfig_LocalProviderWithoutAPIKey(t *testing.T) {
+	originalAPIKey := os.Getenv("OPENAI_API_KEY")
+	originalProvider := os.Getenv("LLM_PROVIDER")
+
+	defer func() {
+		_ = os.Setenv("OPENAI_API_KEY", originalAPIKey)
+		_ = os.Setenv("LLM_PROVIDER", originalProvider)
+	}()
+
+	_ = os.Unsetenv("OPENAI_API_KEY")
+	_ = os.Setenv("LLM_PROVIDER", "local")
+
+	config, err := LoadOpenAIConfig()
+
+	require.NoError(t, err)
+	assert.Equal(t, ProviderLocal, config.Provider)
+	assert.True(t, config.UsesLocalProvider())
+	assert.Empty(t, config.APIKey)
+}
+
+func TestLoadOpenAIConfig_OpenAICompatibleProvider(t *testing.T) {
+	originalValues := map[string]string{
+		"OPENAI_API_KEY":         os.Getenv("OPENAI_API_KEY"),
+		"LLM_PROVIDER":           os.Getenv("LLM_PROVIDER"),
+		"OPENAI_BASE_URL":        os.Getenv("OPENAI_BASE_URL"),
+		"OPENAI_AUTHORING_MODEL": os.Getenv("OPENAI_AUTHORING_MODEL"),
+	}
+
+	defer func() {
+		for key, value := range originalValues {
+			if value == "" {
+				_ = os.Unsetenv(key)
+			} else {
+				_ = os.Setenv(key, value)
+			}
+		}
+	}()
+
+	_ = os.Unsetenv("OPENAI_API_KEY")
+	_ = os.Setenv("LLM_PROVIDER", "openai-compatible")
+	_ = os.Unsetenv("OPENAI_BASE_URL")
+
+	_, err := LoadOpenAIConfig()
+	assert.Error(t, err) // Base URL is required
+
+	_ = os.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1")
+	_ = os.Setenv("OPENAI_AUTHORING_MODEL", "llama3.1:8b")
+
+	config, err := LoadOpenAIConfig()
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:11434/v1", config.BaseURL)
+	assert.False(t, config.ProviderSupportsStructuredOutputs)
+	assert.True(t, config.IsSelfHosted())
+	assert.Contains(t, config.ConfiguredModels(), "llama3.1:8b")
+}
+
+func TestOpenAIConfig_ValidateUnknownProvider(t *testing.T) {
+	config := &OpenAIConfig{
+		Provider:          "mystery",
+		MaxTokens:         1000,
+		Temperature:       0.7,
+		RequestTimeout:    time.Second,
+		RequestsPerMinute: 60,
+	}
+
+	err := config.Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown LLM provider")
+}
+
 func TestLoadOpenAIConfig_CustomValues(t *testing.T) {
 	// Set up custom environment variables
 	originalValues := map[string]string{
@@ -186,3 +258,29 @@ func TestLoadOpenAIConfig_InvalidDuration(t *testing.T) {
 	assert.NotNil(t, config)
 	assert.Equal(t, 30*time.Second, config.RequestTimeout) // Default value
 }
+
+func TestLoadOpenAIConfig_SafetyRemediationRounds(t *testing.T) {
+	originalAPIKey := os.Getenv("OPENAI_API_KEY")
+	originalRounds := os.Getenv("SAFETY_REMEDIATION_ROUNDS")
+
+	defer func() {
+		_ = os.Setenv("OPENAI_API_KEY", originalAPIKey)
+		_ = os.Setenv("SAFETY_REMEDIATION_ROUNDS", originalRounds)
+	}()
+
+	_ = os.Setenv("OPENAI_API_KEY", "test-key")
+	_ = os.Unsetenv("SAFETY_REMEDIATION_ROUNDS")
+
+	config, err := LoadOpenAIConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 2, config.SafetyRemediationRounds) // Default
+
+	_ = os.Setenv("SAFETY_REMEDIATION_ROUNDS", "0")
+	config, err = LoadOpenAIConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 0, config.SafetyRemediationRounds)
+
+	_ = os.Setenv("SAFETY_REMEDIATION_ROUNDS", "-1")
+	_, err = LoadOpenAIConfig()
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -13,6 +14,9 @@ import (
 // Database wraps sql.DB with LazyChef-specific functionality
 type Database struct {
 	*sql.DB
+
+	hooksMu          sync.RWMutex
+	recipeSavedHooks []func(recipeID int)
 }
 
 // Config holds database configuration
@@ -188,6 +192,24 @@ func (db *Database) IsTableEmpty(tableName string) (bool, error) {
 	return count == 0, nil
 }
 
+// OnRecipeSaved registers a hook that runs after a recipe is inserted or updated
+func (db *Database) OnRecipeSaved(fn func(recipeID int)) {
+	db.hooksMu.Lock()
+	defer db.hooksMu.Unlock()
+	db.recipeSavedHooks = append(db.recipeSavedHooks, fn)
+}
+
+// NotifyRecipeSaved runs the registered recipe-saved hooks
+func (db *Database) NotifyRecipeSaved(recipeID int) {
+	db.hooksMu.RLock()
+	hooks := append([]func(int){}, db.recipeSavedHooks...)
+	db.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(recipeID)
+	}
+}
+
 // GetLastInsertID returns the ID of the last inserted row
 func (db *Database) GetLastInsertID() (int64, error) {
 	var id int64
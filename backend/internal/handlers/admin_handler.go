@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,19 +22,33 @@ type AdminHandler struct {
 	tokenRateLimiter      *services.TokenRateLimiter
 	diversityService      *services.DiversityService
 	autoGenerationService *services.AutoGenerationService
+	duplicateResolver     *services.DuplicateResolutionService
+	generationCache       *services.LayeredCache
+	recipeQuarantine      *services.RecipeQuarantine
 }
 
 // NewAdminHandler creates a new admin handler
-func NewAdminHandler(batchService *services.BatchGenerationService, embeddingService *services.EmbeddingDeduplicator, tokenRateLimiter *services.TokenRateLimiter, diversityService *services.DiversityService, autoGenerationService *services.AutoGenerationService) *AdminHandler {
+func NewAdminHandler(batchService *services.BatchGenerationService, embeddingService *services.EmbeddingDeduplicator, tokenRateLimiter *services.TokenRateLimiter, diversityService *services.DiversityService, autoGenerationService *services.AutoGenerationService, duplicateResolver *services.DuplicateResolutionService) *AdminHandler {
 	return &AdminHandler{
 		batchService:          batchService,
 		embeddingService:      embeddingService,
 		tokenRateLimiter:      tokenRateLimiter,
 		diversityService:      diversityService,
 		autoGenerationService: autoGenerationService,
+		duplicateResolver:     duplicateResolver,
 	}
 }
 
+// SetGenerationCache enables the generation cache inspection endpoints
+func (h *AdminHandler) SetGenerationCache(cache *services.LayeredCache) {
+	h.generationCache = cache
+}
+
+// SetRecipeQuarantine enables the safety quarantine review endpoint
+func (h *AdminHandler) SetRecipeQuarantine(quarantine *services.RecipeQuarantine) {
+	h.recipeQuarantine = quarantine
+}
+
 // Batch Generation Endpoints
 
 // SubmitBatchGeneration submits a new batch generation job
@@ -57,6 +73,9 @@ func (h *AdminHandler) SubmitBatchGeneration(c *gin.Context) {
 	}
 
 	job, err := h.batchService.SubmitBatchJob(c.Request.Context(), config)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -226,6 +245,9 @@ func (h *AdminHandler) ScanDuplicates(c *gin.Context) {
 
 	startTime := time.Now()
 	report, err := h.embeddingService.ScanForDuplicates(c.Request.Context(), request.ForceRefresh)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -287,6 +309,9 @@ func (h *AdminHandler) CheckRecipeDuplicates(c *gin.Context) {
 	}
 
 	duplicates, err := h.embeddingService.CheckRecipeDuplicates(c.Request.Context(), &recipe)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -306,6 +331,101 @@ func (h *AdminHandler) CheckRecipeDuplicates(c *gin.Context) {
 	})
 }
 
+// ResolveDuplicate merges, keeps or dismisses a reported duplicate pair
+// POST /api/admin/duplicate-detection/resolve
+func (h *AdminHandler) ResolveDuplicate(c *gin.Context) {
+	var request services.DuplicateResolutionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	resolution, err := h.duplicateResolver.Resolve(request)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidDuplicateAction):
+			status = http.StatusBadRequest
+		case errors.Is(err, models.ErrRecipeNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "Failed to resolve duplicate",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    resolution,
+	})
+}
+
+// AutoMergeDuplicates merges every reported pair above the given thresholds
+// POST /api/admin/duplicate-detection/auto-merge
+func (h *AdminHandler) AutoMergeDuplicates(c *gin.Context) {
+	var request services.AutoMergeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		// Allow empty body, use default thresholds
+		request = services.AutoMergeRequest{}
+	}
+	if request.CosineThreshold > 1 || request.JaccardThreshold > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Thresholds must be between 0 and 1",
+		})
+		return
+	}
+
+	result, err := h.duplicateResolver.AutoMerge(request)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to auto-merge duplicates",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetDuplicateResolutions returns the duplicate resolution audit trail
+// GET /api/admin/duplicate-detection/resolutions
+func (h *AdminHandler) GetDuplicateResolutions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 1000 {
+		limit = 50
+	}
+
+	resolutions, err := h.duplicateResolver.GetResolutions(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get duplicate resolutions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"resolutions": resolutions,
+			"count":       len(resolutions),
+		},
+	})
+}
+
 // RefreshEmbedding updates the embedding for a specific recipe
 // POST /api/admin/embeddings/refresh/:recipe_id
 func (h *AdminHandler) RefreshEmbedding(c *gin.Context) {
@@ -328,6 +448,9 @@ func (h *AdminHandler) RefreshEmbedding(c *gin.Context) {
 	}
 
 	err = h.embeddingService.RefreshEmbedding(c.Request.Context(), recipeID)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -345,8 +468,9 @@ func (h *AdminHandler) RefreshEmbedding(c *gin.Context) {
 
 // Metrics Endpoints
 
-// GetTokenUsageMetrics returns current token usage metrics
-// GET /api/admin/metrics/token-usage
+// GetTokenUsageMetrics returns current token usage metrics and, when the
+// usage ledger is available, persisted usage for a time range
+// GET /api/admin/metrics/token-usage?from=2025-01-01&to=2025-02-01&group_by=model
 func (h *AdminHandler) GetTokenUsageMetrics(c *gin.Context) {
 	if h.tokenRateLimiter == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
@@ -356,14 +480,65 @@ func (h *AdminHandler) GetTokenUsageMetrics(c *gin.Context) {
 		return
 	}
 
-	metrics := h.tokenRateLimiter.GetMetrics()
+	query := services.UsageQuery{GroupBy: c.Query("group_by")}
+	for _, bound := range []struct {
+		param  string
+		target *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		param, target := bound.param, bound.target
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := parseUsageTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid " + param + " parameter",
+				"details": "expected RFC3339 or YYYY-MM-DD",
+			})
+			return
+		}
+		*target = parsed
+	}
+
+	data := gin.H{
+		"metrics":     h.tokenRateLimiter.GetMetrics(),
+		"cost_status": h.tokenRateLimiter.GetCostStatus(),
+		"budgets":     h.tokenRateLimiter.GetBudgetStatus(),
+	}
+
+	if ledger := h.tokenRateLimiter.GetLedger(); ledger != nil {
+		report, err := ledger.Query(query)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrInvalidUsageGroupBy) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{
+				"success": false,
+				"error":   "Failed to query token usage ledger",
+				"details": err.Error(),
+			})
+			return
+		}
+		data["ledger"] = report
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    metrics,
+		"data":    data,
 	})
 }
 
+// parseUsageTime accepts RFC3339 timestamps or plain dates (UTC midnight)
+func parseUsageTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
 // GetCostEfficiencyAnalysis returns cost efficiency analysis
 // GET /api/admin/metrics/cost-efficiency
 func (h *AdminHandler) GetCostEfficiencyAnalysis(c *gin.Context) {
@@ -403,7 +578,9 @@ func (h *AdminHandler) GetCostEfficiencyAnalysis(c *gin.Context) {
 	})
 }
 
-// UpdateBudgets updates daily and monthly budgets
+// UpdateBudgets updates the daily and monthly budgets, per-endpoint and
+// per-user daily sub-budgets and the alert threshold. Omitted fields are
+// left unchanged; a sub-budget of 0 removes it.
 // POST /api/admin/metrics/budgets
 func (h *AdminHandler) UpdateBudgets(c *gin.Context) {
 	if h.tokenRateLimiter == nil {
@@ -415,8 +592,12 @@ func (h *AdminHandler) UpdateBudgets(c *gin.Context) {
 	}
 
 	var request struct {
-		DailyBudgetUSD   float64 `json:"daily_budget_usd" binding:"min=0"`
-		MonthlyBudgetUSD float64 `json:"monthly_budget_usd" binding:"min=0"`
+		DailyBudgetUSD            *float64           `json:"daily_budget_usd" binding:"omitempty,min=0"`
+		MonthlyBudgetUSD          *float64           `json:"monthly_budget_usd" binding:"omitempty,min=0"`
+		EndpointDailyBudgetsUSD   map[string]float64 `json:"endpoint_daily_budgets_usd"`
+		UserDailyBudgetsUSD       map[string]float64 `json:"user_daily_budgets_usd"`
+		DefaultUserDailyBudgetUSD *float64           `json:"default_user_daily_budget_usd" binding:"omitempty,min=0"`
+		AlertThreshold            *float64           `json:"alert_threshold"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -428,15 +609,137 @@ func (h *AdminHandler) UpdateBudgets(c *gin.Context) {
 		return
 	}
 
-	h.tokenRateLimiter.SetBudgets(request.DailyBudgetUSD, request.MonthlyBudgetUSD)
+	if request.AlertThreshold != nil {
+		if err := h.tokenRateLimiter.SetAlertThreshold(*request.AlertThreshold); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid alert threshold",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	// Omitted global budgets keep their current value
+	current := h.tokenRateLimiter.GetCostStatus()
+	daily, monthly := current.DailyBudgetUSD, current.MonthlyBudgetUSD
+	if request.DailyBudgetUSD != nil {
+		daily = *request.DailyBudgetUSD
+	}
+	if request.MonthlyBudgetUSD != nil {
+		monthly = *request.MonthlyBudgetUSD
+	}
+	h.tokenRateLimiter.SetBudgets(daily, monthly)
+
+	for endpoint, budget := range request.EndpointDailyBudgetsUSD {
+		h.tokenRateLimiter.SetEndpointBudget(endpoint, budget)
+	}
+	for userID, budget := range request.UserDailyBudgetsUSD {
+		h.tokenRateLimiter.SetUserBudget(userID, budget)
+	}
+	if request.DefaultUserDailyBudgetUSD != nil {
+		h.tokenRateLimiter.SetDefaultUserBudget(*request.DefaultUserDailyBudgetUSD)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Budgets updated successfully",
-		"data": gin.H{
-			"daily_budget_usd":   request.DailyBudgetUSD,
-			"monthly_budget_usd": request.MonthlyBudgetUSD,
-		},
+		"data":    h.tokenRateLimiter.GetBudgetStatus(),
+	})
+}
+
+// GetModelPricing lists every model price version, or the price in effect
+// for one model at a given time
+// GET /api/admin/metrics/pricing?model=gpt-5-mini&at=2025-09-01
+func (h *AdminHandler) GetModelPricing(c *gin.Context) {
+	if h.tokenRateLimiter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "Token rate limiter not available",
+		})
+		return
+	}
+
+	pricing := h.tokenRateLimiter.GetPricingTable()
+	model := c.Query("model")
+	if model == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    gin.H{"prices": pricing.Prices()},
+		})
+		return
+	}
+
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		parsed, err := parseUsageTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid at parameter",
+				"details": "expected RFC3339 or YYYY-MM-DD",
+			})
+			return
+		}
+		at = parsed
+	}
+
+	price, ok := pricing.Lookup(model, at)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "No price in effect for model",
+			"details": model,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"model": model, "at": at, "price": price},
+	})
+}
+
+// SetModelPrice adds a price version for a model, or replaces the version
+// with the same effective date. Costs already recorded are not repriced.
+// POST /api/admin/metrics/pricing
+func (h *AdminHandler) SetModelPrice(c *gin.Context) {
+	if h.tokenRateLimiter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "Token rate limiter not available",
+		})
+		return
+	}
+
+	var request services.ModelPriceSpec
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	price, err := h.tokenRateLimiter.GetPricingTable().SetPrice(request)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidModelPrice) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "Failed to set model price",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Model price updated successfully",
+		"data":    price,
 	})
 }
 
@@ -470,6 +773,159 @@ func (h *AdminHandler) GetSystemHealth(c *gin.Context) {
 	})
 }
 
+// Generation Cache Endpoints
+
+// GetGenerationCacheStats returns overall and per-tier cache hit metrics
+// GET /api/admin/cache/stats
+func (h *AdminHandler) GetGenerationCacheStats(c *gin.Context) {
+	if !h.requireGenerationCache(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.generationCache.Stats(),
+	})
+}
+
+// ListGenerationCacheKeys lists cached keys, optionally filtered by prefix
+// GET /api/admin/cache/keys?prefix=recipe:
+func (h *AdminHandler) ListGenerationCacheKeys(c *gin.Context) {
+	if !h.requireGenerationCache(c) {
+		return
+	}
+
+	prefix := c.Query("prefix")
+	keys := make([]string, 0)
+	for _, key := range h.generationCache.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"keys":  keys,
+			"count": len(keys),
+		},
+	})
+}
+
+// GetGenerationCacheEntry shows which tiers hold a key and its cached result
+// GET /api/admin/cache/entry?key=...
+func (h *AdminHandler) GetGenerationCacheEntry(c *gin.Context) {
+	if !h.requireGenerationCache(c) {
+		return
+	}
+
+	key, ok := requireCacheKey(c)
+	if !ok {
+		return
+	}
+
+	info, found := h.generationCache.Inspect(key)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Cache key not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    info,
+	})
+}
+
+// InvalidateGenerationCacheEntry removes a key from every cache tier
+// DELETE /api/admin/cache/entry?key=...
+func (h *AdminHandler) InvalidateGenerationCacheEntry(c *gin.Context) {
+	if !h.requireGenerationCache(c) {
+		return
+	}
+
+	key, ok := requireCacheKey(c)
+	if !ok {
+		return
+	}
+
+	info, found := h.generationCache.Inspect(key)
+	h.generationCache.Delete(key)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Cache key invalidated",
+		"data": gin.H{
+			"key":         key,
+			"found":       found,
+			"invalidated": info.Tiers,
+		},
+	})
+}
+
+// Food Safety Quarantine Endpoints
+
+// ListQuarantinedRecipes returns generated recipes that failed food safety
+// validation after remediation, newest first
+// GET /api/admin/safety/quarantine?limit=50
+func (h *AdminHandler) ListQuarantinedRecipes(c *gin.Context) {
+	if h.recipeQuarantine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "Recipe quarantine not available",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 1000 {
+		limit = 50
+	}
+
+	recipes, err := h.recipeQuarantine.List(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get quarantined recipes",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"recipes": recipes,
+			"count":   len(recipes),
+		},
+	})
+}
+
+func (h *AdminHandler) requireGenerationCache(c *gin.Context) bool {
+	if h.generationCache == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "Generation cache not available",
+		})
+		return false
+	}
+	return true
+}
+
+func requireCacheKey(c *gin.Context) (string, bool) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "key query parameter is required",
+		})
+		return "", false
+	}
+	return key, true
+}
+
 // Diversity System Endpoints (Issue #65)
 
 // GetRecipeCoverage handles GET /api/admin/recipe-coverage
@@ -530,7 +986,10 @@ func (h *AdminHandler) GenerateDiverseRecipes(c *gin.Context) {
 	}
 
 	// Generate diverse recipes
-	response, err := h.diversityService.GenerateDiverseRecipes(req)
+	response, err := h.diversityService.GenerateDiverseRecipes(c.Request.Context(), req)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -690,6 +1149,9 @@ func (h *AdminHandler) GenerateAutoRecipes(c *gin.Context) {
 	defer cancel()
 
 	result, err := h.autoGenerationService.GenerateAutoRecipes(ctx, req)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to generate auto recipes",
@@ -714,6 +1176,7 @@ func (h *AdminHandler) GenerateAutoRecipes(c *gin.Context) {
 		"dimensions_covered": result.DimensionsCovered,
 		"generation_summary": result.GenerationSummary,
 		"strategy":           req.Strategy,
+		"stopped_reason":     result.StoppedReason,
 		"note":               "Phase 2実装: 完全AI自動生成システム",
 	})
 }
@@ -770,6 +1233,7 @@ func (h *AdminHandler) BatchAutoGenerateRecipes(c *gin.Context) {
 	startTime := time.Now()
 	totalQuality := 0.0
 	qualityCount := 0
+	var budgetErr error
 
 	// Process in batches
 	for generated := 0; generated < req.TotalCount; {
@@ -798,7 +1262,7 @@ func (h *AdminHandler) BatchAutoGenerateRecipes(c *gin.Context) {
 			}
 
 			batchGenResult, lastErr = h.autoGenerationService.GenerateAutoRecipes(ctx, autoReq)
-			if lastErr == nil {
+			if lastErr == nil || isBudgetRefusal(lastErr) {
 				break
 			}
 
@@ -807,6 +1271,12 @@ func (h *AdminHandler) BatchAutoGenerateRecipes(c *gin.Context) {
 			}
 		}
 
+		if lastErr != nil && isBudgetRefusal(lastErr) {
+			budgetErr = lastErr
+			batchResult.Errors = append(batchResult.Errors, lastErr.Error())
+			goto finish
+		}
+
 		if lastErr != nil {
 			batchResult.Errors = append(batchResult.Errors,
 				fmt.Sprintf("Batch %d failed after %d retries: %v",
@@ -837,6 +1307,11 @@ func (h *AdminHandler) BatchAutoGenerateRecipes(c *gin.Context) {
 
 			batchResult.Batches = append(batchResult.Batches, *batchGenResult)
 			generated += len(batchGenResult.GeneratedRecipes)
+
+			if batchGenResult.StoppedReason != "" {
+				batchResult.Errors = append(batchResult.Errors, batchGenResult.StoppedReason)
+				break
+			}
 		}
 
 		// Progress tracking (optional delay between batches)
@@ -853,6 +1328,11 @@ func (h *AdminHandler) BatchAutoGenerateRecipes(c *gin.Context) {
 finish:
 	batchResult.ElapsedTime = time.Since(startTime).String()
 
+	if batchResult.TotalSuccessful == 0 && budgetErr != nil {
+		respondBudgetError(c, budgetErr)
+		return
+	}
+
 	// Determine response status
 	statusCode := http.StatusOK
 	if batchResult.TotalSuccessful == 0 {
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"lazychef/internal/services"
+)
+
+// isBudgetRefusal reports whether err came from the budget gate
+func isBudgetRefusal(err error) bool {
+	return errors.Is(err, services.ErrBudgetExceeded) || errors.Is(err, services.ErrRateLimited)
+}
+
+// respondBudgetError writes a 402 for exhausted budgets or a 429 for token
+// rate limits when err came from the budget gate, and reports whether it did
+func respondBudgetError(c *gin.Context, err error) bool {
+	if !isBudgetRefusal(err) {
+		return false
+	}
+
+	status := http.StatusPaymentRequired
+	message := "Budget exceeded"
+	if errors.Is(err, services.ErrRateLimited) {
+		status = http.StatusTooManyRequests
+		message = "Rate limit exceeded"
+	}
+
+	body := gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	}
+
+	var budgetErr *services.BudgetError
+	if errors.As(err, &budgetErr) {
+		body["budget"] = gin.H{
+			"scope":         budgetErr.Scope,
+			"key":           budgetErr.Key,
+			"limit_usd":     budgetErr.LimitUSD,
+			"spent_usd":     budgetErr.SpentUSD,
+			"remaining_usd": budgetErr.RemainingUSD,
+			"estimated_usd": budgetErr.EstimatedUSD,
+		}
+		if budgetErr.RetryAfter > 0 {
+			seconds := int(math.Ceil(budgetErr.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			body["retry_after_seconds"] = seconds
+		}
+	}
+
+	c.JSON(status, body)
+	return true
+}
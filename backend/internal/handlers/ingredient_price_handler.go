@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+	"lazychef/internal/services"
+)
+
+// IngredientPriceHandler handles ingredient price HTTP requests
+type IngredientPriceHandler struct {
+	prices *services.IngredientPriceService
+}
+
+// NewIngredientPriceHandler creates a new ingredient price handler
+func NewIngredientPriceHandler(db *database.Database) *IngredientPriceHandler {
+	return &IngredientPriceHandler{
+		prices: services.NewIngredientPriceService(db, services.NewIngredientAggregator()),
+	}
+}
+
+// ListPrices handles GET /api/ingredient-prices
+func (h *IngredientPriceHandler) ListPrices(c *gin.Context) {
+	prices, err := h.prices.ListPrices()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load ingredient prices",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    prices,
+	})
+}
+
+// SetPrice handles PUT /api/ingredient-prices/:ingredient
+// The body gives a shop price and the quantity it buys, e.g. {"price": 198, "quantity": "100g"}
+func (h *IngredientPriceHandler) SetPrice(c *gin.Context) {
+	var req models.IngredientPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	price, err := h.prices.SetPrice(c.Param("ingredient"), req.Price, req.Quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrIngredientNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Ingredient not found",
+				"details": err.Error(),
+			})
+		case errors.Is(err, models.ErrInvalidPrice):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid price",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to save ingredient price",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    price,
+	})
+}
+
+// ImportPrices handles POST /api/ingredient-prices/import
+// Accepts CSV rows of ingredient,price,quantity either as the request body or
+// as a multipart "file" upload
+func (h *IngredientPriceHandler) ImportPrices(c *gin.Context) {
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Missing CSV file",
+				"details": err.Error(),
+			})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Failed to read CSV file",
+				"details": err.Error(),
+			})
+			return
+		}
+		defer func() { _ = file.Close() }()
+		body = file
+	}
+
+	result, err := h.prices.ImportCSV(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to import ingredient prices",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
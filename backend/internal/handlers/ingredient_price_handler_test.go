@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/services"
+)
+
+func setupPriceTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	_, db := setupRecipeTestRouter(t)
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "scripts", "hierarchical_ingredients_schema.sql"))
+	require.NoError(t, err)
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+
+	handler := NewIngredientPriceHandler(db)
+	r := gin.New()
+	r.GET("/api/ingredient-prices", handler.ListPrices)
+	r.POST("/api/ingredient-prices/import", handler.ImportPrices)
+	r.PUT("/api/ingredient-prices/:ingredient", handler.SetPrice)
+	return r
+}
+
+func TestIngredientPriceHandler_SetAndImport(t *testing.T) {
+	r := setupPriceTestRouter(t)
+
+	w := performJSONRequest(r, http.MethodPut, "/api/ingredient-prices/"+url.PathEscape("鶏むね肉"), `{"price": 68, "quantity": "100g"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = performJSONRequest(r, http.MethodPut, "/api/ingredient-prices/"+url.PathEscape("ドリアン"), `{"price": 68, "quantity": "100g"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = performJSONRequest(r, http.MethodPut, "/api/ingredient-prices/"+url.PathEscape("卵"), `{"price": 68, "quantity": "適量"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performJSONRequest(r, http.MethodPost, "/api/ingredient-prices/import", "ingredient,price,quantity\nトマト,98,1個\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var imported struct {
+		Data services.PriceImportResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+	assert.Equal(t, 1, imported.Data.Imported)
+
+	w = performJSONRequest(r, http.MethodGet, "/api/ingredient-prices", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"ingredient":"鶏胸肉"`)
+}
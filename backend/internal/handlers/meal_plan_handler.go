@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"lazychef/internal/models"
 	"lazychef/internal/services"
 	"net/http"
@@ -39,12 +40,11 @@ func (h *MealPlanHandler) CreateMealPlan(c *gin.Context) {
 		req.StartDate = time.Now().Format("2006-01-02")
 	}
 
-	if req.Preferences.MaxCookingTime == 0 {
-		req.Preferences.MaxCookingTime = 15
-	}
-
 	// Create meal plan
-	mealPlan, err := h.planner.CreateWeeklyPlan(req)
+	mealPlan, err := h.planner.CreateWeeklyPlan(c.Request.Context(), req)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create meal plan",
@@ -122,7 +122,8 @@ func (h *MealPlanHandler) ListMealPlans(c *gin.Context) {
 // GenerateShoppingList handles POST /api/meal-plans/shopping-list
 func (h *MealPlanHandler) GenerateShoppingList(c *gin.Context) {
 	var req struct {
-		RecipeIDs []int `json:"recipe_ids"`
+		RecipeIDs []int  `json:"recipe_ids"`
+		UserID    string `json:"user_id,omitempty"` // Subtract this user's pantry
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -142,7 +143,7 @@ func (h *MealPlanHandler) GenerateShoppingList(c *gin.Context) {
 	}
 
 	// Generate shopping list from recipe IDs
-	shoppingList, err := h.planner.GenerateShoppingListFromRecipeIDs(req.RecipeIDs)
+	shoppingList, err := h.planner.GenerateShoppingListFromRecipeIDs(req.RecipeIDs, req.UserID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to generate shopping list",
@@ -151,11 +152,10 @@ func (h *MealPlanHandler) GenerateShoppingList(c *gin.Context) {
 		return
 	}
 
-	// Calculate total estimated cost
+	// Calculate total estimated cost from the priced items
 	totalCost := 0
-	for range shoppingList {
-		// Simple cost estimation: 200 yen per unique item
-		totalCost += 200
+	for _, item := range shoppingList {
+		totalCost += item.Cost
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -167,3 +167,60 @@ func (h *MealPlanHandler) GenerateShoppingList(c *gin.Context) {
 		},
 	})
 }
+
+// MarkMealCooked handles POST /api/meal-plans/:id/days/:day/cooked
+// Records the meal as cooked and takes its ingredients out of the pantry
+func (h *MealPlanHandler) MarkMealCooked(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid meal plan ID",
+		})
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id,omitempty"` // Defaults to the plan's owner
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	mealPlan, consumed, err := h.planner.MarkMealCooked(id, c.Param("day"), req.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrMealPlanNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Meal plan not found",
+			})
+		case errors.Is(err, models.ErrMealDayNotPlanned):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "No meal planned for this day",
+			})
+		case errors.Is(err, models.ErrMealAlreadyCooked):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Meal already marked as cooked",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to mark meal as cooked",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"meal_plan":       mealPlan,
+			"pantry_consumed": consumed,
+		},
+	})
+}
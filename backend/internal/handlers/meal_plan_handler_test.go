@@ -71,3 +71,17 @@ func TestMealPlanHandler_CreateMealPlan_InvalidJSON(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "Invalid request format", response["error"])
 }
+
+func TestMealPlanHandler_MarkMealCooked_Errors(t *testing.T) {
+	_, db := setupRecipeTestRouter(t)
+	handler := NewMealPlanHandler(services.NewMealPlannerService(db, nil))
+
+	r := gin.New()
+	r.POST("/api/meal-plans/:id/days/:day/cooked", handler.MarkMealCooked)
+
+	w := performJSONRequest(r, http.MethodPost, "/api/meal-plans/abc/days/monday/cooked", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performJSONRequest(r, http.MethodPost, "/api/meal-plans/42/days/monday/cooked", `{"user_id": "alice"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
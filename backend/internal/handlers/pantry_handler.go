@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+	"lazychef/internal/services"
+)
+
+// PantryHandler handles pantry inventory HTTP requests
+type PantryHandler struct {
+	pantry *services.PantryService
+}
+
+// NewPantryHandler creates a new pantry handler
+func NewPantryHandler(db *database.Database) *PantryHandler {
+	return &PantryHandler{
+		pantry: services.NewPantryService(db, services.NewIngredientAggregator()),
+	}
+}
+
+// ListItems handles GET /api/users/:user_id/pantry
+func (h *PantryHandler) ListItems(c *gin.Context) {
+	items, err := h.pantry.ListItems(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load pantry",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    items,
+	})
+}
+
+// AddItem handles POST /api/users/:user_id/pantry
+func (h *PantryHandler) AddItem(c *gin.Context) {
+	var req models.PantryItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	item, err := h.pantry.AddItem(c.Param("user_id"), req)
+	if err != nil {
+		h.writeError(c, "Failed to add pantry item", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    item,
+	})
+}
+
+// UpdateItem handles PUT /api/users/:user_id/pantry/:item_id
+func (h *PantryHandler) UpdateItem(c *gin.Context) {
+	id, ok := h.itemID(c)
+	if !ok {
+		return
+	}
+
+	var req models.PantryItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	item, err := h.pantry.UpdateItem(c.Param("user_id"), id, req)
+	if err != nil {
+		h.writeError(c, "Failed to update pantry item", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    item,
+	})
+}
+
+// DeleteItem handles DELETE /api/users/:user_id/pantry/:item_id
+func (h *PantryHandler) DeleteItem(c *gin.Context) {
+	id, ok := h.itemID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.pantry.DeleteItem(c.Param("user_id"), id); err != nil {
+		h.writeError(c, "Failed to delete pantry item", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// itemID parses the item_id path parameter, writing a 400 response if invalid
+func (h *PantryHandler) itemID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("item_id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid pantry item ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// writeError maps pantry service errors to HTTP responses
+func (h *PantryHandler) writeError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrPantryItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Pantry item not found",
+		})
+	case errors.Is(err, models.ErrMissingParameters), errors.Is(err, models.ErrInvalidPantryDate):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func setupPantryTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	_, db := setupRecipeTestRouter(t)
+	handler := NewPantryHandler(db)
+
+	r := gin.New()
+	r.GET("/api/users/:user_id/pantry", handler.ListItems)
+	r.POST("/api/users/:user_id/pantry", handler.AddItem)
+	r.PUT("/api/users/:user_id/pantry/:item_id", handler.UpdateItem)
+	r.DELETE("/api/users/:user_id/pantry/:item_id", handler.DeleteItem)
+	return r
+}
+
+func TestPantryHandler_CRUD(t *testing.T) {
+	r := setupPantryTestRouter(t)
+
+	w := performJSONRequest(r, http.MethodPost, "/api/users/alice/pantry", `{
+		"ingredient": "玉ねぎ",
+		"quantity": "3個",
+		"purchase_date": "2025-01-25",
+		"expiry_date": "2025-02-10"
+	}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created struct {
+		Data models.PantryItem `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "3個", created.Data.Quantity)
+	itemPath := fmt.Sprintf("/api/users/alice/pantry/%d", created.Data.ID)
+
+	w = performJSONRequest(r, http.MethodPut, itemPath, `{"ingredient": "玉ねぎ", "quantity": "1個"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = performJSONRequest(r, http.MethodGet, "/api/users/alice/pantry", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Data []models.PantryItem `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Data, 1)
+	assert.Equal(t, 1.0, listed.Data[0].Amount)
+
+	// Items belong to one user
+	w = performJSONRequest(r, http.MethodDelete, fmt.Sprintf("/api/users/bob/pantry/%d", created.Data.ID), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = performJSONRequest(r, http.MethodDelete, itemPath, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPantryHandler_Invalid(t *testing.T) {
+	r := setupPantryTestRouter(t)
+
+	w := performJSONRequest(r, http.MethodPost, "/api/users/alice/pantry", `{"ingredient": "卵"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performJSONRequest(r, http.MethodPost, "/api/users/alice/pantry", `{"ingredient": "卵", "quantity": "6個", "expiry_date": "02/10/2025"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performJSONRequest(r, http.MethodPut, "/api/users/alice/pantry/abc", `{"ingredient": "卵", "quantity": "6個"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performJSONRequest(r, http.MethodPut, "/api/users/alice/pantry/999", `{"ingredient": "卵", "quantity": "6個"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
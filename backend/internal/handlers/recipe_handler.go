@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,23 +23,45 @@ type RecipeHandler struct {
 	recipeRepository         *services.RecipeRepository
 	generatorService         *services.RecipeGeneratorService
 	enhancedGeneratorService *services.EnhancedRecipeGeneratorService
-	ingredientMapper         *services.SimpleIngredientMapper
+	ingredientResolver       *services.IngredientResolver
+	searchIndex              *services.RecipeSearchIndex
+	preferencesRepository    *services.UserPreferencesRepository
+	recipeMatcher            *services.RecipeMatcher
+	priceService             *services.IngredientPriceService
+	embeddingService         *services.EmbeddingDeduplicator
+	libraryLookup            *services.RecipeLibraryLookup
 }
 
 // NewRecipeHandler creates a new recipe handler
 func NewRecipeHandler(db *database.Database, generatorService *services.RecipeGeneratorService, enhancedGeneratorService *services.EnhancedRecipeGeneratorService) *RecipeHandler {
 	recipeRepository := services.NewRecipeRepository(db)
-	ingredientMapper := services.NewSimpleIngredientMapper()
 	return &RecipeHandler{
 		db:                       db,
 		recipeRepository:         recipeRepository,
 		generatorService:         generatorService,
 		enhancedGeneratorService: enhancedGeneratorService,
-		ingredientMapper:         ingredientMapper,
+		ingredientResolver:       services.NewIngredientResolver(db),
+		searchIndex:              services.NewRecipeSearchIndex(db),
+		preferencesRepository:    services.NewUserPreferencesRepository(db),
+		recipeMatcher:            services.NewRecipeMatcher(db, generatorService),
+		priceService:             services.NewIngredientPriceService(db, services.NewIngredientAggregator()),
 	}
 }
 
+// SetEmbeddingService enables semantic search over stored recipe embeddings
+func (h *RecipeHandler) SetEmbeddingService(embeddingService *services.EmbeddingDeduplicator) {
+	h.embeddingService = embeddingService
+}
+
+// SetLibraryLookup lets GenerateRecipe serve close-enough library recipes
+// instead of generating new ones
+func (h *RecipeHandler) SetLibraryLookup(libraryLookup *services.RecipeLibraryLookup) {
+	h.libraryLookup = libraryLookup
+}
+
 // GenerateRecipe handles POST /api/recipes/generate
+// A library recipe close enough to the request is served instead of a new
+// generation unless ?fresh=true is given
 func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 	var req services.RecipeGenerationRequest
 
@@ -49,8 +73,25 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 		return
 	}
 
+	if !h.applyStoredPreferences(c, &req) {
+		return
+	}
+
+	if result := h.findInLibrary(c, req); result != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"recipe":              result.Recipe,
+			"recipe_id":           result.Metadata.LibraryRecipeID,
+			"metadata":            result.Metadata,
+			"served_from_library": true,
+		})
+		return
+	}
+
 	// Generate recipe
 	result, err := h.generatorService.GenerateRecipe(c.Request.Context(), req)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to generate recipe",
@@ -94,6 +135,309 @@ func (h *RecipeHandler) GenerateRecipe(c *gin.Context) {
 	})
 }
 
+// findInLibrary returns a library recipe that can be served for req, or nil.
+// Lookup failures fall through to generation.
+func (h *RecipeHandler) findInLibrary(c *gin.Context, req services.RecipeGenerationRequest) *services.GenerationResult {
+	if h.libraryLookup == nil || c.Query("fresh") == "true" {
+		return nil
+	}
+
+	result, err := h.libraryLookup.Find(c.Request.Context(), req)
+	if err != nil {
+		log.Printf("Warning: recipe library lookup failed, generating instead: %v", err)
+		return nil
+	}
+	return result
+}
+
+// applyStoredPreferences merges the stored preferences of req.UserID into the
+// generation request and attributes the request's LLM usage to that user.
+// It writes an error response and returns false on failure.
+func (h *RecipeHandler) applyStoredPreferences(c *gin.Context, req *services.RecipeGenerationRequest) bool {
+	if req.UserID != "" {
+		c.Request = c.Request.WithContext(services.WithUsageSource(c.Request.Context(), services.UsageSource{UserID: req.UserID}))
+	}
+	if req.UserID == "" || h.db == nil {
+		return true
+	}
+
+	stored, err := h.preferencesRepository.GetPreferences(req.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return true
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load user preferences",
+			"details": err.Error(),
+		})
+		return false
+	}
+
+	req.ApplyUserPreferences(stored.Preferences)
+	return true
+}
+
+// CreateRecipe handles POST /api/recipes
+// Stores a manually written recipe after validation
+func (h *RecipeHandler) CreateRecipe(c *gin.Context) {
+	var data models.RecipeData
+	if err := decodeJSONBody(c, &data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := prepareRecipeData(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid recipe",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	recipe := &models.Recipe{
+		Data:      data,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.recipeRepository.SaveRecipe(recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to save recipe",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    recipe,
+	})
+}
+
+// GetRecipe handles GET /api/recipes/:id
+func (h *RecipeHandler) GetRecipe(c *gin.Context) {
+	id, ok := parseRecipeID(c)
+	if !ok {
+		return
+	}
+
+	recipe, err := h.recipeRepository.GetRecipe(id)
+	if err != nil {
+		respondRecipeError(c, err, "Failed to get recipe")
+		return
+	}
+	recipe.Data.TotalCost = h.priceService.RecipeCost(&recipe.Data)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    recipe,
+	})
+}
+
+// UpdateRecipe handles PUT /api/recipes/:id
+// Replaces the whole recipe with the request body
+func (h *RecipeHandler) UpdateRecipe(c *gin.Context) {
+	id, ok := parseRecipeID(c)
+	if !ok {
+		return
+	}
+
+	var data models.RecipeData
+	if err := decodeJSONBody(c, &data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.saveRecipeUpdate(c, id, data)
+}
+
+// PatchRecipe handles PATCH /api/recipes/:id
+// Applies a JSON merge patch to the stored recipe; null removes a field
+func (h *RecipeHandler) PatchRecipe(c *gin.Context) {
+	id, ok := parseRecipeID(c)
+	if !ok {
+		return
+	}
+
+	existing, err := h.recipeRepository.GetRecipe(id)
+	if err != nil {
+		respondRecipeError(c, err, "Failed to get recipe")
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	if err := decodeJSONBody(c, &patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	merged, err := mergeRecipePatch(existing.Data, patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid recipe patch",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.saveRecipeUpdate(c, id, merged)
+}
+
+// DeleteRecipe handles DELETE /api/recipes/:id
+func (h *RecipeHandler) DeleteRecipe(c *gin.Context) {
+	id, ok := parseRecipeID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.recipeRepository.DeleteRecipe(id); err != nil {
+		respondRecipeError(c, err, "Failed to delete recipe")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Recipe deleted successfully",
+	})
+}
+
+// saveRecipeUpdate validates and stores new data for an existing recipe
+func (h *RecipeHandler) saveRecipeUpdate(c *gin.Context, id int, data models.RecipeData) {
+	if err := prepareRecipeData(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid recipe",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.recipeRepository.UpdateRecipe(&models.Recipe{ID: id, Data: data}); err != nil {
+		respondRecipeError(c, err, "Failed to update recipe")
+		return
+	}
+
+	recipe, err := h.recipeRepository.GetRecipe(id)
+	if err != nil {
+		respondRecipeError(c, err, "Failed to get updated recipe")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    recipe,
+	})
+}
+
+// parseRecipeID reads the :id path parameter, writing a 400 response when it is invalid
+func parseRecipeID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid recipe ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondRecipeError maps repository errors to HTTP responses
+func respondRecipeError(c *gin.Context, err error, message string) {
+	if errors.Is(err, models.ErrRecipeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Recipe not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+// decodeJSONBody decodes the JSON body without gin binding rules, so
+// optional fields like laziness_score or partial preference updates can be
+// filled in afterwards
+func decodeJSONBody(c *gin.Context, target interface{}) error {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, target)
+}
+
+// mergeRecipePatch applies a top-level JSON merge patch to recipe data
+func mergeRecipePatch(data models.RecipeData, patch map[string]json.RawMessage) (models.RecipeData, error) {
+	current, err := json.Marshal(data)
+	if err != nil {
+		return data, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(current, &fields); err != nil {
+		return data, err
+	}
+
+	for key, value := range patch {
+		if string(value) == "null" {
+			delete(fields, key)
+			continue
+		}
+		fields[key] = value
+	}
+
+	mergedJSON, err := json.Marshal(fields)
+	if err != nil {
+		return data, err
+	}
+
+	var merged models.RecipeData
+	if err := json.Unmarshal(mergedJSON, &merged); err != nil {
+		return data, err
+	}
+	return merged, nil
+}
+
+// validRecipeSeasons lists the season values accepted for recipes
+var validRecipeSeasons = map[string]bool{
+	"spring": true,
+	"summer": true,
+	"fall":   true,
+	"winter": true,
+	"all":    true,
+}
+
+// prepareRecipeData fills defaults, calculates a missing laziness score and validates the recipe
+func prepareRecipeData(data *models.RecipeData) error {
+	if data.Season == "" {
+		data.Season = "all"
+	}
+	if !validRecipeSeasons[data.Season] {
+		return models.ErrInvalidSeason
+	}
+	if data.Difficulty != "" && data.Difficulty != "easy" && data.Difficulty != "medium" && data.Difficulty != "hard" {
+		return models.ErrInvalidDifficulty
+	}
+
+	if data.LazinessScore == 0 {
+		data.LazinessScore = data.CalculateLazinessScore()
+		if data.LazinessScore < 1.0 {
+			data.LazinessScore = 1.0
+		}
+	}
+
+	return data.Validate()
+}
+
 // GenerateRecipeEnhanced generates a recipe using GPT-5 with enhanced validation
 func (h *RecipeHandler) GenerateRecipeEnhanced(c *gin.Context) {
 	var req services.EnhancedGenerationRequest
@@ -106,6 +450,10 @@ func (h *RecipeHandler) GenerateRecipeEnhanced(c *gin.Context) {
 		return
 	}
 
+	if !h.applyStoredPreferences(c, &req.RecipeGenerationRequest) {
+		return
+	}
+
 	// Set default stage if not specified
 	if req.Stage == "" {
 		req.Stage = services.StageAuthoring
@@ -121,6 +469,9 @@ func (h *RecipeHandler) GenerateRecipeEnhanced(c *gin.Context) {
 
 	// Generate recipe using enhanced service
 	result, err := h.enhancedGeneratorService.GenerateRecipeEnhanced(c.Request.Context(), req)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to generate enhanced recipe",
@@ -232,8 +583,15 @@ func (h *RecipeHandler) GenerateBatchRecipes(c *gin.Context) {
 		return
 	}
 
+	if !h.applyStoredPreferences(c, &req.RecipeGenerationRequest) {
+		return
+	}
+
 	// Generate batch recipes
 	result, err := h.generatorService.GenerateBatchRecipes(c.Request.Context(), req)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to generate batch recipes",
@@ -341,6 +699,9 @@ func (h *RecipeHandler) TestRecipeGeneration(c *gin.Context) {
 
 	// Generate recipe
 	result, err := h.generatorService.GenerateRecipe(c.Request.Context(), req)
+	if respondBudgetError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Test recipe generation failed",
@@ -358,145 +719,55 @@ func (h *RecipeHandler) TestRecipeGeneration(c *gin.Context) {
 }
 
 // SearchRecipes handles GET /api/recipes/search
-// SearchRecipes handles GET /api/recipes/search
+// The query term uses the FTS5 index when available, ranked by bm25 with
+// highlighted snippets; otherwise it falls back to LIKE matching.
 func (h *RecipeHandler) SearchRecipes(c *gin.Context) {
-	var criteria models.SearchCriteria
-
-	// Debug: Log all query parameters
-	fmt.Printf("[DEBUG] Raw query parameters: %+v\n", c.Request.URL.Query())
-
-	// Bind query parameters
-	if err := c.ShouldBindQuery(&criteria); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid search parameters",
-			"details": err.Error(),
-		})
+	criteria, ok := bindSearchCriteria(c)
+	if !ok || !h.applySearchPreferences(c, &criteria) {
 		return
 	}
 
-	// Debug: Log bound criteria
-	fmt.Printf("[DEBUG] Bound criteria: %+v\n", criteria)
+	conditions, args, ingredientMatches := h.searchFilterConditions(criteria)
 
-	// Handle comma-separated ingredients from frontend
-	if ingredientsParam := c.Query("ingredients"); ingredientsParam != "" && len(criteria.Ingredients) == 0 {
-		criteria.Ingredients = strings.Split(strings.TrimSpace(ingredientsParam), ",")
-		// Clean up each ingredient
-		for i, ingredient := range criteria.Ingredients {
-			criteria.Ingredients[i] = strings.TrimSpace(ingredient)
-		}
-	}
+	from := `recipes r`
+	selectColumns := `r.id, r.data`
+	orderBy := `r.laziness_score DESC, r.created_at DESC`
+	var rankArgs []interface{}
+	searchMode := "filter"
 
-	// Handle comma-separated tags from frontend
-	if tagsParam := c.Query("tags"); tagsParam != "" && len(criteria.Tags) == 0 {
-		criteria.Tags = strings.Split(strings.TrimSpace(tagsParam), ",")
-		// Clean up each tag
-		for i, tag := range criteria.Tags {
-			criteria.Tags[i] = strings.TrimSpace(tag)
-		}
-	}
-
-	// Debug: Log criteria after comma-separated processing
-	fmt.Printf("[DEBUG] Processed criteria: %+v\n", criteria)
-
-	// Set defaults and validate
-	if criteria.Limit <= 0 || criteria.Limit > 100 {
-		criteria.Limit = 20
-	}
-	if criteria.Offset < 0 {
-		criteria.Offset = 0
-	}
-
-	// Handle page-based pagination (alternative to offset)
-	if criteria.Page > 0 {
-		criteria.Offset = (criteria.Page - 1) * criteria.Limit
-	}
-
-	// Build SQL query with conditions
-	query := `
-		SELECT id, data, created_at
-		FROM recipes
-		WHERE 1=1
-	`
-	args := []interface{}{}
-
-	// Add search conditions
+	var fullText *services.FullTextQuery
 	if criteria.Query != "" {
-		query += ` AND (
-			title LIKE ? OR 
-			json_extract(data, '$.ingredients') LIKE ?
-		)`
-		searchTerm := "%" + criteria.Query + "%"
-		args = append(args, searchTerm, searchTerm)
+		fullText = services.BuildFullTextQuery(criteria.Query)
 	}
-
-	// Handle multiple tags
-	if len(criteria.Tags) > 0 {
-		for _, tag := range criteria.Tags {
-			query += ` AND json_extract(data, '$.tags') LIKE ?`
-			args = append(args, "%"+tag+"%")
-		}
-	} else if criteria.Tag != "" { // Backward compatibility
-		query += ` AND json_extract(data, '$.tags') LIKE ?`
-		args = append(args, "%"+criteria.Tag+"%")
-	}
-
-	// Handle multiple ingredients - use synonym mapping for better matching
-	if len(criteria.Ingredients) > 0 {
-		// Expand ingredients using synonym mapper (Issue #87 fix)
-		expandedIngredients := h.ingredientMapper.ExpandIngredientTerms(criteria.Ingredients)
-		fmt.Printf("[DEBUG] Original ingredients: %v, Expanded: %v\n", criteria.Ingredients, expandedIngredients)
-
-		if len(expandedIngredients) > 0 {
-			// Build OR conditions for all expanded ingredients
-			var orConditions []string
-			for _, ingredient := range expandedIngredients {
-				orConditions = append(orConditions, `EXISTS (
-					SELECT 1 FROM json_each(json_extract(data, '$.ingredients'))
-					WHERE json_extract(value, '$.name') = ?
-				)`)
-				args = append(args, ingredient)
-			}
-			query += ` AND (` + strings.Join(orConditions, " OR ") + `)`
-		}
-	} else if criteria.Ingredient != "" { // Backward compatibility
-		// Also apply synonym mapping to single ingredient searches
-		expandedIngredients := h.ingredientMapper.ExpandIngredientTerms([]string{criteria.Ingredient})
-		fmt.Printf("[DEBUG] Single ingredient '%s' expanded to: %v\n", criteria.Ingredient, expandedIngredients)
-
-		if len(expandedIngredients) > 0 {
-			var orConditions []string
-			for _, ingredient := range expandedIngredients {
-				orConditions = append(orConditions, `EXISTS (
-					SELECT 1 FROM json_each(json_extract(data, '$.ingredients'))
-					WHERE json_extract(value, '$.name') = ?
-				)`)
-				args = append(args, ingredient)
-			}
-			query += ` AND (` + strings.Join(orConditions, " OR ") + `)`
-		}
-	}
-
-	if criteria.MaxCookingTime > 0 {
-		query += ` AND cooking_time <= ?`
-		args = append(args, criteria.MaxCookingTime)
-	}
-
-	if criteria.MinLazinessScore > 0 {
-		query += ` AND laziness_score >= ?`
-		args = append(args, criteria.MinLazinessScore)
+	if fullText != nil && h.searchIndex.Available() {
+		searchMode = "fts5"
+		from = `recipes r JOIN recipes_fts ON recipes_fts.rowid = r.id`
+		selectColumns += `, recipes_fts.title, recipes_fts.ingredients, recipes_fts.steps, ` + fullText.Rank + ` AS search_rank`
+		orderBy = `search_rank, ` + orderBy
+		rankArgs = fullText.RankArgs
+		conditions = append([]string{fullText.Where}, conditions...)
+		args = append(append([]interface{}{}, fullText.Args...), args...)
+	} else if criteria.Query != "" {
+		searchMode = "like"
+		conditions = append([]string{`(r.title LIKE ? OR EXISTS (
+			SELECT 1 FROM json_each(r.data, '$.ingredients')
+			WHERE json_extract(value, '$.name') LIKE ?
+		))`}, conditions...)
+		searchTerm := "%" + criteria.Query + "%"
+		args = append([]interface{}{searchTerm, searchTerm}, args...)
 	}
 
-	if criteria.Season != "" && criteria.Season != "all" {
-		query += ` AND (season = ? OR season = 'all')`
-		args = append(args, criteria.Season)
+	where := ""
+	if len(conditions) > 0 {
+		where = ` WHERE ` + strings.Join(conditions, " AND ")
 	}
 
-	// Add ordering and pagination
-	query += ` ORDER BY laziness_score DESC, created_at DESC LIMIT ? OFFSET ?`
-	args = append(args, criteria.Limit, criteria.Offset)
+	query := `SELECT ` + selectColumns + ` FROM ` + from + where +
+		` ORDER BY ` + orderBy + ` LIMIT ? OFFSET ?`
+	queryArgs := append(append(append([]interface{}{}, rankArgs...), args...), criteria.Limit, criteria.Offset)
 
 	// Execute query
-	rows, err := h.db.Query(query, args...)
+	rows, err := h.db.Query(query, queryArgs...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database query failed",
@@ -513,12 +784,18 @@ func (h *RecipeHandler) SearchRecipes(c *gin.Context) {
 
 	// Parse results
 	recipes := make([]models.RecipeData, 0, criteria.Limit)
+	matches := make([]gin.H, 0, criteria.Limit)
 	for rows.Next() {
 		var id int
 		var dataJSON string
-		var createdAt string
+		var title, ingredients, steps sql.NullString
+		var rank float64
 
-		if err := rows.Scan(&id, &dataJSON, &createdAt); err != nil {
+		dest := []interface{}{&id, &dataJSON}
+		if searchMode == "fts5" {
+			dest = append(dest, &title, &ingredients, &steps, &rank)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to scan recipe data",
 				"details": err.Error(),
@@ -537,6 +814,20 @@ func (h *RecipeHandler) SearchRecipes(c *gin.Context) {
 		}
 
 		recipes = append(recipes, recipe)
+
+		if searchMode == "fts5" {
+			snippets := gin.H{}
+			for field, text := range map[string]string{"title": title.String, "ingredients": ingredients.String, "steps": steps.String} {
+				if snippet := services.HighlightSnippet(text, fullText.Terms, 48); snippet != "" {
+					snippets[field] = snippet
+				}
+			}
+			matches = append(matches, gin.H{
+				"recipe_id": id,
+				"rank":      rank,
+				"snippets":  snippets,
+			})
+		}
 	}
 
 	if err := rows.Err(); err != nil {
@@ -547,102 +838,415 @@ func (h *RecipeHandler) SearchRecipes(c *gin.Context) {
 		return
 	}
 
-	// Get total count for pagination info (separate query for performance)
-	countQuery := `SELECT COUNT(*) FROM recipes WHERE 1=1`
-	countArgs := []interface{}{}
+	// Get total count for pagination info (same conditions without LIMIT/OFFSET)
+	var total int
+	countQuery := `SELECT COUNT(*) FROM ` + from + where
+	if err := h.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		// If count fails, log but don't fail the whole request
+		total = len(recipes)
+	}
 
-	// Re-build count query with same conditions (without LIMIT/OFFSET)
-	if criteria.Query != "" {
-		countQuery += ` AND (title LIKE ? OR json_extract(data, '$.ingredients') LIKE ?)`
-		searchTerm := "%" + criteria.Query + "%"
-		countArgs = append(countArgs, searchTerm, searchTerm)
+	data := gin.H{
+		"recipes":     recipes,
+		"total":       total,
+		"limit":       criteria.Limit,
+		"offset":      criteria.Offset,
+		"page":        criteria.Page,
+		"search_mode": searchMode,
+	}
+	if searchMode == "fts5" {
+		data["matches"] = matches
+	}
+	if len(ingredientMatches) > 0 {
+		data["ingredient_matches"] = ingredientMatches
+	}
+	if len(criteria.Allergies) > 0 {
+		data["excluded_allergies"] = criteria.Allergies
+	}
+	if len(criteria.DietaryRestrictions) > 0 {
+		data["dietary_restrictions"] = criteria.DietaryRestrictions
 	}
 
-	// Handle multiple tags for count
-	if len(criteria.Tags) > 0 {
-		for _, tag := range criteria.Tags {
-			countQuery += ` AND json_extract(data, '$.tags') LIKE ?`
-			countArgs = append(countArgs, "%"+tag+"%")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// semanticCandidatePool is the minimum number of nearest neighbours fetched
+// before filters are applied, so filtered pages still fill up
+const semanticCandidatePool = 200
+
+// SemanticSearchRecipes handles GET /api/recipes/semantic-search?q=...
+// The query text is embedded and recipes are ranked by cosine similarity to
+// it, restricted by the same filters as SearchRecipes (max_cooking_time,
+// season, min_laziness_score, tags, ingredients).
+func (h *RecipeHandler) SemanticSearchRecipes(c *gin.Context) {
+	if h.embeddingService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Semantic search is not configured",
+		})
+		return
+	}
+
+	criteria, ok := bindSearchCriteria(c)
+	if !ok || !h.applySearchPreferences(c, &criteria) {
+		return
+	}
+
+	queryText := strings.TrimSpace(c.Query("q"))
+	if queryText == "" {
+		queryText = strings.TrimSpace(criteria.Query)
+	}
+	if queryText == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Query parameter 'q' is required",
+		})
+		return
+	}
+
+	candidates, err := h.embeddingService.SearchByText(c.Request.Context(), queryText,
+		max(semanticCandidatePool, (criteria.Offset+criteria.Limit)*5))
+	if respondBudgetError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to search recipes",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	conditions, args, ingredientMatches := h.searchFilterConditions(criteria)
+	recipesByID := make(map[int]models.RecipeData)
+	if len(candidates) > 0 {
+		placeholders := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			placeholders[i] = "?"
+			args = append(args, candidate.ID)
 		}
-	} else if criteria.Tag != "" { // Backward compatibility
-		countQuery += ` AND json_extract(data, '$.tags') LIKE ?`
-		countArgs = append(countArgs, "%"+criteria.Tag+"%")
-	}
-
-	// Handle multiple ingredients for count - use synonym mapping for better matching
-	if len(criteria.Ingredients) > 0 {
-		// Use same expanded ingredients as main query
-		expandedIngredients := h.ingredientMapper.ExpandIngredientTerms(criteria.Ingredients)
-		if len(expandedIngredients) > 0 {
-			var orConditions []string
-			for _, ingredient := range expandedIngredients {
-				orConditions = append(orConditions, `EXISTS (
-					SELECT 1 FROM json_each(json_extract(data, '$.ingredients'))
-					WHERE json_extract(value, '$.name') = ?
-				)`)
-				countArgs = append(countArgs, ingredient)
-			}
-			countQuery += ` AND (` + strings.Join(orConditions, " OR ") + `)`
+		conditions = append(conditions, `r.id IN (`+strings.Join(placeholders, ", ")+`)`)
+
+		rows, err := h.db.Query(`SELECT r.id, r.data FROM recipes r WHERE `+strings.Join(conditions, " AND "), args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database query failed",
+				"details": err.Error(),
+			})
+			return
 		}
-	} else if criteria.Ingredient != "" { // Backward compatibility
-		// Use same expanded ingredients as main query
-		expandedIngredients := h.ingredientMapper.ExpandIngredientTerms([]string{criteria.Ingredient})
-		if len(expandedIngredients) > 0 {
-			var orConditions []string
-			for _, ingredient := range expandedIngredients {
-				orConditions = append(orConditions, `EXISTS (
-					SELECT 1 FROM json_each(json_extract(data, '$.ingredients'))
-					WHERE json_extract(value, '$.name') = ?
-				)`)
-				countArgs = append(countArgs, ingredient)
+		defer func() { _ = rows.Close() }()
+
+		for rows.Next() {
+			var id int
+			var dataJSON string
+			if err := rows.Scan(&id, &dataJSON); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to scan recipe data",
+					"details": err.Error(),
+				})
+				return
 			}
-			countQuery += ` AND (` + strings.Join(orConditions, " OR ") + `)`
+
+			var recipe models.RecipeData
+			if err := json.Unmarshal([]byte(dataJSON), &recipe); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to parse recipe JSON",
+					"details": err.Error(),
+				})
+				return
+			}
+			recipesByID[id] = recipe
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Error during row iteration",
+				"details": err.Error(),
+			})
+			return
 		}
 	}
-	if criteria.MaxCookingTime > 0 {
-		countQuery += ` AND cooking_time <= ?`
-		countArgs = append(countArgs, criteria.MaxCookingTime)
+
+	// Keep the similarity order for candidates that passed the filters
+	var ranked []services.VectorMatch
+	for _, candidate := range candidates {
+		if _, ok := recipesByID[candidate.ID]; ok {
+			ranked = append(ranked, candidate)
+		}
 	}
-	if criteria.MinLazinessScore > 0 {
-		countQuery += ` AND laziness_score >= ?`
-		countArgs = append(countArgs, criteria.MinLazinessScore)
+	total := len(ranked)
+	ranked = ranked[min(criteria.Offset, total):min(criteria.Offset+criteria.Limit, total)]
+
+	recipes := make([]models.RecipeData, 0, len(ranked))
+	matches := make([]gin.H, 0, len(ranked))
+	for _, match := range ranked {
+		recipes = append(recipes, recipesByID[match.ID])
+		matches = append(matches, gin.H{
+			"recipe_id":  match.ID,
+			"similarity": match.Similarity,
+		})
 	}
-	if criteria.Season != "" && criteria.Season != "all" {
-		countQuery += ` AND (season = ? OR season = 'all')`
-		countArgs = append(countArgs, criteria.Season)
+
+	data := gin.H{
+		"recipes":     recipes,
+		"matches":     matches,
+		"total":       total,
+		"limit":       criteria.Limit,
+		"offset":      criteria.Offset,
+		"page":        criteria.Page,
+		"query":       queryText,
+		"search_mode": "semantic",
+	}
+	if len(ingredientMatches) > 0 {
+		data["ingredient_matches"] = ingredientMatches
+	}
+	if len(criteria.Allergies) > 0 {
+		data["excluded_allergies"] = criteria.Allergies
+	}
+	if len(criteria.DietaryRestrictions) > 0 {
+		data["dietary_restrictions"] = criteria.DietaryRestrictions
 	}
 
-	var total int
-	if err := h.db.QueryRow(countQuery, countArgs...).Scan(&total); err != nil {
-		// If count fails, log but don't fail the whole request
-		total = len(recipes)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// MatchRecipes handles POST /api/recipes/match-ingredients
+// Ranks library recipes by how many of their ingredients are already on hand,
+// ignoring pantry staples. Optionally generates a recipe when nothing reaches
+// the fallback threshold.
+func (h *RecipeHandler) MatchRecipes(c *gin.Context) {
+	var req services.RecipeMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.recipeMatcher.Match(c.Request.Context(), req)
+	if respondBudgetError(c, err) {
+		return
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrMissingParameters) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "No on-hand ingredients provided",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to match recipes",
+			"details": err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"recipes": recipes,
-			"total":   total,
-			"limit":   criteria.Limit,
-			"offset":  criteria.Offset,
-			"page":    criteria.Page,
-		},
+		"data":    result,
 	})
 }
 
+// bindSearchCriteria binds search query parameters, accepting comma-separated
+// ingredients, tags, allergies and dietary restrictions, and applies
+// pagination defaults. It writes a 400
+// response and returns false on invalid parameters.
+func bindSearchCriteria(c *gin.Context) (models.SearchCriteria, bool) {
+	var criteria models.SearchCriteria
+
+	// Bind query parameters
+	if err := c.ShouldBindQuery(&criteria); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid search parameters",
+			"details": err.Error(),
+		})
+		return criteria, false
+	}
+
+	// Handle comma-separated ingredients from frontend
+	if ingredientsParam := c.Query("ingredients"); ingredientsParam != "" && len(criteria.Ingredients) == 0 {
+		criteria.Ingredients = strings.Split(strings.TrimSpace(ingredientsParam), ",")
+		// Clean up each ingredient
+		for i, ingredient := range criteria.Ingredients {
+			criteria.Ingredients[i] = strings.TrimSpace(ingredient)
+		}
+	}
+
+	// Handle comma-separated tags from frontend
+	if tagsParam := c.Query("tags"); tagsParam != "" && len(criteria.Tags) == 0 {
+		criteria.Tags = strings.Split(strings.TrimSpace(tagsParam), ",")
+		// Clean up each tag
+		for i, tag := range criteria.Tags {
+			criteria.Tags[i] = strings.TrimSpace(tag)
+		}
+	}
+
+	// Handle comma-separated allergies and dietary restrictions
+	criteria.Allergies = splitListParam(criteria.Allergies)
+	diets, unknown := models.SplitDietaryRestrictions(splitListParam(criteria.DietaryRestrictions))
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Unknown dietary restriction",
+			"details": strings.Join(unknown, ", "),
+		})
+		return criteria, false
+	}
+	criteria.DietaryRestrictions = diets
+
+	// Set defaults and validate
+	if criteria.Limit <= 0 || criteria.Limit > 100 {
+		criteria.Limit = 20
+	}
+	if criteria.Offset < 0 {
+		criteria.Offset = 0
+	}
+
+	// Handle page-based pagination (alternative to offset)
+	if criteria.Page > 0 {
+		criteria.Offset = (criteria.Page - 1) * criteria.Limit
+	}
+
+	return criteria, true
+}
+
+// splitListParam accepts a list parameter given either repeated or as one
+// comma-separated value, trimming entries and dropping empty or repeated ones
+func splitListParam(values []string) []string {
+	if len(values) == 1 && strings.Contains(values[0], ",") {
+		values = strings.Split(values[0], ",")
+	}
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+	}
+	return models.MergeUnique(values)
+}
+
+// applySearchPreferences adds the stored allergies and dietary restrictions
+// of criteria.UserID to the search. It writes an error response and returns
+// false on failure.
+func (h *RecipeHandler) applySearchPreferences(c *gin.Context, criteria *models.SearchCriteria) bool {
+	if criteria.UserID == "" || h.db == nil {
+		return true
+	}
+
+	stored, err := h.preferencesRepository.GetPreferences(criteria.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return true
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load user preferences",
+			"details": err.Error(),
+		})
+		return false
+	}
+
+	criteria.Allergies = models.MergeUnique(criteria.Allergies, stored.Preferences.AllergyInfo)
+	// Stored restrictions outside the catalogue cannot be searched on
+	criteria.DietaryRestrictions, _ = models.SplitDietaryRestrictions(append(criteria.DietaryRestrictions, stored.Preferences.DietaryRestrictions...))
+	return true
+}
+
+// searchFilterConditions builds the tag, ingredient, time, laziness, season,
+// allergy and dietary restriction conditions shared by the search and count queries, along with
+// how each ingredient term was resolved
+func (h *RecipeHandler) searchFilterConditions(criteria models.SearchCriteria) ([]string, []interface{}, []services.IngredientTermMatch) {
+	var conditions []string
+	var args []interface{}
+
+	// Handle multiple tags
+	tags := criteria.Tags
+	if len(tags) == 0 && criteria.Tag != "" { // Backward compatibility
+		tags = []string{criteria.Tag}
+	}
+	for _, tag := range tags {
+		conditions = append(conditions, `json_extract(r.data, '$.tags') LIKE ?`)
+		args = append(args, "%"+tag+"%")
+	}
+
+	// Resolve ingredients through the ingredient hierarchy (synonym map as fallback)
+	ingredients := criteria.Ingredients
+	if len(ingredients) == 0 && criteria.Ingredient != "" { // Backward compatibility
+		ingredients = []string{criteria.Ingredient}
+	}
+	matches := h.ingredientResolver.ResolveTerms(ingredients)
+	if expandedIngredients := services.IngredientNames(matches); len(expandedIngredients) > 0 {
+		// Build OR conditions for all expanded ingredients
+		var orConditions []string
+		for _, ingredient := range expandedIngredients {
+			orConditions = append(orConditions, `EXISTS (
+				SELECT 1 FROM json_each(json_extract(r.data, '$.ingredients'))
+				WHERE json_extract(value, '$.name') = ?
+			)`)
+			args = append(args, ingredient)
+		}
+		conditions = append(conditions, `(`+strings.Join(orConditions, " OR ")+`)`)
+	}
+
+	if criteria.MaxCookingTime > 0 {
+		conditions = append(conditions, `r.cooking_time <= ?`)
+		args = append(args, criteria.MaxCookingTime)
+	}
+
+	if criteria.MinLazinessScore > 0 {
+		conditions = append(conditions, `r.laziness_score >= ?`)
+		args = append(args, criteria.MinLazinessScore)
+	}
+
+	if criteria.Season != "" && criteria.Season != "all" {
+		conditions = append(conditions, `(r.season = ? OR r.season = 'all')`)
+		args = append(args, criteria.Season)
+	}
+
+	// Allergens are checked against the recipe's computed allergen list, which
+	// covers hidden sources like マヨネーズ; other terms match ingredient names
+	allergens, others := models.SplitAllergies(criteria.Allergies)
+	if len(allergens) > 0 {
+		conditions = append(conditions, `NOT EXISTS (
+			SELECT 1 FROM json_each(r.data, '$.allergens')
+			WHERE value IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(allergens)), ", ")+`)
+		)`)
+		for _, allergen := range allergens {
+			args = append(args, allergen)
+		}
+	}
+	for _, term := range others {
+		conditions = append(conditions, `NOT EXISTS (
+			SELECT 1 FROM json_each(r.data, '$.ingredients')
+			WHERE json_extract(value, '$.name') LIKE ?
+		)`)
+		args = append(args, "%"+term+"%")
+	}
+
+	// Dietary restrictions use the compliance flags stored on each recipe
+	for _, diet := range criteria.DietaryRestrictions {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM json_each(r.data, '$.dietary_flags') WHERE value = ?
+		)`)
+		args = append(args, diet)
+	}
+
+	return conditions, args, matches
+}
+
 // GetIngredientCategories handles GET /api/recipes/ingredient-categories
 // Returns available ingredient categories for UI dropdown
 func (h *RecipeHandler) GetIngredientCategories(c *gin.Context) {
-	categories := h.ingredientMapper.GetSupportedCategories()
+	categories := []string{}
 
 	// Return both categories and their associated ingredients for debugging
 	categoryDetails := make(map[string]interface{})
-	for _, category := range categories {
-		ingredients := h.ingredientMapper.GetCategoryIngredients(category)
-		categoryDetails[category] = gin.H{
-			"display_name": category,
-			"ingredients":  ingredients,
-			"count":        len(ingredients),
+	for _, category := range h.ingredientResolver.Categories() {
+		categories = append(categories, category.Name)
+		categoryDetails[category.Name] = gin.H{
+			"display_name": category.Name,
+			"ingredients":  category.Ingredients,
+			"count":        len(category.Ingredients),
 		}
 	}
 
@@ -663,7 +1267,8 @@ func (h *RecipeHandler) TestIngredientMapping(c *gin.Context) {
 		searchTerm = "鶏肉" // Default test term
 	}
 
-	expandedIngredients := h.ingredientMapper.ExpandIngredientTerms([]string{searchTerm})
+	matches := h.ingredientResolver.ResolveTerms([]string{searchTerm})
+	expandedIngredients := services.IngredientNames(matches)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -671,7 +1276,8 @@ func (h *RecipeHandler) TestIngredientMapping(c *gin.Context) {
 			"search_term":          searchTerm,
 			"expanded_ingredients": expandedIngredients,
 			"expansion_count":      len(expandedIngredients),
-			"note":                 "This tests the ingredient synonym mapping system (Issue #87 fix)",
+			"matches":              matches,
+			"note":                 "Resolved through the ingredient hierarchy, with the synonym map as fallback (Issue #87 fix)",
 		},
 	})
 }
@@ -1,5 +1,27 @@
 package handlers
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+	"lazychef/internal/services"
+)
+
 // テストは構造体の変更により一時的にコメントアウト
 /*
 import (
@@ -299,3 +321,455 @@ func TestRecipeHandler_TestRecipeGeneration(t *testing.T) {
 	mockGenerator.AssertExpectations(t)
 }
 */
+
+// setupRecipeTestRouter returns a router serving the recipe CRUD endpoints on a schema-initialised database
+func setupRecipeTestRouter(t *testing.T) (*gin.Engine, *database.Database) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "scripts", "init_db.sql"))
+	require.NoError(t, err)
+
+	db, err := database.New(database.Config{Path: filepath.Join(t.TempDir(), "recipes.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+
+	handler := NewRecipeHandler(db, nil, nil)
+	r := gin.New()
+	r.POST("/api/recipes", handler.CreateRecipe)
+	r.GET("/api/recipes/:id", handler.GetRecipe)
+	r.PUT("/api/recipes/:id", handler.UpdateRecipe)
+	r.PATCH("/api/recipes/:id", handler.PatchRecipe)
+	r.DELETE("/api/recipes/:id", handler.DeleteRecipe)
+
+	return r, db
+}
+
+func performJSONRequest(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeRecipeResponse(t *testing.T, w *httptest.ResponseRecorder) models.Recipe {
+	t.Helper()
+
+	var response struct {
+		Success bool          `json:"success"`
+		Data    models.Recipe `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	return response.Data
+}
+
+func TestRecipeHandler_CRUD(t *testing.T) {
+	r, _ := setupRecipeTestRouter(t)
+
+	// Create without laziness score: it is calculated
+	w := performJSONRequest(r, http.MethodPost, "/api/recipes", `{
+		"title": "豚キャベツ炒め",
+		"cooking_time": 10,
+		"ingredients": [{"name": "豚こま肉", "amount": "200g"}, {"name": "キャベツ", "amount": "1/4個"}],
+		"steps": ["切る", "炒める"]
+	}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	created := decodeRecipeResponse(t, w)
+	assert.Greater(t, created.ID, 0)
+	assert.Equal(t, "all", created.Data.Season)
+	assert.Equal(t, 7.5, created.Data.LazinessScore)
+
+	path := fmt.Sprintf("/api/recipes/%d", created.ID)
+
+	w = performJSONRequest(r, http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "豚キャベツ炒め", decodeRecipeResponse(t, w).Data.Title)
+
+	// Patch only the title; other fields are kept
+	w = performJSONRequest(r, http.MethodPatch, path, `{"title": "豚こまキャベツ炒め"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	patched := decodeRecipeResponse(t, w)
+	assert.Equal(t, "豚こまキャベツ炒め", patched.Data.Title)
+	assert.Len(t, patched.Data.Ingredients, 2)
+
+	// Put replaces the whole recipe
+	w = performJSONRequest(r, http.MethodPut, path, `{
+		"title": "冷奴",
+		"cooking_time": 2,
+		"ingredients": [{"name": "豆腐", "amount": "1丁"}],
+		"steps": ["切る"],
+		"season": "summer",
+		"laziness_score": 9.5
+	}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	replaced := decodeRecipeResponse(t, w)
+	assert.Equal(t, "冷奴", replaced.Data.Title)
+	assert.Equal(t, "summer", replaced.Data.Season)
+	assert.Equal(t, 9.5, replaced.Data.LazinessScore)
+
+	w = performJSONRequest(r, http.MethodDelete, path, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = performJSONRequest(r, http.MethodGet, path, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRecipeHandler_CreateRecipe_Invalid(t *testing.T) {
+	r, _ := setupRecipeTestRouter(t)
+
+	tests := map[string]string{
+		"missing title":  `{"cooking_time": 10, "ingredients": [{"name": "卵", "amount": "1個"}], "steps": ["焼く"]}`,
+		"no ingredients": `{"title": "目玉焼き", "cooking_time": 5, "ingredients": [], "steps": ["焼く"]}`,
+		"bad season":     `{"title": "目玉焼き", "cooking_time": 5, "ingredients": [{"name": "卵", "amount": "1個"}], "steps": ["焼く"], "season": "monsoon"}`,
+		"invalid json":   `{"title":`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := performJSONRequest(r, http.MethodPost, "/api/recipes", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestRecipeHandler_NotFoundAndInvalidID(t *testing.T) {
+	r, _ := setupRecipeTestRouter(t)
+
+	assert.Equal(t, http.StatusNotFound, performJSONRequest(r, http.MethodGet, "/api/recipes/999", "").Code)
+	assert.Equal(t, http.StatusNotFound, performJSONRequest(r, http.MethodDelete, "/api/recipes/999", "").Code)
+	assert.Equal(t, http.StatusNotFound, performJSONRequest(r, http.MethodPatch, "/api/recipes/999", `{"title": "x"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, performJSONRequest(r, http.MethodGet, "/api/recipes/abc", "").Code)
+}
+
+func seedSearchRecipes(t *testing.T, r *gin.Engine) {
+	t.Helper()
+
+	for _, body := range []string{
+		`{"title": "キャベツと豚こまの炒め物", "cooking_time": 10,
+			"ingredients": [{"name": "豚こま肉", "amount": "200g"}, {"name": "キャベツ", "amount": "1/4個"}],
+			"steps": ["キャベツをざく切りにする", "豚こま肉と炒める"], "tags": ["簡単"]}`,
+		`{"title": "ふわふわ卵スープ", "cooking_time": 5,
+			"ingredients": [{"name": "卵", "amount": "1個"}, {"name": "鶏がらスープの素", "amount": "小さじ1"}],
+			"steps": ["湯を沸かす", "溶き卵を回し入れる"]}`,
+		`{"title": "冷奴", "cooking_time": 2,
+			"ingredients": [{"name": "豆腐", "amount": "1丁", "notes": "卵豆腐でも可"}],
+			"steps": ["器に盛る"]}`,
+	} {
+		w := performJSONRequest(r, http.MethodPost, "/api/recipes", body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+}
+
+type searchResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Recipes    []models.RecipeData `json:"recipes"`
+		Total      int                 `json:"total"`
+		SearchMode string              `json:"search_mode"`
+		Matches    []struct {
+			RecipeID   int               `json:"recipe_id"`
+			Rank       float64           `json:"rank"`
+			Similarity float64           `json:"similarity"`
+			Snippets   map[string]string `json:"snippets"`
+		} `json:"matches"`
+	} `json:"data"`
+}
+
+func TestRecipeHandler_SearchRecipes_LikeFallback(t *testing.T) {
+	r, db := setupRecipeTestRouter(t)
+	handler := NewRecipeHandler(db, nil, nil)
+	r.GET("/api/recipes/search", handler.SearchRecipes)
+	seedSearchRecipes(t, r)
+
+	w := performJSONRequest(r, http.MethodGet, "/api/recipes/search?query="+url.QueryEscape("卵"), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response searchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if response.Data.SearchMode == "fts5" {
+		t.Skip("FTS5 is available; covered by TestRecipeHandler_SearchRecipes_FullText")
+	}
+
+	// Ingredient notes no longer produce false hits
+	require.Len(t, response.Data.Recipes, 1)
+	assert.Equal(t, "ふわふわ卵スープ", response.Data.Recipes[0].Title)
+	assert.Equal(t, 1, response.Data.Total)
+}
+
+func TestRecipeHandler_SearchRecipes_FullText(t *testing.T) {
+	r, db := setupRecipeTestRouter(t)
+	if err := services.NewRecipeSearchIndex(db).EnsureSchema(); errors.Is(err, services.ErrFullTextSearchUnavailable) {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5")
+	} else {
+		require.NoError(t, err)
+	}
+	handler := NewRecipeHandler(db, nil, nil)
+	r.GET("/api/recipes/search", handler.SearchRecipes)
+	seedSearchRecipes(t, r)
+
+	search := func(query string) searchResponse {
+		w := performJSONRequest(r, http.MethodGet, "/api/recipes/search?query="+url.QueryEscape(query), "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response searchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "fts5", response.Data.SearchMode)
+		return response
+	}
+
+	response := search("キャベツ")
+	require.Len(t, response.Data.Recipes, 1)
+	assert.Equal(t, 1, response.Data.Total)
+	require.Len(t, response.Data.Matches, 1)
+	assert.Contains(t, response.Data.Matches[0].Snippets["title"], "<mark>キャベツ</mark>")
+	assert.Contains(t, response.Data.Matches[0].Snippets["ingredients"], "<mark>キャベツ</mark>")
+
+	// Short terms still match indexed fields only, not ingredient notes
+	response = search("卵")
+	require.Len(t, response.Data.Recipes, 1)
+	assert.Equal(t, "ふわふわ卵スープ", response.Data.Recipes[0].Title)
+	assert.Contains(t, response.Data.Matches[0].Snippets["steps"], "<mark>卵</mark>")
+
+	// Terms are combined with AND
+	response = search("豚こま肉 キャベツ")
+	require.Len(t, response.Data.Recipes, 1)
+	response = search("豚こま肉 鶏がらスープ")
+	assert.Empty(t, response.Data.Recipes)
+}
+
+func TestRecipeHandler_SearchRecipes_IngredientHierarchy(t *testing.T) {
+	r, db := setupRecipeTestRouter(t)
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "scripts", "hierarchical_ingredients_schema.sql"))
+	require.NoError(t, err)
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+
+	handler := NewRecipeHandler(db, nil, nil)
+	r.GET("/api/recipes/search", handler.SearchRecipes)
+	seedSearchRecipes(t, r)
+	w := performJSONRequest(r, http.MethodPost, "/api/recipes", `{"title": "鶏むね肉のレンジ蒸し", "cooking_time": 8,
+		"ingredients": [{"name": "鶏むね肉", "amount": "1枚"}], "steps": ["レンジで加熱する"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = performJSONRequest(r, http.MethodGet, "/api/recipes/search?ingredients="+url.QueryEscape("肉類"), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data struct {
+			Recipes           []models.RecipeData            `json:"recipes"`
+			IngredientMatches []services.IngredientTermMatch `json:"ingredient_matches"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	titles := []string{}
+	for _, recipe := range response.Data.Recipes {
+		titles = append(titles, recipe.Title)
+	}
+	assert.ElementsMatch(t, []string{"キャベツと豚こまの炒め物", "鶏むね肉のレンジ蒸し"}, titles)
+
+	require.Len(t, response.Data.IngredientMatches, 1)
+	assert.Equal(t, "肉類", response.Data.IngredientMatches[0].Term)
+	assert.Equal(t, services.IngredientMatchSourceGroup, response.Data.IngredientMatches[0].Source)
+	assert.Equal(t, []string{"肉類"}, response.Data.IngredientMatches[0].MatchedGroups)
+}
+
+func TestRecipeHandler_MatchRecipes(t *testing.T) {
+	r, db := setupRecipeTestRouter(t)
+	handler := NewRecipeHandler(db, nil, nil)
+	r.POST("/api/recipes/match-ingredients", handler.MatchRecipes)
+	seedSearchRecipes(t, r)
+
+	w := performJSONRequest(r, http.MethodPost, "/api/recipes/match-ingredients", `{"ingredients": ["キャベツ", "卵"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data services.RecipeMatchResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data.Matches, 2)
+	for _, match := range response.Data.Matches {
+		assert.Equal(t, 50.0, match.Coverage)
+		assert.Len(t, match.Missing, 1)
+	}
+
+	w = performJSONRequest(r, http.MethodPost, "/api/recipes/match-ingredients", `{"ingredients": []}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRecipeHandler_SemanticSearchRecipes(t *testing.T) {
+	r, db := setupRecipeTestRouter(t)
+	handler := NewRecipeHandler(db, nil, nil)
+	r.GET("/api/recipes/semantic-search", handler.SemanticSearchRecipes)
+
+	w := performJSONRequest(r, http.MethodGet, "/api/recipes/semantic-search?q=x", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	embeddingService := services.NewEmbeddingDeduplicator(services.NewLocalProvider(), db.DB)
+	handler.SetEmbeddingService(embeddingService)
+	db.OnRecipeSaved(func(id int) {
+		require.NoError(t, embeddingService.IndexRecipe(context.Background(), id))
+	})
+	seedSearchRecipes(t, r)
+
+	search := func(params string) searchResponse {
+		w := performJSONRequest(r, http.MethodGet, "/api/recipes/semantic-search?"+params, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response searchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "semantic", response.Data.SearchMode)
+		return response
+	}
+
+	response := search("q=" + url.QueryEscape("キャベツと豚こま肉の炒め物"))
+	require.Len(t, response.Data.Recipes, 3)
+	assert.Equal(t, 3, response.Data.Total)
+	assert.Equal(t, "キャベツと豚こまの炒め物", response.Data.Recipes[0].Title)
+	require.Len(t, response.Data.Matches, 3)
+	assert.GreaterOrEqual(t, response.Data.Matches[0].Similarity, response.Data.Matches[1].Similarity)
+
+	// Filters narrow the ranked candidates
+	response = search("q=" + url.QueryEscape("キャベツと豚こま肉の炒め物") + "&max_cooking_time=5")
+	assert.Equal(t, 2, response.Data.Total)
+	for _, recipe := range response.Data.Recipes {
+		assert.LessOrEqual(t, recipe.CookingTime, 5)
+	}
+
+	response = search("q=" + url.QueryEscape("卵スープ") + "&limit=1")
+	require.Len(t, response.Data.Recipes, 1)
+	assert.Equal(t, "ふわふわ卵スープ", response.Data.Recipes[0].Title)
+
+	w = performJSONRequest(r, http.MethodGet, "/api/recipes/semantic-search", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRecipeHandler_GenerateRecipe_ServedFromLibrary(t *testing.T) {
+	r, db := setupRecipeTestRouter(t)
+	embeddingService := services.NewEmbeddingDeduplicator(services.NewLocalProvider(), db.DB)
+	db.OnRecipeSaved(func(id int) {
+		require.NoError(t, embeddingService.IndexRecipe(context.Background(), id))
+	})
+	seedSearchRecipes(t, r)
+
+	// No generator is configured, so only a library match can answer
+	handler := NewRecipeHandler(db, nil, nil)
+	handler.SetLibraryLookup(services.NewRecipeLibraryLookup(embeddingService, db, 0.1))
+	r.POST("/api/recipes/generate", handler.GenerateRecipe)
+
+	w := performJSONRequest(r, http.MethodPost, "/api/recipes/generate",
+		`{"ingredients": ["キャベツ", "豚こま肉"], "season": "all", "max_cooking_time": 15}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Recipe            models.RecipeData           `json:"recipe"`
+		RecipeID          int                         `json:"recipe_id"`
+		ServedFromLibrary bool                        `json:"served_from_library"`
+		Metadata          services.GenerationMetadata `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.ServedFromLibrary)
+	assert.True(t, response.Metadata.ServedFromLibrary)
+	assert.Equal(t, "キャベツと豚こまの炒め物", response.Recipe.Title)
+	assert.Equal(t, response.Metadata.LibraryRecipeID, response.RecipeID)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM recipes`).Scan(&count))
+	assert.Equal(t, 3, count, "serving from the library does not save a copy")
+}
+
+func TestRecipeHandler_SearchRecipes_ExcludesAllergies(t *testing.T) {
+	r, db := setupRecipeTestRouter(t)
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "scripts", "hierarchical_ingredients_schema.sql"))
+	require.NoError(t, err)
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+	db.OnRecipeSaved(services.NewAllergenService(db).HandleRecipeSaved)
+
+	stored := models.GetDefaultPreferences()
+	stored.AllergyInfo = []string{"大豆"}
+	_, err = services.NewUserPreferencesRepository(db).SavePreferences("alice", stored)
+	require.NoError(t, err)
+
+	handler := NewRecipeHandler(db, nil, nil)
+	r.GET("/api/recipes/search", handler.SearchRecipes)
+	seedSearchRecipes(t, r)
+	w := performJSONRequest(r, http.MethodPost, "/api/recipes", `{"title": "ブロッコリーのマヨ和え", "cooking_time": 5,
+		"ingredients": [{"name": "ブロッコリー", "amount": "1/2株"}, {"name": "マヨネーズ", "amount": "大さじ1"}], "steps": ["和える"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	search := func(query string) []string {
+		w := performJSONRequest(r, http.MethodGet, "/api/recipes/search?"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response searchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		titles := []string{}
+		for _, recipe := range response.Data.Recipes {
+			titles = append(titles, recipe.Title)
+		}
+		return titles
+	}
+
+	// マヨネーズ contains 卵 through the ingredient hierarchy
+	assert.ElementsMatch(t, []string{"キャベツと豚こまの炒め物", "冷奴"}, search("allergies="+url.QueryEscape("たまご")))
+
+	// Non-allergen terms match ingredient names; stored allergies are added for the user
+	assert.ElementsMatch(t, []string{"キャベツと豚こまの炒め物"},
+		search("allergies="+url.QueryEscape("卵,ブロッコリー")+"&user_id=alice"))
+}
+
+func TestRecipeHandler_SearchRecipes_FiltersDietaryRestrictions(t *testing.T) {
+	r, db := setupRecipeTestRouter(t)
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "scripts", "hierarchical_ingredients_schema.sql"))
+	require.NoError(t, err)
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+	db.OnRecipeSaved(services.NewDietaryRestrictionService(db).HandleRecipeSaved)
+
+	stored := models.GetDefaultPreferences()
+	stored.DietaryRestrictions = []string{"ビーガン", "オーガニック"}
+	_, err = services.NewUserPreferencesRepository(db).SavePreferences("alice", stored)
+	require.NoError(t, err)
+
+	handler := NewRecipeHandler(db, nil, nil)
+	r.GET("/api/recipes/search", handler.SearchRecipes)
+	seedSearchRecipes(t, r)
+	for _, body := range []string{
+		`{"title": "きのこの和風パスタ", "cooking_time": 10,
+			"ingredients": [{"name": "パスタ", "amount": "100g"}, {"name": "しめじ", "amount": "1/2袋"}, {"name": "和風だし", "amount": "小さじ1"}],
+			"steps": ["パスタを茹でる", "しめじと和える"]}`,
+		`{"title": "ほうれん草のおひたし", "cooking_time": 5,
+			"ingredients": [{"name": "ほうれん草", "amount": "1束"}, {"name": "昆布だし", "amount": "50ml"}],
+			"steps": ["茹でる", "だしに浸す"]}`,
+	} {
+		w := performJSONRequest(r, http.MethodPost, "/api/recipes", body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	search := func(query string) []string {
+		w := performJSONRequest(r, http.MethodGet, "/api/recipes/search?"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response searchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		titles := []string{}
+		for _, recipe := range response.Data.Recipes {
+			titles = append(titles, recipe.Title)
+		}
+		return titles
+	}
+
+	// 和風だし is made from fish, 昆布だし is not
+	assert.ElementsMatch(t, []string{"冷奴", "ほうれん草のおひたし"}, search("dietary_restrictions=vegetarian"))
+	assert.ElementsMatch(t, []string{"冷奴", "ほうれん草のおひたし", "キャベツと豚こまの炒め物", "ふわふわ卵スープ"},
+		search("dietary_restrictions="+url.QueryEscape("グルテンフリー")))
+
+	// Stored restrictions are added for the user; terms outside the catalogue are ignored
+	assert.ElementsMatch(t, []string{"冷奴", "ほうれん草のおひたし"}, search("dietary_restrictions=gluten-free&user_id=alice"))
+
+	w := performJSONRequest(r, http.MethodGet, "/api/recipes/search?dietary_restrictions=keto", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "keto")
+}
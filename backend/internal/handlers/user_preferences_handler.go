@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+	"lazychef/internal/services"
+)
+
+// UserPreferencesHandler handles user preference HTTP requests
+type UserPreferencesHandler struct {
+	repository *services.UserPreferencesRepository
+}
+
+// NewUserPreferencesHandler creates a new user preferences handler
+func NewUserPreferencesHandler(db *database.Database) *UserPreferencesHandler {
+	return &UserPreferencesHandler{
+		repository: services.NewUserPreferencesRepository(db),
+	}
+}
+
+// GetPreferences handles GET /api/users/:user_id/preferences
+// Returns the defaults when the user has not saved any preferences yet
+func (h *UserPreferencesHandler) GetPreferences(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	prefs, err := h.repository.GetPreferences(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"data": models.UserPreferences{
+					UserID:      userID,
+					Preferences: models.GetDefaultPreferences(),
+				},
+				"is_default": true,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load user preferences",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"data":       prefs,
+		"is_default": false,
+	})
+}
+
+// UpdatePreferences handles PUT /api/users/:user_id/preferences
+// Replaces all stored preferences of the user
+func (h *UserPreferencesHandler) UpdatePreferences(c *gin.Context) {
+	var prefs models.UserPreferencesData
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.savePreferences(c, prefs)
+}
+
+// PatchPreferences handles PATCH /api/users/:user_id/preferences
+// Only fields present in the body are changed; an empty list clears a list field
+func (h *UserPreferencesHandler) PatchPreferences(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	var update models.UserPreferencesData
+	if err := decodeJSONBody(c, &update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	prefs := models.GetDefaultPreferences()
+	existing, err := h.repository.GetPreferences(userID)
+	switch {
+	case err == nil:
+		prefs = existing.Preferences
+	case !errors.Is(err, models.ErrUserNotFound):
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load user preferences",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	prefs.UpdateFromRequest(update)
+	h.savePreferences(c, prefs)
+}
+
+// savePreferences validates and stores preferences, then writes the response
+func (h *UserPreferencesHandler) savePreferences(c *gin.Context, prefs models.UserPreferencesData) {
+	if err := prefs.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid preferences",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	saved, err := h.repository.SavePreferences(c.Param("user_id"), prefs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to save user preferences",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    saved,
+	})
+}
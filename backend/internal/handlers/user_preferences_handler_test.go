@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func setupPreferencesTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	_, db := setupRecipeTestRouter(t)
+	handler := NewUserPreferencesHandler(db)
+
+	r := gin.New()
+	r.GET("/api/users/:user_id/preferences", handler.GetPreferences)
+	r.PUT("/api/users/:user_id/preferences", handler.UpdatePreferences)
+	r.PATCH("/api/users/:user_id/preferences", handler.PatchPreferences)
+	return r
+}
+
+func decodePreferencesResponse(t *testing.T, w *httptest.ResponseRecorder) (models.UserPreferences, bool) {
+	t.Helper()
+
+	var response struct {
+		Success   bool                   `json:"success"`
+		Data      models.UserPreferences `json:"data"`
+		IsDefault bool                   `json:"is_default"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	return response.Data, response.IsDefault
+}
+
+func TestUserPreferencesHandler_GetDefaults(t *testing.T) {
+	r := setupPreferencesTestRouter(t)
+
+	w := performJSONRequest(r, http.MethodGet, "/api/users/alice/preferences", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	prefs, isDefault := decodePreferencesResponse(t, w)
+	assert.True(t, isDefault)
+	assert.Equal(t, "alice", prefs.UserID)
+	assert.Equal(t, models.GetDefaultPreferences().MaxCookingTime, prefs.Preferences.MaxCookingTime)
+}
+
+func TestUserPreferencesHandler_PutAndPatch(t *testing.T) {
+	r := setupPreferencesTestRouter(t)
+
+	w := performJSONRequest(r, http.MethodPut, "/api/users/alice/preferences", `{
+		"max_cooking_time": 20,
+		"exclude_ingredients": ["セロリ"],
+		"budget_per_week": 5000,
+		"household_size": 2,
+		"allergy_info": ["えび"],
+		"meal_plan_length": 5
+	}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// PATCH only touches the fields in the body
+	w = performJSONRequest(r, http.MethodPatch, "/api/users/alice/preferences", `{"household_size": 3, "allergy_info": []}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = performJSONRequest(r, http.MethodGet, "/api/users/alice/preferences", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	prefs, isDefault := decodePreferencesResponse(t, w)
+	assert.False(t, isDefault)
+	assert.Equal(t, 20, prefs.Preferences.MaxCookingTime)
+	assert.Equal(t, 3, prefs.Preferences.HouseholdSize)
+	assert.Equal(t, 5000, prefs.Preferences.BudgetPerWeek)
+	assert.Equal(t, []string{"セロリ"}, prefs.Preferences.ExcludeIngredients)
+	assert.Empty(t, prefs.Preferences.AllergyInfo)
+
+	// Other users are unaffected
+	w = performJSONRequest(r, http.MethodGet, "/api/users/bob/preferences", "")
+	_, isDefault = decodePreferencesResponse(t, w)
+	assert.True(t, isDefault)
+}
+
+func TestUserPreferencesHandler_Invalid(t *testing.T) {
+	r := setupPreferencesTestRouter(t)
+
+	w := performJSONRequest(r, http.MethodPatch, "/api/users/alice/preferences", `{"household_size": 50}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performJSONRequest(r, http.MethodPatch, "/api/users/alice/preferences", `{"cooking_skill_level": "chef"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performJSONRequest(r, http.MethodPut, "/api/users/alice/preferences", `{"max_cooking_time": 500}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"lazychef/internal/services"
+)
+
+// UsageSourceMiddleware tags the request context with the matched route so
+// LLM token usage recorded while handling it is attributed to the endpoint
+func UsageSourceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx := services.WithUsageSource(c.Request.Context(), services.UsageSource{
+			Endpoint: c.Request.Method + " " + route,
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"strings"
+)
+
+// Allergen categories under Japan's food labeling standard
+const (
+	AllergenSpecified   = "特定原材料"       // Labeling mandatory
+	AllergenRecommended = "特定原材料に準ずるもの" // Labeling recommended
+)
+
+// Allergen is one of the allergens covered by Japanese food labeling
+type Allergen struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Keywords []string `json:"keywords"`         // Ingredient name fragments (and user terms) that mean this allergen
+	Except   []string `json:"except,omitempty"` // Names containing a keyword that are not this allergen, e.g. 焼きそば for そば
+}
+
+// Allergens lists the 特定原材料 followed by the 特定原材料に準ずるもの, in
+// the order allergen lists are reported. Ingredients that only contain an
+// allergen as an ingredient of their own (マヨネーズ → 卵) are resolved
+// through the ingredient hierarchy, not these keywords.
+var Allergens = []Allergen{
+	{Name: "えび", Category: AllergenSpecified, Keywords: []string{"えび", "エビ", "海老", "shrimp", "prawn"}},
+	{Name: "かに", Category: AllergenSpecified, Keywords: []string{"かに", "カニ", "蟹", "crab"}},
+	{Name: "くるみ", Category: AllergenSpecified, Keywords: []string{"くるみ", "クルミ", "胡桃", "walnut"}},
+	{Name: "小麦", Category: AllergenSpecified, Keywords: []string{"小麦", "薄力粉", "強力粉", "中力粉", "パン粉", "食パン", "うどん", "そうめん", "ひやむぎ",
+		"パスタ", "スパゲッティ", "スパゲティ", "マカロニ", "中華麺", "ラーメン", "焼きそば", "餃子の皮", "天ぷら粉", "wheat", "flour"}},
+	{Name: "そば", Category: AllergenSpecified, Keywords: []string{"そば", "蕎麦", "buckwheat"},
+		Except: []string{"焼きそば", "焼そば", "中華そば"}},
+	{Name: "卵", Category: AllergenSpecified, Keywords: []string{"卵", "たまご", "玉子", "タマゴ", "egg"},
+		Except: []string{"eggplant"}},
+	{Name: "乳", Category: AllergenSpecified, Keywords: []string{"牛乳", "ミルク", "乳製品", "生クリーム", "バター", "チーズ", "ヨーグルト", "練乳", "脱脂粉乳",
+		"milk", "butter", "cheese", "cream", "yogurt"},
+		Except: []string{"ココナッツミルク", "アーモンドミルク", "ピーナッツバター", "coconut milk", "almond milk", "peanut butter"}},
+	{Name: "落花生", Category: AllergenSpecified, Keywords: []string{"落花生", "ピーナッツ", "ピーナツ", "peanut"}},
+
+	{Name: "アーモンド", Category: AllergenRecommended, Keywords: []string{"アーモンド", "almond"}},
+	{Name: "あわび", Category: AllergenRecommended, Keywords: []string{"あわび", "アワビ", "鮑", "abalone"}},
+	{Name: "いか", Category: AllergenRecommended, Keywords: []string{"いか", "イカ", "烏賊", "squid"},
+		Except: []string{"すいか", "スイカ", "いかなご"}},
+	{Name: "いくら", Category: AllergenRecommended, Keywords: []string{"いくら", "イクラ", "salmon roe"}},
+	{Name: "オレンジ", Category: AllergenRecommended, Keywords: []string{"オレンジ", "orange"}},
+	{Name: "カシューナッツ", Category: AllergenRecommended, Keywords: []string{"カシューナッツ", "cashew"}},
+	{Name: "キウイフルーツ", Category: AllergenRecommended, Keywords: []string{"キウイ", "kiwi"}},
+	{Name: "牛肉", Category: AllergenRecommended, Keywords: []string{"牛", "ビーフ", "合いびき", "合挽", "beef"},
+		Except: []string{"牛乳", "牛蒡"}},
+	{Name: "ごま", Category: AllergenRecommended, Keywords: []string{"ごま", "ゴマ", "胡麻", "sesame"}},
+	{Name: "さけ", Category: AllergenRecommended, Keywords: []string{"鮭", "さけ", "サケ", "しゃけ", "サーモン", "salmon"},
+		Except: []string{"salmon roe"}},
+	{Name: "さば", Category: AllergenRecommended, Keywords: []string{"さば", "サバ", "鯖", "mackerel"}},
+	{Name: "大豆", Category: AllergenRecommended, Keywords: []string{"大豆", "豆腐", "納豆", "豆乳", "油揚げ", "厚揚げ", "味噌", "みそ", "きな粉", "枝豆", "soy", "tofu"}},
+	{Name: "鶏肉", Category: AllergenRecommended, Keywords: []string{"鶏", "とり肉", "ささみ", "手羽", "チキン", "chicken"},
+		Except: []string{"鶏卵"}},
+	{Name: "バナナ", Category: AllergenRecommended, Keywords: []string{"バナナ", "banana"}},
+	{Name: "豚肉", Category: AllergenRecommended, Keywords: []string{"豚", "ポーク", "ベーコン", "ハム", "ソーセージ", "ウインナー", "合いびき", "合挽", "pork", "bacon"}},
+	{Name: "マカダミアナッツ", Category: AllergenRecommended, Keywords: []string{"マカダミア", "マカデミア", "macadamia"}},
+	{Name: "もも", Category: AllergenRecommended, Keywords: []string{"桃", "ピーチ", "peach"}},
+	{Name: "やまいも", Category: AllergenRecommended, Keywords: []string{"やまいも", "山芋", "長芋", "ながいも", "自然薯", "大和芋", "とろろ"}},
+	{Name: "りんご", Category: AllergenRecommended, Keywords: []string{"りんご", "リンゴ", "林檎", "apple"},
+		Except: []string{"pineapple"}},
+	{Name: "ゼラチン", Category: AllergenRecommended, Keywords: []string{"ゼラチン", "gelatin"}},
+}
+
+// FindAllergen returns the allergen a user term names, matching an allergen
+// name or keyword exactly (e.g. "たまご" and "egg" both name 卵)
+func FindAllergen(term string) (*Allergen, bool) {
+	term = strings.ToLower(strings.TrimSpace(term))
+	if term == "" {
+		return nil, false
+	}
+	for i := range Allergens {
+		if Allergens[i].Name == term {
+			return &Allergens[i], true
+		}
+	}
+	for i := range Allergens {
+		for _, keyword := range Allergens[i].Keywords {
+			if keyword == term {
+				return &Allergens[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Excludes reports whether an ingredient name is on the allergen's exception list
+func (a *Allergen) Excludes(ingredient string) bool {
+	name := strings.ToLower(ingredient)
+	for _, except := range a.Except {
+		if strings.Contains(name, except) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether an ingredient name contains one of the allergen's
+// keywords, ignoring names on its exception list
+func (a *Allergen) Matches(ingredient string) bool {
+	if a.Excludes(ingredient) {
+		return false
+	}
+	name := strings.ToLower(ingredient)
+	for _, keyword := range a.Keywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortAllergens orders allergen names as in Allergens, dropping duplicates
+// and names that are not allergens
+func SortAllergens(names []string) []string {
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+	}
+	sorted := []string{}
+	for _, allergen := range Allergens {
+		if present[allergen.Name] {
+			sorted = append(sorted, allergen.Name)
+		}
+	}
+	return sorted
+}
+
+// SplitAllergies separates a user's allergy list into the allergens it names
+// and the remaining terms, which are matched against ingredient names as-is
+func SplitAllergies(allergies []string) (allergens []string, others []string) {
+	for _, term := range allergies {
+		if allergen, ok := FindAllergen(term); ok {
+			allergens = append(allergens, allergen.Name)
+		} else if term = strings.TrimSpace(term); term != "" {
+			others = append(others, term)
+		}
+	}
+	return SortAllergens(allergens), others
+}
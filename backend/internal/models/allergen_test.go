@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllergen_Matches(t *testing.T) {
+	soba, _ := FindAllergen("そば")
+	assert.True(t, soba.Matches("十割そば"))
+	assert.False(t, soba.Matches("焼きそば麺"))
+
+	milk, _ := FindAllergen("乳")
+	assert.True(t, milk.Matches("無塩バター"))
+	assert.False(t, milk.Matches("ピーナッツバター"))
+
+	egg, ok := FindAllergen("Egg")
+	assert.True(t, ok)
+	assert.Equal(t, "卵", egg.Name)
+	assert.False(t, egg.Matches("eggplant"))
+}
+
+func TestSplitAllergies(t *testing.T) {
+	allergens, others := SplitAllergies([]string{"たまご", "パクチー", "えび", "小麦", " "})
+	assert.Equal(t, []string{"えび", "小麦", "卵"}, allergens)
+	assert.Equal(t, []string{"パクチー"}, others)
+}
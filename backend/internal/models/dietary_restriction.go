@@ -0,0 +1,173 @@
+package models
+
+import (
+	"strings"
+)
+
+// Dietary components recipes are classified by. Restrictions forbid components,
+// and ingredients carry them either by name or through the ingredient hierarchy.
+const (
+	DietaryComponentMeat    = "meat"
+	DietaryComponentFish    = "fish"
+	DietaryComponentEgg     = "egg"
+	DietaryComponentDairy   = "dairy"
+	DietaryComponentHoney   = "honey"
+	DietaryComponentPork    = "pork"
+	DietaryComponentAlcohol = "alcohol"
+	DietaryComponentGluten  = "gluten"
+	DietaryComponentSalt    = "salt"
+)
+
+// DietaryComponent is something in an ingredient that a dietary restriction can forbid
+type DietaryComponent struct {
+	Name     string   `json:"name"`
+	Label    string   `json:"label"`
+	Keywords []string `json:"keywords"`         // Ingredient name fragments that carry this component
+	Except   []string `json:"except,omitempty"` // Names containing a keyword that do not, e.g. 昆布だし for fish
+}
+
+// DietaryComponents lists every component with the ingredient name fragments
+// that reveal it. Ingredients whose names do not (コンソメ contains meat) are
+// resolved through the ingredient hierarchy.
+var DietaryComponents = []DietaryComponent{
+	{Name: DietaryComponentMeat, Label: "肉", Keywords: []string{"肉", "豚", "鶏", "牛", "ベーコン", "ハム", "ソーセージ", "ウインナー", "ウィンナー",
+		"チキン", "ポーク", "ビーフ", "ささみ", "手羽", "レバー", "ラード", "コンソメ", "ブイヨン", "鶏がら", "鶏ガラ", "ゼラチン",
+		"beef", "pork", "chicken", "bacon", "ham", "sausage", "gelatin"},
+		Except: []string{"大豆ミート", "ソイミート", "大豆肉", "果肉", "牛乳", "牛蒡", "野菜ブイヨン", "野菜コンソメ"}},
+	{Name: DietaryComponentFish, Label: "魚介", Keywords: []string{"魚", "鮭", "しゃけ", "サーモン", "ツナ", "さば", "サバ", "鯖", "アジ", "鯵",
+		"まぐろ", "マグロ", "ぶり", "たら", "タラ", "えび", "エビ", "海老", "いか", "イカ", "たこ", "タコ", "しらす", "かに", "カニ", "蟹",
+		"あさり", "しじみ", "ほたて", "ホタテ", "牡蠣", "かつお", "鰹", "煮干し", "いりこ", "だし", "出汁", "ダシ", "アンチョビ", "ナンプラー",
+		"オイスターソース", "明太子", "たらこ", "ちくわ", "かまぼこ", "はんぺん",
+		"fish", "salmon", "tuna", "shrimp", "prawn", "squid", "crab", "anchovy", "dashi", "oyster sauce"},
+		Except: []string{"昆布だし", "椎茸だし", "しいたけだし", "精進だし", "野菜だし", "すいか", "スイカ", "たらの芽"}},
+	{Name: DietaryComponentEgg, Label: "卵", Keywords: []string{"卵", "たまご", "玉子", "タマゴ", "マヨネーズ", "egg", "mayonnaise"},
+		Except: []string{"eggplant"}},
+	{Name: DietaryComponentDairy, Label: "乳製品", Keywords: []string{"牛乳", "ミルク", "乳製品", "チーズ", "バター", "ヨーグルト", "生クリーム", "練乳", "脱脂粉乳",
+		"milk", "cheese", "butter", "cream", "yogurt"},
+		Except: []string{"ココナッツミルク", "アーモンドミルク", "オーツミルク", "ソイミルク", "豆乳", "ピーナッツバター",
+			"coconut milk", "almond milk", "oat milk", "soy milk", "peanut butter"}},
+	{Name: DietaryComponentHoney, Label: "はちみつ", Keywords: []string{"はちみつ", "蜂蜜", "ハチミツ", "honey"}},
+	{Name: DietaryComponentPork, Label: "豚", Keywords: []string{"豚", "ポーク", "ベーコン", "ハム", "ソーセージ", "ウインナー", "ウィンナー", "ラード", "ゼラチン",
+		"pork", "bacon", "ham", "lard", "gelatin"}},
+	{Name: DietaryComponentAlcohol, Label: "アルコール", Keywords: []string{"酒", "みりん", "味醂", "ワイン", "ビール", "紹興酒", "ブランデー", "ラム酒",
+		"wine", "beer", "mirin", "sake", "brandy"},
+		Except: []string{"みりん風", "ノンアルコール"}},
+	{Name: DietaryComponentGluten, Label: "グルテン", Keywords: []string{"小麦", "薄力粉", "強力粉", "中力粉", "パン粉", "食パン", "パン", "うどん", "そうめん",
+		"ひやむぎ", "パスタ", "スパゲッティ", "スパゲティ", "マカロニ", "中華麺", "ラーメン", "そば", "餃子の皮", "春巻きの皮", "天ぷら粉",
+		"お好み焼き粉", "麩", "大麦", "麦味噌", "しょうゆ", "醤油", "めんつゆ", "ルウ", "カレールー", "シチューのルー",
+		"wheat", "flour", "bread", "pasta", "barley", "soy sauce"},
+		Except: []string{"米粉", "十割", "グルテンフリー", "たまり", "パンプキン", "パンチェッタ", "gluten-free", "rice flour"}},
+	{Name: DietaryComponentSalt, Label: "塩分", Keywords: []string{"塩", "しょうゆ", "醤油", "味噌", "みそ", "めんつゆ", "白だし", "ポン酢", "コンソメ", "ブイヨン",
+		"鶏がらスープ", "ガラスープ", "顆粒だし", "ほんだし", "オイスターソース", "ナンプラー", "魚醤", "ベーコン", "ハム", "ウインナー", "ソーセージ",
+		"漬物", "梅干し", "キムチ", "明太子", "たらこ", "ザーサイ", "カレールウ", "salt", "soy sauce", "miso", "bouillon"},
+		Except: []string{"減塩", "無塩", "食塩不使用", "塩分控えめ", "塩分カット", "unsalted", "low sodium"}},
+}
+
+// DietaryRestriction is a diet a recipe can be checked against
+type DietaryRestriction struct {
+	Name      string   `json:"name"`
+	Label     string   `json:"label"`
+	Aliases   []string `json:"aliases,omitempty"`
+	Forbids   []string `json:"forbids"`             // Dietary component names
+	Tolerance int      `json:"tolerance,omitempty"` // Ingredients with a forbidden component a compliant recipe may still use
+	Guidance  string   `json:"guidance"`            // Instruction given to recipe generation
+}
+
+// DietaryRestrictions lists the restrictions the engine enforces, in the order
+// compliance flags are reported
+var DietaryRestrictions = []DietaryRestriction{
+	{Name: "vegetarian", Label: "ベジタリアン", Aliases: []string{"ベジタリアン", "菜食"},
+		Forbids:  []string{DietaryComponentMeat, DietaryComponentFish},
+		Guidance: "肉・魚介を使わない。かつおだし・煮干しだし、コンソメ、鶏がらスープの素、オイスターソース、ナンプラー、ゼラチンも不可（昆布だし・しいたけだし・野菜ブイヨンを使う）"},
+	{Name: "vegan", Label: "ヴィーガン", Aliases: []string{"ヴィーガン", "ビーガン"},
+		Forbids:  []string{DietaryComponentMeat, DietaryComponentFish, DietaryComponentEgg, DietaryComponentDairy, DietaryComponentHoney},
+		Guidance: "肉・魚介・卵・乳製品・はちみつを使わない。だし、コンソメ、マヨネーズ、バターなど動物性の調味料も不可（昆布だし・豆乳・植物油を使う）"},
+	{Name: "pescatarian", Label: "ペスカタリアン", Aliases: []string{"ペスカタリアン"},
+		Forbids:  []string{DietaryComponentMeat},
+		Guidance: "肉を使わない（魚介は可）。コンソメ、鶏がらスープの素、ベーコン、ゼラチンも不可"},
+	{Name: "halal", Label: "ハラール", Aliases: []string{"ハラール", "ハラル"},
+		Forbids:  []string{DietaryComponentPork, DietaryComponentAlcohol},
+		Guidance: "豚肉と豚由来の食品（ベーコン、ハム、ラード、ゼラチン）、アルコール（酒、みりん、ワイン）を使わない。肉はハラール認証のものを使う"},
+	{Name: "gluten-free", Label: "グルテンフリー", Aliases: []string{"グルテンフリー", "gluten_free", "glutenfree"},
+		Forbids:  []string{DietaryComponentGluten},
+		Guidance: "小麦・大麦を使わない。しょうゆ、めんつゆ、カレールウ、パン粉、小麦粉も不可（たまりしょうゆ・米粉・片栗粉を使う）"},
+	{Name: "low-sodium", Label: "減塩", Aliases: []string{"減塩", "low_sodium", "lowsodium"},
+		Forbids: []string{DietaryComponentSalt}, Tolerance: 1,
+		Guidance: "塩分の多い調味料（塩、しょうゆ、味噌、めんつゆ、コンソメなど）は1種類だけ少量にし、加工肉や漬物は使わない。酢・香辛料・香味野菜で味を補う"},
+}
+
+// FindDietaryComponent returns the component with the given name
+func FindDietaryComponent(name string) (*DietaryComponent, bool) {
+	for i := range DietaryComponents {
+		if DietaryComponents[i].Name == name {
+			return &DietaryComponents[i], true
+		}
+	}
+	return nil, false
+}
+
+// Excludes reports whether an ingredient name is on the component's exception list
+func (c *DietaryComponent) Excludes(ingredient string) bool {
+	name := strings.ToLower(ingredient)
+	for _, except := range c.Except {
+		if strings.Contains(name, except) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether an ingredient name contains one of the component's
+// keywords, ignoring names on its exception list
+func (c *DietaryComponent) Matches(ingredient string) bool {
+	if c.Excludes(ingredient) {
+		return false
+	}
+	name := strings.ToLower(ingredient)
+	for _, keyword := range c.Keywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindDietaryRestriction returns the restriction a user term names, matching
+// the name or an alias (e.g. "ビーガン" and "Vegan" both name vegan)
+func FindDietaryRestriction(term string) (*DietaryRestriction, bool) {
+	term = strings.ToLower(strings.TrimSpace(term))
+	if term == "" {
+		return nil, false
+	}
+	for i := range DietaryRestrictions {
+		if DietaryRestrictions[i].Name == term {
+			return &DietaryRestrictions[i], true
+		}
+		for _, alias := range DietaryRestrictions[i].Aliases {
+			if alias == term {
+				return &DietaryRestrictions[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// SplitDietaryRestrictions separates a restriction list into the canonical
+// names of known restrictions, in catalogue order, and the remaining terms,
+// which can only be passed on to generation as free text
+func SplitDietaryRestrictions(restrictions []string) (known []string, others []string) {
+	present := make(map[string]bool)
+	for _, term := range restrictions {
+		if restriction, ok := FindDietaryRestriction(term); ok {
+			present[restriction.Name] = true
+		} else if term = strings.TrimSpace(term); term != "" {
+			others = append(others, term)
+		}
+	}
+	for _, restriction := range DietaryRestrictions {
+		if present[restriction.Name] {
+			known = append(known, restriction.Name)
+		}
+	}
+	return known, MergeUnique(others)
+}
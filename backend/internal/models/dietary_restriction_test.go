@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDietaryRestriction(t *testing.T) {
+	for term, want := range map[string]string{
+		"Vegan":       "vegan",
+		"ビーガン":        "vegan",
+		"ハラール":        "halal",
+		"gluten_free": "gluten-free",
+		"減塩":          "low-sodium",
+	} {
+		restriction, ok := FindDietaryRestriction(term)
+		if assert.True(t, ok, term) {
+			assert.Equal(t, want, restriction.Name, term)
+		}
+	}
+
+	_, ok := FindDietaryRestriction("低糖質")
+	assert.False(t, ok)
+
+	known, others := SplitDietaryRestrictions([]string{"halal", "低糖質", "ベジタリアン", "vegetarian"})
+	assert.Equal(t, []string{"vegetarian", "halal"}, known)
+	assert.Equal(t, []string{"低糖質"}, others)
+}
+
+func TestDietaryComponent_Matches(t *testing.T) {
+	fish, _ := FindDietaryComponent(DietaryComponentFish)
+	assert.True(t, fish.Matches("かつおだし"))
+	assert.False(t, fish.Matches("昆布だし"))
+	assert.False(t, fish.Matches("すいか"))
+
+	meat, _ := FindDietaryComponent(DietaryComponentMeat)
+	assert.True(t, meat.Matches("固形コンソメ"))
+	assert.False(t, meat.Matches("牛乳"))
+	assert.False(t, meat.Matches("大豆ミート"))
+
+	salt, _ := FindDietaryComponent(DietaryComponentSalt)
+	assert.True(t, salt.Matches("しょうゆ"))
+	assert.False(t, salt.Matches("減塩しょうゆ"))
+}
@@ -128,6 +128,7 @@ type DiverseGenerationResponse struct {
 	CoverageImpact CoverageImpact `json:"coverage_impact"`
 	EstimatedCost  float64        `json:"estimated_cost"`
 	Recipes        []RecipeData   `json:"recipes,omitempty"`
+	StoppedReason  string         `json:"stopped_reason,omitempty"` // Set when the budget gate ended generation early
 }
 
 // CoverageImpact shows how generation affected coverage
@@ -17,6 +17,8 @@ var (
 	ErrEmptyShoppingList   = errors.New("shopping list cannot be empty")
 	ErrNoDailyRecipes      = errors.New("meal plan must have daily recipes")
 	ErrInsufficientRecipes = errors.New("meal plan must have at least 3 days of recipes")
+	ErrMealDayNotPlanned   = errors.New("no recipe planned for that day")
+	ErrMealAlreadyCooked   = errors.New("meal already marked as cooked")
 )
 
 // Database errors
@@ -24,6 +26,8 @@ var (
 	ErrRecipeNotFound     = errors.New("recipe not found")
 	ErrMealPlanNotFound   = errors.New("meal plan not found")
 	ErrUserNotFound       = errors.New("user preferences not found")
+	ErrPantryItemNotFound = errors.New("pantry item not found")
+	ErrIngredientNotFound = errors.New("ingredient not found")
 	ErrDatabaseConnection = errors.New("failed to connect to database")
 	ErrInvalidJSON        = errors.New("invalid JSON data")
 )
@@ -49,6 +53,9 @@ var (
 	ErrInvalidSeason     = errors.New("invalid season, must be spring, summer, fall, winter, or all")
 	ErrInvalidDifficulty = errors.New("invalid difficulty, must be easy, medium, or hard")
 	ErrInvalidSkillLevel = errors.New("invalid skill level, must be beginner, intermediate, or advanced")
+	ErrInvalidPreference = errors.New("invalid preference value")
+	ErrInvalidPantryDate = errors.New("invalid pantry date, must be YYYY-MM-DD with expiry after purchase")
+	ErrInvalidPrice      = errors.New("invalid price, must be a non-negative price for a measurable quantity")
 )
 
 // Diversity system errors
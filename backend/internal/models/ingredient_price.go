@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultItemCost is the cost in yen assumed for a shopping item without a known price
+const DefaultItemCost = 200
+
+// IngredientPrice is the price of a specific ingredient per base unit
+type IngredientPrice struct {
+	IngredientID int       `json:"ingredient_id" db:"ingredient_id"`
+	Ingredient   string    `json:"ingredient"`
+	BaseUnit     string    `json:"base_unit" db:"base_unit"`           // g, ml, 個, ...
+	PricePerUnit float64   `json:"price_per_unit" db:"price_per_unit"` // Yen per base unit
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// IngredientPriceRequest sets a price from a shop price and the quantity it buys
+type IngredientPriceRequest struct {
+	Price    float64 `json:"price" binding:"min=0"`       // Yen
+	Quantity string  `json:"quantity" binding:"required"` // e.g. "100g", "10個", "1l"
+}
+
+// BudgetCheck compares a plan's estimated cost with the weekly budget
+type BudgetCheck struct {
+	BudgetPerWeek int          `json:"budget_per_week"`
+	TotalCost     int          `json:"total_cost"`
+	Remaining     int          `json:"remaining"` // Negative when over budget
+	WithinBudget  bool         `json:"within_budget"`
+	OverBy        int          `json:"over_by,omitempty"`
+	Explanation   string       `json:"explanation,omitempty"` // Why the plan could not fit the budget
+	Swaps         []BudgetSwap `json:"swaps,omitempty"`       // Substitutions that would lower the cost
+}
+
+// BudgetSwap suggests replacing an ingredient with a cheaper one from the same group
+type BudgetSwap struct {
+	Ingredient string   `json:"ingredient"`
+	Substitute string   `json:"substitute"`
+	Recipes    []string `json:"recipes,omitempty"` // Plan recipes that use the ingredient
+	Savings    int      `json:"savings"`           // Yen
+}
+
+// NewBudgetCheck checks a total cost against a weekly budget
+func NewBudgetCheck(budgetPerWeek, totalCost int) *BudgetCheck {
+	check := &BudgetCheck{
+		BudgetPerWeek: budgetPerWeek,
+		TotalCost:     totalCost,
+		Remaining:     budgetPerWeek - totalCost,
+		WithinBudget:  totalCost <= budgetPerWeek,
+	}
+	if !check.WithinBudget {
+		check.OverBy = totalCost - budgetPerWeek
+	}
+	return check
+}
@@ -11,13 +11,18 @@ type ShoppingItem struct {
 	Amount   string `json:"amount" binding:"required"`
 	Cost     int    `json:"cost,omitempty"`     // Cost in yen
 	Category string `json:"category,omitempty"` // "meat", "vegetable", "seasoning", etc.
+
+	PantryCovered string `json:"pantry_covered,omitempty"` // Part of the need already in the pantry
+	CostEstimated bool   `json:"cost_estimated,omitempty"` // Cost is the flat default, no price is known
 }
 
 // DailyRecipe represents a recipe assignment for a specific day
 type DailyRecipe struct {
-	RecipeID int    `json:"recipe_id" binding:"required"`
-	Title    string `json:"title" binding:"required"`
-	Day      string `json:"day,omitempty"` // monday, tuesday, etc.
+	RecipeID int        `json:"recipe_id" binding:"required"`
+	Title    string     `json:"title" binding:"required"`
+	Day      string     `json:"day,omitempty"`       // monday, tuesday, etc.
+	CookedAt *time.Time `json:"cooked_at,omitempty"` // Set once the meal is cooked and the pantry decremented
+	Cost     int        `json:"cost,omitempty"`      // Ingredient cost of the recipe in yen
 }
 
 // MealPlan represents a weekly meal plan
@@ -31,6 +36,7 @@ type MealPlan struct {
 // CreateMealPlanRequest represents a meal plan creation request
 type CreateMealPlanRequest struct {
 	StartDate   string              `json:"start_date"`
+	UserID      string              `json:"user_id,omitempty"` // Loads stored user preferences when set
 	Preferences MealPlanPreferences `json:"preferences"`
 }
 
@@ -42,19 +48,26 @@ type MealPlanPreferences struct {
 	BudgetPerWeek       int      `json:"budget_per_week"`
 	HouseholdSize       int      `json:"household_size"`
 	DietaryRestrictions []string `json:"dietary_restrictions"`
+	Allergies           []string `json:"allergies,omitempty"` // Allergens (e.g. 卵, 小麦) or other ingredients to avoid, including as hidden ingredients
+
+	// OptimizeIngredientReuse picks recipes so perishables bought early in the week get used up
+	OptimizeIngredientReuse bool `json:"optimize_ingredient_reuse"`
 }
 
 // SearchCriteria represents recipe search criteria
 type SearchCriteria struct {
-	Query            string   `json:"query" form:"query"` // General search query (title, ingredient)
-	Tags             []string `json:"tags" form:"tags"`
-	Ingredients      []string `json:"ingredients" form:"ingredients"`
-	MaxCookingTime   int      `json:"max_cooking_time" form:"max_cooking_time"`
-	MinLazinessScore float64  `json:"min_laziness_score" form:"min_laziness_score"`
-	Season           string   `json:"season" form:"season"`
-	Limit            int      `json:"limit" form:"limit"`
-	Offset           int      `json:"offset" form:"offset"`
-	Page             int      `json:"page" form:"page"` // Page number (alternative to offset)
+	Query               string   `json:"query" form:"query"` // General search query (title, ingredient)
+	Tags                []string `json:"tags" form:"tags"`
+	Ingredients         []string `json:"ingredients" form:"ingredients"`
+	MaxCookingTime      int      `json:"max_cooking_time" form:"max_cooking_time"`
+	MinLazinessScore    float64  `json:"min_laziness_score" form:"min_laziness_score"`
+	Season              string   `json:"season" form:"season"`
+	Allergies           []string `json:"allergies" form:"allergies"`                       // Allergens or ingredients recipes must not contain
+	DietaryRestrictions []string `json:"dietary_restrictions" form:"dietary_restrictions"` // Restrictions recipes must comply with
+	UserID              string   `json:"user_id" form:"user_id"`                           // Adds the user's stored allergies and dietary restrictions
+	Limit               int      `json:"limit" form:"limit"`
+	Offset              int      `json:"offset" form:"offset"`
+	Page                int      `json:"page" form:"page"` // Page number (alternative to offset)
 
 	// Legacy fields for backward compatibility
 	Tag        string `json:"tag" form:"tag"`
@@ -64,11 +77,15 @@ type SearchCriteria struct {
 // MealPlanData holds the JSON-stored meal plan information
 type MealPlanData struct {
 	StartDate         string                 `json:"start_date" binding:"required"`
+	UserID            string                 `json:"user_id,omitempty"`        // Owner, whose pantry the plan draws on
+	HouseholdSize     int                    `json:"household_size,omitempty"` // People the quantities are scaled for; 0 means as written
 	ShoppingList      []ShoppingItem         `json:"shopping_list" binding:"required"`
 	DailyRecipes      map[string]DailyRecipe `json:"daily_recipes" binding:"required"`
 	TotalCostEstimate int                    `json:"total_cost_estimate"`
+	Budget            *BudgetCheck           `json:"budget,omitempty"` // Set when a weekly budget is given
 	WeekTheme         string                 `json:"week_theme,omitempty"`
 	IngredientReuse   map[string][]string    `json:"ingredient_reuse,omitempty"` // ingredient -> days used
+	WasteScore        float64                `json:"waste_score"`                // % of purchased perishables expected to spoil
 	NutritionSummary  *WeekNutritionSummary  `json:"nutrition_summary,omitempty"`
 }
 
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// PantryDateFormat is the layout of pantry purchase and expiry dates
+const PantryDateFormat = "2006-01-02"
+
+// PantryItem represents an ingredient a user already has at home
+type PantryItem struct {
+	ID           int       `json:"id" db:"id"`
+	UserID       string    `json:"user_id" db:"user_id"`
+	Ingredient   string    `json:"ingredient" db:"ingredient"`
+	Amount       float64   `json:"amount" db:"amount"` // In Unit, normalized to g, ml or 個 where possible
+	Unit         string    `json:"unit" db:"unit"`
+	Quantity     string    `json:"quantity"` // Display form, e.g. "1.5kg"
+	PurchaseDate string    `json:"purchase_date,omitempty" db:"purchase_date"`
+	ExpiryDate   string    `json:"expiry_date,omitempty" db:"expiry_date"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PantryItemRequest represents a request to add or replace a pantry item
+type PantryItemRequest struct {
+	Ingredient   string `json:"ingredient" binding:"required"`
+	Quantity     string `json:"quantity" binding:"required"` // e.g. "200g", "1/2個", "適量"
+	PurchaseDate string `json:"purchase_date,omitempty"`
+	ExpiryDate   string `json:"expiry_date,omitempty"`
+}
+
+// Validate validates the pantry item request
+func (r *PantryItemRequest) Validate() error {
+	if r.Ingredient == "" || r.Quantity == "" {
+		return ErrMissingParameters
+	}
+	for _, date := range []string{r.PurchaseDate, r.ExpiryDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(PantryDateFormat, date); err != nil {
+			return ErrInvalidPantryDate
+		}
+	}
+	if r.PurchaseDate != "" && r.ExpiryDate != "" && r.ExpiryDate < r.PurchaseDate {
+		return ErrInvalidPantryDate
+	}
+	return nil
+}
+
+// IsExpired reports whether the item expired before the given day
+func (p *PantryItem) IsExpired(today time.Time) bool {
+	return p.ExpiryDate != "" && p.ExpiryDate < today.Format(PantryDateFormat)
+}
+
+// PantryConsumption records how much of an ingredient cooking took from the pantry
+type PantryConsumption struct {
+	Ingredient string `json:"ingredient"`
+	Consumed   string `json:"consumed"`
+	Shortfall  string `json:"shortfall,omitempty"` // Needed but not in the pantry
+}
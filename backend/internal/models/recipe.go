@@ -156,7 +156,9 @@ type RecipeData struct {
 	NutritionInfo *NutritionInfo `json:"nutrition_info,omitempty"`
 	ServingSize   FlexibleInt    `json:"serving_size" binding:"min=1"`
 	Difficulty    string         `json:"difficulty,omitempty" binding:"omitempty,oneof=easy medium hard"`
-	TotalCost     int            `json:"total_cost,omitempty"` // Cost in yen
+	TotalCost     int            `json:"total_cost,omitempty"`    // Cost in yen
+	Allergens     []string       `json:"allergens,omitempty"`     // Computed from the ingredients, see AllergenService
+	DietaryFlags  []string       `json:"dietary_flags,omitempty"` // Restrictions the recipe complies with, see DietaryRestrictionService
 }
 
 // RecipeSchema defines the JSON Schema for Structured Outputs
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -44,6 +45,25 @@ func (u *UserPreferencesData) Validate() error {
 		u.MealPlanLength = 7 // Default to 1 week
 	}
 
+	// Reject out-of-range values that bypassed request binding (e.g. PATCH)
+	if u.MaxCookingTime > 120 {
+		return fmt.Errorf("%w: max_cooking_time must be at most 120", ErrInvalidPreference)
+	}
+	if u.BudgetPerWeek < 100 {
+		return fmt.Errorf("%w: budget_per_week must be at least 100", ErrInvalidPreference)
+	}
+	if u.HouseholdSize > 10 {
+		return fmt.Errorf("%w: household_size must be at most 10", ErrInvalidPreference)
+	}
+	if u.MealPlanLength > 14 {
+		return fmt.Errorf("%w: meal_plan_length must be at most 14", ErrInvalidPreference)
+	}
+	switch u.CookingSkillLevel {
+	case "", "beginner", "intermediate", "advanced":
+	default:
+		return ErrInvalidSkillLevel
+	}
+
 	// Validate preferred seasons
 	validSeasons := map[string]bool{
 		"spring": true,
@@ -120,6 +140,20 @@ func (u *UserPreferencesData) GetCookingTimeMultiplier() float64 {
 	}
 }
 
+// AdjustedMaxCookingTime returns the stored cooking time limit scaled to the
+// recipe times a cook at the user's skill level can finish in, or 0 when no
+// limit is stored
+func (u *UserPreferencesData) AdjustedMaxCookingTime() int {
+	if u.MaxCookingTime <= 0 {
+		return 0
+	}
+	adjusted := int(float64(u.MaxCookingTime) / u.GetCookingTimeMultiplier())
+	if adjusted < 1 {
+		adjusted = 1
+	}
+	return adjusted
+}
+
 // HasKitchenEquipment checks if specific equipment is available
 func (u *UserPreferencesData) HasKitchenEquipment(equipment string) bool {
 	for _, item := range u.KitchenEquipment {
@@ -163,10 +197,10 @@ func (u *UserPreferencesData) UpdateFromRequest(update UserPreferencesData) {
 	if update.MaxCookingTime > 0 {
 		u.MaxCookingTime = update.MaxCookingTime
 	}
-	if len(update.ExcludeIngredients) > 0 {
+	if update.ExcludeIngredients != nil {
 		u.ExcludeIngredients = update.ExcludeIngredients
 	}
-	if len(update.PreferredTags) > 0 {
+	if update.PreferredTags != nil {
 		u.PreferredTags = update.PreferredTags
 	}
 	if update.BudgetPerWeek > 0 {
@@ -175,25 +209,65 @@ func (u *UserPreferencesData) UpdateFromRequest(update UserPreferencesData) {
 	if update.HouseholdSize > 0 {
 		u.HouseholdSize = update.HouseholdSize
 	}
-	if len(update.DietaryRestrictions) >= 0 { // Allow empty slice to clear restrictions
+	if update.DietaryRestrictions != nil { // An explicit empty slice clears the list
 		u.DietaryRestrictions = update.DietaryRestrictions
 	}
-	if len(update.PreferredSeasons) > 0 {
+	if update.PreferredSeasons != nil {
 		u.PreferredSeasons = update.PreferredSeasons
 	}
 	if update.CookingSkillLevel != "" {
 		u.CookingSkillLevel = update.CookingSkillLevel
 	}
-	if len(update.KitchenEquipment) >= 0 {
+	if update.KitchenEquipment != nil {
 		u.KitchenEquipment = update.KitchenEquipment
 	}
-	if len(update.AllergyInfo) >= 0 {
+	if update.AllergyInfo != nil {
 		u.AllergyInfo = update.AllergyInfo
 	}
-	if len(update.FavoriteIngredients) >= 0 {
+	if update.FavoriteIngredients != nil {
 		u.FavoriteIngredients = update.FavoriteIngredients
 	}
 	if update.MealPlanLength > 0 {
 		u.MealPlanLength = update.MealPlanLength
 	}
 }
+
+// ApplyToMealPlan fills meal plan preferences from the stored user preferences.
+// Values set on the request win; exclusions, allergies and restrictions are merged,
+// and a stored cooking time limit is scaled by the user's skill level.
+func (u *UserPreferencesData) ApplyToMealPlan(prefs MealPlanPreferences) MealPlanPreferences {
+	if prefs.MaxCookingTime <= 0 {
+		prefs.MaxCookingTime = u.AdjustedMaxCookingTime()
+	}
+	if len(prefs.PreferredTags) == 0 {
+		prefs.PreferredTags = u.PreferredTags
+	}
+	if prefs.BudgetPerWeek <= 0 {
+		prefs.BudgetPerWeek = u.BudgetPerWeek
+	}
+	if prefs.HouseholdSize <= 0 {
+		prefs.HouseholdSize = u.HouseholdSize
+	}
+
+	prefs.ExcludeIngredients = MergeUnique(prefs.ExcludeIngredients, u.ExcludeIngredients, u.AllergyInfo)
+	prefs.Allergies = MergeUnique(prefs.Allergies, u.AllergyInfo)
+	prefs.DietaryRestrictions = MergeUnique(prefs.DietaryRestrictions, u.DietaryRestrictions)
+
+	return prefs
+}
+
+// MergeUnique concatenates string lists, dropping duplicates and empty values
+func MergeUnique(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	merged := []string{}
+	for _, list := range lists {
+		for _, value := range list {
+			if value == "" || seen[value] {
+				continue
+			}
+			seen[value] = true
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
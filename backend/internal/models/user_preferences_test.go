@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserPreferencesData_UpdateFromRequest(t *testing.T) {
+	prefs := GetDefaultPreferences()
+	prefs.ExcludeIngredients = []string{"セロリ"}
+	prefs.AllergyInfo = []string{"えび"}
+
+	// Absent lists are kept, explicit empty lists clear
+	prefs.UpdateFromRequest(UserPreferencesData{HouseholdSize: 3, AllergyInfo: []string{}})
+
+	assert.Equal(t, 3, prefs.HouseholdSize)
+	assert.Equal(t, []string{"セロリ"}, prefs.ExcludeIngredients)
+	assert.Empty(t, prefs.AllergyInfo)
+}
+
+func TestUserPreferencesData_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*UserPreferencesData)
+		err    error
+	}{
+		{"defaults", func(*UserPreferencesData) {}, nil},
+		{"cooking time too long", func(u *UserPreferencesData) { u.MaxCookingTime = 121 }, ErrInvalidPreference},
+		{"budget too small", func(u *UserPreferencesData) { u.BudgetPerWeek = 50 }, ErrInvalidPreference},
+		{"household too large", func(u *UserPreferencesData) { u.HouseholdSize = 11 }, ErrInvalidPreference},
+		{"plan too long", func(u *UserPreferencesData) { u.MealPlanLength = 15 }, ErrInvalidPreference},
+		{"unknown skill level", func(u *UserPreferencesData) { u.CookingSkillLevel = "chef" }, ErrInvalidSkillLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := GetDefaultPreferences()
+			tt.modify(&prefs)
+			err := prefs.Validate()
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tt.err), "got %v", err)
+			}
+		})
+	}
+}
+
+func TestUserPreferencesData_ApplyToMealPlan(t *testing.T) {
+	stored := UserPreferencesData{
+		MaxCookingTime:      30,
+		ExcludeIngredients:  []string{"セロリ"},
+		AllergyInfo:         []string{"えび", "セロリ"},
+		DietaryRestrictions: []string{"vegetarian"},
+		PreferredTags:       []string{"簡単"},
+		BudgetPerWeek:       4000,
+		HouseholdSize:       2,
+		CookingSkillLevel:   "beginner",
+	}
+
+	merged := stored.ApplyToMealPlan(MealPlanPreferences{
+		ExcludeIngredients: []string{"パクチー"},
+		HouseholdSize:      4,
+	})
+
+	assert.Equal(t, 20, merged.MaxCookingTime) // 30 minutes for a beginner
+	assert.Equal(t, []string{"パクチー", "セロリ", "えび"}, merged.ExcludeIngredients)
+	assert.Equal(t, []string{"えび", "セロリ"}, merged.Allergies)
+	assert.Equal(t, []string{"vegetarian"}, merged.DietaryRestrictions)
+	assert.Equal(t, []string{"簡単"}, merged.PreferredTags)
+	assert.Equal(t, 4000, merged.BudgetPerWeek)
+	assert.Equal(t, 4, merged.HouseholdSize)
+
+	// Explicit request values are not rescaled
+	merged = stored.ApplyToMealPlan(MealPlanPreferences{MaxCookingTime: 10})
+	assert.Equal(t, 10, merged.MaxCookingTime)
+}
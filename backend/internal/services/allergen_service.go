@@ -0,0 +1,169 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// AllergenService works out which allergens (特定原材料 and the items
+// recommended alongside them) a recipe contains. Ingredients are matched
+// against the allergen keywords and resolved through the ingredient hierarchy,
+// so a compound ingredient like マヨネーズ contributes 卵.
+type AllergenService struct {
+	db     *database.Database
+	labels ingredientLabelCache
+}
+
+// NewAllergenService creates a new allergen service
+func NewAllergenService(db *database.Database) *AllergenService {
+	return &AllergenService{db: db}
+}
+
+// EnsureSchema creates the ingredient_allergens table. Until it holds rows for
+// an ingredient or one of its groups, allergens are found by keyword only.
+// Each row sets only one of ingredient_id and group_id, and NULLs never
+// collide in a UNIQUE constraint, so uniqueness is enforced by a partial
+// index per column. Duplicates left by the old constraint are dropped first.
+func (s *AllergenService) EnsureSchema() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS ingredient_allergens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ingredient_id INTEGER,
+			group_id INTEGER,
+			allergen TEXT NOT NULL,
+			FOREIGN KEY (ingredient_id) REFERENCES specific_ingredients(id) ON DELETE CASCADE,
+			FOREIGN KEY (group_id) REFERENCES ingredient_groups(id) ON DELETE CASCADE,
+			CHECK ((ingredient_id IS NULL) != (group_id IS NULL)),
+			CHECK (allergen != '')
+		);
+		DELETE FROM ingredient_allergens WHERE id NOT IN (
+			SELECT MIN(id) FROM ingredient_allergens GROUP BY ingredient_id, group_id, allergen
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_allergens_ingredient
+			ON ingredient_allergens(ingredient_id, allergen) WHERE ingredient_id IS NOT NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_allergens_group
+			ON ingredient_allergens(group_id, allergen) WHERE group_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_ingredient_allergens_allergen ON ingredient_allergens(allergen);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create ingredient_allergens: %w", err)
+	}
+	return nil
+}
+
+// AllergenTable maps ingredient names and aliases from the hierarchy to the
+// allergens they contain. The zero value matches keywords only.
+type AllergenTable struct {
+	labels ingredientLabels
+}
+
+// LoadTable loads the allergens of every specific ingredient, including those
+// inherited from its groups and their ancestors, keyed by name and each alias
+func (s *AllergenService) LoadTable() (*AllergenTable, error) {
+	if s == nil {
+		return &AllergenTable{}, nil
+	}
+	labels, err := s.labels.load(s.db, "ingredient_allergens", "allergen")
+	if err != nil {
+		return nil, err
+	}
+	return &AllergenTable{labels: labels}, nil
+}
+
+// loadTable loads the allergen table, logging and falling back to keyword
+// matching when the hierarchy is unavailable
+func (s *AllergenService) loadTable() *AllergenTable {
+	table, err := s.LoadTable()
+	if err != nil {
+		log.Printf("Warning: ingredient allergens unavailable, matching keywords only: %v", err)
+		return &AllergenTable{}
+	}
+	return table
+}
+
+// IngredientAllergens returns the allergens in one ingredient: those its name
+// mentions, plus those of the hierarchy ingredients it names or contains.
+// Contained names honour the allergen exceptions, so 焼きそば is not そば.
+func (t *AllergenTable) IngredientAllergens(ingredient string) []string {
+	var allergens []string
+	for i := range models.Allergens {
+		if models.Allergens[i].Matches(ingredient) {
+			allergens = append(allergens, models.Allergens[i].Name)
+		}
+	}
+
+	allergens = append(allergens, t.labels.lookup(ingredient, func(allergen, name string) bool {
+		catalogued, ok := models.FindAllergen(allergen)
+		return ok && catalogued.Excludes(name)
+	})...)
+	return models.SortAllergens(allergens)
+}
+
+// RecipeAllergens returns the allergens in any of a recipe's ingredients
+func (t *AllergenTable) RecipeAllergens(recipe *models.RecipeData) []string {
+	var allergens []string
+	for _, ingredient := range recipe.Ingredients {
+		allergens = append(allergens, t.IngredientAllergens(ingredient.Name)...)
+	}
+	return models.SortAllergens(allergens)
+}
+
+// Conflicts returns the entries of a user's allergy list that a recipe
+// violates. Allergy terms naming an allergen are checked against the
+// recipe's allergens; other terms must not appear in an ingredient name.
+func (t *AllergenTable) Conflicts(recipe *models.RecipeData, allergies []string) []string {
+	allergens, others := models.SplitAllergies(allergies)
+	contained := make(map[string]bool)
+	for _, allergen := range t.RecipeAllergens(recipe) {
+		contained[allergen] = true
+	}
+
+	var conflicts []string
+	for _, allergen := range allergens {
+		if contained[allergen] {
+			conflicts = append(conflicts, allergen)
+		}
+	}
+	for _, term := range others {
+		if recipeContainsAny(*recipe, []string{term}) {
+			conflicts = append(conflicts, term)
+		}
+	}
+	return conflicts
+}
+
+// RecipeAllergens computes a recipe's allergens with the current table
+func (s *AllergenService) RecipeAllergens(recipe *models.RecipeData) []string {
+	return s.loadTable().RecipeAllergens(recipe)
+}
+
+// HandleRecipeSaved stores the computed allergen list on a newly saved or
+// updated recipe; register it with Database.OnRecipeSaved
+func (s *AllergenService) HandleRecipeSaved(recipeID int) {
+	NewRecipeLabeler(s.db, s, nil).HandleRecipeSaved(recipeID)
+}
+
+// RefreshRecipes recomputes the allergen list of every stored recipe,
+// writing only those that changed, and returns how many were updated.
+// Run it at startup so recipes saved before the list existed, or before
+// the hierarchy changed, carry current allergens.
+func (s *AllergenService) RefreshRecipes() (int, error) {
+	return NewRecipeLabeler(s.db, s, nil).RefreshRecipes()
+}
+
+// update stores a recipe's current allergens, reporting whether they changed
+func (t *AllergenTable) update(recipe *models.RecipeData) bool {
+	allergens := t.RecipeAllergens(recipe)
+	if len(allergens) == 0 {
+		allergens = nil
+	}
+	if slices.Equal(allergens, recipe.Allergens) {
+		return false
+	}
+	recipe.Allergens = allergens
+	return true
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func TestAllergenTable_ResolvesThroughHierarchy(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	table, err := NewAllergenService(db).LoadTable()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"卵", "大豆"}, table.IngredientAllergens("マヨネーズ"))
+	assert.Equal(t, []string{"卵", "大豆"}, table.IngredientAllergens("マヨネーズ（カロリーハーフ）"))
+	assert.Equal(t, []string{"小麦", "大豆"}, table.IngredientAllergens("しょうゆ"))
+	assert.Equal(t, []string{"鶏肉"}, table.IngredientAllergens("鶏むね肉"))
+	assert.Equal(t, []string{"小麦", "そば"}, table.IngredientAllergens("そば"))
+	assert.Equal(t, []string{"小麦"}, table.IngredientAllergens("焼きそば麺"))
+	assert.Empty(t, table.IngredientAllergens("キャベツ"))
+
+	recipe := &models.RecipeData{Ingredients: []models.Ingredient{{Name: "ブロッコリー"}, {Name: "マヨネーズ"}}}
+	assert.Equal(t, []string{"卵"}, table.Conflicts(recipe, []string{"たまご", "えび"}))
+	assert.Equal(t, []string{"ブロッコリー"}, table.Conflicts(recipe, []string{"ブロッコリー"}))
+}
+
+func TestAllergenService_StoresAllergensOnSave(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	repo := NewRecipeRepository(db)
+	legacyID := insertTestRecipe(t, db, models.RecipeData{
+		Title:         "えびマヨ",
+		CookingTime:   10,
+		LazinessScore: 8.0,
+		Ingredients:   []models.Ingredient{{Name: "むきえび"}, {Name: "マヨネーズ"}},
+		Steps:         []string{"和える"},
+	})
+
+	service := NewAllergenService(db)
+	require.NoError(t, service.EnsureSchema())
+	db.OnRecipeSaved(service.HandleRecipeSaved)
+
+	// Recipes saved before the hook are labelled by a refresh
+	n, err := service.RefreshRecipes()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	stored, err := repo.GetRecipe(legacyID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"えび", "卵", "大豆"}, stored.Data.Allergens)
+
+	n, err = service.RefreshRecipes()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	id := insertTestRecipe(t, db, models.RecipeData{
+		Title:         "ざるそば",
+		CookingTime:   5,
+		LazinessScore: 9.0,
+		Ingredients:   []models.Ingredient{{Name: "そば"}, {Name: "めんつゆ"}, {Name: "ねぎ"}},
+		Steps:         []string{"ゆでる"},
+	})
+	stored, err = repo.GetRecipe(id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"小麦", "そば", "大豆"}, stored.Data.Allergens)
+}
+
+func TestAllergenService_EnsureSchemaDedupesRows(t *testing.T) {
+	db := setupSchemaDatabase(t)
+
+	// Tables created with UNIQUE (ingredient_id, group_id, allergen) accepted
+	// the same group allergen twice, because group rows have a NULL ingredient_id
+	_, err := db.Exec(`
+		CREATE TABLE ingredient_allergens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ingredient_id INTEGER,
+			group_id INTEGER,
+			allergen TEXT NOT NULL,
+			UNIQUE (ingredient_id, group_id, allergen)
+		);
+		INSERT INTO ingredient_allergens (group_id, allergen) VALUES (1, '鶏肉'), (1, '鶏肉'), (2, '鶏肉');
+		INSERT INTO ingredient_allergens (ingredient_id, allergen) VALUES (5, '卵'), (5, '卵');
+	`)
+	require.NoError(t, err)
+
+	service := NewAllergenService(db)
+	require.NoError(t, service.EnsureSchema())
+	require.NoError(t, service.EnsureSchema())
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM ingredient_allergens").Scan(&count))
+	assert.Equal(t, 3, count)
+
+	_, err = db.Exec("INSERT INTO ingredient_allergens (group_id, allergen) VALUES (1, '鶏肉')")
+	assert.Error(t, err)
+	_, err = db.Exec("INSERT INTO ingredient_allergens (ingredient_id, allergen) VALUES (5, '卵')")
+	assert.Error(t, err)
+	_, err = db.Exec("INSERT INTO ingredient_allergens (ingredient_id, allergen) VALUES (5, '小麦')")
+	assert.NoError(t, err)
+}
+
+func TestAllergenService_TableReloadsWhenHierarchyChanges(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	service := NewAllergenService(db)
+
+	table, err := service.LoadTable()
+	require.NoError(t, err)
+	assert.Empty(t, table.IngredientAllergens("キャベツ"))
+
+	// The cached table is reused until a hierarchy table changes
+	again, err := service.LoadTable()
+	require.NoError(t, err)
+	assert.Equal(t, reflect.ValueOf(table.labels.byName).Pointer(), reflect.ValueOf(again.labels.byName).Pointer())
+
+	_, err = db.Exec(`INSERT INTO ingredient_allergens (ingredient_id, allergen)
+		SELECT id, '大豆' FROM specific_ingredients WHERE name = 'キャベツ'`)
+	require.NoError(t, err)
+
+	table, err = service.LoadTable()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"大豆"}, table.IngredientAllergens("キャベツ"))
+}
+
+func TestRefreshStoredRecipe_DoesNotOverwriteConcurrentSave(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	id := insertTestRecipe(t, db, models.RecipeData{
+		Title: "えびマヨ", CookingTime: 10, LazinessScore: 8.0,
+		Ingredients: []models.Ingredient{{Name: "むきえび"}, {Name: "マヨネーズ"}},
+		Steps:       []string{"和える"},
+	})
+
+	// A PUT lands between the label hook's read and its write
+	changed, err := refreshStoredRecipe(db, id, func(recipe *models.RecipeData) bool {
+		_, err := db.Exec(`UPDATE recipes SET data = json_set(data, '$.title', 'えびチリ') WHERE id = ?`, id)
+		require.NoError(t, err)
+		recipe.Allergens = []string{"えび"}
+		return true
+	})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	stored, err := NewRecipeRepository(db).GetRecipe(id)
+	require.NoError(t, err)
+	assert.Equal(t, "えびチリ", stored.Data.Title)
+}
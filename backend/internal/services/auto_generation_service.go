@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"time"
@@ -359,9 +360,12 @@ type AutoGenerationResult struct {
 	GenerationSummary map[string]int         `json:"generation_summary"`
 	QualityReport     *QualityReport         `json:"quality_report,omitempty"`
 	AverageQuality    float64                `json:"average_quality"`
+	StoppedReason     string                 `json:"stopped_reason,omitempty"` // Set when the budget gate ended generation early
 }
 
-// GenerateAutoRecipes generates recipes automatically based on coverage gaps
+// GenerateAutoRecipes generates recipes automatically based on coverage gaps.
+// Generation stops early when the budget gate refuses a call; if nothing was
+// generated by then the gate's error is returned.
 func (s *AutoGenerationService) GenerateAutoRecipes(ctx context.Context, req AutoGenerationRequest) (*AutoGenerationResult, error) {
 	// Phase 2: Complete AI auto-generation implementation
 	if req.Strategy == "" {
@@ -418,6 +422,13 @@ func (s *AutoGenerationService) GenerateAutoRecipes(ctx context.Context, req Aut
 		result.TotalAttempts++
 
 		if err != nil {
+			if errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrRateLimited) {
+				if len(result.GeneratedRecipes) == 0 {
+					return nil, err
+				}
+				result.StoppedReason = err.Error()
+				break
+			}
 			result.FailedGenerations++
 			continue
 		}
@@ -535,7 +546,12 @@ func (s *AutoGenerationService) saveRecipeWithDimensions(recipe *models.Recipe,
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.db.NotifyRecipeSaved(recipe.ID)
+	return nil
 }
 
 // generateRandomCombinations creates random dimension combinations
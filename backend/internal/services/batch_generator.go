@@ -22,10 +22,11 @@ import (
 
 // BatchGenerationService handles OpenAI Batch API for cost-efficient mass generation
 type BatchGenerationService struct {
-	client           *openai.Client
+	provider         LLMProvider
 	config           *config.OpenAIConfig
 	db               *sql.DB
 	batchStoragePath string
+	tokenLimiter     *TokenRateLimiter // Optional; records batch usage in the ledger
 }
 
 // GenerationJob represents a batch generation job
@@ -91,15 +92,20 @@ type BatchError struct {
 }
 
 // NewBatchGenerationService creates a new batch generation service
-func NewBatchGenerationService(client *openai.Client, config *config.OpenAIConfig, db *sql.DB, storagePath string) *BatchGenerationService {
+func NewBatchGenerationService(provider LLMProvider, config *config.OpenAIConfig, db *sql.DB, storagePath string) *BatchGenerationService {
 	return &BatchGenerationService{
-		client:           client,
+		provider:         provider,
 		config:           config,
 		db:               db,
 		batchStoragePath: storagePath,
 	}
 }
 
+// SetTokenRateLimiter enables recording of batch token usage and cost
+func (s *BatchGenerationService) SetTokenRateLimiter(limiter *TokenRateLimiter) {
+	s.tokenLimiter = limiter
+}
+
 // SubmitBatchJob submits a new batch generation job
 func (s *BatchGenerationService) SubmitBatchJob(ctx context.Context, config BatchGenerationConfig) (*GenerationJob, error) {
 	jobID := uuid.New().String()
@@ -117,6 +123,13 @@ func (s *BatchGenerationService) SubmitBatchJob(ctx context.Context, config Batc
 		return nil, fmt.Errorf("invalid batch config: %w", err)
 	}
 
+	// Batch jobs are priced up front against the budgets
+	if s.tokenLimiter != nil {
+		if err := s.tokenLimiter.CheckBudget(ctx, s.estimateBatchCost(len(config.Requests), job)); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate JSONL file
 	inputFilePath, err := s.generateJSONLFile(job)
 	if err != nil {
@@ -140,7 +153,7 @@ func (s *BatchGenerationService) SubmitBatchJob(ctx context.Context, config Batc
 		},
 	}
 
-	batch, err := s.client.CreateBatch(ctx, batchReq)
+	batch, err := s.provider.CreateBatch(ctx, batchReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create batch: %w", err)
 	}
@@ -174,7 +187,7 @@ func (s *BatchGenerationService) GetJobStatus(ctx context.Context, jobID string)
 	}
 
 	// Check status with OpenAI
-	batch, err := s.client.RetrieveBatch(ctx, job.BatchID)
+	batch, err := s.provider.RetrieveBatch(ctx, job.BatchID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve batch from OpenAI: %w", err)
 	}
@@ -190,7 +203,7 @@ func (s *BatchGenerationService) GetJobStatus(ctx context.Context, jobID string)
 			job.CostData = &CostData{
 				TotalTokens: batch.RequestCounts.Total,
 				// Note: Batch API doesn't provide detailed token breakdown
-				EstimatedCostUSD: s.estimateBatchCost(batch.RequestCounts.Total, job.Config.Model),
+				EstimatedCostUSD: s.estimateBatchCost(batch.RequestCounts.Total, job),
 			}
 		}
 	}
@@ -215,7 +228,7 @@ func (s *BatchGenerationService) RetrieveBatchResults(ctx context.Context, jobID
 	}
 
 	// Get batch details from OpenAI
-	batch, err := s.client.RetrieveBatch(ctx, job.BatchID)
+	batch, err := s.provider.RetrieveBatch(ctx, job.BatchID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve batch: %w", err)
 	}
@@ -231,11 +244,32 @@ func (s *BatchGenerationService) RetrieveBatchResults(ctx context.Context, jobID
 	}
 
 	// Process results
-	recipes, err := s.processBatchOutput(outputFilePath, job)
+	recipes, usage, err := s.processBatchOutput(outputFilePath, job)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process batch output: %w", err)
 	}
 
+	// Results can be retrieved more than once; record the usage only the first time
+	if job.CostData == nil {
+		job.CostData = &CostData{}
+	}
+	if job.CostData.PromptTokens == 0 && usage.TotalTokens > 0 {
+		job.CostData.PromptTokens = usage.PromptTokens
+		job.CostData.CompletionTokens = usage.CompletionTokens
+		job.CostData.TotalTokens = usage.TotalTokens
+		if s.tokenLimiter != nil {
+			usageCtx := WithUsageSource(ctx, UsageSource{JobID: job.ID})
+			submittedAt := job.CreatedAt
+			if job.SubmittedAt != nil {
+				submittedAt = *job.SubmittedAt
+			}
+			job.CostData.ActualCostUSD = s.tokenLimiter.RecordBatchCall(usageCtx, s.provider.Name(), job.Config.Model, submittedAt, usage)
+		}
+		if err := s.saveJob(job); err != nil {
+			log.Printf("Warning: failed to save job usage: %v", err)
+		}
+	}
+
 	log.Printf("Retrieved %d recipes from batch job %s", len(recipes), jobID)
 	return recipes, nil
 }
@@ -252,7 +286,7 @@ func (s *BatchGenerationService) CancelBatchJob(ctx context.Context, jobID strin
 	}
 
 	// Cancel with OpenAI
-	_, err = s.client.CancelBatch(ctx, job.BatchID)
+	_, err = s.provider.CancelBatch(ctx, job.BatchID)
 	if err != nil {
 		return fmt.Errorf("failed to cancel batch: %w", err)
 	}
@@ -442,7 +476,7 @@ func (s *BatchGenerationService) uploadBatchFile(ctx context.Context, filePath s
 		Purpose:  "batch",
 	}
 
-	uploadedFile, err := s.client.CreateFile(ctx, req)
+	uploadedFile, err := s.provider.CreateFile(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload file: %w", err)
 	}
@@ -452,7 +486,7 @@ func (s *BatchGenerationService) uploadBatchFile(ctx context.Context, filePath s
 
 func (s *BatchGenerationService) downloadBatchOutput(ctx context.Context, fileID, jobID string) (string, error) {
 	// Get file content from OpenAI
-	content, err := s.client.GetFileContent(ctx, fileID)
+	content, err := s.provider.GetFileContent(ctx, fileID)
 	if err != nil {
 		return "", fmt.Errorf("failed to download file: %w", err)
 	}
@@ -480,10 +514,13 @@ func (s *BatchGenerationService) downloadBatchOutput(ctx context.Context, fileID
 	return outputPath, nil
 }
 
-func (s *BatchGenerationService) processBatchOutput(filePath string, job *GenerationJob) ([]*models.RecipeData, error) {
+// processBatchOutput parses the recipes in a batch output file and sums the
+// token usage of every response, including ones whose recipe fails to parse
+func (s *BatchGenerationService) processBatchOutput(filePath string, job *GenerationJob) ([]*models.RecipeData, openai.Usage, error) {
+	var usage openai.Usage
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open output file: %w", err)
+		return nil, usage, fmt.Errorf("failed to open output file: %w", err)
 	}
 	defer func() {
 		if err := file.Close(); err != nil {
@@ -506,6 +543,12 @@ func (s *BatchGenerationService) processBatchOutput(filePath string, job *Genera
 			continue
 		}
 
+		if response.Response != nil {
+			usage.PromptTokens += response.Response.Usage.PromptTokens
+			usage.CompletionTokens += response.Response.Usage.CompletionTokens
+			usage.TotalTokens += response.Response.Usage.TotalTokens
+		}
+
 		if response.Response == nil || len(response.Response.Choices) == 0 {
 			log.Printf("Warning: no response for batch request %s", response.CustomID)
 			continue
@@ -524,20 +567,37 @@ func (s *BatchGenerationService) processBatchOutput(filePath string, job *Genera
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan output file: %w", err)
+		return nil, usage, fmt.Errorf("failed to scan output file: %w", err)
 	}
 
-	return recipes, nil
+	return recipes, usage, nil
 }
 
-func (s *BatchGenerationService) estimateBatchCost(totalRequests int, model string) float64 {
-	// Rough cost estimation - this would need to be updated with actual pricing
-	costPerRequest := 0.001 // $0.001 per request (example)
-	if strings.Contains(model, "gpt-4") {
-		costPerRequest = 0.005
+// batchPromptTokensEstimate approximates the prompt size of one batch request
+const batchPromptTokensEstimate = 800
+
+// estimateBatchCost prices totalRequests at the job's model price in effect
+// when it was submitted, assuming each request uses its full completion budget
+func (s *BatchGenerationService) estimateBatchCost(totalRequests int, job *GenerationJob) float64 {
+	completionTokens := job.Config.MaxTokens
+	if completionTokens <= 0 {
+		completionTokens = s.config.MaxTokens
+	}
+	usage := openai.Usage{
+		PromptTokens:     totalRequests * batchPromptTokensEstimate,
+		CompletionTokens: totalRequests * completionTokens,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	priceAt := job.CreatedAt
+	if job.SubmittedAt != nil {
+		priceAt = *job.SubmittedAt
 	}
 
-	return float64(totalRequests) * costPerRequest * 0.5 // 50% discount for batch API
+	if s.tokenLimiter != nil {
+		return s.tokenLimiter.priceUsage(job.Config.Model, priceAt, usage, true)
+	}
+	return NewPricingTable(nil).Cost(job.Config.Model, priceAt, usage, true)
 }
 
 func (s *BatchGenerationService) saveJob(job *GenerationJob) error {
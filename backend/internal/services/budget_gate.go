@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrBudgetExceeded is returned by the budget gate when a call would
+	// overspend the global, endpoint or user budget
+	ErrBudgetExceeded = errors.New("budget exceeded")
+
+	// ErrRateLimited is returned by the budget gate when the token rate
+	// limit leaves no capacity within gateMaxWait
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// Budget scopes checked by the gate
+const (
+	BudgetScopeDaily    = "daily"
+	BudgetScopeMonthly  = "monthly"
+	BudgetScopeEndpoint = "endpoint"
+	BudgetScopeUser     = "user"
+	BudgetScopeTokens   = "tokens"
+)
+
+// gateMaxWait is how long Admit waits for token capacity before giving up
+const gateMaxWait = 5 * time.Second
+
+// BudgetError describes a call refused by the budget gate. It unwraps to
+// ErrBudgetExceeded or ErrRateLimited.
+type BudgetError struct {
+	Scope        string        `json:"scope"`
+	Key          string        `json:"key,omitempty"` // Endpoint or user ID for sub-budgets
+	LimitUSD     float64       `json:"limit_usd,omitempty"`
+	SpentUSD     float64       `json:"spent_usd,omitempty"`
+	RemainingUSD float64       `json:"remaining_usd"`
+	EstimatedUSD float64       `json:"estimated_usd,omitempty"`
+	RetryAfter   time.Duration `json:"retry_after,omitempty"`
+}
+
+func (e *BudgetError) Error() string {
+	if e.Scope == BudgetScopeTokens {
+		return fmt.Sprintf("%v: token capacity, retry after %s", ErrRateLimited, e.RetryAfter.Round(time.Second))
+	}
+	scope := e.Scope
+	if e.Key != "" {
+		scope += " " + e.Key
+	}
+	return fmt.Sprintf("%v: %s budget $%.4f, spent $%.4f, call needs ~$%.4f", ErrBudgetExceeded, scope,
+		e.LimitUSD, e.SpentUSD, e.EstimatedUSD)
+}
+
+func (e *BudgetError) Unwrap() error {
+	if e.Scope == BudgetScopeTokens {
+		return ErrRateLimited
+	}
+	return ErrBudgetExceeded
+}
+
+// BudgetAlert is sent to alert hooks the first time spend in a budget
+// period crosses the alert threshold
+type BudgetAlert struct {
+	Scope     string    `json:"scope"`
+	Key       string    `json:"key,omitempty"`
+	LimitUSD  float64   `json:"limit_usd"`
+	SpentUSD  float64   `json:"spent_usd"`
+	Threshold float64   `json:"threshold"`
+	At        time.Time `json:"at"`
+}
+
+// SubBudget is the daily spend of one endpoint or user against its budget
+type SubBudget struct {
+	Key          string  `json:"key"`
+	LimitUSD     float64 `json:"limit_usd"` // 0 means no limit
+	SpentUSD     float64 `json:"spent_usd"`
+	RemainingUSD float64 `json:"remaining_usd,omitempty"`
+}
+
+// BudgetStatus reports global and sub-budget spend for the current period
+type BudgetStatus struct {
+	CostTracker
+	DefaultUserBudgetUSD float64     `json:"default_user_daily_budget_usd"`
+	Endpoints            []SubBudget `json:"endpoints"`
+	Users                []SubBudget `json:"users"`
+}
+
+// OnBudgetAlert registers fn to be called when spend crosses AlertThreshold
+// of a daily, monthly, endpoint or user budget. Hooks run synchronously
+// after the call that crossed the threshold has been recorded.
+func (t *TokenRateLimiter) OnBudgetAlert(fn func(BudgetAlert)) {
+	t.costTrackerMu.Lock()
+	t.alertHooks = append(t.alertHooks, fn)
+	t.costTrackerMu.Unlock()
+}
+
+// SetEndpointBudget sets the daily budget for calls made while serving
+// endpoint (e.g. "POST /api/recipes/generate"); 0 removes it
+func (t *TokenRateLimiter) SetEndpointBudget(endpoint string, dailyUSD float64) {
+	t.costTrackerMu.Lock()
+	defer t.costTrackerMu.Unlock()
+	if dailyUSD <= 0 {
+		delete(t.endpointBudgets, endpoint)
+		return
+	}
+	t.endpointBudgets[endpoint] = dailyUSD
+}
+
+// SetUserBudget sets the daily budget for one user; 0 removes it, leaving
+// the default user budget in effect
+func (t *TokenRateLimiter) SetUserBudget(userID string, dailyUSD float64) {
+	t.costTrackerMu.Lock()
+	defer t.costTrackerMu.Unlock()
+	if dailyUSD <= 0 {
+		delete(t.userBudgets, userID)
+		return
+	}
+	t.userBudgets[userID] = dailyUSD
+}
+
+// SetDefaultUserBudget sets the daily budget for users without their own; 0 means unlimited
+func (t *TokenRateLimiter) SetDefaultUserBudget(dailyUSD float64) {
+	t.costTrackerMu.Lock()
+	t.defaultUserBudget = math.Max(0, dailyUSD)
+	t.costTrackerMu.Unlock()
+}
+
+// SetAlertThreshold sets the fraction of a budget at which alert hooks fire
+func (t *TokenRateLimiter) SetAlertThreshold(threshold float64) error {
+	if threshold <= 0 || threshold > 1 {
+		return fmt.Errorf("alert threshold must be in (0, 1], got %v", threshold)
+	}
+	t.costTrackerMu.Lock()
+	t.costTracker.AlertThreshold = threshold
+	t.costTrackerMu.Unlock()
+	return nil
+}
+
+// Admit is the budget gate every LLM call passes through. It refuses calls
+// whose estimated cost would overspend the daily, monthly, endpoint or user
+// budget, attributed via the UsageSource on ctx, and waits briefly for token
+// capacity. Refusals are *BudgetError values.
+func (t *TokenRateLimiter) Admit(ctx context.Context, estimate TokenUsageEstimate) error {
+	if err := t.CheckBudget(ctx, estimate.EstimatedCostUSD); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(gateMaxWait)
+	for !t.ReserveTokens(estimate) {
+		available := t.tokenBucket.getAvailableTokens()
+		retryAfter := t.calculateRetryAfter(estimate.EstimatedTotalTokens - available)
+		if estimate.EstimatedTotalTokens > t.tokenBucket.capacity || time.Now().Add(retryAfter).After(deadline) {
+			t.metricsMu.Lock()
+			t.metrics.TokensRejected += int64(estimate.EstimatedTotalTokens)
+			t.metricsMu.Unlock()
+			return &BudgetError{Scope: BudgetScopeTokens, RetryAfter: retryAfter, RemainingUSD: t.remainingDaily()}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+	return nil
+}
+
+// budgetCheck is one budget a call is measured against
+type budgetCheck struct {
+	scope, key   string
+	limit, spent float64
+}
+
+// budgetChecksLocked lists the budgets that apply to source. Callers hold costTrackerMu.
+func (t *TokenRateLimiter) budgetChecksLocked(source UsageSource) []budgetCheck {
+	checks := []budgetCheck{
+		{BudgetScopeDaily, "", t.costTracker.DailyBudgetUSD, t.costTracker.DailySpentUSD},
+		{BudgetScopeMonthly, "", t.costTracker.MonthlyBudgetUSD, t.costTracker.MonthlySpentUSD},
+	}
+	if source.Endpoint != "" {
+		checks = append(checks, budgetCheck{BudgetScopeEndpoint, source.Endpoint,
+			t.endpointBudgets[source.Endpoint], t.endpointSpent[source.Endpoint]})
+	}
+	if source.UserID != "" {
+		checks = append(checks, budgetCheck{BudgetScopeUser, source.UserID,
+			t.userBudgetLocked(source.UserID), t.userSpent[source.UserID]})
+	}
+	return checks
+}
+
+// CheckBudget is the budget half of Admit, for work priced up front such as
+// Batch API jobs
+func (t *TokenRateLimiter) CheckBudget(ctx context.Context, costUSD float64) error {
+	t.updateCostTracker()
+	if err := t.checkBudgets(UsageSourceFromContext(ctx), costUSD); err != nil {
+		t.metricsMu.Lock()
+		t.metrics.RequestsBlocked++
+		t.metricsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// checkBudgets returns a *BudgetError for the first budget cost would overspend
+func (t *TokenRateLimiter) checkBudgets(source UsageSource, cost float64) error {
+	t.costTrackerMu.RLock()
+	defer t.costTrackerMu.RUnlock()
+
+	for _, check := range t.budgetChecksLocked(source) {
+		if check.limit > 0 && check.spent+cost > check.limit {
+			return &BudgetError{
+				Scope:        check.scope,
+				Key:          check.key,
+				LimitUSD:     check.limit,
+				SpentUSD:     check.spent,
+				RemainingUSD: math.Max(0, check.limit-check.spent),
+				EstimatedUSD: cost,
+			}
+		}
+	}
+	return nil
+}
+
+// recordSubSpend adds cost to the endpoint and user on source and fires
+// alert hooks for any budget that crossed the threshold
+func (t *TokenRateLimiter) recordSubSpend(source UsageSource, cost float64) {
+	t.costTrackerMu.Lock()
+	if source.Endpoint != "" {
+		t.endpointSpent[source.Endpoint] += cost
+	}
+	if source.UserID != "" {
+		t.userSpent[source.UserID] += cost
+	}
+
+	threshold := t.costTracker.AlertThreshold
+	var alerts []BudgetAlert
+	for _, check := range t.budgetChecksLocked(source) {
+		alertKey := check.scope + ":" + check.key
+		if check.limit <= 0 || threshold <= 0 || check.spent < check.limit*threshold || t.alertsFired[alertKey] {
+			continue
+		}
+		t.alertsFired[alertKey] = true
+		alerts = append(alerts, BudgetAlert{
+			Scope:     check.scope,
+			Key:       check.key,
+			LimitUSD:  check.limit,
+			SpentUSD:  check.spent,
+			Threshold: threshold,
+			At:        time.Now(),
+		})
+	}
+	hooks := append([]func(BudgetAlert){}, t.alertHooks...)
+	t.costTrackerMu.Unlock()
+
+	for _, alert := range alerts {
+		log.Printf("Warning: %s budget %s at %.0f%% ($%.4f of $%.4f)", alert.Scope, alert.Key,
+			alert.SpentUSD/alert.LimitUSD*100, alert.SpentUSD, alert.LimitUSD)
+		for _, hook := range hooks {
+			hook(alert)
+		}
+	}
+}
+
+// resetSubBudgets clears daily sub-budget spend and alerts, and monthly
+// alerts when the month rolled over. Callers hold costTrackerMu.
+func (t *TokenRateLimiter) resetSubBudgets(monthly bool) {
+	t.endpointSpent = make(map[string]float64)
+	t.userSpent = make(map[string]float64)
+	for key := range t.alertsFired {
+		if monthly || key != BudgetScopeMonthly+":" {
+			delete(t.alertsFired, key)
+		}
+	}
+}
+
+// userBudgetLocked returns the daily budget for userID. Callers hold costTrackerMu.
+func (t *TokenRateLimiter) userBudgetLocked(userID string) float64 {
+	if budget, ok := t.userBudgets[userID]; ok {
+		return budget
+	}
+	return t.defaultUserBudget
+}
+
+func (t *TokenRateLimiter) remainingDaily() float64 {
+	t.costTrackerMu.RLock()
+	defer t.costTrackerMu.RUnlock()
+	if t.costTracker.DailyBudgetUSD <= 0 {
+		return 0
+	}
+	return math.Max(0, t.costTracker.DailyBudgetUSD-t.costTracker.DailySpentUSD)
+}
+
+// GetBudgetStatus returns global budgets and every endpoint and user that
+// has a budget or has spent today
+func (t *TokenRateLimiter) GetBudgetStatus() BudgetStatus {
+	t.updateCostTracker()
+	status := BudgetStatus{CostTracker: t.GetCostStatus()}
+
+	t.costTrackerMu.RLock()
+	defer t.costTrackerMu.RUnlock()
+	status.DefaultUserBudgetUSD = t.defaultUserBudget
+	status.Endpoints = subBudgets(t.endpointBudgets, t.endpointSpent, func(string) float64 { return 0 })
+	status.Users = subBudgets(t.userBudgets, t.userSpent, func(string) float64 { return t.defaultUserBudget })
+	return status
+}
+
+func subBudgets(limits, spent map[string]float64, fallback func(string) float64) []SubBudget {
+	keys := make(map[string]bool, len(limits)+len(spent))
+	for key := range limits {
+		keys[key] = true
+	}
+	for key := range spent {
+		keys[key] = true
+	}
+
+	result := make([]SubBudget, 0, len(keys))
+	for key := range keys {
+		limit, ok := limits[key]
+		if !ok {
+			limit = fallback(key)
+		}
+		sb := SubBudget{Key: key, LimitUSD: limit, SpentUSD: spent[key]}
+		if limit > 0 {
+			sb.RemainingUSD = math.Max(0, limit-sb.SpentUSD)
+		}
+		result = append(result, sb)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
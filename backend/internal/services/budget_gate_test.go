@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProvider counts chat completions reaching the wrapped provider
+type countingProvider struct {
+	*LocalProvider
+	calls int
+}
+
+func (p *countingProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	p.calls++
+	return p.LocalProvider.CreateChatCompletion(ctx, req)
+}
+
+func TestTokenRateLimiter_AdmitEnforcesGlobalAndSubBudgets(t *testing.T) {
+	limiter := NewTokenRateLimiter(60, 100000, 1.0, 10.0)
+	defer limiter.Stop()
+
+	estimate := TokenUsageEstimate{EstimatedTotalTokens: 10, EstimatedCostUSD: 0.4}
+	require.NoError(t, limiter.Admit(context.Background(), estimate))
+
+	usage := openai.Usage{PromptTokens: 1_000_000}
+	ctx := WithUsageSource(context.Background(), UsageSource{Endpoint: "POST /api/recipes/generate", UserID: "user-1"})
+	spent := limiter.RecordCall(ctx, "openai", "gpt-5-mini", "generation", usage)
+	require.Greater(t, spent, 0.0)
+
+	limiter.SetEndpointBudget("POST /api/recipes/generate", spent+0.1)
+	err := limiter.Admit(ctx, TokenUsageEstimate{EstimatedTotalTokens: 10, EstimatedCostUSD: 0.2})
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	var budgetErr *BudgetError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, BudgetScopeEndpoint, budgetErr.Scope)
+	assert.Equal(t, "POST /api/recipes/generate", budgetErr.Key)
+	assert.InDelta(t, 0.1, budgetErr.RemainingUSD, 1e-9)
+
+	// Other endpoints are unaffected, but the same user's default budget applies everywhere
+	other := WithUsageSource(context.Background(), UsageSource{Endpoint: "POST /api/admin/auto-generate", UserID: "user-1"})
+	require.NoError(t, limiter.Admit(other, TokenUsageEstimate{EstimatedTotalTokens: 10, EstimatedCostUSD: 0.2}))
+	limiter.SetDefaultUserBudget(spent)
+	err = limiter.Admit(other, TokenUsageEstimate{EstimatedTotalTokens: 10, EstimatedCostUSD: 0.01})
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, BudgetScopeUser, budgetErr.Scope)
+	assert.Equal(t, "user-1", budgetErr.Key)
+
+	// The global daily budget applies to anonymous calls too
+	err = limiter.Admit(context.Background(), TokenUsageEstimate{EstimatedTotalTokens: 10, EstimatedCostUSD: 1.0})
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, BudgetScopeDaily, budgetErr.Scope)
+
+	status := limiter.GetBudgetStatus()
+	require.Len(t, status.Endpoints, 1)
+	assert.InDelta(t, spent, status.Endpoints[0].SpentUSD, 1e-9)
+	require.Len(t, status.Users, 1)
+	assert.Equal(t, "user-1", status.Users[0].Key)
+}
+
+func TestTokenRateLimiter_AdmitRefusesWhenTokensUnavailable(t *testing.T) {
+	limiter := NewTokenRateLimiter(60, 10, 100.0, 1000.0)
+	defer limiter.Stop()
+
+	err := limiter.Admit(context.Background(), TokenUsageEstimate{EstimatedTotalTokens: 1_000_000})
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.NotErrorIs(t, err, ErrBudgetExceeded)
+	var budgetErr *BudgetError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Greater(t, budgetErr.RetryAfter.Seconds(), 0.0)
+}
+
+func TestTokenRateLimiter_BudgetAlertFiresOncePerPeriod(t *testing.T) {
+	limiter := NewTokenRateLimiter(60, 100000, 0, 0)
+	defer limiter.Stop()
+	require.Error(t, limiter.SetAlertThreshold(1.5))
+	require.NoError(t, limiter.SetAlertThreshold(0.5))
+
+	var alerts []BudgetAlert
+	limiter.OnBudgetAlert(func(alert BudgetAlert) { alerts = append(alerts, alert) })
+
+	ctx := WithUsageSource(context.Background(), UsageSource{UserID: "user-2"})
+	usage := openai.Usage{PromptTokens: 1_000_000}
+	cost := limiter.RecordCall(ctx, "openai", "gpt-5-mini", "generation", usage)
+	limiter.SetUserBudget("user-2", cost*3)
+
+	limiter.RecordCall(ctx, "openai", "gpt-5-mini", "generation", usage)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, BudgetScopeUser, alerts[0].Scope)
+	assert.Equal(t, "user-2", alerts[0].Key)
+
+	limiter.RecordCall(ctx, "openai", "gpt-5-mini", "generation", usage)
+	assert.Len(t, alerts, 1, "an alert fires once per period")
+}
+
+func TestMeteredProvider_RefusesBeforeCallingProvider(t *testing.T) {
+	limiter := NewTokenRateLimiter(60, 100000, 0.000001, 1000.0)
+	defer limiter.Stop()
+
+	inner := &countingProvider{LocalProvider: NewLocalProvider()}
+	provider := NewMeteredProvider(inner, limiter)
+
+	_, err := provider.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Model:     "gpt-5",
+		MaxTokens: 4000,
+		Messages:  []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "鶏肉を使ったレシピ"}},
+	})
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Zero(t, inner.calls)
+	assert.Equal(t, int64(1), limiter.GetMetrics().RequestsBlocked)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"lazychef/internal/models"
+)
+
+// cookingTimeBuckets are the cooking time limits cache keys are rounded down to
+var cookingTimeBuckets = []int{5, 10, 15, 20, 30, 45, 60, 90, 120}
+
+// CacheKeyNormalizer builds generation cache keys that are equal for
+// requests asking for the same thing: ingredient order is ignored, names
+// and aliases resolve to one canonical ingredient, and cooking times share
+// a bucket. Because a bucket covers several limits, callers must check a
+// cached recipe against the request's MaxCookingTime before serving it.
+type CacheKeyNormalizer struct {
+	resolver *IngredientResolver
+}
+
+// NewCacheKeyNormalizer creates a normalizer. With a nil resolver only
+// ordering, whitespace and cooking time are normalized.
+func NewCacheKeyNormalizer(resolver *IngredientResolver) *CacheKeyNormalizer {
+	return &CacheKeyNormalizer{resolver: resolver}
+}
+
+// Key returns the cache key for a single recipe request. Dietary
+// restrictions join the constraints under their canonical names, so
+// "ビーガン" and "vegan" share a key.
+func (n *CacheKeyNormalizer) Key(req RecipeGenerationRequest) string {
+	constraints := append([]string{}, req.Constraints...)
+	known, others := models.SplitDietaryRestrictions(req.DietaryRestrictions)
+	for _, restriction := range append(known, others...) {
+		constraints = append(constraints, "diet="+restriction)
+	}
+
+	return fmt.Sprintf("recipe:%s:%s:t%d:%d:%s:%s",
+		strings.Join(n.CanonicalIngredients(req.Ingredients), ","),
+		req.Season,
+		CookingTimeBucket(req.MaxCookingTime),
+		req.Servings,
+		strings.Join(normalizedSet(constraints), ","),
+		strings.Join(normalizedSet(req.Preferences), ","),
+	)
+}
+
+// BatchKey returns the cache key for a batch request
+func (n *CacheKeyNormalizer) BatchKey(req BatchGenerationRequest) string {
+	return fmt.Sprintf("batch:%d:%s", req.Count, strings.TrimPrefix(n.Key(req.RecipeGenerationRequest), "recipe:"))
+}
+
+// CanonicalIngredients resolves each ingredient to its canonical name
+// (the specific ingredient an alias belongs to, or the matched group) and
+// returns the distinct names sorted
+func (n *CacheKeyNormalizer) CanonicalIngredients(ingredients []string) []string {
+	terms := normalizedSet(ingredients)
+	if n.resolver == nil {
+		return terms
+	}
+
+	canonical := make([]string, 0, len(terms))
+	for _, match := range n.resolver.ResolveTerms(terms) {
+		switch {
+		case match.Source == IngredientMatchSourceIngredient && match.MatchedIngredient != "":
+			canonical = append(canonical, match.MatchedIngredient)
+		case match.Source == IngredientMatchSourceGroup && len(match.MatchedGroups) > 0:
+			canonical = append(canonical, match.MatchedGroups[0])
+		default:
+			canonical = append(canonical, match.Term)
+		}
+	}
+	return normalizedSet(canonical)
+}
+
+// CookingTimeBucket rounds a cooking time limit down to its bucket
+func CookingTimeBucket(minutes int) int {
+	bucket := minutes
+	for _, limit := range cookingTimeBuckets {
+		if minutes >= limit {
+			bucket = limit
+		}
+	}
+	return bucket
+}
+
+// normalizedSet trims, deduplicates and sorts values, dropping empty ones
+func normalizedSet(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// resultFitsRequest reports whether a cached result keeps within the
+// request's cooking time limit
+func resultFitsRequest(result *GenerationResult, maxCookingTime int) bool {
+	if maxCookingTime <= 0 {
+		return true
+	}
+	if result.Recipe != nil && result.Recipe.CookingTime > maxCookingTime {
+		return false
+	}
+	for _, recipe := range result.Recipes {
+		if recipe.CookingTime > maxCookingTime {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/config"
+	"lazychef/internal/models"
+)
+
+func TestCacheKeyNormalizer_EquivalentRequestsShareKey(t *testing.T) {
+	normalizer := NewCacheKeyNormalizer(NewIngredientResolver(setupHierarchyDatabase(t)))
+
+	base := RecipeGenerationRequest{
+		Ingredients:    []string{"鶏胸肉", "玉ねぎ"},
+		Season:         "all",
+		MaxCookingTime: 12,
+		Constraints:    []string{"辛くしない", "電子レンジのみ"},
+	}
+	same := base
+	same.Ingredients = []string{"玉ねぎ", " 鶏むね肉", "チキンブレスト"}
+	same.MaxCookingTime = 14
+	same.Constraints = []string{"電子レンジのみ", "辛くしない"}
+	assert.Equal(t, normalizer.Key(base), normalizer.Key(same))
+	assert.Equal(t, []string{"玉ねぎ", "鶏胸肉"}, normalizer.CanonicalIngredients(same.Ingredients))
+
+	longer := base
+	longer.MaxCookingTime = 20
+	assert.NotEqual(t, normalizer.Key(base), normalizer.Key(longer))
+
+	group := base
+	group.Ingredients = []string{"鶏肉", "玉ねぎ"}
+	assert.NotEqual(t, normalizer.Key(base), normalizer.Key(group), "a group is not the same request as one of its ingredients")
+
+	vegan := base
+	vegan.DietaryRestrictions = []string{"vegan"}
+	alias := base
+	alias.DietaryRestrictions = []string{"ビーガン"}
+	assert.Equal(t, normalizer.Key(vegan), normalizer.Key(alias))
+	assert.NotEqual(t, normalizer.Key(base), normalizer.Key(vegan))
+
+	assert.Equal(t, 3, CookingTimeBucket(3))
+	assert.Equal(t, 10, CookingTimeBucket(14))
+	assert.Equal(t, 120, CookingTimeBucket(120))
+}
+
+func TestRecipeGeneratorService_CacheHitMustFitCookingTime(t *testing.T) {
+	service, err := NewRecipeGeneratorService(&config.OpenAIConfig{
+		Provider:          config.ProviderLocal,
+		Model:             "local",
+		MaxTokens:         1000,
+		RequestsPerMinute: 600,
+		RequestTimeout:    5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	req := RecipeGenerationRequest{Ingredients: []string{"キャベツ", "豚こま肉"}, Season: "all", MaxCookingTime: 14}
+	service.GetCache().Set(service.GetCacheKeyNormalizer().Key(req), &GenerationResult{
+		Recipe: &models.RecipeData{Title: "豚キャベツ炒め", CookingTime: 13},
+	})
+
+	reordered := req
+	reordered.Ingredients = []string{"豚こま肉", "キャベツ"}
+	result, err := service.GenerateRecipe(context.Background(), reordered)
+	require.NoError(t, err)
+	assert.True(t, result.Metadata.CacheHit)
+	assert.Equal(t, "豚キャベツ炒め", result.Recipe.Title)
+
+	// Same bucket, but the cached recipe takes longer than this request allows
+	tighter := req
+	tighter.MaxCookingTime = 11
+	result, err = service.GenerateRecipe(context.Background(), tighter)
+	require.NoError(t, err)
+	assert.False(t, result.Metadata.CacheHit)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// ErrDietaryRestrictionViolated is returned when a generated recipe breaks
+// one of the dietary restrictions it was requested with
+var ErrDietaryRestrictionViolated = errors.New("recipe violates dietary restrictions")
+
+// DietaryRestrictionService classifies recipes against the dietary
+// restriction catalogue. Ingredients are matched against the component
+// keywords and resolved through the ingredient hierarchy, where group
+// mappings (every 肉類 ingredient is meat) and hidden ingredients (だし
+// contains fish, コンソメ contains meat) are recorded.
+type DietaryRestrictionService struct {
+	db     *database.Database
+	labels ingredientLabelCache
+}
+
+// NewDietaryRestrictionService creates a new dietary restriction service
+func NewDietaryRestrictionService(db *database.Database) *DietaryRestrictionService {
+	return &DietaryRestrictionService{db: db}
+}
+
+// EnsureSchema creates the ingredient_dietary_components table that tags
+// ingredients and groups with components such as meat, fish or alcohol,
+// which each dietary restriction forbids. As with ingredient_allergens, a row
+// names either an ingredient or a group, so each gets its own partial unique
+// index in place of a UNIQUE constraint that NULLs slip through.
+func (s *DietaryRestrictionService) EnsureSchema() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS ingredient_dietary_components (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ingredient_id INTEGER,
+			group_id INTEGER,
+			component TEXT NOT NULL,
+			FOREIGN KEY (ingredient_id) REFERENCES specific_ingredients(id) ON DELETE CASCADE,
+			FOREIGN KEY (group_id) REFERENCES ingredient_groups(id) ON DELETE CASCADE,
+			CHECK ((ingredient_id IS NULL) != (group_id IS NULL)),
+			CHECK (component != '')
+		);
+		DELETE FROM ingredient_dietary_components WHERE id NOT IN (
+			SELECT MIN(id) FROM ingredient_dietary_components GROUP BY ingredient_id, group_id, component
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_dietary_components_ingredient
+			ON ingredient_dietary_components(ingredient_id, component) WHERE ingredient_id IS NOT NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_dietary_components_group
+			ON ingredient_dietary_components(group_id, component) WHERE group_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_ingredient_dietary_components_component ON ingredient_dietary_components(component);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create ingredient_dietary_components: %w", err)
+	}
+	return nil
+}
+
+// DietaryViolation is an ingredient that breaks a dietary restriction
+type DietaryViolation struct {
+	Restriction string `json:"restriction"`
+	Ingredient  string `json:"ingredient"`
+	Component   string `json:"component"`
+}
+
+// String describes the violation, e.g. "vegetarian: 顆粒だし (魚介)"
+func (v DietaryViolation) String() string {
+	label := v.Component
+	if component, ok := models.FindDietaryComponent(v.Component); ok {
+		label = component.Label
+	}
+	return fmt.Sprintf("%s: %s (%s)", v.Restriction, v.Ingredient, label)
+}
+
+// DietaryTable maps ingredient names and aliases from the hierarchy to the
+// dietary components they carry. The zero value matches keywords only.
+type DietaryTable struct {
+	labels ingredientLabels
+}
+
+// LoadTable loads the dietary components of every specific ingredient,
+// including those inherited from its groups, keyed by name and each alias
+func (s *DietaryRestrictionService) LoadTable() (*DietaryTable, error) {
+	if s == nil {
+		return &DietaryTable{}, nil
+	}
+	labels, err := s.labels.load(s.db, "ingredient_dietary_components", "component")
+	if err != nil {
+		return nil, err
+	}
+	return &DietaryTable{labels: labels}, nil
+}
+
+// loadTable loads the dietary table, logging and falling back to keyword
+// matching when the hierarchy is unavailable
+func (s *DietaryRestrictionService) loadTable() *DietaryTable {
+	table, err := s.LoadTable()
+	if err != nil {
+		log.Printf("Warning: ingredient dietary components unavailable, matching keywords only: %v", err)
+		return &DietaryTable{}
+	}
+	return table
+}
+
+// IngredientComponents returns the dietary components one ingredient carries,
+// in catalogue order: those its name reveals plus those of the hierarchy
+// ingredients it names or contains
+func (t *DietaryTable) IngredientComponents(ingredient string) []string {
+	present := make(map[string]bool)
+	for i := range models.DietaryComponents {
+		if models.DietaryComponents[i].Matches(ingredient) {
+			present[models.DietaryComponents[i].Name] = true
+		}
+	}
+	for _, component := range t.labels.lookup(ingredient, func(component, name string) bool {
+		catalogued, ok := models.FindDietaryComponent(component)
+		return ok && catalogued.Excludes(name)
+	}) {
+		present[component] = true
+	}
+
+	components := []string{}
+	for _, component := range models.DietaryComponents {
+		if present[component.Name] {
+			components = append(components, component.Name)
+		}
+	}
+	return components
+}
+
+// Violations returns the ingredients of a recipe that break the given
+// restrictions. Restrictions outside the catalogue are ignored. A restriction
+// with a tolerance is only broken once more ingredients than it allows
+// carry a forbidden component.
+func (t *DietaryTable) Violations(recipe *models.RecipeData, restrictions []string) []DietaryViolation {
+	known, _ := models.SplitDietaryRestrictions(restrictions)
+	if len(known) == 0 {
+		return nil
+	}
+
+	components := make([][]string, len(recipe.Ingredients))
+	for i, ingredient := range recipe.Ingredients {
+		components[i] = t.IngredientComponents(ingredient.Name)
+	}
+
+	var violations []DietaryViolation
+	for _, name := range known {
+		restriction, _ := models.FindDietaryRestriction(name)
+		var found []DietaryViolation
+		for i, ingredient := range recipe.Ingredients {
+			for _, component := range components[i] {
+				if slices.Contains(restriction.Forbids, component) {
+					found = append(found, DietaryViolation{Restriction: restriction.Name, Ingredient: ingredient.Name, Component: component})
+					break
+				}
+			}
+		}
+		if len(found) > restriction.Tolerance {
+			violations = append(violations, found...)
+		}
+	}
+	return violations
+}
+
+// Compliance returns the catalogue restrictions a recipe complies with
+func (t *DietaryTable) Compliance(recipe *models.RecipeData) []string {
+	flags := []string{}
+	for _, restriction := range models.DietaryRestrictions {
+		if len(t.Violations(recipe, []string{restriction.Name})) == 0 {
+			flags = append(flags, restriction.Name)
+		}
+	}
+	return flags
+}
+
+// update stores a recipe's current compliance flags, reporting whether they changed
+func (t *DietaryTable) update(recipe *models.RecipeData) bool {
+	flags := t.Compliance(recipe)
+	if len(flags) == 0 {
+		flags = nil
+	}
+	if slices.Equal(flags, recipe.DietaryFlags) {
+		return false
+	}
+	recipe.DietaryFlags = flags
+	return true
+}
+
+// Violations checks a recipe against restrictions with the current table.
+// A nil service matches keywords only.
+func (s *DietaryRestrictionService) Violations(recipe *models.RecipeData, restrictions []string) []DietaryViolation {
+	if len(restrictions) == 0 {
+		return nil
+	}
+	return s.loadTable().Violations(recipe, restrictions)
+}
+
+// CheckRecipe returns an error wrapping ErrDietaryRestrictionViolated that
+// lists the offending ingredients when a recipe breaks any restriction
+func (s *DietaryRestrictionService) CheckRecipe(recipe *models.RecipeData, restrictions []string) error {
+	if violations := s.Violations(recipe, restrictions); len(violations) > 0 {
+		return fmt.Errorf("%w: %s", ErrDietaryRestrictionViolated, describeDietaryViolations(violations))
+	}
+	return nil
+}
+
+// HandleRecipeSaved stores the computed compliance flags on a newly saved or
+// updated recipe; register it with Database.OnRecipeSaved
+func (s *DietaryRestrictionService) HandleRecipeSaved(recipeID int) {
+	NewRecipeLabeler(s.db, nil, s).HandleRecipeSaved(recipeID)
+}
+
+// RefreshRecipes recomputes the compliance flags of every stored recipe,
+// writing only those that changed, and returns how many were updated
+func (s *DietaryRestrictionService) RefreshRecipes() (int, error) {
+	return NewRecipeLabeler(s.db, nil, s).RefreshRecipes()
+}
+
+// DietaryGuidance returns the generation constraints for a restriction list:
+// the catalogue guidance for known restrictions, and the term as-is otherwise
+func DietaryGuidance(restrictions []string) []string {
+	known, others := models.SplitDietaryRestrictions(restrictions)
+	guidance := make([]string, 0, len(known)+len(others))
+	for _, name := range known {
+		restriction, _ := models.FindDietaryRestriction(name)
+		guidance = append(guidance, fmt.Sprintf("%s対応: %s", restriction.Label, restriction.Guidance))
+	}
+	for _, other := range others {
+		guidance = append(guidance, fmt.Sprintf("食事制限: %s", other))
+	}
+	return guidance
+}
+
+// describeDietaryViolations joins violations for error messages and logs
+func describeDietaryViolations(violations []DietaryViolation) string {
+	descriptions := make([]string, len(violations))
+	for i, violation := range violations {
+		descriptions[i] = violation.String()
+	}
+	return strings.Join(descriptions, ", ")
+}
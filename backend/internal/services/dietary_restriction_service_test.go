@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/config"
+	"lazychef/internal/models"
+)
+
+func dietaryRecipe(title string, ingredients ...string) models.RecipeData {
+	recipe := models.RecipeData{
+		Title:         title,
+		CookingTime:   10,
+		Steps:         []string{"作る"},
+		Season:        "all",
+		LazinessScore: 8.0,
+		ServingSize:   models.FlexibleInt(1),
+	}
+	for _, name := range ingredients {
+		recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Amount: "100g"})
+	}
+	return recipe
+}
+
+func TestDietaryTable_ResolvesHiddenIngredients(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	table, err := NewDietaryRestrictionService(db).LoadTable()
+	require.NoError(t, err)
+
+	// Hidden ingredients are only known to the hierarchy
+	assert.Equal(t, []string{"fish", "salt"}, table.IngredientComponents("ほんだし"))
+	assert.Equal(t, []string{"meat", "salt"}, table.IngredientComponents("顆粒コンソメ"))
+	assert.Equal(t, []string{"fish", "gluten", "salt"}, table.IngredientComponents("めんつゆ"))
+	assert.Equal(t, []string{"alcohol"}, table.IngredientComponents("みりん"))
+	// Group mappings carry meat down to every 肉類 ingredient
+	assert.Equal(t, []string{"meat", "pork", "salt"}, table.IngredientComponents("ベーコン"))
+	assert.Equal(t, []string{"meat"}, table.IngredientComponents("チキンブレスト"))
+	// Exceptions apply to contained names too
+	assert.Equal(t, []string{"salt"}, table.IngredientComponents("昆布だし"))
+	assert.Empty(t, table.IngredientComponents("キャベツ"))
+
+	// Seaweed sits under 魚介類 but is not fish
+	assert.Empty(t, table.IngredientComponents("わかめ"))
+	assert.Equal(t, []string{"fish"}, table.IngredientComponents("ツナ缶"))
+
+	miso := dietaryRecipe("豆腐の味噌汁", "豆腐", "わかめ", "味噌", "ほんだし")
+	assert.Equal(t, []string{"pescatarian", "halal", "gluten-free"}, table.Compliance(&miso))
+	violations := table.Violations(&miso, []string{"ヴィーガン", "減塩"})
+	require.Len(t, violations, 3)
+	assert.Equal(t, "vegan: ほんだし (魚介)", violations[0].String())
+	assert.Equal(t, DietaryViolation{Restriction: "low-sodium", Ingredient: "味噌", Component: "salt"}, violations[1])
+
+	// Low-sodium tolerates a single salty seasoning
+	salad := dietaryRecipe("冷奴", "豆腐", "ねぎ", "しょうゆ")
+	assert.Equal(t, []string{"vegetarian", "vegan", "pescatarian", "halal", "low-sodium"}, table.Compliance(&salad))
+}
+
+func TestDietaryRestrictionService_StoresFlagsOnSave(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	repo := NewRecipeRepository(db)
+	legacyID := insertTestRecipe(t, db, dietaryRecipe("野菜スープ", "キャベツ", "顆粒コンソメ"))
+
+	service := NewDietaryRestrictionService(db)
+	require.NoError(t, service.EnsureSchema())
+	db.OnRecipeSaved(service.HandleRecipeSaved)
+
+	n, err := service.RefreshRecipes()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	stored, err := repo.GetRecipe(legacyID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"halal", "gluten-free", "low-sodium"}, stored.Data.DietaryFlags)
+
+	id := insertTestRecipe(t, db, dietaryRecipe("トマトの昆布だし浸し", "トマト", "昆布だし"))
+	stored, err = repo.GetRecipe(id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vegetarian", "vegan", "pescatarian", "halal", "gluten-free", "low-sodium"}, stored.Data.DietaryFlags)
+}
+
+func TestRecipeGeneratorService_RejectsDietaryViolations(t *testing.T) {
+	service, err := NewRecipeGeneratorService(&config.OpenAIConfig{
+		Provider:          config.ProviderLocal,
+		Model:             "local",
+		MaxTokens:         1000,
+		RequestsPerMinute: 600,
+		RequestTimeout:    5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	// The local provider cooks whatever it is asked to, so the check must catch it
+	req := RecipeGenerationRequest{
+		Ingredients:         []string{"鶏もも肉", "キャベツ"},
+		Season:              "all",
+		MaxCookingTime:      15,
+		DietaryRestrictions: []string{"vegetarian"},
+	}
+	result, err := service.GenerateRecipe(context.Background(), req)
+	require.ErrorIs(t, err, ErrDietaryRestrictionViolated)
+	assert.Contains(t, result.Error, "vegetarian: 鶏もも肉 (肉)")
+
+	req.Ingredients = []string{"豆腐", "キャベツ"}
+	result, err = service.GenerateRecipe(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "豆腐", result.Recipe.Ingredients[0].Name)
+
+	prompt := GetRecipeGenerationPrompt(req).UserPrompt
+	assert.Contains(t, prompt, "## 食事制限（厳守）")
+	assert.Contains(t, prompt, "ベジタリアン対応: 肉・魚介を使わない。かつおだし")
+}
+
+func TestDietaryRestrictionService_EnsureSchemaDedupesRows(t *testing.T) {
+	db := setupSchemaDatabase(t)
+
+	// The old UNIQUE (ingredient_id, group_id, component) let group rows repeat
+	_, err := db.Exec(`
+		CREATE TABLE ingredient_dietary_components (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ingredient_id INTEGER,
+			group_id INTEGER,
+			component TEXT NOT NULL,
+			UNIQUE (ingredient_id, group_id, component)
+		);
+		INSERT INTO ingredient_dietary_components (group_id, component) VALUES (1, 'meat'), (1, 'meat'), (1, 'pork');
+		INSERT INTO ingredient_dietary_components (ingredient_id, component) VALUES (5, 'fish'), (5, 'fish');
+	`)
+	require.NoError(t, err)
+
+	service := NewDietaryRestrictionService(db)
+	require.NoError(t, service.EnsureSchema())
+	require.NoError(t, service.EnsureSchema())
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM ingredient_dietary_components").Scan(&count))
+	assert.Equal(t, 3, count)
+
+	_, err = db.Exec("INSERT INTO ingredient_dietary_components (group_id, component) VALUES (1, 'meat')")
+	assert.Error(t, err)
+	_, err = db.Exec("INSERT INTO ingredient_dietary_components (ingredient_id, component) VALUES (5, 'fish')")
+	assert.Error(t, err)
+}
+
+func TestRecipeLabeler_StoresBothLabelSetsOnSave(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	labeler := NewRecipeLabeler(db, NewAllergenService(db), NewDietaryRestrictionService(db))
+	db.OnRecipeSaved(labeler.HandleRecipeSaved)
+
+	id := insertTestRecipe(t, db, dietaryRecipe("ブロッコリーのマヨ和え", "ブロッコリー", "マヨネーズ"))
+	stored, err := NewRecipeRepository(db).GetRecipe(id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"卵", "大豆"}, stored.Data.Allergens)
+	assert.Contains(t, stored.Data.DietaryFlags, "vegetarian")
+	assert.NotContains(t, stored.Data.DietaryFlags, "vegan")
+
+	n, err := labeler.RefreshRecipes()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
@@ -1,11 +1,12 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
-	"strings"
 	"time"
 
 	"lazychef/internal/database"
@@ -133,8 +134,10 @@ func (s *DiversityService) updateDimensionStats(counts map[string]map[string]int
 	}
 }
 
-// GenerateDiverseRecipes generates recipes using diversity-focused strategies
-func (s *DiversityService) GenerateDiverseRecipes(req models.DiverseGenerationRequest) (*models.DiverseGenerationResponse, error) {
+// GenerateDiverseRecipes generates recipes using diversity-focused strategies.
+// Generation stops early when the budget gate refuses a call; if nothing was
+// generated by then the gate's error is returned.
+func (s *DiversityService) GenerateDiverseRecipes(ctx context.Context, req models.DiverseGenerationRequest) (*models.DiverseGenerationResponse, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, err
@@ -169,14 +172,18 @@ func (s *DiversityService) GenerateDiverseRecipes(req models.DiverseGenerationRe
 	// Generate recipes for target combinations
 	generatedRecipes := make([]models.RecipeData, 0)
 	diversityScore := 0.0
+	stoppedReason := ""
 
 	for i, combo := range targetCombos {
-		// Create generation prompt based on combination
-		prompt := s.createPromptFromCombo(combo, config)
-
-		// Generate recipe (simplified - in real implementation would use batch API)
-		recipeData, err := s.generateSingleRecipe(prompt, config)
+		recipeData, err := s.generateSingleRecipe(ctx, combo)
 		if err != nil {
+			if errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrRateLimited) {
+				if len(generatedRecipes) == 0 {
+					return nil, err
+				}
+				stoppedReason = err.Error()
+				break
+			}
 			log.Printf("Warning: failed to generate recipe for combo %v: %v", combo, err)
 			continue
 		}
@@ -210,6 +217,7 @@ func (s *DiversityService) GenerateDiverseRecipes(req models.DiverseGenerationRe
 		CoverageImpact: impact,
 		EstimatedCost:  float64(len(generatedRecipes)) * 0.01, // $0.01 per recipe estimate
 		Recipes:        generatedRecipes,
+		StoppedReason:  stoppedReason,
 	}
 
 	return response, nil
@@ -304,25 +312,25 @@ func (s *DiversityService) selectRandomly(batchSize int) ([]models.DimensionComb
 	return combos, nil
 }
 
-// createPromptFromCombo creates a generation prompt based on dimension combination
-func (s *DiversityService) createPromptFromCombo(combo models.DimensionCombo, config models.GenerationConfig) string {
-	var promptParts []string
+// comboConstraints describes a dimension combination as prompt constraints
+func comboConstraints(combo models.DimensionCombo) []string {
+	var constraints []string
 
 	// Add dimension constraints to prompt
 	if combo.MealType != "" && combo.MealType != "なし" {
-		promptParts = append(promptParts, fmt.Sprintf("食事タイプ: %s", combo.MealType))
+		constraints = append(constraints, fmt.Sprintf("食事タイプ: %s", combo.MealType))
 	}
 	if combo.Staple != "" && combo.Staple != "なし" {
-		promptParts = append(promptParts, fmt.Sprintf("主食: %s", combo.Staple))
+		constraints = append(constraints, fmt.Sprintf("主食: %s", combo.Staple))
 	}
 	if combo.Protein != "" && combo.Protein != "なし" {
-		promptParts = append(promptParts, fmt.Sprintf("メインの食材: %s", combo.Protein))
+		constraints = append(constraints, fmt.Sprintf("メインの食材: %s", combo.Protein))
 	}
 	if combo.CookingMethod != "" {
-		promptParts = append(promptParts, fmt.Sprintf("調理法: %s", combo.CookingMethod))
+		constraints = append(constraints, fmt.Sprintf("調理法: %s", combo.CookingMethod))
 	}
 	if combo.Seasoning != "" {
-		promptParts = append(promptParts, fmt.Sprintf("味付け: %s", combo.Seasoning))
+		constraints = append(constraints, fmt.Sprintf("味付け: %s", combo.Seasoning))
 	}
 	if combo.LazynessLevel != "" {
 		lazynessDesc := map[string]string{
@@ -331,46 +339,56 @@ func (s *DiversityService) createPromptFromCombo(combo models.DimensionCombo, co
 			"3_ちょい手間": "少し手間をかけても美味しい",
 		}
 		if desc, ok := lazynessDesc[combo.LazynessLevel]; ok {
-			promptParts = append(promptParts, fmt.Sprintf("難易度: %s", desc))
+			constraints = append(constraints, fmt.Sprintf("難易度: %s", desc))
 		}
 	}
 
-	prompt := fmt.Sprintf(`以下の条件でレシピを作成してください：
-
-%s
-
-- 調理時間は15分以内
-- 初心者でも作れる簡単なレシピ
-- 日本の家庭でよく使われる食材
-- 栄養バランスを考慮
-- 手順は3ステップ以内`, strings.Join(promptParts, "\n"))
-
-	return prompt
+	return append(constraints,
+		"初心者でも作れる簡単なレシピ",
+		"日本の家庭でよく使われる食材",
+		"栄養バランスを考慮",
+		"手順は3ステップ以内",
+	)
 }
 
-// generateSingleRecipe generates a single recipe (simplified implementation)
-func (s *DiversityService) generateSingleRecipe(prompt string, config models.GenerationConfig) (*models.RecipeData, error) {
-	// In a full implementation, this would use the actual generator service
-	// For now, return a placeholder recipe
-
-	// This would normally call s.generatorService.GenerateRecipe() with the prompt
-	// but we'll return a simplified version for Phase 1
-
-	placeholderRecipe := &models.RecipeData{
-		Title:       fmt.Sprintf("多様化レシピ %d", time.Now().Unix()%1000),
-		CookingTime: 10,
-		Ingredients: []models.Ingredient{
-			{Name: "基本食材", Amount: "適量"},
-		},
-		Steps:         []string{"調理する", "味付けする", "完成"},
-		Tags:          []string{"簡単", "多様化"},
-		Season:        "all",
-		LazinessScore: 8.0,
-		ServingSize:   models.FlexibleInt(1),
-		Difficulty:    "easy",
-	}
-
-	return placeholderRecipe, nil
+// generateSingleRecipe generates a recipe for one dimension combination
+// through the generator service, so the call passes the budget gate. Without
+// a generator it returns a placeholder recipe.
+func (s *DiversityService) generateSingleRecipe(ctx context.Context, combo models.DimensionCombo) (*models.RecipeData, error) {
+	if s.generatorService == nil {
+		return &models.RecipeData{
+			Title:       fmt.Sprintf("多様化レシピ %d", time.Now().Unix()%1000),
+			CookingTime: 10,
+			Ingredients: []models.Ingredient{
+				{Name: "基本食材", Amount: "適量"},
+			},
+			Steps:         []string{"調理する", "味付けする", "完成"},
+			Tags:          []string{"簡単", "多様化"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+			Difficulty:    "easy",
+		}, nil
+	}
+
+	var ingredients []string
+	for _, ingredient := range []string{combo.Protein, combo.Staple} {
+		if ingredient != "" && ingredient != "なし" {
+			ingredients = append(ingredients, ingredient)
+		}
+	}
+
+	result, err := s.generatorService.GenerateRecipe(ctx, RecipeGenerationRequest{
+		Ingredients:    ingredients,
+		Season:         "all",
+		MaxCookingTime: 15,
+		Servings:       1,
+		Constraints:    comboConstraints(combo),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Recipe, nil
 }
 
 // InitializeSystem initializes the diversity system
@@ -0,0 +1,529 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// Duplicate resolution actions
+const (
+	DuplicateActionMerge    = "merge"     // Keep one recipe, delete the other and repoint references
+	DuplicateActionKeepBoth = "keep_both" // Not a duplicate; never report the pair again
+	DuplicateActionDismiss  = "dismiss"   // Clear the current report; a later scan may find it again
+)
+
+// Default thresholds for bulk auto-merge, stricter than detection
+const (
+	DefaultAutoMergeCosineThreshold  = 0.97
+	DefaultAutoMergeJaccardThreshold = 0.9
+)
+
+// ErrInvalidDuplicateAction is returned for unknown actions or malformed pairs
+var ErrInvalidDuplicateAction = errors.New("invalid duplicate resolution")
+
+// dimensionComboFields are the dimension types stored in dimension_coverage combos
+var dimensionComboFields = map[string]bool{
+	"meal_type": true, "staple": true, "protein": true,
+	"cooking_method": true, "seasoning": true, "laziness_level": true,
+}
+
+// DuplicateResolutionRequest resolves one reported pair
+type DuplicateResolutionRequest struct {
+	RecipeID        int    `json:"recipe_id" binding:"required"`
+	SimilarRecipeID int    `json:"similar_recipe_id" binding:"required"`
+	Action          string `json:"action" binding:"required"` // merge, keep_both, dismiss
+	KeepRecipeID    int    `json:"keep_recipe_id,omitempty"`  // merge only; defaults to the older recipe
+	ResolvedBy      string `json:"resolved_by,omitempty"`     // Who resolved it, for the audit trail
+	Note            string `json:"note,omitempty"`
+}
+
+// DuplicateResolution is an audit trail entry
+type DuplicateResolution struct {
+	ID               int       `json:"id"`
+	RecipeID         int       `json:"recipe_id"`
+	SimilarRecipeID  int       `json:"similar_recipe_id"`
+	Action           string    `json:"action"`
+	KeptRecipeID     int       `json:"kept_recipe_id,omitempty"`
+	RemovedRecipeID  int       `json:"removed_recipe_id,omitempty"`
+	RemovedTitle     string    `json:"removed_title,omitempty"`
+	SimilarityScore  float64   `json:"similarity_score"`
+	JaccardScore     float64   `json:"jaccard_score"`
+	MealPlansUpdated int       `json:"meal_plans_updated"`
+	CoverageAdjusted int       `json:"coverage_adjusted"` // dimension_coverage rows decremented
+	Automatic        bool      `json:"automatic"`
+	ResolvedBy       string    `json:"resolved_by,omitempty"`
+	Note             string    `json:"note,omitempty"`
+	ResolvedAt       time.Time `json:"resolved_at"`
+}
+
+// AutoMergeRequest configures a bulk merge of near-certain duplicates
+type AutoMergeRequest struct {
+	CosineThreshold  float64 `json:"cosine_threshold,omitempty"`
+	JaccardThreshold float64 `json:"jaccard_threshold,omitempty"`
+	Limit            int     `json:"limit,omitempty"`   // Maximum merges; 0 means no limit
+	DryRun           bool    `json:"dry_run,omitempty"` // Report candidates without merging
+}
+
+// AutoMergeResult summarises a bulk merge
+type AutoMergeResult struct {
+	CosineThreshold  float64               `json:"cosine_threshold"`
+	JaccardThreshold float64               `json:"jaccard_threshold"`
+	DryRun           bool                  `json:"dry_run"`
+	Candidates       []DuplicateResult     `json:"candidates"`
+	Merged           []DuplicateResolution `json:"merged"`
+	Skipped          int                   `json:"skipped"` // Pairs whose recipe was already merged away
+}
+
+// DuplicateResolutionService acts on duplicate detection results
+type DuplicateResolutionService struct {
+	db           *database.Database
+	deduplicator *EmbeddingDeduplicator // Optional; merged recipes are dropped from its index
+}
+
+// NewDuplicateResolutionService creates a new duplicate resolution service
+func NewDuplicateResolutionService(db *database.Database, deduplicator *EmbeddingDeduplicator) *DuplicateResolutionService {
+	return &DuplicateResolutionService{
+		db:           db,
+		deduplicator: deduplicator,
+	}
+}
+
+// EnsureSchema creates the audit and dimension mapping tables on databases
+// initialised before they were added to init_db.sql
+func (s *DuplicateResolutionService) EnsureSchema() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS duplicate_resolutions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recipe_id INTEGER NOT NULL,
+			similar_recipe_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			kept_recipe_id INTEGER,
+			removed_recipe_id INTEGER,
+			removed_title TEXT,
+			similarity_score REAL,
+			jaccard_score REAL,
+			meal_plans_updated INTEGER DEFAULT 0,
+			coverage_adjusted INTEGER DEFAULT 0,
+			automatic BOOLEAN DEFAULT 0,
+			resolved_by TEXT,
+			note TEXT,
+			resolved_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			CHECK (action IN ('merge', 'keep_both', 'dismiss')),
+			CHECK (recipe_id < similar_recipe_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_duplicate_resolutions_pair ON duplicate_resolutions(recipe_id, similar_recipe_id)`,
+		`CREATE TABLE IF NOT EXISTS recipe_dimension_mappings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recipe_id INTEGER NOT NULL,
+			dimension_id INTEGER NOT NULL,
+			confidence_score REAL DEFAULT 1.0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE,
+			FOREIGN KEY (dimension_id) REFERENCES recipe_dimensions(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_dimension_mappings_recipe ON recipe_dimension_mappings(recipe_id)`,
+	}
+	for _, stmt := range statements {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create duplicate resolution schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Resolve applies a merge, keep-both or dismiss decision to a pair
+func (s *DuplicateResolutionService) Resolve(req DuplicateResolutionRequest) (*DuplicateResolution, error) {
+	if req.RecipeID <= 0 || req.SimilarRecipeID <= 0 || req.RecipeID == req.SimilarRecipeID {
+		return nil, fmt.Errorf("%w: a pair of distinct recipe IDs is required", ErrInvalidDuplicateAction)
+	}
+
+	switch req.Action {
+	case DuplicateActionMerge:
+		return s.merge(req, false)
+	case DuplicateActionKeepBoth, DuplicateActionDismiss:
+		return s.clearPair(req)
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidDuplicateAction, req.Action)
+	}
+}
+
+// AutoMerge merges every reported pair at or above both thresholds, keeping
+// the older recipe of each pair
+func (s *DuplicateResolutionService) AutoMerge(req AutoMergeRequest) (*AutoMergeResult, error) {
+	if req.CosineThreshold <= 0 {
+		req.CosineThreshold = DefaultAutoMergeCosineThreshold
+	}
+	if req.JaccardThreshold <= 0 {
+		req.JaccardThreshold = DefaultAutoMergeJaccardThreshold
+	}
+
+	rows, err := s.db.Query(`
+		SELECT recipe_id, similar_recipe_id, MAX(similarity_score), MAX(COALESCE(jaccard_score, 0)), detection_method
+		FROM duplicate_detection_results d
+		WHERE similarity_score >= ? AND COALESCE(jaccard_score, 0) >= ?
+		  AND NOT `+keptPairPredicate("d")+`
+		GROUP BY MIN(recipe_id, similar_recipe_id), MAX(recipe_id, similar_recipe_id)
+		ORDER BY MAX(similarity_score) DESC
+	`, req.CosineThreshold, req.JaccardThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query merge candidates: %w", err)
+	}
+
+	result := &AutoMergeResult{
+		CosineThreshold:  req.CosineThreshold,
+		JaccardThreshold: req.JaccardThreshold,
+		DryRun:           req.DryRun,
+		Candidates:       []DuplicateResult{},
+		Merged:           []DuplicateResolution{},
+	}
+	for rows.Next() {
+		var candidate DuplicateResult
+		if err := rows.Scan(&candidate.RecipeID, &candidate.SimilarRecipeID, &candidate.SimilarityScore,
+			&candidate.JaccardScore, &candidate.DetectionMethod); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan merge candidate: %w", err)
+		}
+		result.Candidates = append(result.Candidates, candidate)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	if req.DryRun {
+		return result, nil
+	}
+
+	for _, candidate := range result.Candidates {
+		if req.Limit > 0 && len(result.Merged) >= req.Limit {
+			break
+		}
+
+		resolution, err := s.merge(DuplicateResolutionRequest{
+			RecipeID:        candidate.RecipeID,
+			SimilarRecipeID: candidate.SimilarRecipeID,
+			Action:          DuplicateActionMerge,
+			ResolvedBy:      "auto-merge",
+		}, true)
+		if errors.Is(err, models.ErrRecipeNotFound) {
+			result.Skipped++ // Chains like A~B, B~C: B may already be gone
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Merged = append(result.Merged, *resolution)
+	}
+
+	log.Printf("Auto-merge: merged %d of %d duplicate pairs (cosine >= %.2f, jaccard >= %.2f)",
+		len(result.Merged), len(result.Candidates), req.CosineThreshold, req.JaccardThreshold)
+	return result, nil
+}
+
+// GetResolutions returns the audit trail, newest first
+func (s *DuplicateResolutionService) GetResolutions(limit int) ([]DuplicateResolution, error) {
+	rows, err := s.db.Query(`
+		SELECT id, recipe_id, similar_recipe_id, action, COALESCE(kept_recipe_id, 0), COALESCE(removed_recipe_id, 0),
+		       COALESCE(removed_title, ''), COALESCE(similarity_score, 0), COALESCE(jaccard_score, 0),
+		       meal_plans_updated, coverage_adjusted, automatic, COALESCE(resolved_by, ''), COALESCE(note, ''), resolved_at
+		FROM duplicate_resolutions
+		ORDER BY resolved_at DESC, id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate resolutions: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	resolutions := []DuplicateResolution{}
+	for rows.Next() {
+		var r DuplicateResolution
+		if err := rows.Scan(&r.ID, &r.RecipeID, &r.SimilarRecipeID, &r.Action, &r.KeptRecipeID, &r.RemovedRecipeID,
+			&r.RemovedTitle, &r.SimilarityScore, &r.JaccardScore, &r.MealPlansUpdated, &r.CoverageAdjusted,
+			&r.Automatic, &r.ResolvedBy, &r.Note, &r.ResolvedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate resolution: %w", err)
+		}
+		resolutions = append(resolutions, r)
+	}
+
+	return resolutions, rows.Err()
+}
+
+// merge deletes one recipe of the pair after moving its references to the other
+func (s *DuplicateResolutionService) merge(req DuplicateResolutionRequest, automatic bool) (*DuplicateResolution, error) {
+	keptID := req.KeepRecipeID
+	if keptID == 0 {
+		keptID = min(req.RecipeID, req.SimilarRecipeID)
+	}
+	removedID := req.RecipeID
+	switch keptID {
+	case req.RecipeID:
+		removedID = req.SimilarRecipeID
+	case req.SimilarRecipeID:
+	default:
+		return nil, fmt.Errorf("%w: keep_recipe_id must be one of the pair", ErrInvalidDuplicateAction)
+	}
+
+	resolution := s.newResolution(req, automatic)
+	resolution.KeptRecipeID = keptID
+	resolution.RemovedRecipeID = removedID
+
+	err := s.db.ExecuteInTx(func(tx *sql.Tx) error {
+		keptTitle, err := recipeTitle(tx, keptID)
+		if err != nil {
+			return err
+		}
+		if resolution.RemovedTitle, err = recipeTitle(tx, removedID); err != nil {
+			return err
+		}
+
+		if resolution.MealPlansUpdated, err = repointMealPlans(tx, removedID, keptID, keptTitle); err != nil {
+			return err
+		}
+		if resolution.CoverageAdjusted, err = repointDimensionCoverage(tx, removedID, keptID); err != nil {
+			return err
+		}
+
+		// Embeddings and detection results cascade with the recipe
+		if _, err := tx.Exec(`DELETE FROM recipes WHERE id = ?`, removedID); err != nil {
+			return fmt.Errorf("failed to delete merged recipe: %w", err)
+		}
+
+		return insertResolution(tx, resolution)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.deduplicator != nil {
+		s.deduplicator.vectorIndex().Remove(removedID)
+	}
+
+	log.Printf("Merged duplicate recipe %d into %d (%d meal plans updated)",
+		removedID, keptID, resolution.MealPlansUpdated)
+	return resolution, nil
+}
+
+// clearPair removes the pair's detection results and records the decision
+func (s *DuplicateResolutionService) clearPair(req DuplicateResolutionRequest) (*DuplicateResolution, error) {
+	resolution := s.newResolution(req, false)
+
+	err := s.db.ExecuteInTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+			DELETE FROM duplicate_detection_results
+			WHERE (recipe_id = ? AND similar_recipe_id = ?) OR (recipe_id = ? AND similar_recipe_id = ?)
+		`, req.RecipeID, req.SimilarRecipeID, req.SimilarRecipeID, req.RecipeID); err != nil {
+			return fmt.Errorf("failed to clear duplicate results: %w", err)
+		}
+		return insertResolution(tx, resolution)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resolution, nil
+}
+
+// newResolution starts an audit entry with the latest detection scores for the pair
+func (s *DuplicateResolutionService) newResolution(req DuplicateResolutionRequest, automatic bool) *DuplicateResolution {
+	resolution := &DuplicateResolution{
+		RecipeID:        min(req.RecipeID, req.SimilarRecipeID),
+		SimilarRecipeID: max(req.RecipeID, req.SimilarRecipeID),
+		Action:          req.Action,
+		Automatic:       automatic,
+		ResolvedBy:      req.ResolvedBy,
+		Note:            req.Note,
+		ResolvedAt:      time.Now(),
+	}
+
+	var jaccard sql.NullFloat64
+	err := s.db.QueryRow(`
+		SELECT similarity_score, jaccard_score FROM duplicate_detection_results
+		WHERE (recipe_id = ? AND similar_recipe_id = ?) OR (recipe_id = ? AND similar_recipe_id = ?)
+		ORDER BY detected_at DESC, id DESC
+		LIMIT 1
+	`, req.RecipeID, req.SimilarRecipeID, req.SimilarRecipeID, req.RecipeID).Scan(&resolution.SimilarityScore, &jaccard)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Warning: failed to look up duplicate scores: %v", err)
+	}
+	resolution.JaccardScore = jaccard.Float64
+
+	return resolution
+}
+
+// keptPairPredicate matches detection rows (aliased as alias) whose pair was marked keep_both
+func keptPairPredicate(alias string) string {
+	return `EXISTS (
+		SELECT 1 FROM duplicate_resolutions dr
+		WHERE dr.action = 'keep_both'
+		  AND dr.recipe_id = MIN(` + alias + `.recipe_id, ` + alias + `.similar_recipe_id)
+		  AND dr.similar_recipe_id = MAX(` + alias + `.recipe_id, ` + alias + `.similar_recipe_id)
+	)`
+}
+
+func recipeTitle(tx *sql.Tx, recipeID int) (string, error) {
+	var title string
+	err := tx.QueryRow(`SELECT title FROM recipes WHERE id = ?`, recipeID).Scan(&title)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("recipe %d: %w", recipeID, models.ErrRecipeNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get recipe %d: %w", recipeID, err)
+	}
+	return title, nil
+}
+
+// repointMealPlans switches DailyRecipe references from one recipe to another
+func repointMealPlans(tx *sql.Tx, fromID, toID int, toTitle string) (int, error) {
+	rows, err := tx.Query(`
+		SELECT id, week_data FROM meal_plans
+		WHERE EXISTS (
+			SELECT 1 FROM json_each(week_data, '$.daily_recipes')
+			WHERE json_extract(value, '$.recipe_id') = ?
+		)
+	`, fromID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query meal plans: %w", err)
+	}
+
+	plans := make(map[int]models.MealPlanData)
+	for rows.Next() {
+		var id int
+		var weekData string
+		if err := rows.Scan(&id, &weekData); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("failed to scan meal plan: %w", err)
+		}
+		var data models.MealPlanData
+		if err := json.Unmarshal([]byte(weekData), &data); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("failed to parse meal plan %d: %w", id, err)
+		}
+		plans[id] = data
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	for id, data := range plans {
+		for day, daily := range data.DailyRecipes {
+			if daily.RecipeID == fromID {
+				daily.RecipeID = toID
+				daily.Title = toTitle
+				data.DailyRecipes[day] = daily
+			}
+		}
+
+		weekDataJSON, err := json.Marshal(data)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal meal plan data: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE meal_plans SET week_data = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			string(weekDataJSON), id); err != nil {
+			return 0, fmt.Errorf("failed to update meal plan %d: %w", id, err)
+		}
+	}
+
+	return len(plans), nil
+}
+
+// repointDimensionCoverage moves the removed recipe's dimension mappings to the
+// kept recipe. When the kept recipe is already mapped, the removed recipe's
+// combination loses one recipe, so its coverage count is decremented instead.
+func repointDimensionCoverage(tx *sql.Tx, removedID, keptID int) (int, error) {
+	rows, err := tx.Query(`
+		SELECT rd.dimension_type, rd.dimension_value
+		FROM recipe_dimension_mappings m
+		JOIN recipe_dimensions rd ON rd.id = m.dimension_id
+		WHERE m.recipe_id = ?
+	`, removedID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query dimension mappings: %w", err)
+	}
+
+	var conditions []string
+	var args []interface{}
+	for rows.Next() {
+		var dimensionType, dimensionValue string
+		if err := rows.Scan(&dimensionType, &dimensionValue); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("failed to scan dimension mapping: %w", err)
+		}
+		if dimensionComboFields[dimensionType] {
+			conditions = append(conditions, `json_extract(dimension_combo, '$.`+dimensionType+`') = ?`)
+			args = append(args, dimensionValue)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close rows: %w", err)
+	}
+	if len(conditions) == 0 {
+		return 0, nil
+	}
+
+	var keptMappings int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM recipe_dimension_mappings WHERE recipe_id = ?`, keptID).Scan(&keptMappings); err != nil {
+		return 0, fmt.Errorf("failed to count dimension mappings: %w", err)
+	}
+	if keptMappings == 0 {
+		if _, err := tx.Exec(`UPDATE recipe_dimension_mappings SET recipe_id = ? WHERE recipe_id = ?`, keptID, removedID); err != nil {
+			return 0, fmt.Errorf("failed to repoint dimension mappings: %w", err)
+		}
+		return 0, nil
+	}
+
+	result, err := tx.Exec(`
+		UPDATE dimension_coverage
+		SET current_count = current_count - 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM dimension_coverage
+			WHERE current_count > 0 AND `+strings.Join(conditions, " AND ")+`
+			ORDER BY current_count DESC
+			LIMIT 1
+		)
+	`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to adjust dimension coverage: %w", err)
+	}
+	adjusted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check adjusted coverage: %w", err)
+	}
+	return int(adjusted), nil
+}
+
+func insertResolution(tx *sql.Tx, r *DuplicateResolution) error {
+	var kept, removed interface{}
+	if r.KeptRecipeID != 0 {
+		kept, removed = r.KeptRecipeID, r.RemovedRecipeID
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO duplicate_resolutions
+		(recipe_id, similar_recipe_id, action, kept_recipe_id, removed_recipe_id, removed_title,
+		 similarity_score, jaccard_score, meal_plans_updated, coverage_adjusted, automatic, resolved_by, note, resolved_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.RecipeID, r.SimilarRecipeID, r.Action, kept, removed, r.RemovedTitle,
+		r.SimilarityScore, r.JaccardScore, r.MealPlansUpdated, r.CoverageAdjusted, r.Automatic, r.ResolvedBy, r.Note, r.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record duplicate resolution: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get resolution ID: %w", err)
+	}
+	r.ID = int(id)
+	return nil
+}
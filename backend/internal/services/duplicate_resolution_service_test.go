@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+func newDuplicateTestRecipe(title string, ingredients ...string) models.RecipeData {
+	recipe := models.RecipeData{
+		Title:         title,
+		CookingTime:   10,
+		Steps:         []string{"作る"},
+		Season:        "all",
+		LazinessScore: 8.0,
+		ServingSize:   models.FlexibleInt(1),
+	}
+	for _, name := range ingredients {
+		recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Amount: "100g"})
+	}
+	return recipe
+}
+
+func insertDuplicatePair(t *testing.T, db *database.Database, recipeID, similarID int, cosine, jaccard float64) {
+	t.Helper()
+	_, err := db.Exec(`
+		INSERT INTO duplicate_detection_results (recipe_id, similar_recipe_id, similarity_score, jaccard_score, detection_method)
+		VALUES (?, ?, ?, ?, 'combined')
+	`, recipeID, similarID, cosine, jaccard)
+	require.NoError(t, err)
+}
+
+func TestDuplicateResolution_MergeRepointsReferences(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	resolver := NewDuplicateResolutionService(db, nil)
+	require.NoError(t, resolver.EnsureSchema())
+
+	keptID := insertTestRecipe(t, db, newDuplicateTestRecipe("豚キャベツ炒め", "豚肉", "キャベツ"))
+	removedID := insertTestRecipe(t, db, newDuplicateTestRecipe("豚とキャベツの炒め物", "豚肉", "キャベツ"))
+	insertDuplicatePair(t, db, removedID, keptID, 0.96, 1.0)
+
+	weekData, err := json.Marshal(models.MealPlanData{
+		StartDate: "2025-01-06",
+		DailyRecipes: map[string]models.DailyRecipe{
+			"monday":  {RecipeID: removedID, Title: "豚とキャベツの炒め物"},
+			"tuesday": {RecipeID: keptID, Title: "豚キャベツ炒め"},
+		},
+	})
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO meal_plans (week_data) VALUES (?)`, string(weekData))
+	require.NoError(t, err)
+
+	// Both recipes are mapped to the same combination, which counts them twice
+	_, err = db.Exec(`
+		INSERT INTO recipe_dimension_mappings (recipe_id, dimension_id)
+		SELECT ?, id FROM recipe_dimensions WHERE dimension_type = 'protein' AND dimension_value = '豚肉';
+		INSERT INTO recipe_dimension_mappings (recipe_id, dimension_id)
+		SELECT ?, id FROM recipe_dimensions WHERE dimension_type = 'protein' AND dimension_value = '豚肉';
+		INSERT INTO dimension_coverage (dimension_combo, current_count) VALUES ('{"protein":"豚肉"}', 2);
+	`, keptID, removedID)
+	require.NoError(t, err)
+
+	resolution, err := resolver.Resolve(DuplicateResolutionRequest{
+		RecipeID:        removedID,
+		SimilarRecipeID: keptID,
+		Action:          DuplicateActionMerge,
+		ResolvedBy:      "admin",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, keptID, resolution.KeptRecipeID, "the older recipe is kept by default")
+	assert.Equal(t, removedID, resolution.RemovedRecipeID)
+	assert.Equal(t, "豚とキャベツの炒め物", resolution.RemovedTitle)
+	assert.Equal(t, 1, resolution.MealPlansUpdated)
+	assert.Equal(t, 1, resolution.CoverageAdjusted)
+	assert.Equal(t, 0.96, resolution.SimilarityScore)
+
+	_, err = NewRecipeRepository(db).GetRecipe(removedID)
+	assert.ErrorIs(t, err, models.ErrRecipeNotFound)
+
+	var stored string
+	require.NoError(t, db.QueryRow(`SELECT week_data FROM meal_plans`).Scan(&stored))
+	var plan models.MealPlanData
+	require.NoError(t, json.Unmarshal([]byte(stored), &plan))
+	assert.Equal(t, keptID, plan.DailyRecipes["monday"].RecipeID)
+	assert.Equal(t, "豚キャベツ炒め", plan.DailyRecipes["monday"].Title)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT current_count FROM dimension_coverage WHERE dimension_combo = '{"protein":"豚肉"}'`).Scan(&count))
+	assert.Equal(t, 1, count)
+
+	resolutions, err := resolver.GetResolutions(10)
+	require.NoError(t, err)
+	require.Len(t, resolutions, 1)
+	assert.Equal(t, DuplicateActionMerge, resolutions[0].Action)
+	assert.Equal(t, "admin", resolutions[0].ResolvedBy)
+	assert.Equal(t, min(keptID, removedID), resolutions[0].RecipeID)
+
+	_, err = resolver.Resolve(DuplicateResolutionRequest{RecipeID: removedID, SimilarRecipeID: keptID, Action: DuplicateActionMerge})
+	assert.ErrorIs(t, err, models.ErrRecipeNotFound)
+	_, err = resolver.Resolve(DuplicateResolutionRequest{RecipeID: keptID, SimilarRecipeID: keptID, Action: DuplicateActionMerge})
+	assert.ErrorIs(t, err, ErrInvalidDuplicateAction)
+}
+
+func TestDuplicateResolution_KeepBothSuppressesPair(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	resolver := NewDuplicateResolutionService(db, nil)
+	dedup := NewEmbeddingDeduplicator(NewLocalProvider(), db.DB)
+
+	firstID := insertTestRecipe(t, db, newDuplicateTestRecipe("親子丼", "鶏肉", "卵"))
+	secondID := insertTestRecipe(t, db, newDuplicateTestRecipe("他人丼", "豚肉", "卵"))
+	insertDuplicatePair(t, db, secondID, firstID, 0.9, 0.5)
+
+	_, err := resolver.Resolve(DuplicateResolutionRequest{RecipeID: secondID, SimilarRecipeID: firstID, Action: DuplicateActionKeepBoth})
+	require.NoError(t, err)
+
+	results, err := dedup.GetDuplicateResults(10, "")
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Later scans don't report the pair again, in either direction
+	require.NoError(t, dedup.saveDuplicateResult(DuplicateResult{RecipeID: firstID, SimilarRecipeID: secondID, SimilarityScore: 0.9, DetectionMethod: "embedding"}))
+	results, err = dedup.GetDuplicateResults(10, "")
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Both recipes survive
+	for _, id := range []int{firstID, secondID} {
+		_, err := NewRecipeRepository(db).GetRecipe(id)
+		assert.NoError(t, err)
+	}
+}
+
+func TestDuplicateResolution_AutoMergeAboveThresholds(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	resolver := NewDuplicateResolutionService(db, nil)
+
+	a := insertTestRecipe(t, db, newDuplicateTestRecipe("卵かけご飯", "卵", "ご飯"))
+	b := insertTestRecipe(t, db, newDuplicateTestRecipe("卵かけごはん", "卵", "ご飯"))
+	c := insertTestRecipe(t, db, newDuplicateTestRecipe("TKG", "卵", "ご飯"))
+	d := insertTestRecipe(t, db, newDuplicateTestRecipe("卵雑炊", "卵", "ご飯", "だし"))
+	insertDuplicatePair(t, db, b, a, 0.99, 1.0)
+	insertDuplicatePair(t, db, c, b, 0.98, 1.0) // b is merged away first
+	insertDuplicatePair(t, db, d, a, 0.99, 0.67)
+
+	dryRun, err := resolver.AutoMerge(AutoMergeRequest{DryRun: true})
+	require.NoError(t, err)
+	assert.Len(t, dryRun.Candidates, 2)
+	assert.Empty(t, dryRun.Merged)
+
+	result, err := resolver.AutoMerge(AutoMergeRequest{CosineThreshold: 0.95, JaccardThreshold: 0.9})
+	require.NoError(t, err)
+	require.Len(t, result.Merged, 1)
+	assert.Equal(t, a, result.Merged[0].KeptRecipeID)
+	assert.Equal(t, b, result.Merged[0].RemovedRecipeID)
+	assert.True(t, result.Merged[0].Automatic)
+	assert.Equal(t, 1, result.Skipped)
+
+	for id, exists := range map[int]bool{a: true, b: false, c: true, d: true} {
+		_, err := NewRecipeRepository(db).GetRecipe(id)
+		assert.Equal(t, exists, err == nil, "recipe %d", id)
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/sashabaranov/go-openai"
 	"lazychef/internal/models"
@@ -17,11 +20,17 @@ import (
 
 // EmbeddingDeduplicator handles recipe similarity detection using OpenAI embeddings
 type EmbeddingDeduplicator struct {
-	client              *openai.Client
+	provider            LLMProvider
 	db                  *sql.DB
+	embeddingModel      string
 	embeddingVersion    string  // "v3"
 	similarityThreshold float64 // cosine similarity threshold for duplicates
 	jaccardThreshold    float64 // jaccard coefficient threshold for ingredients
+	topK                int     // nearest neighbours examined per recipe
+
+	index     *VectorIndex
+	indexMu   sync.Mutex // guards index replacement and indexLoad
+	indexLoad bool
 }
 
 // RecipeEmbedding represents a stored recipe embedding
@@ -52,16 +61,115 @@ type SimilarityReport struct {
 }
 
 // NewEmbeddingDeduplicator creates a new embedding deduplicator
-func NewEmbeddingDeduplicator(client *openai.Client, db *sql.DB) *EmbeddingDeduplicator {
+func NewEmbeddingDeduplicator(provider LLMProvider, db *sql.DB) *EmbeddingDeduplicator {
 	return &EmbeddingDeduplicator{
-		client:              client,
+		provider:            provider,
 		db:                  db,
+		embeddingModel:      string(openai.AdaEmbeddingV2),
 		embeddingVersion:    "v3",
 		similarityThreshold: 0.85, // 85% similarity threshold
 		jaccardThreshold:    0.7,  // 70% ingredient overlap
+		topK:                20,
+		index:               NewVectorIndex(),
 	}
 }
 
+// LoadIndex rebuilds the in-memory vector index from recipe_embeddings.
+// Legacy JSON-encoded vectors are rewritten as binary blobs on the way.
+func (d *EmbeddingDeduplicator) LoadIndex() error {
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+
+	rows, err := d.db.Query(`
+		SELECT recipe_id, embedding, dimensions
+		FROM recipe_embeddings
+		WHERE embedding_version = ?
+	`, d.embeddingVersion)
+	if err != nil {
+		return fmt.Errorf("failed to query embeddings: %w", err)
+	}
+
+	index := NewVectorIndex()
+	legacy := make(map[int][]float32)
+	for rows.Next() {
+		var recipeID, dimensions int
+		var blob []byte
+		if err := rows.Scan(&recipeID, &blob, &dimensions); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan embedding: %w", err)
+		}
+
+		vector, isLegacy, err := decodeEmbedding(blob, dimensions)
+		if err != nil {
+			log.Printf("Warning: skipping embedding for recipe %d: %v", recipeID, err)
+			continue
+		}
+		if isLegacy {
+			legacy[recipeID] = vector
+		}
+		if err := index.Add(recipeID, vector); err != nil {
+			log.Printf("Warning: skipping embedding for recipe %d: %v", recipeID, err)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	for recipeID, vector := range legacy {
+		if _, err := d.db.Exec(`UPDATE recipe_embeddings SET embedding = ? WHERE recipe_id = ?`,
+			encodeEmbedding(vector), recipeID); err != nil {
+			log.Printf("Warning: failed to convert embedding for recipe %d: %v", recipeID, err)
+		}
+	}
+
+	d.index = index
+	d.indexLoad = true
+	log.Printf("Loaded %d recipe embeddings into vector index (%d converted from JSON)", index.Len(), len(legacy))
+	return nil
+}
+
+// IndexSize returns the number of vectors in the in-memory index
+func (d *EmbeddingDeduplicator) IndexSize() int {
+	return d.vectorIndex().Len()
+}
+
+// HandleRecipeSaved embeds a newly saved or updated recipe, adds it to the
+// index and records any duplicates it has. It runs in the background because
+// embedding requires a provider call; register it with Database.OnRecipeSaved.
+func (d *EmbeddingDeduplicator) HandleRecipeSaved(recipeID int) {
+	go func() {
+		ctx := WithUsageSource(context.Background(), UsageSource{Endpoint: "embedding-index"})
+		if err := d.IndexRecipe(ctx, recipeID); err != nil {
+			log.Printf("Warning: failed to index recipe %d: %v", recipeID, err)
+		}
+	}()
+}
+
+// IndexRecipe embeds a stored recipe, updates the index and saves duplicate pairs
+func (d *EmbeddingDeduplicator) IndexRecipe(ctx context.Context, recipeID int) error {
+	recipe, err := d.getRecipeByID(recipeID)
+	if err != nil {
+		return fmt.Errorf("failed to get recipe: %w", err)
+	}
+
+	embedding, err := d.getOrCreateEmbedding(ctx, recipe, false)
+	if err != nil {
+		return err
+	}
+
+	similarities, err := d.findSimilarRecipes(ctx, recipe, embedding)
+	if err != nil {
+		return fmt.Errorf("failed to find similar recipes: %w", err)
+	}
+	for _, sim := range similarities {
+		if err := d.saveDuplicateResult(sim); err != nil {
+			log.Printf("Warning: failed to save duplicate result: %v", err)
+		}
+	}
+
+	return nil
+}
+
 // ScanForDuplicates performs a full duplicate detection scan
 func (d *EmbeddingDeduplicator) ScanForDuplicates(ctx context.Context, forceRefresh bool) (*SimilarityReport, error) {
 	// Get all recipes
@@ -165,8 +273,9 @@ func (d *EmbeddingDeduplicator) RefreshEmbedding(ctx context.Context, recipeID i
 func (d *EmbeddingDeduplicator) GetDuplicateResults(limit int, method string) ([]DuplicateResult, error) {
 	query := `
 		SELECT recipe_id, similar_recipe_id, similarity_score, jaccard_score, detection_method
-		FROM duplicate_detection_results 
+		FROM duplicate_detection_results d
 		WHERE ($1 = '' OR detection_method = $1)
+		  AND NOT ` + keptPairPredicate("d") + `
 		ORDER BY similarity_score DESC, detected_at DESC
 		LIMIT $2
 	`
@@ -292,19 +401,35 @@ func (d *EmbeddingDeduplicator) getOrCreateEmbedding(ctx context.Context, recipe
 		return nil, fmt.Errorf("failed to store embedding: %w", err)
 	}
 
+	if err := d.vectorIndex().Add(embedding.RecipeID, embedding.Embedding); err != nil {
+		log.Printf("Warning: failed to index embedding for recipe %d: %v", embedding.RecipeID, err)
+	}
+
 	return embedding, nil
 }
 
+// SearchByText embeds free text, such as a search query, and returns the k
+// most similar indexed recipes. Only recipes with stored embeddings are found.
+func (d *EmbeddingDeduplicator) SearchByText(ctx context.Context, text string, k int) ([]VectorMatch, error) {
+	vector, err := d.embedText(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+	return d.vectorIndex().Search(vector, k), nil
+}
+
 func (d *EmbeddingDeduplicator) generateEmbedding(ctx context.Context, recipe *models.RecipeData) ([]float32, error) {
 	// Create text representation for embedding
-	text := d.recipeToText(recipe)
+	return d.embedText(ctx, d.recipeToText(recipe))
+}
 
+func (d *EmbeddingDeduplicator) embedText(ctx context.Context, text string) ([]float32, error) {
 	req := openai.EmbeddingRequest{
 		Input: []string{text},
-		Model: openai.AdaEmbeddingV2, // text-embedding-3-small or text-embedding-3-large
+		Model: openai.EmbeddingModel(d.embeddingModel),
 	}
 
-	resp, err := d.client.CreateEmbeddings(ctx, req)
+	resp, err := d.provider.CreateEmbeddings(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding: %w", err)
 	}
@@ -371,27 +496,23 @@ func (d *EmbeddingDeduplicator) getStoredEmbedding(recipeID int) (*RecipeEmbeddi
 	}
 
 	// Deserialize embedding vector
-	if err := json.Unmarshal(embeddingBlob, &embedding.Embedding); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal embedding: %w", err)
+	embedding.Embedding, _, err = decodeEmbedding(embeddingBlob, embedding.Dimensions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode embedding: %w", err)
 	}
 
 	return &embedding, nil
 }
 
 func (d *EmbeddingDeduplicator) storeEmbedding(embedding *RecipeEmbedding) error {
-	// Serialize embedding vector
-	embeddingBlob, err := json.Marshal(embedding.Embedding)
-	if err != nil {
-		return fmt.Errorf("failed to marshal embedding: %w", err)
-	}
-
 	query := `
 		INSERT OR REPLACE INTO recipe_embeddings
 		(recipe_id, embedding_version, content_hash, embedding, dimensions)
 		VALUES (?, ?, ?, ?, ?)
 	`
 
-	_, err = d.db.Exec(query,
+	embeddingBlob := encodeEmbedding(embedding.Embedding)
+	_, err := d.db.Exec(query,
 		embedding.RecipeID, embedding.EmbeddingVersion, embedding.ContentHash,
 		embeddingBlob, embedding.Dimensions,
 	)
@@ -400,74 +521,50 @@ func (d *EmbeddingDeduplicator) storeEmbedding(embedding *RecipeEmbedding) error
 }
 
 func (d *EmbeddingDeduplicator) findSimilarRecipes(ctx context.Context, targetRecipe *RecipeWithID, targetEmbedding *RecipeEmbedding) ([]DuplicateResult, error) {
-	// Get all other embeddings
-	query := `
-		SELECT recipe_id, embedding_version, content_hash, embedding, dimensions
-		FROM recipe_embeddings 
-		WHERE recipe_id != ? AND embedding_version = ?
-	`
-
-	rows, err := d.db.Query(query, targetRecipe.ID, d.embeddingVersion)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query embeddings: %w", err)
+	// Ask the vector index for the nearest neighbours (one extra for the recipe itself)
+	index := d.vectorIndex()
+	if index.Len() == 0 {
+		return nil, nil
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			log.Printf("Warning: failed to close rows: %v", err)
-		}
-	}()
+	matches := index.Search(targetEmbedding.Embedding, d.topK+1)
 
 	var results []DuplicateResult
 
-	for rows.Next() {
-		var otherEmbedding RecipeEmbedding
-		var embeddingBlob []byte
-
-		err := rows.Scan(
-			&otherEmbedding.RecipeID, &otherEmbedding.EmbeddingVersion,
-			&otherEmbedding.ContentHash, &embeddingBlob, &otherEmbedding.Dimensions,
-		)
-		if err != nil {
-			log.Printf("Warning: failed to scan embedding: %v", err)
+	for _, match := range matches {
+		if match.ID == targetRecipe.ID {
 			continue
 		}
-
-		// Deserialize embedding
-		if err := json.Unmarshal(embeddingBlob, &otherEmbedding.Embedding); err != nil {
-			log.Printf("Warning: failed to unmarshal embedding: %v", err)
-			continue
+		if match.Similarity < d.similarityThreshold {
+			break // Matches are sorted, nothing further qualifies
 		}
 
-		// Calculate cosine similarity
-		cosineSim := d.cosineSimilarity(targetEmbedding.Embedding, otherEmbedding.Embedding)
-
-		if cosineSim >= d.similarityThreshold {
-			// Get the other recipe for Jaccard calculation
-			otherRecipe, err := d.getRecipeByID(otherEmbedding.RecipeID)
-			if err != nil {
-				log.Printf("Warning: failed to get recipe %d: %v", otherEmbedding.RecipeID, err)
+		// Get the other recipe for Jaccard calculation
+		otherRecipe, err := d.getRecipeByID(match.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				index.Remove(match.ID) // Recipe was deleted since it was indexed
 				continue
 			}
+			log.Printf("Warning: failed to get recipe %d: %v", match.ID, err)
+			continue
+		}
 
-			// Calculate Jaccard coefficient for ingredients
-			jaccardSim := d.jaccardSimilarity(targetRecipe.Data.Ingredients, otherRecipe.Data.Ingredients)
-
-			// Determine detection method
-			method := "embedding"
-			if jaccardSim >= d.jaccardThreshold {
-				method = "combined"
-			}
-
-			result := DuplicateResult{
-				RecipeID:        targetRecipe.ID,
-				SimilarRecipeID: otherEmbedding.RecipeID,
-				SimilarityScore: cosineSim,
-				JaccardScore:    jaccardSim,
-				DetectionMethod: method,
-			}
+		// Calculate Jaccard coefficient for ingredients
+		jaccardSim := d.jaccardSimilarity(targetRecipe.Data.Ingredients, otherRecipe.Data.Ingredients)
 
-			results = append(results, result)
+		// Determine detection method
+		method := "embedding"
+		if jaccardSim >= d.jaccardThreshold {
+			method = "combined"
 		}
+
+		results = append(results, DuplicateResult{
+			RecipeID:        targetRecipe.ID,
+			SimilarRecipeID: match.ID,
+			SimilarityScore: match.Similarity,
+			JaccardScore:    jaccardSim,
+			DetectionMethod: method,
+		})
 	}
 
 	// Sort by similarity score (highest first)
@@ -478,6 +575,55 @@ func (d *EmbeddingDeduplicator) findSimilarRecipes(ctx context.Context, targetRe
 	return results, nil
 }
 
+// vectorIndex returns the index, loading it from the database on first use
+func (d *EmbeddingDeduplicator) vectorIndex() *VectorIndex {
+	d.indexMu.Lock()
+	loaded := d.indexLoad
+	d.indexMu.Unlock()
+
+	if !loaded && d.db != nil {
+		if err := d.LoadIndex(); err != nil {
+			log.Printf("Warning: failed to load vector index: %v", err)
+			d.indexMu.Lock()
+			d.indexLoad = true // Don't retry on every call; new embeddings are still added
+			d.indexMu.Unlock()
+		}
+	}
+
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+	return d.index
+}
+
+// encodeEmbedding packs a vector as little-endian float32s
+func encodeEmbedding(vector []float32) []byte {
+	blob := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(v))
+	}
+	return blob
+}
+
+// decodeEmbedding unpacks a binary vector, also accepting the legacy JSON
+// array encoding (reported through isLegacy)
+func decodeEmbedding(blob []byte, dimensions int) (vector []float32, isLegacy bool, err error) {
+	if len(blob) > 0 && blob[0] == '[' {
+		if err := json.Unmarshal(blob, &vector); err != nil {
+			return nil, true, fmt.Errorf("failed to unmarshal legacy embedding: %w", err)
+		}
+		return vector, true, nil
+	}
+
+	if len(blob)%4 != 0 || (dimensions > 0 && len(blob) != 4*dimensions) {
+		return nil, false, fmt.Errorf("embedding blob has %d bytes, expected %d dimensions", len(blob), dimensions)
+	}
+	vector = make([]float32, len(blob)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+	}
+	return vector, false, nil
+}
+
 func (d *EmbeddingDeduplicator) cosineSimilarity(a, b []float32) float64 {
 	if len(a) != len(b) {
 		return 0
@@ -532,15 +678,21 @@ func (d *EmbeddingDeduplicator) jaccardSimilarity(ingredientsA, ingredientsB []m
 }
 
 func (d *EmbeddingDeduplicator) saveDuplicateResult(result DuplicateResult) error {
+	// Pairs an admin marked as "keep both" are not reported again
 	query := `
 		INSERT OR REPLACE INTO duplicate_detection_results
 		(recipe_id, similar_recipe_id, similarity_score, jaccard_score, detection_method)
-		VALUES (?, ?, ?, ?, ?)
+		SELECT ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM duplicate_resolutions
+			WHERE action = 'keep_both' AND recipe_id = ? AND similar_recipe_id = ?
+		)
 	`
 
 	_, err := d.db.Exec(query,
 		result.RecipeID, result.SimilarRecipeID, result.SimilarityScore,
 		result.JaccardScore, result.DetectionMethod,
+		min(result.RecipeID, result.SimilarRecipeID), max(result.RecipeID, result.SimilarRecipeID),
 	)
 
 	return err
@@ -556,3 +708,23 @@ func (d *EmbeddingDeduplicator) SetThresholds(cosineThreshold, jaccardThreshold
 func (d *EmbeddingDeduplicator) GetThresholds() (cosine, jaccard float64) {
 	return d.similarityThreshold, d.jaccardThreshold
 }
+
+// SetEmbeddingModel switches the embedding model. Vectors from different
+// models are not comparable, so non-default models get their own version tag.
+func (d *EmbeddingDeduplicator) SetEmbeddingModel(model string) {
+	if model == "" {
+		return
+	}
+	d.embeddingModel = model
+	if model == string(openai.AdaEmbeddingV2) {
+		d.embeddingVersion = "v3"
+	} else {
+		d.embeddingVersion = "v3:" + model
+	}
+
+	// Vectors from another model aren't comparable; reload for the new version
+	d.indexMu.Lock()
+	d.index = NewVectorIndex()
+	d.indexLoad = false
+	d.indexMu.Unlock()
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func TestEncodeDecodeEmbedding(t *testing.T) {
+	vector := []float32{0.25, -1.5, 3}
+
+	blob := encodeEmbedding(vector)
+	assert.Len(t, blob, 12)
+	decoded, legacy, err := decodeEmbedding(blob, 3)
+	require.NoError(t, err)
+	assert.False(t, legacy)
+	assert.Equal(t, vector, decoded)
+
+	jsonBlob, err := json.Marshal(vector)
+	require.NoError(t, err)
+	decoded, legacy, err = decodeEmbedding(jsonBlob, 3)
+	require.NoError(t, err)
+	assert.True(t, legacy)
+	assert.Equal(t, vector, decoded)
+
+	_, _, err = decodeEmbedding(blob, 4)
+	assert.Error(t, err)
+}
+
+func TestEmbeddingDeduplicator_IndexesSavedRecipes(t *testing.T) {
+	db := setupSchemaDatabase(t)
+
+	newRecipe := func(title string, ingredients ...string) models.RecipeData {
+		recipe := models.RecipeData{
+			Title:         title,
+			CookingTime:   10,
+			Steps:         []string{"作る"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		}
+		for _, name := range ingredients {
+			recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Amount: "100g"})
+		}
+		return recipe
+	}
+	firstID := insertTestRecipe(t, db, newRecipe("豚キャベツ炒め", "豚肉", "キャベツ"))
+	secondID := insertTestRecipe(t, db, newRecipe("豚キャベツ炒め", "豚肉", "キャベツ"))
+	insertTestRecipe(t, db, newRecipe("チョコレートケーキ", "チョコレート", "小麦粉", "卵"))
+
+	dedup := NewEmbeddingDeduplicator(NewLocalProvider(), db.DB)
+	ctx := context.Background()
+	for _, id := range []int{firstID, secondID} {
+		require.NoError(t, dedup.IndexRecipe(ctx, id))
+	}
+	assert.Equal(t, 2, dedup.IndexSize())
+
+	results, err := dedup.GetDuplicateResults(10, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, secondID, results[0].RecipeID)
+	assert.Equal(t, firstID, results[0].SimilarRecipeID)
+	assert.Equal(t, "combined", results[0].DetectionMethod)
+
+	// Embeddings are stored as binary and reload into a fresh index
+	var blob []byte
+	require.NoError(t, db.QueryRow(`SELECT embedding FROM recipe_embeddings WHERE recipe_id = ?`, firstID).Scan(&blob))
+	assert.Len(t, blob, 4*localEmbeddingDimensions)
+
+	reloaded := NewEmbeddingDeduplicator(NewLocalProvider(), db.DB)
+	require.NoError(t, reloaded.LoadIndex())
+	assert.Equal(t, 2, reloaded.IndexSize())
+}
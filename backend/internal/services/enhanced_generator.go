@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sashabaranov/go-openai"
@@ -16,12 +17,19 @@ import (
 
 // EnhancedRecipeGeneratorService provides GPT-5 compatible recipe generation with Structured Outputs
 type EnhancedRecipeGeneratorService struct {
-	client              *openai.Client
+	provider            LLMProvider
 	config              *config.OpenAIConfig
 	rateLimiter         *RateLimiter
-	cache               *RecipeCache
+	cache               GenerationCache
+	cacheKeys           *CacheKeyNormalizer
 	foodSafetyValidator *FoodSafetyValidator
 	qualityValidator    *QualityCheckService
+	dietary             *DietaryRestrictionService
+	quarantine          *RecipeQuarantine
+
+	// structuredOutputsRejected is set once the provider refuses a JSON-schema
+	// response format, so later calls skip straight to tolerant parsing
+	structuredOutputsRejected atomic.Bool
 }
 
 // GenerationStage represents the stage of recipe generation
@@ -53,26 +61,50 @@ type EnhancedGenerationResult struct {
 	SystemFingerprint  string              `json:"system_fingerprint,omitempty"`
 	SafetyCheckResult  *SafetyCheckResult  `json:"safety_check_result,omitempty"`
 	QualityCheckResult *QualityCheckResult `json:"quality_check_result,omitempty"`
+	DietaryViolations  []DietaryViolation  `json:"dietary_violations,omitempty"`
+	RemediationRounds  []RemediationRound  `json:"remediation_rounds,omitempty"`
+	QuarantineID       int                 `json:"quarantine_id,omitempty"`
 	StructuredOutputs  bool                `json:"structured_outputs"`
 }
 
 // NewEnhancedRecipeGeneratorService creates a new enhanced generator service
-func NewEnhancedRecipeGeneratorService(client *openai.Client, config *config.OpenAIConfig, rateLimiter *RateLimiter, cache *RecipeCache) *EnhancedRecipeGeneratorService {
+func NewEnhancedRecipeGeneratorService(provider LLMProvider, config *config.OpenAIConfig, rateLimiter *RateLimiter, cache GenerationCache) *EnhancedRecipeGeneratorService {
 	return &EnhancedRecipeGeneratorService{
-		client:              client,
+		provider:            provider,
 		config:              config,
 		rateLimiter:         rateLimiter,
 		cache:               cache,
+		cacheKeys:           NewCacheKeyNormalizer(nil),
 		foodSafetyValidator: NewFoodSafetyValidator(config.FoodSafetyStrictMode),
 		qualityValidator:    NewQualityCheckService(config),
 	}
 }
 
+// SetCacheKeyNormalizer replaces the cache key normalizer
+func (s *EnhancedRecipeGeneratorService) SetCacheKeyNormalizer(normalizer *CacheKeyNormalizer) {
+	s.cacheKeys = normalizer
+}
+
+// SetDietaryRestrictionService lets the dietary check resolve hidden
+// ingredients through the ingredient hierarchy
+func (s *EnhancedRecipeGeneratorService) SetDietaryRestrictionService(dietary *DietaryRestrictionService) {
+	s.dietary = dietary
+}
+
 // GetConfig returns the OpenAI config
 func (s *EnhancedRecipeGeneratorService) GetConfig() *config.OpenAIConfig {
 	return s.config
 }
 
+// StructuredOutputsActive reports whether requests are sent with a JSON-schema
+// response format: enabled in config, supported by the provider and not
+// rejected at runtime
+func (s *EnhancedRecipeGeneratorService) StructuredOutputsActive() bool {
+	return s.config.UseStructuredOutputs &&
+		s.provider.SupportsStructuredOutputs() &&
+		!s.structuredOutputsRejected.Load()
+}
+
 // GetFoodSafetyValidator returns the food safety validator
 func (s *EnhancedRecipeGeneratorService) GetFoodSafetyValidator() *FoodSafetyValidator {
 	return s.foodSafetyValidator
@@ -90,11 +122,11 @@ func (s *EnhancedRecipeGeneratorService) GenerateRecipeEnhanced(ctx context.Cont
 
 	// Check cache first
 	cacheKey := s.generateEnhancedCacheKey(req)
-	if cachedResult := s.cache.Get(cacheKey); cachedResult != nil {
+	if cachedResult := s.cache.Get(cacheKey); cachedResult != nil && resultFitsRequest(cachedResult, req.MaxCookingTime) {
 		enhancedResult := &EnhancedGenerationResult{
 			GenerationResult:  cachedResult,
 			Stage:             req.Stage,
-			StructuredOutputs: s.config.UseStructuredOutputs,
+			StructuredOutputs: s.StructuredOutputsActive(),
 		}
 		enhancedResult.Metadata.CacheHit = true
 		enhancedResult.Metadata.RequestID = requestID
@@ -111,6 +143,7 @@ func (s *EnhancedRecipeGeneratorService) GenerateRecipeEnhanced(ctx context.Cont
 	model := s.selectModelForStage(req.Stage)
 
 	// Generate recipe using selected model
+	ctx = WithUsageSource(ctx, UsageSource{Stage: string(req.Stage)})
 	recipe, tokensUsed, systemFingerprint, err := s.generateWithStructuredOutputs(ctx, req, model)
 	if err != nil {
 		return &EnhancedGenerationResult{
@@ -126,15 +159,17 @@ func (s *EnhancedRecipeGeneratorService) GenerateRecipeEnhanced(ctx context.Cont
 			},
 			Stage:             req.Stage,
 			ModelUsed:         model,
-			StructuredOutputs: s.config.UseStructuredOutputs,
+			StructuredOutputs: s.StructuredOutputsActive(),
 		}, err
 	}
 
-	// Perform food safety validation
-	safetyResult, err := s.foodSafetyValidator.ValidateRecipe(recipe)
-	if err != nil {
-		return nil, fmt.Errorf("safety validation failed: %w", err)
+	// Perform food safety validation, remediating failures through the critique model
+	gate, gateErr := s.GateRecipe(ctx, recipe, "enhanced")
+	if gate == nil {
+		return nil, gateErr
 	}
+	recipe = gate.Recipe
+	safetyResult := gate.Safety
 
 	// Perform quality validation
 	qualityResult, err := s.qualityValidator.ValidateRecipe(recipe)
@@ -163,13 +198,23 @@ func (s *EnhancedRecipeGeneratorService) GenerateRecipeEnhanced(ctx context.Cont
 		SystemFingerprint:  systemFingerprint,
 		SafetyCheckResult:  safetyResult,
 		QualityCheckResult: qualityResult,
-		StructuredOutputs:  s.config.UseStructuredOutputs,
+		DietaryViolations:  s.dietary.Violations(recipe, req.DietaryRestrictions),
+		RemediationRounds:  gate.Rounds,
+		QuarantineID:       gate.QuarantineID,
+		StructuredOutputs:  s.StructuredOutputsActive(),
+	}
+
+	// Recipes that still failed the safety gate were quarantined
+	if gateErr != nil {
+		result.Error = gateErr.Error()
+		return result, gateErr
 	}
 
-	// Check if generation should be rejected based on safety/quality
-	if s.config.FoodSafetyStrictMode && !safetyResult.Passed {
-		result.Error = fmt.Sprintf("Recipe failed safety validation: %v", safetyResult.Violations)
-		return result, fmt.Errorf("recipe failed safety validation")
+	// Dietary restrictions are hard requirements regardless of strict mode
+	if len(result.DietaryViolations) > 0 {
+		err := fmt.Errorf("%w: %s", ErrDietaryRestrictionViolated, describeDietaryViolations(result.DietaryViolations))
+		result.Error = err.Error()
+		return result, err
 	}
 
 	// Cache successful result
@@ -222,8 +267,9 @@ func (s *EnhancedRecipeGeneratorService) generateWithStructuredOutputs(ctx conte
 		}
 	}
 
-	// Add Structured Outputs if enabled
-	if s.config.UseStructuredOutputs {
+	// Add Structured Outputs if enabled and the provider can honour them
+	structured := s.StructuredOutputsActive()
+	if structured {
 		schema := models.GetRecipeJSONSchema()
 
 		// Debug: print schema to see what's being sent
@@ -257,7 +303,19 @@ func (s *EnhancedRecipeGeneratorService) generateWithStructuredOutputs(ctx conte
 	defer cancel()
 
 	// Call OpenAI API
-	resp, err := s.client.CreateChatCompletion(timeoutCtx, chatReq)
+	resp, err := s.provider.CreateChatCompletion(timeoutCtx, chatReq)
+	if err != nil && structured && isStructuredOutputsUnsupported(err) {
+		// Self-hosted models often reject json_schema; retry once with plain JSON.
+		// Only a self-hosted server is assumed to keep rejecting it, so OpenAI
+		// still gets the schema on the next request
+		log.Printf("Provider %s rejected structured outputs, falling back to tolerant parsing: %v", s.provider.Name(), err)
+		if s.config.Provider == config.ProviderOpenAICompatible {
+			s.structuredOutputsRejected.Store(true)
+		}
+		structured = false
+		chatReq.ResponseFormat = nil
+		resp, err = s.provider.CreateChatCompletion(timeoutCtx, chatReq)
+	}
 	if err != nil {
 		return nil, 0, "", fmt.Errorf("OpenAI API call failed: %w", err)
 	}
@@ -266,8 +324,10 @@ func (s *EnhancedRecipeGeneratorService) generateWithStructuredOutputs(ctx conte
 		return nil, resp.Usage.TotalTokens, resp.SystemFingerprint, errors.New("no choices returned from OpenAI")
 	}
 
-	content := resp.Choices[0].Message.Content
-	content = strings.TrimSpace(content)
+	content := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if !structured {
+		content = extractJSONContent(content)
+	}
 
 	// Parse JSON response
 	var recipe models.RecipeData
@@ -275,6 +335,13 @@ func (s *EnhancedRecipeGeneratorService) generateWithStructuredOutputs(ctx conte
 		return nil, resp.Usage.TotalTokens, resp.SystemFingerprint, fmt.Errorf("failed to parse recipe JSON: %w", err)
 	}
 
+	// Without a schema the model may use legacy field names or ranges
+	if !structured {
+		if err := fixRecipeInconsistencies(&recipe, content); err != nil {
+			return nil, resp.Usage.TotalTokens, resp.SystemFingerprint, fmt.Errorf("failed to fix recipe inconsistencies: %w", err)
+		}
+	}
+
 	return &recipe, resp.Usage.TotalTokens, resp.SystemFingerprint, nil
 }
 
@@ -292,6 +359,30 @@ func (s *EnhancedRecipeGeneratorService) selectModelForStage(stage GenerationSta
 	}
 }
 
+// isStructuredOutputsUnsupported reports whether an API error means the server
+// does not understand the JSON-schema response format. Other 400/422 errors,
+// such as an unknown model or an oversized prompt, are not fixed by dropping
+// the schema
+func isStructuredOutputsUnsupported(err error) bool {
+	var message string
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		message = apiErr.Message
+		if apiErr.Param != nil {
+			message += " " + *apiErr.Param
+		}
+	case errors.As(err, &reqErr):
+		message = string(reqErr.Body)
+	default:
+		message = err.Error()
+	}
+
+	message = strings.ToLower(message)
+	return strings.Contains(message, "response_format") || strings.Contains(message, "json_schema")
+}
+
 // isGPT5Model checks if the model is a GPT-5 variant
 func (s *EnhancedRecipeGeneratorService) isGPT5Model(model string) bool {
 	return strings.HasPrefix(model, "gpt-5")
@@ -305,8 +396,9 @@ func (s *EnhancedRecipeGeneratorService) generateEnhancedPrompt(req EnhancedGene
 	safetyInstructions := `
 
 CRITICAL FOOD SAFETY REQUIREMENTS:
-- Always specify safe cooking temperatures for meat, poultry, and eggs
-- Chicken/poultry: 165°F, Ground beef/pork: 160°F, Whole cuts beef/pork/lamb: 145°F
+- Always state how meat, poultry, fish and eggs are cooked through, in Celsius or with a doneness cue home cooks can see
+- Chicken/poultry: 74°C, Ground beef/pork: 71°C, Whole cuts beef/pork/lamb and fish: 63°C
+- Doneness cues such as 「中まで火が通るまで」「肉汁が透明になるまで」「卵が固まるまで」 or a heating time (「5分加熱する」) are acceptable instead of a temperature
 - Never suggest eating raw flour, raw eggs in no-bake recipes, or undercooked meat
 - Include allergen warnings for common allergens (nuts, dairy, eggs, wheat, soy, fish, shellfish)
 - Avoid dangerous practices: thawing on counter, reusing marinades, rinsing raw chicken
@@ -321,7 +413,7 @@ LAZYCHEF CONSTRAINTS:
 	enhancedSystemPrompt := basePrompt.SystemPrompt + safetyInstructions
 
 	// Add structured output instruction if enabled
-	if s.config.UseStructuredOutputs {
+	if s.StructuredOutputsActive() {
 		enhancedSystemPrompt += "\n\nIMPORTANT: Respond with valid JSON matching the exact schema provided. Include the safety_compliance object with required food safety information."
 	}
 
@@ -333,11 +425,6 @@ LAZYCHEF CONSTRAINTS:
 
 // generateEnhancedCacheKey generates a cache key for enhanced requests
 func (s *EnhancedRecipeGeneratorService) generateEnhancedCacheKey(req EnhancedGenerationRequest) string {
-	baseKey := s.generateCacheKey(req.RecipeGenerationRequest)
-	return fmt.Sprintf("%s:stage=%s:structured=%t", baseKey, req.Stage, s.config.UseStructuredOutputs)
-}
-
-// generateCacheKey generates a cache key from the base request
-func (s *EnhancedRecipeGeneratorService) generateCacheKey(req RecipeGenerationRequest) string {
-	return fmt.Sprintf("recipe:%s:%d:%s", strings.Join(req.Ingredients, ","), req.MaxCookingTime, strings.Join(req.Preferences, ","))
+	baseKey := s.cacheKeys.Key(req.RecipeGenerationRequest)
+	return fmt.Sprintf("%s:stage=%s:structured=%t", baseKey, req.Stage, s.StructuredOutputsActive())
 }
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/config"
+)
+
+// newCompatibleServer fakes an OpenAI-compatible server that, like many
+// self-hosted backends, rejects JSON-schema response formats and wraps its
+// answer in a Markdown code fence
+func newCompatibleServer(t *testing.T, schemaRequests *int32) *httptest.Server {
+	t.Helper()
+	return newSchemaRejectingServer(t, schemaRequests, `{"error":{"message":"response_format json_schema is not supported","type":"invalid_request_error"}}`)
+}
+
+// newSchemaRejectingServer answers requests that carry a response format with
+// a 400 and the given error body, and plain JSON requests with a recipe
+func newSchemaRejectingServer(t *testing.T, schemaRequests *int32, errorBody string) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, ok := req["response_format"]; ok {
+			atomic.AddInt32(schemaRequests, 1)
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(errorBody))
+			return
+		}
+
+		content := "```json\n" + `{"title":"鶏肉のレンジ蒸し","active_time":7,"ingredients":[{"name":"鶏肉","amount":"200g"}],` +
+			`"steps":[{"instruction":"耐熱皿に入れる","effort_level":1},{"instruction":"レンジで加熱する","effort_level":2}],` +
+			`"season":"オールシーズン","laziness_score":85,"serving_size":"2"}` + "\n```"
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion",
+			"model":   req["model"],
+			"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": content}, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 20, "total_tokens": 30},
+		})
+	}))
+}
+
+func TestEnhancedGenerator_FallsBackWhenStructuredOutputsRejected(t *testing.T) {
+	var schemaRequests int32
+	server := newCompatibleServer(t, &schemaRequests)
+	defer server.Close()
+
+	cfg := &config.OpenAIConfig{
+		Provider:                          config.ProviderOpenAICompatible,
+		BaseURL:                           server.URL + "/v1",
+		Model:                             "llama3.1:8b",
+		AuthoringModel:                    "llama3.1:8b",
+		MaxTokens:                         1000,
+		Temperature:                       0.7,
+		RequestTimeout:                    5 * time.Second,
+		RequestsPerMinute:                 600,
+		UseStructuredOutputs:              true,
+		ProviderSupportsStructuredOutputs: true, // Server claims support but rejects it
+	}
+	provider, err := NewLLMProvider(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, config.ProviderOpenAICompatible, provider.Name())
+
+	service := NewEnhancedRecipeGeneratorService(provider, cfg, NewRateLimiter(600), NewRecipeCache(10, time.Minute))
+	assert.True(t, service.StructuredOutputsActive())
+
+	result, err := service.GenerateRecipeEnhanced(context.Background(), EnhancedGenerationRequest{
+		RecipeGenerationRequest: RecipeGenerationRequest{Ingredients: []string{"鶏肉"}, Season: "all", MaxCookingTime: 10},
+		Stage:                   StageAuthoring,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.Recipe)
+
+	// Tolerant parsing plus fixRecipeInconsistencies repaired the legacy shape
+	assert.Equal(t, "鶏肉のレンジ蒸し", result.Recipe.Title)
+	assert.Equal(t, 7, result.Recipe.CookingTime)
+	assert.Equal(t, "all", result.Recipe.Season)
+	assert.Equal(t, 8.5, result.Recipe.LazinessScore)
+	assert.Equal(t, 2, result.Recipe.ServingSize.Int())
+	assert.Equal(t, []string{"耐熱皿に入れる", "レンジで加熱する"}, []string(result.Recipe.Steps))
+	assert.False(t, result.StructuredOutputs)
+
+	// The rejection is remembered, so the next request skips the schema
+	assert.False(t, service.StructuredOutputsActive())
+	_, err = service.GenerateRecipeEnhanced(context.Background(), EnhancedGenerationRequest{
+		RecipeGenerationRequest: RecipeGenerationRequest{Ingredients: []string{"豚肉"}, Season: "all", MaxCookingTime: 10},
+		Stage:                   StageAuthoring,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&schemaRequests))
+}
+
+func TestEnhancedGenerator_OnlyFallsBackOnSchemaErrors(t *testing.T) {
+	var schemaRequests int32
+	server := newSchemaRejectingServer(t, &schemaRequests, `{"error":{"message":"model 'llama3.1:8b' not found","type":"invalid_request_error"}}`)
+	defer server.Close()
+
+	cfg := &config.OpenAIConfig{
+		Provider:                          config.ProviderOpenAICompatible,
+		BaseURL:                           server.URL + "/v1",
+		AuthoringModel:                    "llama3.1:8b",
+		MaxTokens:                         1000,
+		RequestTimeout:                    5 * time.Second,
+		RequestsPerMinute:                 600,
+		UseStructuredOutputs:              true,
+		ProviderSupportsStructuredOutputs: true,
+	}
+	provider, err := NewLLMProvider(cfg)
+	require.NoError(t, err)
+
+	service := NewEnhancedRecipeGeneratorService(provider, cfg, NewRateLimiter(600), NewRecipeCache(10, time.Minute))
+	_, err = service.GenerateRecipeEnhanced(context.Background(), EnhancedGenerationRequest{
+		RecipeGenerationRequest: RecipeGenerationRequest{Ingredients: []string{"鶏肉"}, Season: "all", MaxCookingTime: 10},
+		Stage:                   StageAuthoring,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+	assert.True(t, service.StructuredOutputsActive(), "an unrelated 400 does not disable structured outputs")
+}
+
+func TestEnhancedGenerator_OpenAIFallbackIsNotRemembered(t *testing.T) {
+	var schemaRequests int32
+	server := newCompatibleServer(t, &schemaRequests)
+	defer server.Close()
+
+	cfg := &config.OpenAIConfig{
+		Provider:             config.ProviderOpenAI,
+		APIKey:               "test-key",
+		BaseURL:              server.URL + "/v1",
+		AuthoringModel:       "gpt-4o-mini",
+		MaxTokens:            1000,
+		RequestTimeout:       5 * time.Second,
+		RequestsPerMinute:    600,
+		UseStructuredOutputs: true,
+	}
+	provider, err := NewLLMProvider(cfg)
+	require.NoError(t, err)
+
+	service := NewEnhancedRecipeGeneratorService(provider, cfg, NewRateLimiter(600), NewRecipeCache(10, time.Minute))
+	for _, ingredient := range []string{"鶏肉", "豚肉"} {
+		result, err := service.GenerateRecipeEnhanced(context.Background(), EnhancedGenerationRequest{
+			RecipeGenerationRequest: RecipeGenerationRequest{Ingredients: []string{ingredient}, Season: "all", MaxCookingTime: 10},
+			Stage:                   StageAuthoring,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, result.Recipe)
+	}
+
+	// Each request still tries the schema first
+	assert.True(t, service.StructuredOutputsActive())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&schemaRequests))
+}
+
+func TestEnhancedGenerator_SkipsSchemaWhenProviderLacksSupport(t *testing.T) {
+	var schemaRequests int32
+	server := newCompatibleServer(t, &schemaRequests)
+	defer server.Close()
+
+	cfg := &config.OpenAIConfig{
+		Provider:             config.ProviderOpenAICompatible,
+		BaseURL:              server.URL + "/v1",
+		AuthoringModel:       "qwen2.5",
+		MaxTokens:            1000,
+		RequestTimeout:       5 * time.Second,
+		RequestsPerMinute:    600,
+		UseStructuredOutputs: true,
+	}
+	provider, err := NewLLMProvider(cfg)
+	require.NoError(t, err)
+
+	service := NewEnhancedRecipeGeneratorService(provider, cfg, NewRateLimiter(600), NewRecipeCache(10, time.Minute))
+	assert.False(t, service.StructuredOutputsActive())
+
+	_, err = service.GenerateRecipeEnhanced(context.Background(), EnhancedGenerationRequest{
+		RecipeGenerationRequest: RecipeGenerationRequest{Ingredients: []string{"鶏肉"}, Season: "all", MaxCookingTime: 10},
+		Stage:                   StageAuthoring,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&schemaRequests))
+}
+
+func TestExtractJSONContent(t *testing.T) {
+	assert.Equal(t, `{"a":1}`, extractJSONContent("```json\n{\"a\":1}\n```"))
+	assert.Equal(t, `{"a":{"b":2}}`, extractJSONContent(`Here is your recipe: {"a":{"b":2}} Enjoy!`))
+	assert.Equal(t, "not json", extractJSONContent("  not json  "))
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"lazychef/internal/models"
+)
+
+// defaultSafetyRulesYAML is the built-in bilingual rule set
+//
+//go:embed rules/food_safety_rules.yaml
+var defaultSafetyRulesYAML []byte
+
+// ErrInvalidSafetyRule is returned when a rules file has an incomplete rule
+// or a pattern that does not compile
+var ErrInvalidSafetyRule = errors.New("invalid food safety rule")
+
+// Food safety rule severities
+const (
+	SafetySeverityViolation = "violation"
+	SafetySeverityWarning   = "warning"
+)
+
+// defaultNegationWindow is used when a rules file does not set one
+const defaultNegationWindow = 12
+
+// ingredientPlaceholder in a rule message is replaced by the ingredient name
+const ingredientPlaceholder = "{ingredient}"
+
+// SafetyRuleMessage is a rule's explanation in Japanese and English
+type SafetyRuleMessage struct {
+	Ja string `json:"ja" yaml:"ja"`
+	En string `json:"en" yaml:"en"`
+}
+
+// SafetyRuleSpec is one rule as written in a rules file. See
+// rules/food_safety_rules.yaml for what each condition means.
+type SafetyRuleSpec struct {
+	ID          string            `json:"id" yaml:"id"`
+	Severity    string            `json:"severity" yaml:"severity"`
+	Message     SafetyRuleMessage `json:"message" yaml:"message"`
+	Ingredients []string          `json:"ingredients,omitempty" yaml:"ingredients"`
+	Patterns    []string          `json:"patterns,omitempty" yaml:"patterns"`
+	Require     []string          `json:"require,omitempty" yaml:"require"`
+	Unless      []string          `json:"unless,omitempty" yaml:"unless"`
+}
+
+// safetyNegations are phrases that cancel a pattern match nearby
+type safetyNegations struct {
+	Before []string `json:"before" yaml:"before"`
+	After  []string `json:"after" yaml:"after"`
+}
+
+// safetyRulesFile is the layout of a YAML or JSON rules file
+type safetyRulesFile struct {
+	NegationWindow int              `json:"negation_window" yaml:"negation_window"`
+	Negations      safetyNegations  `json:"negations" yaml:"negations"`
+	Rules          []SafetyRuleSpec `json:"rules" yaml:"rules"`
+}
+
+// SafetyRuleMatch is a rule that fired for a recipe
+type SafetyRuleMatch struct {
+	RuleID     string            `json:"rule_id"`
+	Severity   string            `json:"severity"`
+	Message    SafetyRuleMessage `json:"message"`
+	Ingredient string            `json:"ingredient,omitempty"` // the ingredient that gated the rule
+	Matched    string            `json:"matched,omitempty"`    // the recipe text a pattern matched
+}
+
+// Text returns the match as one bilingual line for Violations and Warnings
+func (m SafetyRuleMatch) Text() string {
+	switch {
+	case m.Message.Ja == "":
+		return fmt.Sprintf("[%s] %s", m.RuleID, m.Message.En)
+	case m.Message.En == "":
+		return fmt.Sprintf("[%s] %s", m.RuleID, m.Message.Ja)
+	}
+	return fmt.Sprintf("[%s] %s / %s", m.RuleID, m.Message.Ja, m.Message.En)
+}
+
+// safetyRule is a validated rule with its expressions compiled
+type safetyRule struct {
+	spec        SafetyRuleSpec
+	ingredients []string
+	patterns    []*regexp.Regexp
+	require     []*regexp.Regexp
+	unless      []*regexp.Regexp
+}
+
+// safetyRuleSet is an immutable compiled rule set. Loading a file builds a
+// new set from the merged specs, so validators can swap sets atomically.
+type safetyRuleSet struct {
+	file            safetyRulesFile
+	negationsBefore []*regexp.Regexp
+	negationsAfter  []*regexp.Regexp
+	rules           []*safetyRule
+}
+
+// defaultSafetyRules is compiled once; an invalid embedded file is a build bug
+var defaultSafetyRules = mustParseSafetyRules(defaultSafetyRulesYAML)
+
+func mustParseSafetyRules(data []byte) *safetyRuleSet {
+	var file safetyRulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		panic(fmt.Sprintf("failed to parse built-in food safety rules: %v", err))
+	}
+	set, err := compileSafetyRules(file)
+	if err != nil {
+		panic(fmt.Sprintf("failed to compile built-in food safety rules: %v", err))
+	}
+	return set
+}
+
+// readSafetyRulesFile parses a YAML (.yaml, .yml) or JSON rules file
+func readSafetyRulesFile(path string) (safetyRulesFile, error) {
+	var file safetyRulesFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file, fmt.Errorf("failed to read food safety rules file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return file, fmt.Errorf("failed to parse food safety rules file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// merge layers extra over the set's specs: rules with a known ID replace the
+// existing rule, new IDs are appended, negations are added and a non-zero
+// negation window replaces the current one
+func (s *safetyRuleSet) merge(extra safetyRulesFile) safetyRulesFile {
+	merged := safetyRulesFile{
+		NegationWindow: s.file.NegationWindow,
+		Negations: safetyNegations{
+			Before: append(append([]string{}, s.file.Negations.Before...), extra.Negations.Before...),
+			After:  append(append([]string{}, s.file.Negations.After...), extra.Negations.After...),
+		},
+		Rules: append([]SafetyRuleSpec{}, s.file.Rules...),
+	}
+	if extra.NegationWindow > 0 {
+		merged.NegationWindow = extra.NegationWindow
+	}
+
+	index := make(map[string]int, len(merged.Rules))
+	for i, rule := range merged.Rules {
+		index[rule.ID] = i
+	}
+	for _, rule := range extra.Rules {
+		if i, ok := index[rule.ID]; ok {
+			merged.Rules[i] = rule
+			continue
+		}
+		index[rule.ID] = len(merged.Rules)
+		merged.Rules = append(merged.Rules, rule)
+	}
+	return merged
+}
+
+// compileSafetyRules validates every rule and compiles its expressions
+func compileSafetyRules(file safetyRulesFile) (*safetyRuleSet, error) {
+	if file.NegationWindow <= 0 {
+		file.NegationWindow = defaultNegationWindow
+	}
+	set := &safetyRuleSet{file: file}
+
+	var err error
+	if set.negationsBefore, err = compileSafetyPatterns("negations", file.Negations.Before); err != nil {
+		return nil, err
+	}
+	if set.negationsAfter, err = compileSafetyPatterns("negations", file.Negations.After); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(file.Rules))
+	for _, spec := range file.Rules {
+		rule, err := compileSafetyRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[rule.spec.ID] {
+			return nil, fmt.Errorf("%w: duplicate rule id %q", ErrInvalidSafetyRule, rule.spec.ID)
+		}
+		seen[rule.spec.ID] = true
+		set.rules = append(set.rules, rule)
+	}
+	return set, nil
+}
+
+func compileSafetyRule(spec SafetyRuleSpec) (*safetyRule, error) {
+	spec.ID = strings.TrimSpace(spec.ID)
+	if spec.ID == "" {
+		return nil, fmt.Errorf("%w: rule id is required", ErrInvalidSafetyRule)
+	}
+	if spec.Severity == "" {
+		spec.Severity = SafetySeverityViolation
+	}
+	if spec.Severity != SafetySeverityViolation && spec.Severity != SafetySeverityWarning {
+		return nil, fmt.Errorf("%w: rule %q has unknown severity %q", ErrInvalidSafetyRule, spec.ID, spec.Severity)
+	}
+	if spec.Message.Ja == "" && spec.Message.En == "" {
+		return nil, fmt.Errorf("%w: rule %q needs a message", ErrInvalidSafetyRule, spec.ID)
+	}
+	if len(spec.Patterns) == 0 && len(spec.Require) == 0 {
+		return nil, fmt.Errorf("%w: rule %q needs patterns or require", ErrInvalidSafetyRule, spec.ID)
+	}
+
+	rule := &safetyRule{spec: spec}
+	for _, keyword := range spec.Ingredients {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			rule.ingredients = append(rule.ingredients, keyword)
+		}
+	}
+
+	var err error
+	if rule.patterns, err = compileSafetyPatterns(spec.ID, spec.Patterns); err != nil {
+		return nil, err
+	}
+	if rule.require, err = compileSafetyPatterns(spec.ID, spec.Require); err != nil {
+		return nil, err
+	}
+	if rule.unless, err = compileSafetyPatterns(spec.ID, spec.Unless); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func compileSafetyPatterns(owner string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s has invalid pattern %q: %v", ErrInvalidSafetyRule, owner, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// evaluate runs every rule against a recipe
+func (s *safetyRuleSet) evaluate(recipe *models.RecipeData) []SafetyRuleMatch {
+	text := strings.ToLower(recipe.Title + " " + strings.Join([]string(recipe.Steps), " "))
+
+	var matches []SafetyRuleMatch
+	for _, rule := range s.rules {
+		ingredients := rule.gatingIngredients(recipe)
+		if len(rule.ingredients) > 0 && len(ingredients) == 0 {
+			continue
+		}
+
+		matched := ""
+		if len(rule.patterns) > 0 {
+			if matched = s.firstMatch(rule.patterns, text); matched == "" {
+				continue
+			}
+		}
+		if anyMatch(rule.require, text) || anyMatch(rule.unless, text) {
+			continue
+		}
+
+		// Rules that name the ingredient fire once per gating ingredient
+		if !rule.mentionsIngredient() || len(ingredients) == 0 {
+			ingredients = []string{""}
+		}
+		for _, ingredient := range ingredients {
+			matches = append(matches, SafetyRuleMatch{
+				RuleID:   rule.spec.ID,
+				Severity: rule.spec.Severity,
+				Message: SafetyRuleMessage{
+					Ja: strings.ReplaceAll(rule.spec.Message.Ja, ingredientPlaceholder, ingredient),
+					En: strings.ReplaceAll(rule.spec.Message.En, ingredientPlaceholder, ingredient),
+				},
+				Ingredient: ingredient,
+				Matched:    matched,
+			})
+		}
+	}
+	return matches
+}
+
+// gatingIngredients returns the recipe ingredients naming one of the rule's
+// ingredient keywords
+func (r *safetyRule) gatingIngredients(recipe *models.RecipeData) []string {
+	var names []string
+	for _, ingredient := range recipe.Ingredients {
+		name := strings.ToLower(ingredient.Name)
+		for _, keyword := range r.ingredients {
+			if strings.Contains(name, keyword) {
+				names = append(names, ingredient.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func (r *safetyRule) mentionsIngredient() bool {
+	return strings.Contains(r.spec.Message.Ja, ingredientPlaceholder) ||
+		strings.Contains(r.spec.Message.En, ingredientPlaceholder)
+}
+
+// firstMatch returns the first pattern match in text that is not negated
+func (s *safetyRuleSet) firstMatch(patterns []*regexp.Regexp, text string) string {
+	for _, re := range patterns {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			if !s.negated(text, loc[0], loc[1]) {
+				return text[loc[0]:loc[1]]
+			}
+		}
+	}
+	return ""
+}
+
+// negated reports whether a negation sits within the window before or after
+// text[start:end]
+func (s *safetyRuleSet) negated(text string, start, end int) bool {
+	before := []rune(text[:start])
+	if len(before) > s.file.NegationWindow {
+		before = before[len(before)-s.file.NegationWindow:]
+	}
+	after := []rune(text[end:])
+	if len(after) > s.file.NegationWindow {
+		after = after[:s.file.NegationWindow]
+	}
+	return anyMatch(s.negationsBefore, string(before)) || anyMatch(s.negationsAfter, string(after))
+}
+
+func anyMatch(patterns []*regexp.Regexp, text string) bool {
+	for _, re := range patterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TemperatureUnit is the unit safety results describe temperatures in
+type TemperatureUnit string
+
+// Temperature units
+const (
+	TemperatureCelsius    TemperatureUnit = "celsius"
+	TemperatureFahrenheit TemperatureUnit = "fahrenheit"
+)
+
+// Ways a temperature requirement can be satisfied
+const (
+	TempSatisfiedByTemperature = "temperature" // A stated temperature at or above the minimum
+	TempSatisfiedByCue         = "cue"         // A visual or time-based doneness description
+)
+
+// minDonenessMinutes is the shortest heating time accepted as a doneness cue
+const minDonenessMinutes = 3
+
+// FahrenheitToCelsius converts °F to °C
+func FahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+// CelsiusToFahrenheit converts °C to °F
+func CelsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+// Format renders a temperature given in °F in the unit, e.g. "74°C"
+func (u TemperatureUnit) Format(tempF float64) string {
+	if u == TemperatureFahrenheit {
+		return fmt.Sprintf("%.0f°F", tempF)
+	}
+	return fmt.Sprintf("%.0f°C", math.Round(FahrenheitToCelsius(tempF)))
+}
+
+// ParseTemperatureUnit returns the unit a config value names, defaulting to Celsius
+func ParseTemperatureUnit(value string) TemperatureUnit {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "f", "fahrenheit":
+		return TemperatureFahrenheit
+	default:
+		return TemperatureCelsius
+	}
+}
+
+// temperatureExceptions are ingredient names that contain a temperature
+// keyword but are processed or not the protein itself (牛乳 contains 牛)
+var temperatureExceptions = []string{
+	"eggplant", "牛乳", "牛蒡", "がら", "スープの素", "だし", "魚醤", "魚肉ソーセージ", "フレーク", "水煮", "ゆで卵", "卵豆腐",
+}
+
+// lookupRequiredTemperature returns the safe minimum temperature (°F) of the
+// longest table entry an ingredient names or contains, so 鶏もも肉 resolves
+// to 鶏 and 豚ひき肉 to ひき肉
+func lookupRequiredTemperature(temperatures map[string]float64, ingredient string) (string, float64, bool) {
+	name := strings.ToLower(strings.TrimSpace(ingredient))
+	if temp, ok := temperatures[name]; ok {
+		return name, temp, true
+	}
+	for _, except := range temperatureExceptions {
+		if strings.Contains(name, except) {
+			return "", 0, false
+		}
+	}
+
+	keys := make([]string, 0, len(temperatures))
+	for key := range temperatures {
+		if strings.Contains(name, key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return "", 0, false
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys[0], temperatures[keys[0]], true
+}
+
+// temperatureMention is a temperature stated in recipe text
+type temperatureMention struct {
+	text    string
+	value   float64
+	celsius bool
+}
+
+// fahrenheit returns the mentioned temperature in °F
+func (m temperatureMention) fahrenheit() float64 {
+	if m.celsius {
+		return CelsiusToFahrenheit(m.value)
+	}
+	return m.value
+}
+
+// temperaturePattern matches "75℃", "75°C", "75度", "165°F", "165℉" and "165f"
+var temperaturePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(°\s*[cf]|℃|℉|度|f\b)`)
+
+// findTemperatureMentions returns the temperatures stated in lowercased text
+func findTemperatureMentions(text string) []temperatureMention {
+	var mentions []temperatureMention
+	for _, match := range temperaturePattern.FindAllStringSubmatch(text, -1) {
+		value, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			continue
+		}
+		unit := strings.ReplaceAll(match[2], " ", "")
+		mentions = append(mentions, temperatureMention{
+			text:    match[0],
+			value:   value,
+			celsius: unit == "°c" || unit == "℃" || unit == "度",
+		})
+	}
+	return mentions
+}
+
+// donenessCuePatterns are visual doneness descriptions home cooks use instead
+// of a thermometer. Negated forms (火を通さない) do not match.
+var donenessCuePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`中(心)?まで(しっかり|完全に|十分に?|よく)?火(が|を)通(る|っ|す|し)`),
+	regexp.MustCompile(`(しっかり|完全に|十分に?|よく)火(が|を)通(る|っ|す|し)`),
+	regexp.MustCompile(`火が通(る|っ)(まで|たら|た)`),
+	regexp.MustCompile(`(肉汁|汁)が(透明|澄ん)`),
+	regexp.MustCompile(`(赤み|赤い部分|ピンク色?)(が)?(なくなる|なくなっ|消え|残らない)`),
+	regexp.MustCompile(`身が(白く|ほぐれ)`),
+	regexp.MustCompile(`cooked through|juices run clear|no longer pink`),
+}
+
+// donenessEggPattern matches egg-setting cues, which only count for eggs
+// (ゼリーが固まるまで冷やす says nothing about the chicken)
+var donenessEggPattern = regexp.MustCompile(`(しっかり|完全に)?固まる(まで)|until set|set firm`)
+
+// eggTemperatureKeys are the temperature table entries for eggs
+var eggTemperatureKeys = map[string]bool{"egg": true, "eggs": true, "卵": true, "たまご": true}
+
+// donenessTimePattern matches a heating time in one step, e.g. "5分加熱する"
+var (
+	donenessTimePattern = regexp.MustCompile(`(\d+)\s*分(以上|ほど|程度|くらい|ぐらい)?`)
+	donenessHeatPattern = regexp.MustCompile(`加熱|焼|煮|茹|ゆで|蒸|揚|炒め|レンジ|チン|bake|boil|simmer|roast|fry`)
+)
+
+// findDonenessCue returns the first doneness description for one ingredient,
+// given its name and the temperature table key it resolved to. A visual cue
+// counts in a step that names the ingredient or in the step right after one;
+// a heating time of at least minDonenessMinutes counts only in a step that
+// names and heats it, so "ご飯をレンジで3分温める" does not cook the chicken.
+func findDonenessCue(steps []string, ingredient, key string) (string, bool) {
+	names := []string{strings.ToLower(strings.TrimSpace(ingredient))}
+	if key != "" {
+		names = append(names, key)
+	}
+	patterns := donenessCuePatterns
+	if eggTemperatureKeys[key] {
+		patterns = append(slices.Clip(patterns), donenessEggPattern)
+	}
+
+	lowered := make([]string, len(steps))
+	named := make([]bool, len(steps))
+	for i, step := range steps {
+		lowered[i] = strings.ToLower(step)
+		for _, name := range names {
+			if name != "" && strings.Contains(lowered[i], name) {
+				named[i] = true
+				break
+			}
+		}
+	}
+
+	for i, step := range lowered {
+		if !named[i] && (i == 0 || !named[i-1]) {
+			continue
+		}
+		for _, pattern := range patterns {
+			if cue := pattern.FindString(step); cue != "" {
+				return cue, true
+			}
+		}
+	}
+	for i, step := range lowered {
+		if !named[i] || !donenessHeatPattern.MatchString(step) {
+			continue
+		}
+		for _, match := range donenessTimePattern.FindAllStringSubmatch(step, -1) {
+			if minutes, err := strconv.Atoi(match[1]); err == nil && minutes >= minDonenessMinutes {
+				return match[0], true
+			}
+		}
+	}
+	return "", false
+}
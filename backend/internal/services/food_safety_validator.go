@@ -2,29 +2,71 @@ package services
 
 import (
 	"fmt"
-	"regexp"
-	"strconv"
+	"math"
 	"strings"
+	"sync"
 
 	"lazychef/internal/models"
 )
 
 // FoodSafetyValidator validates recipes for food safety compliance
 type FoodSafetyValidator struct {
-	usdaTemperatures  map[string]float64 // USDA safe minimum temperatures (°F)
-	dangerousPatterns []string           // Dangerous cooking patterns to avoid
-	requiredWarnings  map[string]string  // Required warnings for specific ingredients
-	strictMode        bool               // Enable strict validation mode
+	usdaTemperatures map[string]float64 // USDA safe minimum temperatures (°F)
+	requiredWarnings map[string]string  // Required warnings for specific ingredients
+	strictMode       bool               // Enable strict validation mode
+
+	mu    sync.RWMutex
+	rules *safetyRuleSet  // Bilingual dangerous-practice rules
+	unit  TemperatureUnit // Unit results describe temperatures in
 }
 
-// NewFoodSafetyValidator creates a new food safety validator
+// NewFoodSafetyValidator creates a new food safety validator with the
+// built-in rule set
 func NewFoodSafetyValidator(strictMode bool) *FoodSafetyValidator {
 	return &FoodSafetyValidator{
-		usdaTemperatures:  getUSDATemperatures(),
-		dangerousPatterns: getDangerousPatterns(),
-		requiredWarnings:  getRequiredWarnings(),
-		strictMode:        strictMode,
+		usdaTemperatures: getUSDATemperatures(),
+		requiredWarnings: getRequiredWarnings(),
+		strictMode:       strictMode,
+		rules:            defaultSafetyRules,
+		unit:             TemperatureCelsius,
+	}
+}
+
+// SetTemperatureUnit sets the unit results describe temperatures in
+func (v *FoodSafetyValidator) SetTemperatureUnit(unit TemperatureUnit) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.unit = unit
+}
+
+// LoadRulesFile layers the rules in a YAML (.yaml, .yml) or JSON file over
+// the current ones, returning how many rules it contained. A rule with an
+// existing ID replaces it. If any rule is invalid nothing changes.
+func (v *FoodSafetyValidator) LoadRulesFile(path string) (int, error) {
+	file, err := readSafetyRulesFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	set, err := compileSafetyRules(v.rules.merge(file))
+	if err != nil {
+		return 0, fmt.Errorf("failed to load food safety rules file %s: %w", path, err)
+	}
+	v.rules = set
+	return len(file.Rules), nil
+}
+
+// RuleIDs returns the IDs of the active rules in evaluation order
+func (v *FoodSafetyValidator) RuleIDs() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	ids := make([]string, 0, len(v.rules.rules))
+	for _, rule := range v.rules.rules {
+		ids = append(ids, rule.spec.ID)
 	}
+	return ids
 }
 
 // SafetyCheckResult represents the result of a food safety check
@@ -32,31 +74,43 @@ type SafetyCheckResult struct {
 	Passed           bool              `json:"passed"`
 	Violations       []string          `json:"violations"`
 	Warnings         []string          `json:"warnings"`
+	TemperatureUnit  TemperatureUnit   `json:"temperature_unit"`
 	RequiredTemps    []TempRequirement `json:"required_temps"`
 	MissingTemps     []string          `json:"missing_temps"`
 	AllergenWarnings []string          `json:"allergen_warnings"`
+	RuleMatches      []SafetyRuleMatch `json:"rule_matches"`
 }
 
-// TempRequirement represents a required temperature check
+// TempRequirement represents a required temperature check. It is satisfied by
+// a stated temperature at or above the minimum or by a doneness cue.
 type TempRequirement struct {
 	Ingredient  string  `json:"ingredient"`
 	MinTempF    float64 `json:"min_temp_f"`
+	MinTempC    float64 `json:"min_temp_c"`
 	Description string  `json:"description"`
+	SatisfiedBy string  `json:"satisfied_by,omitempty"` // TempSatisfiedByTemperature or TempSatisfiedByCue
+	Evidence    string  `json:"evidence,omitempty"`     // The step text that satisfied it
 }
 
 // ValidateRecipe performs comprehensive food safety validation
 func (v *FoodSafetyValidator) ValidateRecipe(recipe *models.RecipeData) (*SafetyCheckResult, error) {
+	v.mu.RLock()
+	unit := v.unit
+	v.mu.RUnlock()
+
 	result := &SafetyCheckResult{
 		Passed:           true,
+		TemperatureUnit:  unit,
 		Violations:       []string{},
 		Warnings:         []string{},
 		RequiredTemps:    []TempRequirement{},
 		MissingTemps:     []string{},
 		AllergenWarnings: []string{},
+		RuleMatches:      []SafetyRuleMatch{},
 	}
 
-	// Check for dangerous patterns in steps
-	v.checkDangerousPatterns(recipe, result)
+	// Check dangerous practices and handling against the rule set
+	v.checkSafetyRules(recipe, result)
 
 	// Check temperature requirements
 	v.checkTemperatureRequirements(recipe, result)
@@ -64,9 +118,6 @@ func (v *FoodSafetyValidator) ValidateRecipe(recipe *models.RecipeData) (*Safety
 	// Check for allergen warnings
 	v.checkAllergenRequirements(recipe, result)
 
-	// Check raw ingredient handling
-	v.checkRawIngredientHandling(recipe, result)
-
 	// In strict mode, any violation fails the check
 	if v.strictMode && len(result.Violations) > 0 {
 		result.Passed = false
@@ -75,53 +126,62 @@ func (v *FoodSafetyValidator) ValidateRecipe(recipe *models.RecipeData) (*Safety
 	return result, nil
 }
 
-// checkDangerousPatterns checks for dangerous cooking patterns
-func (v *FoodSafetyValidator) checkDangerousPatterns(recipe *models.RecipeData, result *SafetyCheckResult) {
-	allText := strings.ToLower(recipe.Title + " " + strings.Join([]string(recipe.Steps), " "))
-
-	for _, pattern := range v.dangerousPatterns {
-		if matched, _ := regexp.MatchString(pattern, allText); matched {
-			result.Violations = append(result.Violations, fmt.Sprintf("Dangerous pattern detected: %s", pattern))
+// checkSafetyRules reports rule matches as violations or warnings
+func (v *FoodSafetyValidator) checkSafetyRules(recipe *models.RecipeData, result *SafetyCheckResult) {
+	v.mu.RLock()
+	rules := v.rules
+	v.mu.RUnlock()
+
+	for _, match := range rules.evaluate(recipe) {
+		result.RuleMatches = append(result.RuleMatches, match)
+		if match.Severity == SafetySeverityWarning {
+			result.Warnings = append(result.Warnings, match.Text())
+		} else {
+			result.Violations = append(result.Violations, match.Text())
 		}
 	}
 }
 
-// checkTemperatureRequirements validates cooking temperatures
+// checkTemperatureRequirements checks that every ingredient with a safe
+// minimum temperature is cooked to it, either by a stated temperature in °C
+// or °F or by a doneness cue such as 中まで火が通るまで or 肉汁が透明になるまで
+// near a step that names it
 func (v *FoodSafetyValidator) checkTemperatureRequirements(recipe *models.RecipeData, result *SafetyCheckResult) {
-	tempRegex := regexp.MustCompile(`(\d+)°?[fF]`)
-	stepsText := strings.ToLower(strings.Join([]string(recipe.Steps), " "))
+	steps := []string(recipe.Steps)
+	mentions := findTemperatureMentions(strings.ToLower(strings.Join(steps, " ")))
 
 	for _, ingredient := range recipe.Ingredients {
-		ingredientName := strings.ToLower(ingredient.Name)
+		key, requiredTemp, exists := lookupRequiredTemperature(v.usdaTemperatures, ingredient.Name)
+		if !exists {
+			continue
+		}
 
-		// Check if this ingredient requires temperature monitoring
-		if requiredTemp, exists := v.usdaTemperatures[ingredientName]; exists {
-			tempReq := TempRequirement{
-				Ingredient:  ingredient.Name,
-				MinTempF:    requiredTemp,
-				Description: fmt.Sprintf("%s must reach at least %.0f°F", ingredient.Name, requiredTemp),
+		tempReq := TempRequirement{
+			Ingredient:  ingredient.Name,
+			MinTempF:    requiredTemp,
+			MinTempC:    math.Round(FahrenheitToCelsius(requiredTemp)*10) / 10,
+			Description: fmt.Sprintf("%s must reach at least %s", ingredient.Name, result.TemperatureUnit.Format(requiredTemp)),
+		}
+		for _, mention := range mentions {
+			if mention.fahrenheit() >= requiredTemp {
+				tempReq.SatisfiedBy = TempSatisfiedByTemperature
+				tempReq.Evidence = mention.text
+				break
 			}
-			result.RequiredTemps = append(result.RequiredTemps, tempReq)
-
-			// Check if temperature is mentioned in steps
-			tempMentioned := false
-			matches := tempRegex.FindAllStringSubmatch(stepsText, -1)
-
-			for _, match := range matches {
-				if temp, err := strconv.ParseFloat(match[1], 64); err == nil {
-					if temp >= requiredTemp {
-						tempMentioned = true
-						break
-					}
-				}
+		}
+		if tempReq.SatisfiedBy == "" {
+			if cue, ok := findDonenessCue(steps, ingredient.Name, key); ok {
+				tempReq.SatisfiedBy = TempSatisfiedByCue
+				tempReq.Evidence = cue
 			}
+		}
+		result.RequiredTemps = append(result.RequiredTemps, tempReq)
 
-			if !tempMentioned {
-				result.MissingTemps = append(result.MissingTemps, ingredient.Name)
-				result.Violations = append(result.Violations,
-					fmt.Sprintf("Missing safe temperature instruction for %s (required: %.0f°F)",
-						ingredient.Name, requiredTemp))
-			}
+		if tempReq.SatisfiedBy == "" {
+			result.MissingTemps = append(result.MissingTemps, ingredient.Name)
+			result.Violations = append(result.Violations,
+				fmt.Sprintf("Missing safe temperature or doneness cue for %s (required: %s, or e.g. 中まで火が通るまで)",
+					ingredient.Name, result.TemperatureUnit.Format(requiredTemp)))
 		}
 	}
 }
@@ -139,30 +199,8 @@ func (v *FoodSafetyValidator) checkAllergenRequirements(recipe *models.RecipeDat
 	}
 }
 
-// checkRawIngredientHandling checks for proper raw ingredient handling
-func (v *FoodSafetyValidator) checkRawIngredientHandling(recipe *models.RecipeData, result *SafetyCheckResult) {
-	stepsText := strings.ToLower(strings.Join([]string(recipe.Steps), " "))
-
-	rawIngredients := []string{"raw chicken", "raw beef", "raw pork", "raw fish", "raw egg"}
-
-	for _, ingredient := range recipe.Ingredients {
-		ingredientName := strings.ToLower(ingredient.Name)
-
-		for _, rawItem := range rawIngredients {
-			if strings.Contains(ingredientName, strings.Replace(rawItem, "raw ", "", 1)) {
-				// Check if proper handling is mentioned
-				if !strings.Contains(stepsText, "wash hands") &&
-					!strings.Contains(stepsText, "sanitize") &&
-					!strings.Contains(stepsText, "separate") {
-					result.Warnings = append(result.Warnings,
-						fmt.Sprintf("Consider adding hand washing/sanitizing instructions when handling %s", ingredient.Name))
-				}
-			}
-		}
-	}
-}
-
-// getUSDATemperatures returns USDA safe minimum internal temperatures (°F)
+// getUSDATemperatures returns USDA safe minimum internal temperatures (°F),
+// keyed by English and Japanese ingredient names
 func getUSDATemperatures() map[string]float64 {
 	return map[string]float64{
 		"chicken":        165, // Poultry
@@ -181,20 +219,27 @@ func getUSDATemperatures() map[string]float64 {
 		"shrimp":         145,
 		"egg":            160, // Egg dishes
 		"eggs":           160,
-	}
-}
-
-// getDangerousPatterns returns regex patterns for dangerous cooking practices
-func getDangerousPatterns() []string {
-	return []string{
-		`raw\s+flour`,              // Raw flour consumption
-		`no.cook.*chicken`,         // No-cook chicken dishes
-		`room\s+temperature.*meat`, // Leaving meat at room temperature
-		`thaw.*counter`,            // Thawing on counter
-		`rinse.*raw\s+chicken`,     // Rinsing raw chicken (spreads bacteria)
-		`undercooked.*egg`,         // Undercooked eggs
-		`raw.*cookie\s+dough`,      // Raw cookie dough
-		`marinade.*reuse`,          // Reusing marinades
+		"鶏":              165, // 鶏肉・ささみ・手羽
+		"ささみ":            165,
+		"手羽":             165,
+		"鴨":              165,
+		"牛":              145, // 牛肉・豚肉・ラム
+		"豚":              145,
+		"ラム":             145,
+		"ひき肉":            160, // 合いびき肉・豚ひき肉
+		"挽き肉":            160,
+		"挽肉":             160,
+		"ミンチ":            160,
+		"合いびき":           160,
+		"合挽":             160,
+		"鶏ひき肉":           165,
+		"魚":              145, // 魚介
+		"鮭":              145,
+		"サーモン":           145,
+		"えび":             145,
+		"海老":             145,
+		"卵":              160,
+		"たまご":            160,
 	}
 }
 
@@ -220,14 +265,19 @@ func getRequiredWarnings() map[string]string {
 
 // IsTemperatureSafe checks if a given temperature meets safety requirements for an ingredient
 func (v *FoodSafetyValidator) IsTemperatureSafe(ingredient string, tempF float64) bool {
-	if requiredTemp, exists := v.usdaTemperatures[strings.ToLower(ingredient)]; exists {
+	if _, requiredTemp, exists := lookupRequiredTemperature(v.usdaTemperatures, ingredient); exists {
 		return tempF >= requiredTemp
 	}
 	return true // No specific requirement
 }
 
-// GetRequiredTemperature returns the USDA required temperature for an ingredient
+// IsTemperatureSafeCelsius is IsTemperatureSafe for a temperature in °C
+func (v *FoodSafetyValidator) IsTemperatureSafeCelsius(ingredient string, tempC float64) bool {
+	return v.IsTemperatureSafe(ingredient, CelsiusToFahrenheit(tempC))
+}
+
+// GetRequiredTemperature returns the USDA required temperature (°F) for an ingredient
 func (v *FoodSafetyValidator) GetRequiredTemperature(ingredient string) (float64, bool) {
-	temp, exists := v.usdaTemperatures[strings.ToLower(ingredient)]
+	_, temp, exists := lookupRequiredTemperature(v.usdaTemperatures, ingredient)
 	return temp, exists
 }
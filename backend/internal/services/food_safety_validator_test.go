@@ -0,0 +1,249 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func safetyRuleIDs(result *SafetyCheckResult) []string {
+	ids := []string{}
+	for _, match := range result.RuleMatches {
+		ids = append(ids, match.RuleID)
+	}
+	return ids
+}
+
+func TestFoodSafetyValidator_JapaneseRules(t *testing.T) {
+	validator := NewFoodSafetyValidator(true)
+
+	tests := []struct {
+		name        string
+		title       string
+		steps       []string
+		ingredients []string
+		want        []string
+	}{
+		{"raw poultry", "鶏ささみのわさび和え", []string{"ささみは筋を取り、鶏肉を生で薄切りにする", "わさび醤油で和える"}, []string{"ささみ"}, []string{"raw-poultry"}},
+		{"chicken tataki", "鶏むね肉のたたき", []string{"表面だけさっと焼く", "薄切りにして盛る"}, []string{"鶏むね肉"}, []string{"raw-poultry"}},
+		{"raw chicken into the pan", "鶏もも肉のパリパリ焼き", []string{"鶏もも肉を生のままフライパンに皮目から入れる", "中まで火が通るまで焼き、少ない油で仕上げる"}, []string{"鶏もも肉"}, []string{}},
+		{"raw poultry not negated by a later ない", "鶏ささみの和え物", []string{"鶏ささみを生のまま和える。調味料は少ない量でよい"}, []string{"ささみ"}, []string{"raw-poultry"}},
+		{"ground meat into the pan raw", "ずぼらそぼろ", []string{"豚ひき肉を生のままフライパンに入れ、肉汁が透明になるまで炒める"}, []string{"豚ひき肉"}, []string{}},
+		{"negated raw poultry", "鶏むね肉の塩焼き", []string{"鶏肉は生で食べないでください。中までしっかり焼く"}, []string{"鶏むね肉"}, []string{}},
+		{"rare hamburger", "レアハンバーグ", []string{"合いびき肉をこねて、中はレアに仕上げる"}, []string{"合いびき肉"}, []string{"undercooked-ground-meat"}},
+		{"ground meat cooked through", "ハンバーグ", []string{"ひき肉をこねて焼く", "ピンク色が残らないように中まで焼く"}, []string{"合いびき肉"}, []string{}},
+		{"counter thawing", "鮭のムニエル", []string{"冷凍の鮭は常温で解凍する", "バターで焼く"}, []string{"鮭"}, []string{"counter-thawing"}},
+		{"fridge thawing", "鮭のムニエル", []string{"冷凍の鮭は冷蔵庫で解凍する", "常温で解凍しないこと"}, []string{"鮭"}, []string{}},
+		{"reused marinade", "豚の生姜焼き", []string{"豚肉をたれに漬ける", "焼いたら漬けだれをそのままかける"}, []string{"豚肉"}, []string{"reused-marinade"}},
+		{"boiled marinade", "豚の生姜焼き", []string{"豚肉をたれに漬ける", "焼いたら漬けだれを加えてしっかり煮立てて絡める"}, []string{"豚肉"}, []string{}},
+		{"english patterns still apply", "Cookie dough bites", []string{"Mix raw flour with butter"}, []string{"flour"}, []string{"raw-flour"}},
+		{"english negation", "Steak", []string{"Never thaw meat on the counter"}, []string{"beef"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipe := &models.RecipeData{Title: tt.title, Steps: models.FlexibleSteps(tt.steps)}
+			for _, name := range tt.ingredients {
+				recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name})
+			}
+
+			result, err := validator.ValidateRecipe(recipe)
+			require.NoError(t, err)
+
+			var violations []string
+			for _, id := range safetyRuleIDs(result) {
+				if id != "raw-protein-handling" {
+					violations = append(violations, id)
+				}
+			}
+			if len(tt.want) == 0 {
+				assert.Empty(t, violations)
+				assert.True(t, result.Passed || len(result.MissingTemps) > 0)
+				return
+			}
+			assert.Equal(t, tt.want, violations)
+			assert.False(t, result.Passed)
+			assert.Contains(t, result.Violations[0], "["+tt.want[0]+"]")
+		})
+	}
+}
+
+func TestFoodSafetyValidator_RawProteinHandlingWarning(t *testing.T) {
+	validator := NewFoodSafetyValidator(true)
+	recipe := &models.RecipeData{
+		Title:       "豚こまと鶏ももの炒め",
+		Steps:       models.FlexibleSteps{"肉を切って炒める"},
+		Ingredients: []models.Ingredient{{Name: "豚こま肉"}, {Name: "鶏もも肉"}, {Name: "キャベツ"}},
+	}
+
+	result, err := validator.ValidateRecipe(recipe)
+	require.NoError(t, err)
+	require.Len(t, result.Warnings, 2)
+	assert.Contains(t, result.Warnings[0], "豚こま肉を扱った後は手を洗い")
+	assert.Equal(t, "鶏もも肉", result.RuleMatches[1].Ingredient)
+
+	recipe.Steps = append(recipe.Steps, "切ったら手をよく洗う")
+	result, err = validator.ValidateRecipe(recipe)
+	require.NoError(t, err)
+	assert.Empty(t, result.Warnings)
+}
+
+func TestFoodSafetyValidator_LoadRulesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`rules:
+  - id: raw-flour
+    severity: warning
+    message:
+      ja: 小麦粉は加熱してから食べてください
+    patterns: ['生の小麦粉']
+  - id: raw-oyster
+    message:
+      ja: 加熱用の牡蠣を生で食べないでください
+      en: Oysters labelled for cooking must not be eaten raw
+    ingredients: ['牡蠣', 'かき']
+    patterns: ['生(で|のまま)']
+`), 0o600))
+
+	validator := NewFoodSafetyValidator(true)
+	n, err := validator.LoadRulesFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "raw-oyster", validator.RuleIDs()[len(validator.RuleIDs())-1])
+
+	result, err := validator.ValidateRecipe(&models.RecipeData{
+		Title:       "牡蠣ポン酢",
+		Steps:       models.FlexibleSteps{"加熱用の牡蠣を生のままポン酢で和える"},
+		Ingredients: []models.Ingredient{{Name: "牡蠣（加熱用）"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"raw-oyster"}, safetyRuleIDs(result))
+	assert.False(t, result.Passed)
+
+	// The overridden rule is now a warning
+	result, err = validator.ValidateRecipe(&models.RecipeData{Title: "クッキー生地", Steps: models.FlexibleSteps{"生の小麦粉をそのまま食べる"}})
+	require.NoError(t, err)
+	assert.Empty(t, result.Violations)
+	assert.Equal(t, []string{"[raw-flour] 小麦粉は加熱してから食べてください"}, result.Warnings)
+
+	// An invalid file leaves the rules unchanged
+	badPath := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(badPath, []byte(`{"rules": [{"id": "broken", "message": {"en": "x"}, "patterns": ["("]}]}`), 0o600))
+	before := validator.RuleIDs()
+	_, err = validator.LoadRulesFile(badPath)
+	assert.ErrorIs(t, err, ErrInvalidSafetyRule)
+	assert.Equal(t, before, validator.RuleIDs())
+}
+
+func TestFoodSafetyValidator_RawIntoThePanPassesStrictMode(t *testing.T) {
+	validator := NewFoodSafetyValidator(true)
+
+	result, err := validator.ValidateRecipe(&models.RecipeData{
+		Title: "鶏もも肉のパリパリ焼き",
+		Steps: models.FlexibleSteps{
+			"鶏もも肉を生のままフライパンに皮目から入れる",
+			"中まで火が通るまで焼き、鶏肉を切った包丁とまな板は洗う",
+		},
+		Ingredients: []models.Ingredient{{Name: "鶏もも肉"}},
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Passed, result.Violations)
+	assert.Empty(t, result.RuleMatches)
+}
+
+func TestFoodSafetyValidator_TemperatureRequirements(t *testing.T) {
+	validator := NewFoodSafetyValidator(true)
+
+	tests := []struct {
+		name        string
+		ingredient  string
+		steps       []string
+		satisfiedBy string
+		evidence    string
+	}{
+		{"celsius", "鶏もも肉", []string{"中心温度75℃で1分以上加熱する"}, TempSatisfiedByTemperature, "75℃"},
+		{"degrees", "豚ロース", []string{"180度のオーブンで焼く"}, TempSatisfiedByTemperature, "180度"},
+		{"fahrenheit", "chicken breast", []string{"Bake until it reaches 165°F"}, TempSatisfiedByTemperature, "165°f"},
+		{"cooked through cue", "鶏むね肉", []string{"鶏肉をフライパンで中まで火が通るまで焼く"}, TempSatisfiedByCue, "中まで火が通る"},
+		{"cue in the next step", "合いびき肉", []string{"合いびき肉を丸める", "肉汁が透明になるまで焼く"}, TempSatisfiedByCue, "肉汁が透明"},
+		{"heating time cue", "鮭", []string{"鮭にラップをして電子レンジで4分加熱する"}, TempSatisfiedByCue, "4分"},
+		{"egg set cue", "卵", []string{"溶き卵を流し入れ、しっかり固まるまで焼く"}, TempSatisfiedByCue, "しっかり固まるまで"},
+		{"too cool", "鶏もも肉", []string{"60℃で焼く"}, "", ""},
+		{"too short", "鶏もも肉", []string{"鶏肉をさっと1分炒める"}, "", ""},
+		{"negated cue", "豚こま肉", []string{"豚肉は火を通さないように和える"}, "", ""},
+		{"cue for another step", "鶏むね肉", []string{"鶏むね肉をそぎ切りにする", "ねぎを刻む", "ソースを火が通るまで煮詰める"}, "", ""},
+		{"heating time for another ingredient", "鶏むね肉", []string{"鶏むね肉を切る", "ご飯をレンジで3分温める"}, "", ""},
+		{"heating time in the next step", "豚ひき肉", []string{"豚ひき肉をこねる", "フライパンで5分焼く"}, "", ""},
+		{"set cue is for eggs only", "豚ひき肉", []string{"豚ひき肉を型に詰める", "ゼリー液を冷蔵庫で固まるまで冷やす"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := validator.ValidateRecipe(&models.RecipeData{
+				Title:       "テスト",
+				Steps:       models.FlexibleSteps(tt.steps),
+				Ingredients: []models.Ingredient{{Name: tt.ingredient}},
+			})
+			require.NoError(t, err)
+			require.Len(t, result.RequiredTemps, 1)
+			assert.Equal(t, tt.satisfiedBy, result.RequiredTemps[0].SatisfiedBy)
+			assert.Equal(t, tt.evidence, result.RequiredTemps[0].Evidence)
+			if tt.satisfiedBy == "" {
+				assert.Equal(t, []string{tt.ingredient}, result.MissingTemps)
+			} else {
+				assert.Empty(t, result.MissingTemps)
+			}
+		})
+	}
+}
+
+func TestFoodSafetyValidator_DonenessCuesDoNotCoverOtherIngredients(t *testing.T) {
+	validator := NewFoodSafetyValidator(true)
+
+	for _, step := range []string{"ご飯をレンジで3分温める", "ゼリー液を冷蔵庫で固まるまで冷やす"} {
+		result, err := validator.ValidateRecipe(&models.RecipeData{
+			Title:       "鶏そぼろとゼリー",
+			Steps:       models.FlexibleSteps{"鶏むね肉と豚ひき肉を器に盛る", "たれをかける", step},
+			Ingredients: []models.Ingredient{{Name: "鶏むね肉"}, {Name: "豚ひき肉"}},
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Passed, step)
+		assert.Equal(t, []string{"鶏むね肉", "豚ひき肉"}, result.MissingTemps, step)
+	}
+}
+
+func TestFoodSafetyValidator_TemperatureUnits(t *testing.T) {
+	validator := NewFoodSafetyValidator(true)
+	recipe := &models.RecipeData{
+		Title:       "鶏の照り焼き",
+		Steps:       models.FlexibleSteps{"鶏もも肉を焼く"},
+		Ingredients: []models.Ingredient{{Name: "鶏もも肉"}, {Name: "牛乳"}, {Name: "なす"}},
+	}
+
+	result, err := validator.ValidateRecipe(recipe)
+	require.NoError(t, err)
+	assert.Equal(t, TemperatureCelsius, result.TemperatureUnit)
+	require.Len(t, result.RequiredTemps, 1, "牛乳 is not beef")
+	assert.Equal(t, 165.0, result.RequiredTemps[0].MinTempF)
+	assert.Equal(t, 73.9, result.RequiredTemps[0].MinTempC)
+	assert.Equal(t, "鶏もも肉 must reach at least 74°C", result.RequiredTemps[0].Description)
+	assert.Contains(t, result.Violations[len(result.Violations)-1], "required: 74°C")
+	assert.False(t, result.Passed)
+
+	validator.SetTemperatureUnit(ParseTemperatureUnit("Fahrenheit"))
+	result, err = validator.ValidateRecipe(recipe)
+	require.NoError(t, err)
+	assert.Equal(t, TemperatureFahrenheit, result.TemperatureUnit)
+	assert.Equal(t, "鶏もも肉 must reach at least 165°F", result.RequiredTemps[0].Description)
+
+	assert.True(t, validator.IsTemperatureSafeCelsius("豚ひき肉", 75))
+	assert.False(t, validator.IsTemperatureSafeCelsius("鶏ひき肉", 72))
+	temp, ok := validator.GetRequiredTemperature("牛豚合いびき肉")
+	assert.True(t, ok)
+	assert.Equal(t, 160.0, temp)
+}
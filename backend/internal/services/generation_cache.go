@@ -0,0 +1,211 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GenerationCache stores generation results by cache key. Implementations
+// treat storage failures as misses so a broken cache never blocks generation.
+type GenerationCache interface {
+	Name() string
+	Get(key string) *GenerationResult
+	Set(key string, value *GenerationResult)
+	Delete(key string)
+	Clear()
+	Size() int
+	Keys() []string
+}
+
+// CacheTierStats reports hit and miss counts for one cache tier
+type CacheTierStats struct {
+	Tier    string  `json:"tier"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+	Writes  int64   `json:"writes"`
+	Entries int     `json:"entries"`
+}
+
+// CacheStats reports overall and per-tier cache metrics
+type CacheStats struct {
+	Hits    int64            `json:"hits"`
+	Misses  int64            `json:"misses"`
+	HitRate float64          `json:"hit_rate"`
+	Tiers   []CacheTierStats `json:"tiers"`
+}
+
+// CacheKeyInfo describes which tiers hold a cache key
+type CacheKeyInfo struct {
+	Key    string            `json:"key"`
+	Tiers  []string          `json:"tiers"`
+	Result *GenerationResult `json:"result,omitempty"`
+}
+
+// LayeredCache checks its tiers in order, fastest first. A hit in a slower
+// tier is copied into the faster ones, and writes go to every tier.
+type LayeredCache struct {
+	mu     sync.Mutex
+	tiers  []GenerationCache
+	stats  []CacheTierStats
+	hits   int64
+	misses int64
+}
+
+// NewLayeredCache creates a cache over tiers, fastest first
+func NewLayeredCache(tiers ...GenerationCache) *LayeredCache {
+	c := &LayeredCache{}
+	for _, tier := range tiers {
+		c.AddTier(tier)
+	}
+	return c
+}
+
+// AddTier appends a slower tier behind the existing ones
+func (c *LayeredCache) AddTier(tier GenerationCache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tiers = append(c.tiers, tier)
+	c.stats = append(c.stats, CacheTierStats{Tier: tier.Name()})
+}
+
+// Name returns the tier names joined with "+"
+func (c *LayeredCache) Name() string {
+	tiers := c.snapshot()
+	names := make([]string, 0, len(tiers))
+	for _, tier := range tiers {
+		names = append(names, tier.Name())
+	}
+	return strings.Join(names, "+")
+}
+
+// Get returns the first hit, backfilling faster tiers that missed
+func (c *LayeredCache) Get(key string) *GenerationResult {
+	tiers := c.snapshot()
+	for i, tier := range tiers {
+		result := tier.Get(key)
+		c.recordLookup(i, result != nil)
+		if result == nil {
+			continue
+		}
+
+		for j := 0; j < i; j++ {
+			tiers[j].Set(key, result)
+		}
+		c.mu.Lock()
+		c.hits++
+		c.mu.Unlock()
+		return result
+	}
+
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+	return nil
+}
+
+// Set writes value to every tier
+func (c *LayeredCache) Set(key string, value *GenerationResult) {
+	for i, tier := range c.snapshot() {
+		tier.Set(key, value)
+		c.mu.Lock()
+		c.stats[i].Writes++
+		c.mu.Unlock()
+	}
+}
+
+// Delete invalidates key in every tier
+func (c *LayeredCache) Delete(key string) {
+	for _, tier := range c.snapshot() {
+		tier.Delete(key)
+	}
+}
+
+// Clear empties every tier
+func (c *LayeredCache) Clear() {
+	for _, tier := range c.snapshot() {
+		tier.Clear()
+	}
+}
+
+// Size returns the number of distinct keys across tiers
+func (c *LayeredCache) Size() int {
+	return len(c.Keys())
+}
+
+// Keys returns the distinct keys across tiers, sorted
+func (c *LayeredCache) Keys() []string {
+	seen := make(map[string]bool)
+	for _, tier := range c.snapshot() {
+		for _, key := range tier.Keys() {
+			seen[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Inspect reports which tiers hold key and the cached result, without
+// counting towards hit metrics or backfilling
+func (c *LayeredCache) Inspect(key string) (*CacheKeyInfo, bool) {
+	info := &CacheKeyInfo{Key: key, Tiers: []string{}}
+	for _, tier := range c.snapshot() {
+		result := tier.Get(key)
+		if result == nil {
+			continue
+		}
+		info.Tiers = append(info.Tiers, tier.Name())
+		if info.Result == nil {
+			info.Result = result
+		}
+	}
+	return info, len(info.Tiers) > 0
+}
+
+// Stats returns overall and per-tier hit and miss counts
+func (c *LayeredCache) Stats() CacheStats {
+	c.mu.Lock()
+	tiers := append([]GenerationCache{}, c.tiers...)
+	stats := CacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		HitRate: hitRate(c.hits, c.misses),
+		Tiers:   append([]CacheTierStats{}, c.stats...),
+	}
+	c.mu.Unlock()
+
+	for i := range stats.Tiers {
+		stats.Tiers[i].HitRate = hitRate(stats.Tiers[i].Hits, stats.Tiers[i].Misses)
+		stats.Tiers[i].Entries = tiers[i].Size()
+	}
+	return stats
+}
+
+func (c *LayeredCache) snapshot() []GenerationCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]GenerationCache{}, c.tiers...)
+}
+
+func (c *LayeredCache) recordLookup(tier int, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.stats[tier].Hits++
+	} else {
+		c.stats[tier].Misses++
+	}
+}
+
+func hitRate(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
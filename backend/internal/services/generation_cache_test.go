@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func cachedResult(title string) *GenerationResult {
+	return &GenerationResult{
+		Recipe:   &models.RecipeData{Title: title, CookingTime: 10, Steps: []string{"焼く"}},
+		Metadata: GenerationMetadata{RequestID: "req-1", Model: "gpt-5-mini", TokensUsed: 120},
+	}
+}
+
+func TestSQLiteRecipeCache_SurvivesRestartAndExpires(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	cache := NewSQLiteRecipeCache(db, time.Hour)
+	require.NoError(t, cache.EnsureSchema())
+
+	cache.Set("recipe:鶏肉", cachedResult("鶏肉のソテー"))
+	cache.Set("recipe:鶏肉", cachedResult("鶏肉の照り焼き"))
+
+	// A new cache over the same database, as after a redeploy or on another replica
+	restarted := NewSQLiteRecipeCache(db, time.Hour)
+	result := restarted.Get("recipe:鶏肉")
+	require.NotNil(t, result)
+	assert.Equal(t, "鶏肉の照り焼き", result.Recipe.Title)
+	assert.Equal(t, 120, result.Metadata.TokensUsed)
+	assert.Equal(t, 1, restarted.Size())
+	assert.Equal(t, []string{"recipe:鶏肉"}, restarted.Keys())
+
+	expired := NewSQLiteRecipeCache(db, -time.Minute)
+	expired.Set("recipe:豚肉", cachedResult("豚の生姜焼き"))
+	assert.Nil(t, cache.Get("recipe:豚肉"))
+	purged, err := cache.PurgeExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	restarted.Delete("recipe:鶏肉")
+	assert.Nil(t, cache.Get("recipe:鶏肉"))
+}
+
+func TestLayeredCache_BackfillsFasterTiersAndCountsPerTier(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	persistent := NewSQLiteRecipeCache(db, time.Hour)
+	require.NoError(t, persistent.EnsureSchema())
+	persistent.Set("recipe:キャベツ", cachedResult("キャベツ炒め"))
+
+	memory := NewRecipeCache(10, time.Hour)
+	defer memory.Stop()
+	cache := NewLayeredCache(memory, persistent)
+	assert.Equal(t, "memory+sqlite", cache.Name())
+
+	// First lookup misses memory, hits SQLite and copies the entry into memory
+	require.NotNil(t, cache.Get("recipe:キャベツ"))
+	assert.True(t, memory.HasKey("recipe:キャベツ"))
+	require.NotNil(t, cache.Get("recipe:キャベツ"))
+	assert.Nil(t, cache.Get("recipe:なす"))
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	require.Len(t, stats.Tiers, 2)
+	assert.Equal(t, CacheTierStats{Tier: "memory", Hits: 1, Misses: 2, HitRate: 1.0 / 3, Entries: 1}, stats.Tiers[0])
+	assert.Equal(t, CacheTierStats{Tier: "sqlite", Hits: 1, Misses: 1, HitRate: 0.5, Entries: 1}, stats.Tiers[1])
+
+	cache.Set("recipe:なす", cachedResult("なすの揚げ浸し"))
+	assert.Equal(t, []string{"recipe:なす", "recipe:キャベツ"}, cache.Keys())
+
+	info, found := cache.Inspect("recipe:なす")
+	require.True(t, found)
+	assert.Equal(t, []string{"memory", "sqlite"}, info.Tiers)
+	assert.Equal(t, "なすの揚げ浸し", info.Result.Recipe.Title)
+	assert.Equal(t, int64(2), cache.Stats().Hits, "inspecting does not count as a hit")
+
+	cache.Delete("recipe:なす")
+	_, found = cache.Inspect("recipe:なす")
+	assert.False(t, found)
+	assert.Nil(t, persistent.Get("recipe:なす"))
+}
@@ -17,10 +17,13 @@ import (
 
 // RecipeGeneratorService handles AI-powered recipe generation
 type RecipeGeneratorService struct {
-	client      *openai.Client
+	provider    LLMProvider
 	config      *config.OpenAIConfig
 	rateLimiter *RateLimiter
-	cache       *RecipeCache
+	cache       *LayeredCache
+	cacheKeys   *CacheKeyNormalizer
+	dietary     *DietaryRestrictionService
+	safetyGate  RecipeSafetyGate
 }
 
 // GenerationResult holds the result of recipe generation
@@ -40,6 +43,11 @@ type GenerationMetadata struct {
 	ProcessingTime time.Duration `json:"processing_time"`
 	CacheHit       bool          `json:"cache_hit"`
 	RetryCount     int           `json:"retry_count"`
+
+	// Set when an existing library recipe was served instead of generating
+	ServedFromLibrary bool    `json:"served_from_library,omitempty"`
+	LibraryRecipeID   int     `json:"library_recipe_id,omitempty"`
+	LibrarySimilarity float64 `json:"library_similarity,omitempty"`
 }
 
 // BatchGenerationRequest represents a request for multiple recipes
@@ -54,21 +62,31 @@ func NewRecipeGeneratorService(config *config.OpenAIConfig) (*RecipeGeneratorSer
 		return nil, errors.New("config cannot be nil")
 	}
 
-	client := openai.NewClient(config.APIKey)
+	provider, err := NewLLMProvider(config)
+	if err != nil {
+		return nil, err
+	}
 	rateLimiter := NewRateLimiter(config.RequestsPerMinute)
-	cache := NewRecipeCache(1000, 24*time.Hour) // Cache for 24 hours
+	cache := NewLayeredCache(NewRecipeCache(1000, 24*time.Hour)) // Cache for 24 hours
 
 	return &RecipeGeneratorService{
-		client:      client,
+		provider:    provider,
 		config:      config,
 		rateLimiter: rateLimiter,
 		cache:       cache,
+		cacheKeys:   NewCacheKeyNormalizer(nil),
 	}, nil
 }
 
-// GetClient returns the OpenAI client
-func (s *RecipeGeneratorService) GetClient() *openai.Client {
-	return s.client
+// GetProvider returns the LLM provider
+func (s *RecipeGeneratorService) GetProvider() LLMProvider {
+	return s.provider
+}
+
+// UseProvider replaces the LLM provider, e.g. with a metered wrapper. Call it
+// before other services take the provider from GetProvider.
+func (s *RecipeGeneratorService) UseProvider(provider LLMProvider) {
+	s.provider = provider
 }
 
 // GetRateLimiter returns the rate limiter
@@ -76,19 +94,44 @@ func (s *RecipeGeneratorService) GetRateLimiter() *RateLimiter {
 	return s.rateLimiter
 }
 
-// GetCache returns the recipe cache
-func (s *RecipeGeneratorService) GetCache() *RecipeCache {
+// GetCache returns the generation cache. It starts with an in-memory tier;
+// callers add persistent tiers with AddTier.
+func (s *RecipeGeneratorService) GetCache() *LayeredCache {
 	return s.cache
 }
 
+// SetCacheKeyNormalizer replaces the cache key normalizer, e.g. with one
+// that resolves ingredient aliases through the ingredient hierarchy
+func (s *RecipeGeneratorService) SetCacheKeyNormalizer(normalizer *CacheKeyNormalizer) {
+	s.cacheKeys = normalizer
+}
+
+// GetCacheKeyNormalizer returns the cache key normalizer
+func (s *RecipeGeneratorService) GetCacheKeyNormalizer() *CacheKeyNormalizer {
+	return s.cacheKeys
+}
+
+// SetDietaryRestrictionService lets the dietary check resolve hidden
+// ingredients through the ingredient hierarchy; without it only ingredient
+// names are checked
+func (s *RecipeGeneratorService) SetDietaryRestrictionService(dietary *DietaryRestrictionService) {
+	s.dietary = dietary
+}
+
+// SetSafetyGate makes every generated recipe pass the food safety gate
+// before it is returned or cached, so callers only ever save gated recipes
+func (s *RecipeGeneratorService) SetSafetyGate(gate RecipeSafetyGate) {
+	s.safetyGate = gate
+}
+
 // GenerateRecipe generates a single recipe based on the request
 func (s *RecipeGeneratorService) GenerateRecipe(ctx context.Context, req RecipeGenerationRequest) (*GenerationResult, error) {
 	startTime := time.Now()
 	requestID := generateRequestID()
 
 	// Check cache first
-	cacheKey := s.generateCacheKey(req)
-	if cachedResult := s.cache.Get(cacheKey); cachedResult != nil {
+	cacheKey := s.cacheKeys.Key(req)
+	if cachedResult := s.cache.Get(cacheKey); cachedResult != nil && resultFitsRequest(cachedResult, req.MaxCookingTime) {
 		cachedResult.Metadata.CacheHit = true
 		cachedResult.Metadata.RequestID = requestID
 		cachedResult.Metadata.ProcessingTime = time.Since(startTime)
@@ -113,6 +156,7 @@ func (s *RecipeGeneratorService) GenerateRecipe(ctx context.Context, req RecipeG
 	}
 
 	// Call OpenAI API with retries
+	ctx = WithUsageSource(ctx, UsageSource{Stage: "generation"})
 	recipe, tokensUsed, retryCount, err := s.callOpenAIWithRetry(ctx, promptTemplate)
 	if err != nil {
 		result.Error = err.Error()
@@ -131,6 +175,22 @@ func (s *RecipeGeneratorService) GenerateRecipe(ctx context.Context, req RecipeG
 		return result, fmt.Errorf("recipe validation failed: %w", err)
 	}
 
+	// Unsafe recipes are remediated or quarantined before anyone can save them
+	if s.safetyGate != nil {
+		gate, err := s.safetyGate.GateRecipe(ctx, result.Recipe, "generation")
+		if err != nil {
+			result.Error = err.Error()
+			return result, err
+		}
+		result.Recipe = gate.Recipe
+	}
+
+	// Models do not always honour dietary restrictions, so check the result
+	if err := s.dietary.CheckRecipe(result.Recipe, req.DietaryRestrictions); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+
 	// Cache the result
 	s.cache.Set(cacheKey, result)
 
@@ -146,8 +206,8 @@ func (s *RecipeGeneratorService) GenerateBatchRecipes(ctx context.Context, req B
 	requestID := generateRequestID()
 
 	// Check cache
-	cacheKey := s.generateBatchCacheKey(req)
-	if cachedResult := s.cache.Get(cacheKey); cachedResult != nil {
+	cacheKey := s.cacheKeys.BatchKey(req)
+	if cachedResult := s.cache.Get(cacheKey); cachedResult != nil && resultFitsRequest(cachedResult, req.MaxCookingTime) {
 		cachedResult.Metadata.CacheHit = true
 		cachedResult.Metadata.RequestID = requestID
 		cachedResult.Metadata.ProcessingTime = time.Since(startTime)
@@ -172,6 +232,7 @@ func (s *RecipeGeneratorService) GenerateBatchRecipes(ctx context.Context, req B
 	}
 
 	// Call OpenAI API
+	ctx = WithUsageSource(ctx, UsageSource{Stage: "generation"})
 	recipes, tokensUsed, retryCount, err := s.callOpenAIBatchWithRetry(ctx, promptTemplate)
 	if err != nil {
 		result.Error = err.Error()
@@ -180,16 +241,37 @@ func (s *RecipeGeneratorService) GenerateBatchRecipes(ctx context.Context, req B
 		return result, err
 	}
 
-	result.Recipes = recipes
 	result.Metadata.TokensUsed = tokensUsed
 	result.Metadata.ProcessingTime = time.Since(startTime)
 	result.Metadata.RetryCount = retryCount
 
-	// Validate each recipe
-	for i := range result.Recipes {
-		if err := s.validateAndEnhanceRecipe(&result.Recipes[i]); err != nil {
+	// Validate each recipe, dropping those that fail the safety gate or
+	// break the dietary restrictions
+	var dropErr error
+	for i := range recipes {
+		if err := s.validateAndEnhanceRecipe(&recipes[i]); err != nil {
 			log.Printf("Warning: Recipe %d validation failed: %v", i, err)
 		}
+		if s.safetyGate != nil {
+			gate, err := s.safetyGate.GateRecipe(ctx, &recipes[i], "batch")
+			if err != nil {
+				log.Printf("Warning: Dropping recipe %d '%s': %v", i, recipes[i].Title, err)
+				dropErr = err
+				continue
+			}
+			recipes[i] = *gate.Recipe
+		}
+		if err := s.dietary.CheckRecipe(&recipes[i], req.DietaryRestrictions); err != nil {
+			log.Printf("Warning: Dropping recipe %d '%s': %v", i, recipes[i].Title, err)
+			dropErr = err
+			continue
+		}
+		result.Recipes = append(result.Recipes, recipes[i])
+	}
+	if len(result.Recipes) == 0 && dropErr != nil {
+		err := fmt.Errorf("none of the %d generated recipes could be kept: %w", len(recipes), dropErr)
+		result.Error = err.Error()
+		return result, err
 	}
 
 	// Cache the result
@@ -282,7 +364,7 @@ func (s *RecipeGeneratorService) callOpenAIForRecipe(ctx context.Context, prompt
 	timeoutCtx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
 	defer cancel()
 
-	resp, err := s.client.CreateChatCompletion(timeoutCtx, req)
+	resp, err := s.provider.CreateChatCompletion(timeoutCtx, req)
 	if err != nil {
 		return nil, 0, fmt.Errorf("OpenAI API call failed: %w", err)
 	}
@@ -291,8 +373,7 @@ func (s *RecipeGeneratorService) callOpenAIForRecipe(ctx context.Context, prompt
 		return nil, resp.Usage.TotalTokens, errors.New("no choices returned from OpenAI")
 	}
 
-	content := resp.Choices[0].Message.Content
-	content = strings.TrimSpace(content)
+	content := extractJSONContent(resp.Choices[0].Message.Content)
 
 	// Parse JSON response
 	var recipe models.RecipeData
@@ -301,7 +382,7 @@ func (s *RecipeGeneratorService) callOpenAIForRecipe(ctx context.Context, prompt
 	}
 
 	// Fix OpenAI API inconsistencies
-	if err := s.fixRecipeInconsistencies(&recipe, content); err != nil {
+	if err := fixRecipeInconsistencies(&recipe, content); err != nil {
 		return nil, resp.Usage.TotalTokens, fmt.Errorf("failed to fix recipe inconsistencies: %w", err)
 	}
 
@@ -309,7 +390,7 @@ func (s *RecipeGeneratorService) callOpenAIForRecipe(ctx context.Context, prompt
 }
 
 // fixRecipeInconsistencies fixes common OpenAI API response inconsistencies
-func (s *RecipeGeneratorService) fixRecipeInconsistencies(recipe *models.RecipeData, rawContent string) error {
+func fixRecipeInconsistencies(recipe *models.RecipeData, rawContent string) error {
 	// Parse raw JSON to extract missing fields
 	var rawData map[string]interface{}
 	if err := json.Unmarshal([]byte(rawContent), &rawData); err != nil {
@@ -370,7 +451,7 @@ func (s *RecipeGeneratorService) callOpenAIForBatchRecipes(ctx context.Context,
 	timeoutCtx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
 	defer cancel()
 
-	resp, err := s.client.CreateChatCompletion(timeoutCtx, req)
+	resp, err := s.provider.CreateChatCompletion(timeoutCtx, req)
 	if err != nil {
 		return nil, 0, fmt.Errorf("OpenAI API call failed: %w", err)
 	}
@@ -379,8 +460,7 @@ func (s *RecipeGeneratorService) callOpenAIForBatchRecipes(ctx context.Context,
 		return nil, resp.Usage.TotalTokens, errors.New("no choices returned from OpenAI")
 	}
 
-	content := resp.Choices[0].Message.Content
-	content = strings.TrimSpace(content)
+	content := extractJSONContent(resp.Choices[0].Message.Content)
 
 	// Parse JSON response
 	var batchResponse struct {
@@ -435,23 +515,16 @@ func (s *RecipeGeneratorService) validateAndEnhanceRecipe(recipe *models.RecipeD
 
 // Helper functions
 
-func (s *RecipeGeneratorService) generateCacheKey(req RecipeGenerationRequest) string {
-	return fmt.Sprintf("recipe:%s:%s:%d:%d",
-		strings.Join(req.Ingredients, ","),
-		req.Season,
-		req.MaxCookingTime,
-		req.Servings,
-	)
-}
-
-func (s *RecipeGeneratorService) generateBatchCacheKey(req BatchGenerationRequest) string {
-	return fmt.Sprintf("batch:%s:%s:%d:%d:%d",
-		strings.Join(req.Ingredients, ","),
-		req.Season,
-		req.MaxCookingTime,
-		req.Servings,
-		req.Count,
-	)
+// extractJSONContent strips Markdown code fences and surrounding prose that
+// models without JSON mode tend to wrap around their answer
+func extractJSONContent(content string) string {
+	content = strings.TrimSpace(content)
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return content
+	}
+	return content[start : end+1]
 }
 
 func generateRequestID() string {
@@ -459,6 +532,11 @@ func generateRequestID() string {
 }
 
 func isNonRetryableError(err error) bool {
+	// The budget gate has already waited for capacity where it makes sense
+	if errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrRateLimited) {
+		return true
+	}
+
 	// Check for specific error types that shouldn't be retried
 	errStr := err.Error()
 	return strings.Contains(errStr, "invalid_api_key") ||
@@ -470,6 +548,7 @@ func isNonRetryableError(err error) bool {
 func (s *RecipeGeneratorService) GetHealth() map[string]interface{} {
 	return map[string]interface{}{
 		"status":          "healthy",
+		"provider":        s.provider.Name(),
 		"model":           s.config.Model,
 		"cache_size":      s.cache.Size(),
 		"rate_limit_rpm":  s.config.RequestsPerMinute,
@@ -24,7 +24,7 @@ func TestNewRecipeGeneratorService(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.NotNil(t, service)
-	assert.NotNil(t, service.client)
+	assert.NotNil(t, service.provider)
 	assert.NotNil(t, service.config)
 	assert.NotNil(t, service.rateLimiter)
 	assert.NotNil(t, service.cache)
@@ -256,8 +256,8 @@ func TestGenerateCacheKey(t *testing.T) {
 		Servings:    2,
 	}
 
-	key1 := service.generateCacheKey(req)
-	key2 := service.generateCacheKey(req)
+	key1 := service.GetCacheKeyNormalizer().Key(req)
+	key2 := service.GetCacheKeyNormalizer().Key(req)
 
 	// Same request should generate same key
 	assert.Equal(t, key1, key2)
@@ -266,7 +266,7 @@ func TestGenerateCacheKey(t *testing.T) {
 	// Different request should generate different key
 	req2 := req
 	req2.Ingredients = []string{"鶏肉"}
-	key3 := service.generateCacheKey(req2)
+	key3 := service.GetCacheKeyNormalizer().Key(req2)
 	assert.NotEqual(t, key1, key3)
 }
 
@@ -304,3 +304,34 @@ func TestRecipeGeneratorService_ContextCancellation(t *testing.T) {
 		assert.NotEmpty(t, result.Error)
 	}
 }
+
+func TestRecipeGenerationRequest_ApplyUserPreferences(t *testing.T) {
+	req := RecipeGenerationRequest{
+		Ingredients:    []string{"鶏もも肉"},
+		Season:         "all",
+		MaxCookingTime: 30,
+		Preferences:    []string{"甘め"},
+	}
+
+	req.ApplyUserPreferences(models.UserPreferencesData{
+		MaxCookingTime:      15,
+		CookingSkillLevel:   "intermediate",
+		ExcludeIngredients:  []string{"セロリ"},
+		AllergyInfo:         []string{"えび"},
+		KitchenEquipment:    []string{"電子レンジ"},
+		PreferredTags:       []string{"簡単"},
+		HouseholdSize:       2,
+		DietaryRestrictions: []string{"ベジタリアン"},
+	})
+
+	assert.Equal(t, 15, req.MaxCookingTime)
+	assert.Equal(t, 2, req.Servings)
+	assert.Equal(t, []string{"セロリを使わない", "えびを使わない", "使える調理器具: 電子レンジ"}, req.Constraints)
+	assert.Equal(t, []string{"甘め", "簡単"}, req.Preferences)
+	assert.Equal(t, []string{"ベジタリアン"}, req.DietaryRestrictions)
+
+	// A beginner's limit is tightened the same way as for meal plans
+	req = RecipeGenerationRequest{Ingredients: []string{"鶏もも肉"}, Season: "all", MaxCookingTime: 30}
+	req.ApplyUserPreferences(models.UserPreferencesData{MaxCookingTime: 30, CookingSkillLevel: "beginner"})
+	assert.Equal(t, 20, req.MaxCookingTime)
+}
@@ -88,7 +88,23 @@ func (a *IngredientAggregator) ParseQuantity(amountStr string) (*IngredientQuant
 		}
 	}
 
-	// Pattern 2: "1大さじ" style (number first, then unit)
+	// Pattern 2: "1/4個" style (fraction first, then unit)
+	fractionRe := regexp.MustCompile(`^(\d+)/(\d+)\s*(.*)$`)
+	fractionMatches := fractionRe.FindStringSubmatch(amountStr)
+
+	if len(fractionMatches) == 4 {
+		numerator, numErr := strconv.ParseFloat(fractionMatches[1], 64)
+		denominator, denErr := strconv.ParseFloat(fractionMatches[2], 64)
+		if numErr == nil && denErr == nil && denominator != 0 {
+			unit := strings.TrimSpace(fractionMatches[3])
+			if unit == "" {
+				unit = "個"
+			}
+			return &IngredientQuantity{Amount: numerator / denominator, Unit: unit}, nil
+		}
+	}
+
+	// Pattern 3: "1大さじ" style (number first, then unit)
 	numberFirstRe := regexp.MustCompile(`^(\d+\.?\d*)\s*(.*)$`)
 	numberFirstMatches := numberFirstRe.FindStringSubmatch(amountStr)
 
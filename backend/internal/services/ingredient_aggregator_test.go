@@ -28,6 +28,8 @@ func TestParseQuantity(t *testing.T) {
 		{"", nil, true},
 		{"2", &IngredientQuantity{Amount: 2, Unit: "個"}, false},
 		{"3 本", &IngredientQuantity{Amount: 3, Unit: "本"}, false},
+		{"1/4個", &IngredientQuantity{Amount: 0.25, Unit: "個"}, false},
+		{"1/2本", &IngredientQuantity{Amount: 0.5, Unit: "本"}, false},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// ingredientLabels maps ingredient names and aliases from the hierarchy to
+// labels attached to them, such as allergens or dietary components
+type ingredientLabels struct {
+	byName map[string][]string
+	names  []string // byName keys, longest first
+}
+
+// loadIngredientLabels loads the labels in a label table (ingredient_allergens,
+// ingredient_dietary_components) for every specific ingredient, including
+// those inherited from its groups and their ancestors, keyed by name and alias.
+// The table and column are fixed names, never user input.
+func loadIngredientLabels(db *database.Database, table, column string) (ingredientLabels, error) {
+	labels := ingredientLabels{byName: make(map[string][]string)}
+	if db == nil {
+		return labels, nil
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		WITH RECURSIVE group_labels(group_id, label) AS (
+			SELECT group_id, %[2]s FROM %[1]s WHERE group_id IS NOT NULL
+			UNION
+			SELECT g.id, gl.label FROM ingredient_groups g JOIN group_labels gl ON g.parent_id = gl.group_id
+		)
+		SELECT si.name, si.aliases, l.%[2]s
+		FROM specific_ingredients si
+		JOIN %[1]s l ON l.ingredient_id = si.id
+		UNION
+		SELECT si.name, si.aliases, gl.label
+		FROM specific_ingredients si
+		JOIN ingredient_group_mappings igm ON igm.ingredient_id = si.id
+		JOIN group_labels gl ON gl.group_id = igm.group_id
+	`, table, column))
+	if err != nil {
+		return labels, fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	for rows.Next() {
+		var name, label string
+		var aliases sql.NullString
+		if err := rows.Scan(&name, &aliases, &label); err != nil {
+			return labels, fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+
+		labels.add(name, label)
+		if aliases.Valid && aliases.String != "" {
+			var aliasList []string
+			if err := json.Unmarshal([]byte(aliases.String), &aliasList); err == nil {
+				for _, alias := range aliasList {
+					labels.add(alias, label)
+				}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return labels, err
+	}
+
+	sort.Slice(labels.names, func(i, j int) bool {
+		if len(labels.names[i]) != len(labels.names[j]) {
+			return len(labels.names[i]) > len(labels.names[j])
+		}
+		return labels.names[i] < labels.names[j]
+	})
+	return labels, nil
+}
+
+// labelTableVersion fingerprints the hierarchy tables a label table is
+// resolved through: their row counts and highest rowids, plus the total
+// alias length so renamed aliases count as a change. It costs a few index
+// scans, far less than resolving the labels themselves.
+func labelTableVersion(db *database.Database, table string) (string, error) {
+	var version string
+	err := db.QueryRow(fmt.Sprintf(`
+		SELECT
+			(SELECT COUNT(*) || ':' || COALESCE(MAX(rowid), 0) || ':' || TOTAL(length(aliases)) FROM specific_ingredients) || '/' ||
+			(SELECT COUNT(*) || ':' || COALESCE(MAX(rowid), 0) || ':' || TOTAL(COALESCE(parent_id, 0)) FROM ingredient_groups) || '/' ||
+			(SELECT COUNT(*) || ':' || COALESCE(MAX(rowid), 0) FROM ingredient_group_mappings) || '/' ||
+			(SELECT COUNT(*) || ':' || COALESCE(MAX(rowid), 0) FROM %s)
+	`, table)).Scan(&version)
+	if err != nil {
+		return "", fmt.Errorf("failed to query %s version: %w", table, err)
+	}
+	return version, nil
+}
+
+// ingredientLabelCache keeps loaded labels until the hierarchy tables change,
+// so saving a recipe does not resolve the whole hierarchy again
+type ingredientLabelCache struct {
+	mu      sync.Mutex
+	version string
+	labels  ingredientLabels
+}
+
+// load returns the cached labels of a label table, reloading them when the
+// hierarchy version has changed since they were loaded
+func (c *ingredientLabelCache) load(db *database.Database, table, column string) (ingredientLabels, error) {
+	if db == nil {
+		return ingredientLabels{}, nil
+	}
+	version, err := labelTableVersion(db, table)
+	if err != nil {
+		return ingredientLabels{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version == version {
+		return c.labels, nil
+	}
+	labels, err := loadIngredientLabels(db, table, column)
+	if err != nil {
+		return labels, err
+	}
+	c.version, c.labels = version, labels
+	return labels, nil
+}
+
+func (l *ingredientLabels) add(name, label string) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return
+	}
+	if l.byName == nil {
+		l.byName = make(map[string][]string)
+	}
+	if _, exists := l.byName[name]; !exists {
+		l.names = append(l.names, name)
+	}
+	l.byName[name] = append(l.byName[name], label)
+}
+
+// lookup returns the labels of the hierarchy ingredient an ingredient names
+// exactly, or else of every hierarchy ingredient whose name or alias it
+// contains (so "マヨネーズ（カロリーハーフ）" still resolves to マヨネーズ).
+// Labels from contained names are skipped when excluded reports the
+// ingredient as an exception, so 焼きそば does not inherit そば.
+func (l ingredientLabels) lookup(ingredient string, excluded func(label, ingredient string) bool) []string {
+	name := strings.ToLower(strings.TrimSpace(ingredient))
+	if exact, ok := l.byName[name]; ok {
+		return exact
+	}
+
+	var labels []string
+	for _, known := range l.names {
+		if !strings.Contains(name, known) {
+			continue
+		}
+		for _, label := range l.byName[known] {
+			if !excluded(label, name) {
+				labels = append(labels, label)
+			}
+		}
+	}
+	return labels
+}
+
+// refreshStoredRecipes runs update on every stored recipe, writing back
+// those it changed, and returns how many were written
+func refreshStoredRecipes(db *database.Database, update func(*models.RecipeData) bool) (int, error) {
+	rows, err := db.Query(`SELECT id FROM recipes ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query recipes: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("failed to scan recipe id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		log.Printf("Warning: failed to close rows: %v", err)
+	}
+
+	updated := 0
+	for _, id := range ids {
+		changed, err := refreshStoredRecipe(db, id, update)
+		if err != nil {
+			return updated, err
+		}
+		if changed {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// refreshStoredRecipe runs update on one stored recipe and writes it back if
+// it changed. It writes the row directly so saved-recipe hooks do not run again.
+// The write only lands if the row still holds the data that was read; a save
+// in between wins, and its own hook labels the newer data.
+func refreshStoredRecipe(db *database.Database, recipeID int, update func(*models.RecipeData) bool) (bool, error) {
+	var data string
+	if err := db.QueryRow(`SELECT data FROM recipes WHERE id = ?`, recipeID).Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return false, models.ErrRecipeNotFound
+		}
+		return false, fmt.Errorf("failed to query recipe: %w", err)
+	}
+	recipe, err := decodeStoredRecipe(recipeID, data)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal recipe: %w", err)
+	}
+
+	if !update(&recipe.Data) {
+		return false, nil
+	}
+
+	updated, err := json.Marshal(recipe.Data)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+	result, err := db.Exec(`UPDATE recipes SET data = ? WHERE id = ? AND data = ?`, string(updated), recipeID, data)
+	if err != nil {
+		return false, fmt.Errorf("failed to update recipe: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update recipe: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// RecipeLabeler stores allergens and dietary compliance flags on saved
+// recipes with one read and at most one write per recipe. Either service
+// may be nil to store only the other's labels.
+type RecipeLabeler struct {
+	db        *database.Database
+	allergens *AllergenService
+	dietary   *DietaryRestrictionService
+}
+
+// NewRecipeLabeler creates a labeler over the given services
+func NewRecipeLabeler(db *database.Database, allergens *AllergenService, dietary *DietaryRestrictionService) *RecipeLabeler {
+	return &RecipeLabeler{db: db, allergens: allergens, dietary: dietary}
+}
+
+// update loads the current tables once and returns a function applying both
+func (l *RecipeLabeler) update() func(*models.RecipeData) bool {
+	var allergens *AllergenTable
+	if l.allergens != nil {
+		allergens = l.allergens.loadTable()
+	}
+	var dietary *DietaryTable
+	if l.dietary != nil {
+		dietary = l.dietary.loadTable()
+	}
+
+	return func(recipe *models.RecipeData) bool {
+		changed := false
+		if allergens != nil && allergens.update(recipe) {
+			changed = true
+		}
+		if dietary != nil && dietary.update(recipe) {
+			changed = true
+		}
+		return changed
+	}
+}
+
+// HandleRecipeSaved stores the labels of a newly saved or updated recipe;
+// register it with Database.OnRecipeSaved
+func (l *RecipeLabeler) HandleRecipeSaved(recipeID int) {
+	if _, err := refreshStoredRecipe(l.db, recipeID, l.update()); err != nil {
+		log.Printf("Warning: failed to store allergens and dietary flags for recipe %d: %v", recipeID, err)
+	}
+}
+
+// RefreshRecipes recomputes the labels of every stored recipe, writing only
+// those that changed, and returns how many were updated. Run it at startup so
+// recipes saved before the labels existed, or before the hierarchy changed,
+// carry current labels.
+func (l *RecipeLabeler) RefreshRecipes() (int, error) {
+	return refreshStoredRecipes(l.db, l.update())
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// IngredientPriceService manages per-ingredient prices and turns quantities into costs
+type IngredientPriceService struct {
+	db         *database.Database
+	aggregator *IngredientAggregator
+}
+
+// NewIngredientPriceService creates a new ingredient price service
+func NewIngredientPriceService(db *database.Database, aggregator *IngredientAggregator) *IngredientPriceService {
+	return &IngredientPriceService{
+		db:         db,
+		aggregator: aggregator,
+	}
+}
+
+// EnsureSchema creates the ingredient_prices table that SetPrice and
+// ImportCSV write to, holding one price per base unit for each specific
+// ingredient
+func (s *IngredientPriceService) EnsureSchema() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS ingredient_prices (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ingredient_id INTEGER NOT NULL UNIQUE,
+			base_unit TEXT NOT NULL,
+			price_per_unit REAL NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (ingredient_id) REFERENCES specific_ingredients(id) ON DELETE CASCADE,
+			CHECK (base_unit != ''),
+			CHECK (price_per_unit >= 0)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create ingredient_prices: %w", err)
+	}
+	return nil
+}
+
+// ListPrices returns every stored price
+func (s *IngredientPriceService) ListPrices() ([]models.IngredientPrice, error) {
+	rows, err := s.db.Query(`
+		SELECT ip.ingredient_id, si.name, ip.base_unit, ip.price_per_unit, ip.updated_at
+		FROM ingredient_prices ip
+		JOIN specific_ingredients si ON si.id = ip.ingredient_id
+		ORDER BY si.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ingredient prices: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	prices := make([]models.IngredientPrice, 0)
+	for rows.Next() {
+		var price models.IngredientPrice
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&price.IngredientID, &price.Ingredient, &price.BaseUnit, &price.PricePerUnit, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ingredient price: %w", err)
+		}
+		price.UpdatedAt = updatedAt.Time
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+// SetPrice stores the price of an ingredient from a shop price and the
+// quantity it buys, e.g. 198 yen for "100g". The ingredient may be given by
+// name or alias.
+func (s *IngredientPriceService) SetPrice(ingredient string, price float64, quantity string) (*models.IngredientPrice, error) {
+	if price < 0 || math.IsNaN(price) || math.IsInf(price, 0) {
+		return nil, models.ErrInvalidPrice
+	}
+
+	qty, err := s.aggregator.ParseQuantity(quantity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidPrice, err)
+	}
+	base, err := s.aggregator.ConvertToBaseUnit(qty)
+	if err != nil || base.Unit == "適量" || base.Amount <= 0 {
+		return nil, models.ErrInvalidPrice
+	}
+
+	var ingredientID int
+	var name string
+	err = s.db.QueryRow(`
+		SELECT si.id, si.name
+		FROM specific_ingredients si
+		WHERE si.name = ?
+		   OR si.display_name = ?
+		   OR EXISTS (SELECT 1 FROM json_each(si.aliases) WHERE value = ?)
+		ORDER BY si.name = ? DESC
+		LIMIT 1
+	`, ingredient, ingredient, ingredient, ingredient).Scan(&ingredientID, &name)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", models.ErrIngredientNotFound, ingredient)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up ingredient: %w", err)
+	}
+
+	perUnit := price / base.Amount
+	_, err = s.db.Exec(`
+		INSERT INTO ingredient_prices (ingredient_id, base_unit, price_per_unit)
+		VALUES (?, ?, ?)
+		ON CONFLICT(ingredient_id) DO UPDATE SET
+			base_unit = excluded.base_unit,
+			price_per_unit = excluded.price_per_unit,
+			updated_at = CURRENT_TIMESTAMP
+	`, ingredientID, base.Unit, perUnit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save ingredient price: %w", err)
+	}
+
+	return &models.IngredientPrice{
+		IngredientID: ingredientID,
+		Ingredient:   name,
+		BaseUnit:     base.Unit,
+		PricePerUnit: perUnit,
+	}, nil
+}
+
+// PriceImportError describes a CSV row that could not be imported
+type PriceImportError struct {
+	Line       int    `json:"line"`
+	Ingredient string `json:"ingredient,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// PriceImportResult summarises a CSV import
+type PriceImportResult struct {
+	Imported int                `json:"imported"`
+	Skipped  []PriceImportError `json:"skipped"`
+}
+
+// ImportCSV imports prices from CSV rows of ingredient,price,quantity, e.g.
+// "豚こま肉,198,100g". A header row is skipped. Rows that fail are reported
+// and the rest are still imported.
+func (s *IngredientPriceService) ImportCSV(r io.Reader) (*PriceImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	result := &PriceImportResult{Skipped: []PriceImportError{}}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+
+		if len(record) < 3 {
+			result.Skipped = append(result.Skipped, PriceImportError{Line: line, Reason: "expected ingredient,price,quantity"})
+			continue
+		}
+
+		ingredient := strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff"))
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if line == 1 {
+				continue // Header row
+			}
+			result.Skipped = append(result.Skipped, PriceImportError{Line: line, Ingredient: ingredient, Reason: "invalid price"})
+			continue
+		}
+
+		if _, err := s.SetPrice(ingredient, price, strings.TrimSpace(record[2])); err != nil {
+			if !errors.Is(err, models.ErrIngredientNotFound) && !errors.Is(err, models.ErrInvalidPrice) {
+				return nil, err
+			}
+			result.Skipped = append(result.Skipped, PriceImportError{Line: line, Ingredient: ingredient, Reason: err.Error()})
+			continue
+		}
+		result.Imported++
+	}
+
+	return result, nil
+}
+
+// PriceTable maps ingredient names and aliases to their prices
+type PriceTable map[string]models.IngredientPrice
+
+// LoadTable loads every price, keyed by ingredient name and each alias
+func (s *IngredientPriceService) LoadTable() (PriceTable, error) {
+	table := make(PriceTable)
+	if s == nil || s.db == nil {
+		return table, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT ip.ingredient_id, si.name, si.aliases, ip.base_unit, ip.price_per_unit
+		FROM ingredient_prices ip
+		JOIN specific_ingredients si ON si.id = ip.ingredient_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ingredient prices: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	for rows.Next() {
+		var price models.IngredientPrice
+		var aliases sql.NullString
+		if err := rows.Scan(&price.IngredientID, &price.Ingredient, &aliases, &price.BaseUnit, &price.PricePerUnit); err != nil {
+			return nil, fmt.Errorf("failed to scan ingredient price: %w", err)
+		}
+
+		table[price.Ingredient] = price
+		if aliases.Valid && aliases.String != "" {
+			var aliasList []string
+			if err := json.Unmarshal([]byte(aliases.String), &aliasList); err == nil {
+				for _, alias := range aliasList {
+					if _, exists := table[alias]; !exists {
+						table[alias] = price
+					}
+				}
+			}
+		}
+	}
+
+	return table, rows.Err()
+}
+
+// Cost returns the cost in yen of a quantity of an ingredient. known is false
+// when the ingredient has no price or its unit can't be converted to the
+// priced unit. "適量" costs nothing.
+func (t PriceTable) Cost(aggregator *IngredientAggregator, ingredient string, qty *IngredientQuantity) (cost float64, known bool) {
+	if qty == nil || qty.Unit == "適量" {
+		return 0, true
+	}
+
+	price, exists := t[ingredient]
+	if !exists {
+		return 0, false
+	}
+
+	base, err := aggregator.ConvertToBaseUnit(qty)
+	if err != nil || base.Unit != price.BaseUnit {
+		return 0, false
+	}
+	return base.Amount * price.PricePerUnit, true
+}
+
+// ItemCost returns the cost of a shopping item, falling back to
+// models.DefaultItemCost when the price is unknown
+func (t PriceTable) ItemCost(aggregator *IngredientAggregator, ingredient string, qty *IngredientQuantity) (cost int, estimated bool) {
+	yen, known := t.Cost(aggregator, ingredient, qty)
+	if !known {
+		return models.DefaultItemCost, true
+	}
+	return int(math.Round(yen)), false
+}
+
+// RecipeCost returns the ingredient cost of a recipe in yen
+func (t PriceTable) RecipeCost(aggregator *IngredientAggregator, recipe *models.RecipeData) int {
+	return t.ScaledRecipeCost(aggregator, recipe, 1)
+}
+
+// ScaledRecipeCost returns the ingredient cost of a recipe with every
+// quantity multiplied by scale, e.g. to cook it for a larger household
+func (t PriceTable) ScaledRecipeCost(aggregator *IngredientAggregator, recipe *models.RecipeData, scale float64) int {
+	total := 0
+	for _, ingredient := range recipe.Ingredients {
+		qty, err := aggregator.ParseQuantity(ingredient.Amount)
+		if err != nil {
+			continue
+		}
+		qty.Amount *= scale
+		cost, _ := t.ItemCost(aggregator, ingredient.Name, qty)
+		total += cost
+	}
+	return total
+}
+
+// loadPriceTable loads the price table, logging and returning an empty table
+// on failure so costs fall back to flat estimates
+func (s *IngredientPriceService) loadPriceTable() PriceTable {
+	table, err := s.LoadTable()
+	if err != nil {
+		log.Printf("Warning: ingredient prices unavailable, using flat estimates: %v", err)
+		return make(PriceTable)
+	}
+	return table
+}
+
+// RecipeCost prices a recipe with the current price table
+func (s *IngredientPriceService) RecipeCost(recipe *models.RecipeData) int {
+	return s.loadPriceTable().RecipeCost(s.aggregator, recipe)
+}
+
+// CheaperAlternatives returns priced ingredients from the same group as the
+// given ingredient that use the same base unit and cost less, cheapest first.
+// Only the most specific group with cheaper members is used, so キャベツ is
+// compared with other leafy vegetables before vegetables in general.
+func (s *IngredientPriceService) CheaperAlternatives(ingredient string) ([]models.IngredientPrice, error) {
+	rows, err := s.db.Query(`
+		WITH target AS (
+			SELECT si.id, ip.base_unit, ip.price_per_unit
+			FROM specific_ingredients si
+			JOIN ingredient_prices ip ON ip.ingredient_id = si.id
+			WHERE si.name = ?
+			   OR EXISTS (SELECT 1 FROM json_each(si.aliases) WHERE value = ?)
+			LIMIT 1
+		)
+		SELECT g.level, alt.id, alt.name, p.base_unit, p.price_per_unit
+		FROM target t
+		JOIN ingredient_group_mappings tm ON tm.ingredient_id = t.id
+		JOIN ingredient_groups g ON g.id = tm.group_id
+		JOIN ingredient_group_mappings am ON am.group_id = tm.group_id AND am.ingredient_id != t.id
+		JOIN specific_ingredients alt ON alt.id = am.ingredient_id
+		JOIN ingredient_prices p ON p.ingredient_id = alt.id
+		WHERE p.base_unit = t.base_unit AND p.price_per_unit < t.price_per_unit
+		ORDER BY g.level DESC, p.price_per_unit, alt.name
+	`, ingredient, ingredient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cheaper alternatives: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	alternatives := make([]models.IngredientPrice, 0)
+	seen := make(map[int]bool)
+	bestLevel := -1
+	for rows.Next() {
+		var level int
+		var price models.IngredientPrice
+		if err := rows.Scan(&level, &price.IngredientID, &price.Ingredient, &price.BaseUnit, &price.PricePerUnit); err != nil {
+			return nil, fmt.Errorf("failed to scan alternative: %w", err)
+		}
+		if bestLevel == -1 {
+			bestLevel = level
+		}
+		if level != bestLevel || seen[price.IngredientID] {
+			continue
+		}
+		seen[price.IngredientID] = true
+		alternatives = append(alternatives, price)
+	}
+
+	return alternatives, rows.Err()
+}
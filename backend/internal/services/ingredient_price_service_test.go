@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func TestIngredientPriceService_SetPriceAndCost(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	aggregator := NewIngredientAggregator()
+	service := NewIngredientPriceService(db, aggregator)
+
+	price, err := service.SetPrice("豚肉切り落とし", 198, "100g")
+	require.NoError(t, err)
+	assert.Equal(t, "豚こま肉", price.Ingredient, "aliases resolve to the specific ingredient")
+	assert.Equal(t, "g", price.BaseUnit)
+	assert.InDelta(t, 1.98, price.PricePerUnit, 1e-9)
+
+	price, err = service.SetPrice("卵", 250, "10個")
+	require.NoError(t, err)
+	assert.InDelta(t, 25.0, price.PricePerUnit, 1e-9)
+
+	_, err = service.SetPrice("ドラゴンフルーツ", 500, "1個")
+	assert.ErrorIs(t, err, models.ErrIngredientNotFound)
+	_, err = service.SetPrice("卵", 100, "適量")
+	assert.ErrorIs(t, err, models.ErrInvalidPrice)
+
+	table, err := service.LoadTable()
+	require.NoError(t, err)
+
+	cost, known := table.Cost(aggregator, "豚こま肉", &IngredientQuantity{Amount: 0.5, Unit: "kg"})
+	assert.True(t, known)
+	assert.InDelta(t, 990.0, cost, 1e-6)
+
+	cost, known = table.Cost(aggregator, "たまご", &IngredientQuantity{Amount: 2, Unit: "個"})
+	assert.True(t, known, "aliases are priced too")
+	assert.InDelta(t, 50.0, cost, 1e-9)
+
+	_, known = table.Cost(aggregator, "豚こま肉", &IngredientQuantity{Amount: 1, Unit: "パック"})
+	assert.False(t, known, "units that don't convert to the priced unit are unknown")
+
+	itemCost, estimated := table.ItemCost(aggregator, "謎の食材", &IngredientQuantity{Amount: 1, Unit: "個"})
+	assert.True(t, estimated)
+	assert.Equal(t, models.DefaultItemCost, itemCost)
+
+	recipeCost := table.RecipeCost(aggregator, &models.RecipeData{Ingredients: []models.Ingredient{
+		{Name: "豚こま肉", Amount: "200g"},
+		{Name: "卵", Amount: "2個"},
+		{Name: "塩", Amount: "少々"},
+	}})
+	assert.Equal(t, 396+50, recipeCost)
+}
+
+func TestIngredientPriceService_ImportCSV(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	service := NewIngredientPriceService(db, NewIngredientAggregator())
+
+	result, err := service.ImportCSV(strings.NewReader("ingredient,price,quantity\n" +
+		"キャベツ,150,1個\n" +
+		"しょう油,300,1l\n" +
+		"ドラゴンフルーツ,500,1個\n" +
+		"もやし,abc,1袋\n" +
+		"鶏胸肉\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Imported)
+	require.Len(t, result.Skipped, 3)
+	assert.Equal(t, 4, result.Skipped[0].Line)
+	assert.Equal(t, "ドラゴンフルーツ", result.Skipped[0].Ingredient)
+
+	prices, err := service.ListPrices()
+	require.NoError(t, err)
+	byName := make(map[string]models.IngredientPrice)
+	for _, price := range prices {
+		byName[price.Ingredient] = price
+	}
+	assert.InDelta(t, 150.0, byName["キャベツ"].PricePerUnit, 1e-9)
+	assert.Equal(t, "ml", byName["しょうゆ"].BaseUnit)
+	assert.InDelta(t, 0.3, byName["しょうゆ"].PricePerUnit, 1e-9)
+}
+
+func TestMealPlannerService_CostsFromPriceTable(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	prices := NewIngredientPriceService(db, NewIngredientAggregator())
+	_, err := prices.SetPrice("豚こま肉", 150, "100g")
+	require.NoError(t, err)
+	_, err = prices.SetPrice("キャベツ", 200, "1個")
+	require.NoError(t, err)
+
+	for i := 0; i < len(mealPlanDays); i++ {
+		insertTestRecipe(t, db, models.RecipeData{
+			Title:       "豚キャベツ" + strings.Repeat("!", i),
+			CookingTime: 10,
+			Ingredients: []models.Ingredient{
+				{Name: "豚こま肉", Amount: "100g"},
+				{Name: "キャベツ", Amount: "1/4個"},
+				{Name: "謎のスパイス", Amount: "1個"},
+			},
+			Steps:         []string{"炒める"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		})
+	}
+
+	service := NewMealPlannerService(db, nil)
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate:   "2025-01-27",
+		Preferences: models.MealPlanPreferences{BudgetPerWeek: 1000},
+	})
+	require.NoError(t, err)
+
+	byName := make(map[string]models.ShoppingItem)
+	for _, item := range plan.WeekData.ShoppingList {
+		byName[item.Item] = item
+	}
+	assert.Equal(t, 750, byName["豚こま肉"].Cost)
+	assert.Equal(t, 250, byName["キャベツ"].Cost)
+	assert.False(t, byName["キャベツ"].CostEstimated)
+	assert.Equal(t, models.DefaultItemCost, byName["謎のスパイス"].Cost)
+	assert.True(t, byName["謎のスパイス"].CostEstimated)
+
+	assert.Equal(t, 750+250+models.DefaultItemCost, plan.WeekData.TotalCostEstimate)
+	for _, daily := range plan.WeekData.DailyRecipes {
+		assert.Equal(t, 150+50+models.DefaultItemCost, daily.Cost)
+	}
+
+	require.NotNil(t, plan.WeekData.Budget)
+	assert.False(t, plan.WeekData.Budget.WithinBudget)
+	assert.Equal(t, -200, plan.WeekData.Budget.Remaining)
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"log"
+
+	"lazychef/internal/database"
+)
+
+// IngredientResolver is the single place search terms are turned into
+// ingredient names. The hierarchical ingredient tables are authoritative;
+// the built-in synonym map is only used when the tables are missing or a
+// term is not found in them.
+type IngredientResolver struct {
+	search *IngredientSearchService
+	mapper *SimpleIngredientMapper
+}
+
+// NewIngredientResolver creates a new ingredient resolver
+func NewIngredientResolver(db *database.Database) *IngredientResolver {
+	resolver := &IngredientResolver{
+		mapper: NewSimpleIngredientMapper(),
+	}
+	if db != nil {
+		resolver.search = NewIngredientSearchService(db.DB)
+	}
+	return resolver
+}
+
+// ResolveTerms resolves each search term, reporting which group or
+// ingredient it matched
+func (r *IngredientResolver) ResolveTerms(terms []string) []IngredientTermMatch {
+	matches := make([]IngredientTermMatch, 0, len(terms))
+
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+
+		if r.search != nil {
+			match, err := r.search.ResolveTerm(term)
+			if err != nil {
+				log.Printf("Ingredient hierarchy lookup failed for %q, using synonym map: %v", term, err)
+			} else if match != nil {
+				matches = append(matches, *match)
+				continue
+			}
+		}
+
+		matches = append(matches, r.resolveWithSynonymMap(term))
+	}
+
+	return matches
+}
+
+// resolveWithSynonymMap resolves a term through the built-in synonym table
+func (r *IngredientResolver) resolveWithSynonymMap(term string) IngredientTermMatch {
+	expanded := r.mapper.ExpandIngredientTerms([]string{term})
+	if len(expanded) <= 1 {
+		return IngredientTermMatch{
+			Term:        term,
+			Source:      IngredientMatchSourceNone,
+			Ingredients: []string{term},
+		}
+	}
+
+	match := IngredientTermMatch{
+		Term:        term,
+		Source:      IngredientMatchSourceSynonymMap,
+		Ingredients: expanded,
+	}
+	for _, category := range r.mapper.GetSupportedCategories() {
+		if category == term {
+			match.MatchedGroups = []string{category}
+		}
+	}
+	return match
+}
+
+// IngredientCategory is a top-level ingredient group and its ingredients
+type IngredientCategory struct {
+	Name        string
+	Ingredients []string
+}
+
+// Categories returns the top-level ingredient groups in display order
+func (r *IngredientResolver) Categories() []IngredientCategory {
+	var categories []IngredientCategory
+
+	if r.search != nil {
+		groups, err := r.search.GetAvailableGroups()
+		if err == nil && len(groups) > 0 {
+			for _, group := range groups {
+				if group.Level != 1 {
+					continue
+				}
+				category := IngredientCategory{Name: group.DisplayName, Ingredients: []string{}}
+				if match, err := r.search.ResolveTerm(group.DisplayName); err == nil && match != nil {
+					// The first entry is the group name itself
+					category.Ingredients = match.Ingredients[1:]
+				}
+				categories = append(categories, category)
+			}
+			return categories
+		}
+	}
+
+	for _, name := range r.mapper.GetSupportedCategories() {
+		categories = append(categories, IngredientCategory{
+			Name:        name,
+			Ingredients: r.mapper.GetCategoryIngredients(name),
+		})
+	}
+	return categories
+}
+
+// IngredientNames flattens matches into the distinct names to search for
+func IngredientNames(matches []IngredientTermMatch) []string {
+	var names []string
+	for _, match := range matches {
+		names = append(names, match.Ingredients...)
+	}
+	return removeDuplicates(names)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/database"
+)
+
+func setupHierarchyDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	db := setupSchemaDatabase(t)
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "scripts", "hierarchical_ingredients_schema.sql"))
+	require.NoError(t, err)
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+	return db
+}
+
+func TestIngredientResolver_GroupExpandsThroughHierarchy(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+
+	// A level-3 group mapped only below 鶏肉 must still be reached from 肉類
+	_, err := db.Exec(`
+		INSERT INTO ingredient_groups (name, display_name, parent_id, level, sort_order)
+		VALUES ('chicken_parts', '鶏の部位', (SELECT id FROM ingredient_groups WHERE name = 'chicken'), 3, 1);
+		INSERT INTO specific_ingredients (name, display_name, aliases) VALUES ('鶏ささみ', '鶏ささみ', '["ささみ"]');
+		INSERT INTO ingredient_group_mappings (ingredient_id, group_id, primary_group)
+		VALUES ((SELECT id FROM specific_ingredients WHERE name = '鶏ささみ'),
+		        (SELECT id FROM ingredient_groups WHERE name = 'chicken_parts'), TRUE);
+	`)
+	require.NoError(t, err)
+
+	matches := NewIngredientResolver(db).ResolveTerms([]string{"肉類"})
+	require.Len(t, matches, 1)
+	assert.Equal(t, IngredientMatchSourceGroup, matches[0].Source)
+	assert.Equal(t, []string{"肉類"}, matches[0].MatchedGroups)
+	for _, name := range []string{"牛切り落とし", "豚こま肉", "鶏胸肉", "鶏むね肉", "豚切り落とし", "鶏ささみ", "ささみ"} {
+		assert.Contains(t, matches[0].Ingredients, name)
+	}
+	assert.NotContains(t, matches[0].Ingredients, "キャベツ")
+}
+
+func TestIngredientResolver_AliasResolvesToIngredient(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+
+	matches := NewIngredientResolver(db).ResolveTerms([]string{"鶏むね肉"})
+	require.Len(t, matches, 1)
+	assert.Equal(t, IngredientMatchSourceIngredient, matches[0].Source)
+	assert.Equal(t, "鶏胸肉", matches[0].MatchedIngredient)
+	assert.ElementsMatch(t, []string{"鶏むね肉", "鶏胸肉", "チキンブレスト"}, matches[0].Ingredients)
+}
+
+func TestIngredientResolver_FallsBackToSynonymMap(t *testing.T) {
+	// Without the hierarchy tables the synonym map is used
+	resolver := NewIngredientResolver(setupSchemaDatabase(t))
+
+	matches := resolver.ResolveTerms([]string{"鶏肉", "ドラゴンフルーツ"})
+	require.Len(t, matches, 2)
+	assert.Equal(t, IngredientMatchSourceSynonymMap, matches[0].Source)
+	assert.Contains(t, matches[0].Ingredients, "鶏胸肉")
+	assert.Equal(t, IngredientMatchSourceNone, matches[1].Source)
+	assert.Equal(t, []string{"ドラゴンフルーツ"}, matches[1].Ingredients)
+
+	assert.Contains(t, IngredientNames(matches), "ドラゴンフルーツ")
+}
+
+func TestIngredientResolver_Categories(t *testing.T) {
+	categories := NewIngredientResolver(setupHierarchyDatabase(t)).Categories()
+	require.NotEmpty(t, categories)
+	assert.Equal(t, "肉類", categories[0].Name)
+	assert.Contains(t, categories[0].Ingredients, "豚こま肉")
+	assert.NotContains(t, categories[0].Ingredients, "肉類")
+}
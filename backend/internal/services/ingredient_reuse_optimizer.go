@@ -0,0 +1,399 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"lazychef/internal/models"
+)
+
+// PerishableInfo describes how a perishable ingredient is bought and how long it keeps
+type PerishableInfo struct {
+	PackageGrams  float64 // Typical purchase size in grams (ml treated as grams)
+	PieceGrams    float64 // Weight of one piece (個, 本, 枚...); 0 means one piece is one package
+	ShelfLifeDays int     // Days the ingredient keeps after purchase
+}
+
+// ReuseAnalysis holds the ingredient reuse and waste evaluation of a plan
+type ReuseAnalysis struct {
+	IngredientReuse map[string][]string `json:"ingredient_reuse"` // perishable -> days used (2+ days only)
+	WasteScore      float64             `json:"waste_score"`      // % of purchased perishables expected to spoil
+	PurchasedGrams  float64             `json:"purchased_grams"`
+	WastedGrams     float64             `json:"wasted_grams"`
+	Leftovers       map[string]float64  `json:"leftovers,omitempty"` // perishable -> grams left at week end
+}
+
+// IngredientReuseOptimizer picks recipe sequences that use up perishable ingredients
+type IngredientReuseOptimizer struct {
+	aggregator  *IngredientAggregator
+	perishables map[string]PerishableInfo
+	maxSeeds    int
+}
+
+// NewIngredientReuseOptimizer creates a new ingredient reuse optimizer
+func NewIngredientReuseOptimizer(aggregator *IngredientAggregator) *IngredientReuseOptimizer {
+	return &IngredientReuseOptimizer{
+		aggregator:  aggregator,
+		perishables: initPerishableIngredients(),
+		maxSeeds:    20,
+	}
+}
+
+// initPerishableIngredients returns typical supermarket package sizes and shelf lives
+func initPerishableIngredients() map[string]PerishableInfo {
+	return map[string]PerishableInfo{
+		// 野菜
+		"キャベツ":   {PackageGrams: 1200, PieceGrams: 1200, ShelfLifeDays: 14},
+		"白菜":     {PackageGrams: 500, PieceGrams: 2000, ShelfLifeDays: 10},
+		"レタス":    {PackageGrams: 300, PieceGrams: 300, ShelfLifeDays: 7},
+		"もやし":    {PackageGrams: 200, ShelfLifeDays: 2},
+		"玉ねぎ":    {PackageGrams: 600, PieceGrams: 200, ShelfLifeDays: 30},
+		"にんじん":   {PackageGrams: 450, PieceGrams: 150, ShelfLifeDays: 14},
+		"じゃがいも":  {PackageGrams: 450, PieceGrams: 150, ShelfLifeDays: 30},
+		"長ねぎ":    {PackageGrams: 200, PieceGrams: 100, ShelfLifeDays: 7},
+		"ほうれん草":  {PackageGrams: 200, ShelfLifeDays: 3},
+		"小松菜":    {PackageGrams: 200, ShelfLifeDays: 3},
+		"ブロッコリー": {PackageGrams: 250, PieceGrams: 250, ShelfLifeDays: 4},
+		"トマト":    {PackageGrams: 450, PieceGrams: 150, ShelfLifeDays: 5},
+		"きゅうり":   {PackageGrams: 300, PieceGrams: 100, ShelfLifeDays: 5},
+		"なす":     {PackageGrams: 300, PieceGrams: 100, ShelfLifeDays: 5},
+		"ピーマン":   {PackageGrams: 150, PieceGrams: 30, ShelfLifeDays: 7},
+		"大根":     {PackageGrams: 500, PieceGrams: 1000, ShelfLifeDays: 7},
+		"しめじ":    {PackageGrams: 100, ShelfLifeDays: 5},
+
+		// 肉・魚
+		"鶏もも肉": {PackageGrams: 300, PieceGrams: 300, ShelfLifeDays: 2},
+		"鶏むね肉": {PackageGrams: 300, PieceGrams: 300, ShelfLifeDays: 2},
+		"豚こま肉": {PackageGrams: 300, ShelfLifeDays: 3},
+		"豚バラ肉": {PackageGrams: 200, ShelfLifeDays: 3},
+		"ひき肉":  {PackageGrams: 250, ShelfLifeDays: 2},
+		"鮭":    {PackageGrams: 160, PieceGrams: 80, ShelfLifeDays: 2},
+
+		// 乳製品・卵・大豆製品
+		"牛乳": {PackageGrams: 1000, ShelfLifeDays: 7},
+		"卵":  {PackageGrams: 600, PieceGrams: 60, ShelfLifeDays: 14},
+		"豆腐": {PackageGrams: 300, PieceGrams: 300, ShelfLifeDays: 5},
+		"納豆": {PackageGrams: 150, PieceGrams: 50, ShelfLifeDays: 7},
+	}
+}
+
+// reuseStock tracks an opened package of a perishable ingredient
+type reuseStock struct {
+	remaining   float64
+	purchaseDay int
+}
+
+// reuseState simulates the fridge while a plan is built day by day
+type reuseState struct {
+	stock         map[string]*reuseStock
+	usage         map[string][]string
+	purchased     float64
+	wasted        float64
+	householdSize int // Recipes are scaled to feed this many; 0 keeps them as written
+}
+
+func newReuseState(householdSize int) *reuseState {
+	return &reuseState{
+		stock:         make(map[string]*reuseStock),
+		usage:         make(map[string][]string),
+		householdSize: householdSize,
+	}
+}
+
+// SelectRecipes picks count recipes from the ranked candidates so that perishables
+// bought for one day are used up on later days. Candidates keep their ranking as a tie-breaker.
+// Quantities are scaled to the household size unless it is 0.
+func (o *IngredientReuseOptimizer) SelectRecipes(candidates []*models.Recipe, days []string, householdSize int) []*models.Recipe {
+	selected, _ := o.selectRecipes(uniqueRecipesByTitle(candidates), days, householdSize, nil)
+	return selected
+}
+
+// SelectRecipesWithinBudget picks recipes like SelectRecipes, considering only
+// combinations whose total cost fits the budget. costs[i] is the cost of
+// candidates[i]. When no combination fits it returns the cheapest one and
+// fits is false.
+func (o *IngredientReuseOptimizer) SelectRecipesWithinBudget(candidates []*models.Recipe, costs []int, days []string, householdSize, budget int) (selected []*models.Recipe, fits bool) {
+	unique := make([]*models.Recipe, 0, len(candidates))
+	uniqueCosts := make([]int, 0, len(candidates))
+	seen := make(map[string]bool)
+	for i, candidate := range candidates {
+		if seen[candidate.Data.Title] {
+			continue
+		}
+		seen[candidate.Data.Title] = true
+		unique = append(unique, candidate)
+		uniqueCosts = append(uniqueCosts, costs[i])
+	}
+	return o.selectRecipes(unique, days, householdSize, newRecipeBudget(uniqueCosts, budget))
+}
+
+// selectRecipes runs the reuse search over candidates unique by title,
+// keeping every partial plan completable within budget
+func (o *IngredientReuseOptimizer) selectRecipes(unique []*models.Recipe, days []string, householdSize int, budget *recipeBudget) ([]*models.Recipe, bool) {
+	count := len(days)
+	if len(unique) <= count {
+		spent := 0
+		for i := range unique {
+			spent += budget.cost(i)
+		}
+		return unique, budget == nil || spent <= budget.limit
+	}
+
+	var best []*models.Recipe
+	bestWaste := math.MaxFloat64
+	bestLaziness := 0.0
+
+	// Multi-start greedy search: every seed fixes day one, then each following day
+	// takes the candidate that reuses the most open stock for the least new waste
+	seeds := 0
+	for seed := 0; seed < len(unique) && seeds < o.maxSeeds; seed++ {
+		if !budget.allows(seed, 0, count-1, nil) {
+			continue
+		}
+		seeds++
+
+		plan := []*models.Recipe{unique[seed]}
+		used := map[int]bool{seed: true}
+		spent := budget.cost(seed)
+		state := newReuseState(householdSize)
+		o.applyRecipe(state, unique[seed].Data, 0, days[0])
+
+		for day := 1; day < count; day++ {
+			bestIdx := -1
+			bestScore := math.Inf(-1)
+			for idx, candidate := range unique {
+				if used[idx] || !budget.allows(idx, spent, count-day-1, used) {
+					continue
+				}
+				score := o.scoreRecipe(state, candidate.Data, day) - float64(idx)*0.001
+				if score > bestScore {
+					bestScore = score
+					bestIdx = idx
+				}
+			}
+			used[bestIdx] = true
+			spent += budget.cost(bestIdx)
+			plan = append(plan, unique[bestIdx])
+			o.applyRecipe(state, unique[bestIdx].Data, day, days[day])
+		}
+
+		waste := o.finish(state, count).WasteScore
+		laziness := averageLaziness(plan)
+		if waste < bestWaste || (waste == bestWaste && laziness > bestLaziness) {
+			best = plan
+			bestWaste = waste
+			bestLaziness = laziness
+		}
+	}
+
+	if best == nil {
+		// No day-one recipe leaves room in the budget for the rest of the week
+		return budget.cheapest(unique, count), false
+	}
+	return best, true
+}
+
+// Analyze evaluates ingredient reuse and waste for recipes assigned to days in
+// order, with quantities scaled to the household size unless it is 0
+func (o *IngredientReuseOptimizer) Analyze(recipes []models.RecipeData, days []string, householdSize int) *ReuseAnalysis {
+	state := newReuseState(householdSize)
+	for i, recipe := range recipes {
+		if i >= len(days) {
+			break
+		}
+		o.applyRecipe(state, recipe, i, days[i])
+	}
+	return o.finish(state, len(days))
+}
+
+// LeftoverIngredients lists perishables with opened stock after cooking the recipes,
+// largest leftover first. Useful as generation seeds so leftovers get used up.
+func (o *IngredientReuseOptimizer) LeftoverIngredients(recipes []models.RecipeData, householdSize int) []string {
+	state := newReuseState(householdSize)
+	for i, recipe := range recipes {
+		o.applyRecipe(state, recipe, i, "")
+	}
+
+	names := make([]string, 0, len(state.stock))
+	for name, stock := range state.stock {
+		if stock.remaining > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := state.stock[names[i]].remaining, state.stock[names[j]].remaining
+		if ri != rj {
+			return ri > rj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// scoreRecipe rates how well a recipe fits the current fridge state on a given day
+func (o *IngredientReuseOptimizer) scoreRecipe(state *reuseState, recipe models.RecipeData, day int) float64 {
+	reuseGain := 0.0
+	newLeftover := 0.0
+
+	for _, ingredient := range recipe.Ingredients {
+		name, info, ok := o.lookupPerishable(ingredient.Name)
+		if !ok {
+			continue
+		}
+		grams := o.gramsFor(ingredient.Amount, info) * householdScale(recipe, state.householdSize)
+
+		if stock, exists := state.stock[name]; exists && stock.remaining > 0 && day-stock.purchaseDay < info.ShelfLifeDays {
+			fromStock := math.Min(grams, stock.remaining)
+			reuseGain += fromStock / info.PackageGrams
+			grams -= fromStock
+		}
+		if grams > 0 {
+			packages := math.Ceil(grams / info.PackageGrams)
+			newLeftover += (packages*info.PackageGrams - grams) / info.PackageGrams
+		}
+	}
+
+	return reuseGain*2 - newLeftover + recipe.LazinessScore/20
+}
+
+// applyRecipe consumes perishables for a recipe cooked on the given day
+func (o *IngredientReuseOptimizer) applyRecipe(state *reuseState, recipe models.RecipeData, day int, dayName string) {
+	for _, ingredient := range recipe.Ingredients {
+		name, info, ok := o.lookupPerishable(ingredient.Name)
+		if !ok {
+			continue
+		}
+		grams := o.gramsFor(ingredient.Amount, info) * householdScale(recipe, state.householdSize)
+
+		stock, exists := state.stock[name]
+		if exists && day-stock.purchaseDay >= info.ShelfLifeDays {
+			// Spoiled before it could be used
+			state.wasted += stock.remaining
+			stock.remaining = 0
+		}
+		if !exists {
+			stock = &reuseStock{purchaseDay: day}
+			state.stock[name] = stock
+		}
+
+		if grams > stock.remaining {
+			needed := grams - stock.remaining
+			packages := math.Ceil(needed / info.PackageGrams)
+			stock.remaining += packages * info.PackageGrams
+			state.purchased += packages * info.PackageGrams
+			stock.purchaseDay = day
+		}
+		stock.remaining -= grams
+
+		if dayName != "" {
+			days := state.usage[name]
+			if len(days) == 0 || days[len(days)-1] != dayName {
+				state.usage[name] = append(days, dayName)
+			}
+		}
+	}
+}
+
+// finish counts leftovers at the end of the plan as waste and builds the analysis
+func (o *IngredientReuseOptimizer) finish(state *reuseState, planDays int) *ReuseAnalysis {
+	wasted := state.wasted
+	leftovers := make(map[string]float64)
+	for name, stock := range state.stock {
+		if stock.remaining <= 0 {
+			continue
+		}
+		leftovers[name] = stock.remaining
+		// Stock that will still be fresh next week is not counted as waste
+		if stock.purchaseDay+o.perishables[name].ShelfLifeDays <= planDays+7 {
+			wasted += stock.remaining
+		}
+	}
+
+	reuse := make(map[string][]string)
+	for name, days := range state.usage {
+		if len(days) >= 2 {
+			reuse[name] = days
+		}
+	}
+
+	wasteScore := 0.0
+	if state.purchased > 0 {
+		wasteScore = math.Round(wasted/state.purchased*1000) / 10
+	}
+
+	return &ReuseAnalysis{
+		IngredientReuse: reuse,
+		WasteScore:      wasteScore,
+		PurchasedGrams:  state.purchased,
+		WastedGrams:     wasted,
+		Leftovers:       leftovers,
+	}
+}
+
+// lookupPerishable finds the perishable entry whose name is the longest match for the ingredient
+func (o *IngredientReuseOptimizer) lookupPerishable(ingredient string) (string, PerishableInfo, bool) {
+	bestName := ""
+	for name := range o.perishables {
+		if strings.Contains(ingredient, name) && len(name) > len(bestName) {
+			bestName = name
+		}
+	}
+	if bestName == "" {
+		return "", PerishableInfo{}, false
+	}
+	return bestName, o.perishables[bestName], true
+}
+
+// gramsFor converts a recipe amount into grams for a perishable ingredient
+func (o *IngredientReuseOptimizer) gramsFor(amount string, info PerishableInfo) float64 {
+	qty, err := o.aggregator.ParseQuantity(amount)
+	if err != nil || qty.Unit == "適量" {
+		// Small garnish amounts still open a package
+		return info.PackageGrams * 0.1
+	}
+
+	base, err := o.aggregator.ConvertToBaseUnit(qty)
+	if err != nil {
+		return info.PackageGrams * 0.1
+	}
+
+	switch o.aggregator.GetUnitType(base.Unit) {
+	case "weight", "volume":
+		return base.Amount
+	case "count":
+		if info.PieceGrams > 0 {
+			return base.Amount * info.PieceGrams
+		}
+		return base.Amount * info.PackageGrams
+	default:
+		// 丁, 玉, 株, 束 and similar units are treated as whole packages
+		return base.Amount * info.PackageGrams
+	}
+}
+
+// uniqueRecipesByTitle drops later recipes that repeat an earlier title
+func uniqueRecipesByTitle(recipes []*models.Recipe) []*models.Recipe {
+	seen := make(map[string]bool)
+	unique := make([]*models.Recipe, 0, len(recipes))
+	for _, recipe := range recipes {
+		if seen[recipe.Data.Title] {
+			continue
+		}
+		seen[recipe.Data.Title] = true
+		unique = append(unique, recipe)
+	}
+	return unique
+}
+
+// averageLaziness returns the mean laziness score of the recipes
+func averageLaziness(recipes []*models.Recipe) float64 {
+	if len(recipes) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, recipe := range recipes {
+		total += recipe.Data.LazinessScore
+	}
+	return total / float64(len(recipes))
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func reuseTestRecipe(id int, title string, laziness float64, ingredients ...models.Ingredient) *models.Recipe {
+	return &models.Recipe{
+		ID: id,
+		Data: models.RecipeData{
+			Title:         title,
+			CookingTime:   10,
+			Ingredients:   ingredients,
+			Steps:         []string{"作る"},
+			Season:        "all",
+			LazinessScore: laziness,
+		},
+	}
+}
+
+func TestIngredientReuseOptimizer_Analyze(t *testing.T) {
+	optimizer := NewIngredientReuseOptimizer(NewIngredientAggregator())
+	days := []string{"monday", "tuesday", "wednesday", "thursday"}
+
+	recipes := []models.RecipeData{
+		{Title: "キャベツ炒め", Ingredients: []models.Ingredient{{Name: "キャベツ", Amount: "1/2個"}}},
+		{Title: "冷奴", Ingredients: []models.Ingredient{{Name: "豆腐", Amount: "1丁"}}},
+		{Title: "卵かけご飯", Ingredients: []models.Ingredient{{Name: "卵", Amount: "1個"}, {Name: "醤油", Amount: "小さじ1"}}},
+		{Title: "コールスロー", Ingredients: []models.Ingredient{{Name: "キャベツ", Amount: "1/2個"}}},
+	}
+
+	analysis := optimizer.Analyze(recipes, days, 0)
+
+	assert.Equal(t, []string{"monday", "thursday"}, analysis.IngredientReuse["キャベツ"])
+	assert.NotContains(t, analysis.IngredientReuse, "豆腐")
+	assert.NotContains(t, analysis.Leftovers, "キャベツ")
+	// Leftover eggs keep into next week, so nothing is wasted
+	assert.InDelta(t, 540, analysis.Leftovers["卵"], 0.01)
+	assert.Equal(t, 0.0, analysis.WasteScore)
+}
+
+func TestIngredientReuseOptimizer_Analyze_SpoiledStock(t *testing.T) {
+	optimizer := NewIngredientReuseOptimizer(NewIngredientAggregator())
+	days := []string{"monday", "tuesday", "wednesday", "thursday"}
+
+	// Bean sprouts keep for two days, so Thursday needs a new bag
+	recipes := []models.RecipeData{
+		{Title: "もやし炒め", Ingredients: []models.Ingredient{{Name: "もやし", Amount: "100g"}}},
+		{Title: "冷奴", Ingredients: []models.Ingredient{{Name: "豆腐", Amount: "1丁"}}},
+		{Title: "冷奴2", Ingredients: []models.Ingredient{{Name: "豆腐", Amount: "1丁"}}},
+		{Title: "もやしナムル", Ingredients: []models.Ingredient{{Name: "もやし", Amount: "100g"}}},
+	}
+
+	analysis := optimizer.Analyze(recipes, days, 0)
+
+	assert.InDelta(t, 200, analysis.WastedGrams, 0.01)
+	assert.InDelta(t, 1000, analysis.PurchasedGrams, 0.01)
+	assert.Equal(t, 20.0, analysis.WasteScore)
+}
+
+func TestIngredientReuseOptimizer_SelectRecipes(t *testing.T) {
+	optimizer := NewIngredientReuseOptimizer(NewIngredientAggregator())
+	days := []string{"monday", "tuesday", "wednesday"}
+
+	candidates := []*models.Recipe{
+		reuseTestRecipe(1, "キャベツ炒め", 9.0, models.Ingredient{Name: "キャベツ", Amount: "1/3個"}),
+		reuseTestRecipe(2, "ブロッコリーサラダ", 8.5, models.Ingredient{Name: "ブロッコリー", Amount: "1/2個"}),
+		reuseTestRecipe(3, "ほうれん草のおひたし", 8.5, models.Ingredient{Name: "ほうれん草", Amount: "100g"}),
+		reuseTestRecipe(4, "コールスロー", 7.0, models.Ingredient{Name: "キャベツ", Amount: "1/3個"}),
+		reuseTestRecipe(5, "キャベツの味噌汁", 6.0, models.Ingredient{Name: "キャベツ", Amount: "1/3個"}),
+	}
+
+	selected := optimizer.SelectRecipes(candidates, days, 0)
+	require.Len(t, selected, len(days))
+
+	titles := make([]string, 0, len(selected))
+	for _, recipe := range selected {
+		titles = append(titles, recipe.Data.Title)
+	}
+	assert.ElementsMatch(t, []string{"キャベツ炒め", "コールスロー", "キャベツの味噌汁"}, titles)
+
+	analysis := optimizer.Analyze(recipeDataOf(selected), days, 0)
+	assert.Equal(t, 0.0, analysis.WasteScore)
+	assert.Len(t, analysis.IngredientReuse["キャベツ"], 3)
+}
+
+func TestIngredientReuseOptimizer_LeftoverIngredients(t *testing.T) {
+	optimizer := NewIngredientReuseOptimizer(NewIngredientAggregator())
+
+	leftovers := optimizer.LeftoverIngredients([]models.RecipeData{
+		{Title: "キャベツ炒め", Ingredients: []models.Ingredient{{Name: "キャベツ", Amount: "1/4個"}, {Name: "醤油", Amount: "大さじ1"}}},
+		{Title: "冷奴", Ingredients: []models.Ingredient{{Name: "豆腐", Amount: "1丁"}}},
+	}, 0)
+
+	assert.Equal(t, []string{"キャベツ"}, leftovers)
+}
+
+func TestIngredientReuseOptimizer_AnalyzeScalesToHousehold(t *testing.T) {
+	optimizer := NewIngredientReuseOptimizer(NewIngredientAggregator())
+	recipes := []models.RecipeData{{
+		Title:       "もやし炒め",
+		ServingSize: models.FlexibleInt(1),
+		Ingredients: []models.Ingredient{{Name: "もやし", Amount: "100g"}},
+	}}
+
+	// One 200g bag feeds one person with 100g spare, but four people need two bags
+	assert.InDelta(t, 200, optimizer.Analyze(recipes, []string{"monday"}, 0).PurchasedGrams, 0.01)
+	assert.InDelta(t, 400, optimizer.Analyze(recipes, []string{"monday"}, 4).PurchasedGrams, 0.01)
+	assert.Equal(t, 0.0, optimizer.Analyze(recipes, []string{"monday"}, 2).WasteScore)
+}
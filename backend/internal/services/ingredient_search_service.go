@@ -2,6 +2,7 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -16,76 +17,77 @@ func NewIngredientSearchService(db *sql.DB) *IngredientSearchService {
 	return &IngredientSearchService{db: db}
 }
 
-// IngredientSearchResult represents the result of ingredient search
-type IngredientSearchResult struct {
-	SpecificIngredients []string `json:"specific_ingredients"`
-	MatchedGroups       []string `json:"matched_groups"`
+// Sources of an ingredient term match
+const (
+	IngredientMatchSourceIngredient = "ingredient"  // Specific ingredient name or alias
+	IngredientMatchSourceGroup      = "group"       // Ingredient group, expanded through the hierarchy
+	IngredientMatchSourceSynonymMap = "synonym_map" // Built-in synonym table fallback
+	IngredientMatchSourceNone       = "none"        // Unresolved, matched literally
+)
+
+// IngredientTermMatch describes how a single search term was resolved
+type IngredientTermMatch struct {
+	Term              string   `json:"term"`
+	Source            string   `json:"source"`
+	MatchedGroups     []string `json:"matched_groups,omitempty"`
+	MatchedIngredient string   `json:"matched_ingredient,omitempty"`
+	Ingredients       []string `json:"ingredients"` // Names and aliases to match in recipes
 }
 
-// SearchIngredientsByGroup searches for specific ingredients by group name or display name
-// This function handles the hierarchical ingredient classification system
-func (s *IngredientSearchService) SearchIngredientsByGroup(searchTerms []string) (*IngredientSearchResult, error) {
-	if len(searchTerms) == 0 {
-		return &IngredientSearchResult{
-			SpecificIngredients: []string{},
-			MatchedGroups:       []string{},
-		}, nil
+// ResolveTerm resolves a search term against the ingredient hierarchy. A
+// specific ingredient match expands to its name and aliases; a group match
+// expands to every ingredient in the group and its descendant groups.
+// Returns nil when the term matches nothing.
+func (s *IngredientSearchService) ResolveTerm(term string) (*IngredientTermMatch, error) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return nil, nil
 	}
 
-	var allSpecificIngredients []string
-	var matchedGroups []string
-
-	for _, term := range searchTerms {
-		// Clean the search term
-		term = strings.TrimSpace(term)
-		if term == "" {
-			continue
-		}
-
-		// First, try exact match in specific ingredients
-		specificIngredients, err := s.findSpecificIngredients(term)
-		if err != nil {
-			return nil, fmt.Errorf("specific ingredient search failed: %w", err)
-		}
-
-		// If found specific ingredients, add them directly
-		if len(specificIngredients) > 0 {
-			allSpecificIngredients = append(allSpecificIngredients, specificIngredients...)
-			continue
-		}
-
-		// If not found in specific ingredients, search in groups
-		groupIngredients, groups, err := s.findIngredientsByGroup(term)
-		if err != nil {
-			return nil, fmt.Errorf("group ingredient search failed: %w", err)
-		}
-
-		allSpecificIngredients = append(allSpecificIngredients, groupIngredients...)
-		matchedGroups = append(matchedGroups, groups...)
+	// First, try exact match in specific ingredients
+	names, err := s.findSpecificIngredients(term)
+	if err != nil {
+		return nil, fmt.Errorf("specific ingredient search failed: %w", err)
+	}
+	if len(names) > 0 {
+		return &IngredientTermMatch{
+			Term:              term,
+			Source:            IngredientMatchSourceIngredient,
+			MatchedIngredient: names[0],
+			Ingredients:       removeDuplicates(append([]string{term}, names...)),
+		}, nil
 	}
 
-	// Remove duplicates
-	allSpecificIngredients = removeDuplicates(allSpecificIngredients)
-	matchedGroups = removeDuplicates(matchedGroups)
+	// If not found in specific ingredients, search in groups
+	names, groups, err := s.findIngredientsByGroup(term)
+	if err != nil {
+		return nil, fmt.Errorf("group ingredient search failed: %w", err)
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
 
-	return &IngredientSearchResult{
-		SpecificIngredients: allSpecificIngredients,
-		MatchedGroups:       matchedGroups,
+	return &IngredientTermMatch{
+		Term:          term,
+		Source:        IngredientMatchSourceGroup,
+		MatchedGroups: groups,
+		Ingredients:   removeDuplicates(append([]string{term}, names...)),
 	}, nil
 }
 
-// findSpecificIngredients searches for ingredients by exact name or aliases
+// findSpecificIngredients searches for ingredients by exact name, display
+// name or alias, returning each match's name followed by its aliases
 func (s *IngredientSearchService) findSpecificIngredients(searchTerm string) ([]string, error) {
 	query := `
-		SELECT DISTINCT name 
-		FROM specific_ingredients 
-		WHERE name = ? 
-		   OR display_name = ?
-		   OR (aliases IS NOT NULL AND json_extract(aliases, '$') LIKE ?)
+		SELECT si.name, si.aliases
+		FROM specific_ingredients si
+		WHERE si.name = ?
+		   OR si.display_name = ?
+		   OR EXISTS (SELECT 1 FROM json_each(si.aliases) WHERE value = ?)
+		ORDER BY si.name
 	`
 
-	aliasPattern := fmt.Sprintf(`%%"%s"%%`, searchTerm)
-	rows, err := s.db.Query(query, searchTerm, searchTerm, aliasPattern)
+	rows, err := s.db.Query(query, searchTerm, searchTerm, searchTerm)
 	if err != nil {
 		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
@@ -99,35 +101,61 @@ func (s *IngredientSearchService) findSpecificIngredients(searchTerm string) ([]
 	var ingredients []string
 	for rows.Next() {
 		var ingredient string
-		if err := rows.Scan(&ingredient); err != nil {
+		var aliases sql.NullString
+		if err := rows.Scan(&ingredient, &aliases); err != nil {
 			return nil, fmt.Errorf("row scan failed: %w", err)
 		}
-		ingredients = append(ingredients, ingredient)
+		ingredients = appendAliases(append(ingredients, ingredient), aliases)
 	}
 
-	return ingredients, nil
+	return ingredients, rows.Err()
 }
 
-// findIngredientsByGroup searches for ingredients by group name (hierarchical)
+// findIngredientsByGroup searches for ingredients by group name (hierarchical).
+// Groups matching the term exactly win over partial matches, and each matched
+// group expands through its descendant groups.
 func (s *IngredientSearchService) findIngredientsByGroup(searchTerm string) ([]string, []string, error) {
-	// Search in ingredient groups (both name and display_name)
-	query := `
-		SELECT DISTINCT si.name, ig.display_name
-		FROM specific_ingredients si
-		JOIN ingredient_group_mappings igm ON si.id = igm.ingredient_id
-		JOIN ingredient_groups ig ON igm.group_id = ig.id
-		WHERE ig.name = ? 
-		   OR ig.display_name = ?
-		   OR ig.name LIKE ?
-		   OR ig.display_name LIKE ?
-		ORDER BY si.name
-	`
+	for _, where := range []string{
+		"name = ? OR display_name = ?",
+		"name LIKE ? OR display_name LIKE ?",
+	} {
+		arg := searchTerm
+		if strings.Contains(where, "LIKE") {
+			arg = "%" + searchTerm + "%"
+		}
 
-	likePattern := "%" + searchTerm + "%"
-	rows, err := s.db.Query(query, searchTerm, searchTerm, likePattern, likePattern)
-	if err != nil {
-		return nil, nil, fmt.Errorf("group query execution failed: %w", err)
+		rows, err := s.db.Query(`
+			WITH RECURSIVE matched(id, root) AS (
+				SELECT id, id FROM ingredient_groups WHERE `+where+`
+				UNION
+				SELECT g.id, m.root FROM ingredient_groups g JOIN matched m ON g.parent_id = m.id
+			)
+			SELECT ig.display_name, si.name, si.aliases
+			FROM ingredient_groups ig
+			JOIN matched m ON m.root = ig.id
+			LEFT JOIN ingredient_group_mappings igm ON igm.group_id = m.id
+			LEFT JOIN specific_ingredients si ON si.id = igm.ingredient_id
+			ORDER BY ig.level, ig.sort_order, si.name
+		`, arg, arg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("group query execution failed: %w", err)
+		}
+
+		ingredients, groups, err := scanGroupIngredients(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(groups) > 0 {
+			return removeDuplicates(ingredients), groups, nil
+		}
 	}
+
+	return nil, nil, nil
+}
+
+// scanGroupIngredients reads (group, name, aliases) rows into the ingredient
+// names and aliases and the distinct group names
+func scanGroupIngredients(rows *sql.Rows) ([]string, []string, error) {
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil {
 			// Log error but don't fail the function
@@ -140,18 +168,34 @@ func (s *IngredientSearchService) findIngredientsByGroup(searchTerm string) ([]s
 	groupSet := make(map[string]bool) // To avoid duplicate groups
 
 	for rows.Next() {
-		var ingredient, groupName string
-		if err := rows.Scan(&ingredient, &groupName); err != nil {
+		var groupName string
+		var ingredient, aliases sql.NullString
+		if err := rows.Scan(&groupName, &ingredient, &aliases); err != nil {
 			return nil, nil, fmt.Errorf("group row scan failed: %w", err)
 		}
-		ingredients = append(ingredients, ingredient)
 		if !groupSet[groupName] {
 			groups = append(groups, groupName)
 			groupSet[groupName] = true
 		}
+		if !ingredient.Valid {
+			continue
+		}
+		ingredients = appendAliases(append(ingredients, ingredient.String), aliases)
 	}
 
-	return ingredients, groups, nil
+	return ingredients, groups, rows.Err()
+}
+
+// appendAliases appends the names in a JSON alias array, ignoring malformed ones
+func appendAliases(names []string, aliases sql.NullString) []string {
+	if !aliases.Valid || aliases.String == "" {
+		return names
+	}
+	var aliasList []string
+	if err := json.Unmarshal([]byte(aliases.String), &aliasList); err != nil {
+		return names
+	}
+	return append(names, aliasList...)
 }
 
 // GetAvailableGroups returns all ingredient groups for UI dropdown
@@ -245,43 +289,6 @@ func (s *IngredientSearchService) GetHierarchicalGroups() (map[string]interface{
 	return hierarchy, nil
 }
 
-// BuildIngredientSearchCondition builds SQL condition for ingredient search
-// This replaces the simple LIKE search with hierarchical search
-func (s *IngredientSearchService) BuildIngredientSearchCondition(ingredients []string) (string, []interface{}, error) {
-	if len(ingredients) == 0 {
-		return "", []interface{}{}, nil
-	}
-
-	// Get all specific ingredients that match the search terms
-	searchResult, err := s.SearchIngredientsByGroup(ingredients)
-	if err != nil {
-		return "", nil, fmt.Errorf("ingredient search failed: %w", err)
-	}
-
-	// Combine original search terms with found specific ingredients
-	allIngredients := append(ingredients, searchResult.SpecificIngredients...)
-	allIngredients = removeDuplicates(allIngredients)
-
-	if len(allIngredients) == 0 {
-		return "", []interface{}{}, nil
-	}
-
-	// Build SQL condition
-	var conditions []string
-	var args []interface{}
-
-	for _, ingredient := range allIngredients {
-		conditions = append(conditions, `EXISTS (
-			SELECT 1 FROM json_each(json_extract(data, '$.ingredients'))
-			WHERE json_extract(value, '$.name') = ?
-		)`)
-		args = append(args, ingredient)
-	}
-
-	sqlCondition := " AND (" + strings.Join(conditions, " OR ") + ")"
-	return sqlCondition, args, nil
-}
-
 // Helper function to remove duplicates from string slice
 func removeDuplicates(slice []string) []string {
 	keys := make(map[string]bool)
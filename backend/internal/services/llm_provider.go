@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/sashabaranov/go-openai"
+
+	"lazychef/internal/config"
+)
+
+// LLMProvider abstracts the language model backend behind recipe generation,
+// embeddings and batch jobs. Requests and responses use the OpenAI API shapes
+// so every backend can be swapped in without touching prompt or parsing code.
+type LLMProvider interface {
+	// Name identifies the backend ("openai", "openai-compatible", "local")
+	Name() string
+
+	// SupportsStructuredOutputs reports whether JSON-schema response formats are honoured
+	SupportsStructuredOutputs() bool
+
+	// Chat completion (structured output is requested through ResponseFormat)
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+
+	// Embeddings
+	CreateEmbeddings(ctx context.Context, req openai.EmbeddingRequest) (openai.EmbeddingResponse, error)
+
+	// Batch API
+	CreateFile(ctx context.Context, req openai.FileRequest) (openai.File, error)
+	GetFileContent(ctx context.Context, fileID string) (io.ReadCloser, error)
+	CreateBatch(ctx context.Context, req openai.CreateBatchRequest) (openai.Batch, error)
+	RetrieveBatch(ctx context.Context, batchID string) (openai.Batch, error)
+	CancelBatch(ctx context.Context, batchID string) (openai.Batch, error)
+}
+
+// NewLLMProvider creates the provider selected by the configuration
+func NewLLMProvider(cfg *config.OpenAIConfig) (LLMProvider, error) {
+	switch cfg.Provider {
+	case "", config.ProviderOpenAI:
+		if cfg.BaseURL == "" {
+			return NewOpenAIProvider(openai.NewClient(cfg.APIKey)), nil
+		}
+		clientConfig := openai.DefaultConfig(cfg.APIKey)
+		clientConfig.BaseURL = cfg.BaseURL
+		return NewOpenAIProvider(openai.NewClientWithConfig(clientConfig)), nil
+	case config.ProviderOpenAICompatible:
+		clientConfig := openai.DefaultConfig(cfg.APIKey)
+		clientConfig.BaseURL = cfg.BaseURL
+		return NewOpenAICompatibleProvider(openai.NewClientWithConfig(clientConfig), cfg.ProviderSupportsStructuredOutputs), nil
+	case config.ProviderLocal:
+		return NewLocalProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.Provider)
+	}
+}
+
+// OpenAIProvider sends requests to the OpenAI API or any server speaking the
+// same protocol (Ollama, llama.cpp server, vLLM)
+type OpenAIProvider struct {
+	client            *openai.Client
+	name              string
+	structuredOutputs bool
+}
+
+// NewOpenAIProvider wraps an OpenAI client as an LLMProvider
+func NewOpenAIProvider(client *openai.Client) *OpenAIProvider {
+	return &OpenAIProvider{client: client, name: config.ProviderOpenAI, structuredOutputs: true}
+}
+
+// NewOpenAICompatibleProvider wraps a client pointed at a self-hosted
+// OpenAI-compatible server
+func NewOpenAICompatibleProvider(client *openai.Client, structuredOutputs bool) *OpenAIProvider {
+	return &OpenAIProvider{client: client, name: config.ProviderOpenAICompatible, structuredOutputs: structuredOutputs}
+}
+
+// Name returns the provider name
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+// SupportsStructuredOutputs reports JSON-schema support
+func (p *OpenAIProvider) SupportsStructuredOutputs() bool {
+	return p.structuredOutputs
+}
+
+// CreateChatCompletion calls the chat completions endpoint
+func (p *OpenAIProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	return p.client.CreateChatCompletion(ctx, req)
+}
+
+// CreateEmbeddings calls the embeddings endpoint
+func (p *OpenAIProvider) CreateEmbeddings(ctx context.Context, req openai.EmbeddingRequest) (openai.EmbeddingResponse, error) {
+	return p.client.CreateEmbeddings(ctx, req)
+}
+
+// CreateFile uploads a file
+func (p *OpenAIProvider) CreateFile(ctx context.Context, req openai.FileRequest) (openai.File, error) {
+	return p.client.CreateFile(ctx, req)
+}
+
+// GetFileContent downloads a file
+func (p *OpenAIProvider) GetFileContent(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	content, err := p.client.GetFileContent(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// CreateBatch creates a batch job
+func (p *OpenAIProvider) CreateBatch(ctx context.Context, req openai.CreateBatchRequest) (openai.Batch, error) {
+	resp, err := p.client.CreateBatch(ctx, req)
+	return resp.Batch, err
+}
+
+// RetrieveBatch fetches a batch job
+func (p *OpenAIProvider) RetrieveBatch(ctx context.Context, batchID string) (openai.Batch, error) {
+	resp, err := p.client.RetrieveBatch(ctx, batchID)
+	return resp.Batch, err
+}
+
+// CancelBatch cancels a batch job
+func (p *OpenAIProvider) CancelBatch(ctx context.Context, batchID string) (openai.Batch, error) {
+	resp, err := p.client.CancelBatch(ctx, batchID)
+	return resp.Batch, err
+}
@@ -0,0 +1,561 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+
+	"lazychef/internal/config"
+	"lazychef/internal/models"
+)
+
+// localEmbeddingDimensions is the vector size produced by the local provider
+const localEmbeddingDimensions = 256
+
+// LocalProvider is a deterministic, offline LLMProvider. It fills recipe
+// templates from the ingredients, season and time limits found in the prompt,
+// answers safety remediation prompts with mechanically corrected recipes,
+// derives embeddings from character bigrams and completes batch jobs
+// immediately, so the full stack runs without an API key.
+type LocalProvider struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	batches map[string]openai.Batch
+	nextID  int
+}
+
+// localRecipeTemplate is a canned cooking method the local provider fills in
+type localRecipeTemplate struct {
+	suffix string
+	tags   []string
+	steps  []string // %s is replaced with the joined ingredient names
+}
+
+var localRecipeTemplates = []localRecipeTemplate{
+	{
+		suffix: "のレンジ蒸し",
+		tags:   []string{"簡単", "ずぼら", "一品"},
+		steps: []string{
+			"耐熱皿に%sを入れ、塩こしょうをふる",
+			"ふんわりラップをして電子レンジ(600W)で中まで火が通るまで5分加熱する",
+			"全体を混ぜて器に盛る",
+		},
+	},
+	{
+		suffix: "のワンパン炒め",
+		tags:   []string{"簡単", "10分以内", "ずぼら"},
+		steps: []string{
+			"フライパンに油を熱し、%sを入れる",
+			"中火で中まで火が通るまで炒め、醤油とみりんで味をととのえる",
+			"フライパンごと食卓に出す",
+		},
+	},
+	{
+		suffix: "のほったらかし煮",
+		tags:   []string{"ずぼら", "和食", "常備菜・作り置き"},
+		steps: []string{
+			"鍋に%sと水、めんつゆを入れる",
+			"ふたをして弱火で中まで火が通るまで煮る",
+		},
+	},
+	{
+		suffix: "のせ丼",
+		tags:   []string{"簡単", "丼・ワンプレート", "ずぼら"},
+		steps: []string{
+			"%sを食べやすく切ってフライパンで中まで火が通るまで焼く",
+			"ごはんにのせ、ポン酢をかける",
+		},
+	},
+}
+
+var (
+	localBatchCountPattern   = regexp.MustCompile(`(\d+)個のずぼらレシピ`)
+	localIngredientsPattern  = regexp.MustCompile(`(?:## 使用する材料\n|材料: )([^\n]+)`)
+	localSeasonPattern       = regexp.MustCompile(`(?:## 季節\n|季節: )([^\n]+)`)
+	localMaxTimePattern      = regexp.MustCompile(`最大調理時間\D*(\d+)`)
+	localServingsPattern     = regexp.MustCompile(`(\d+)人分`)
+	localSeasonFromJapanese  = map[string]string{"春": "spring", "夏": "summer", "秋": "fall", "冬": "winter", "オールシーズン": "all"}
+	localDefaultIngredients  = []string{"卵", "ごはん"}
+	localMaxRecipesPerAnswer = 10
+
+	localRemediationRecipePattern  = regexp.MustCompile(`(?s)## 修正するレシピ\n(.*?)\n\n` + regexp.QuoteMeta(safetyRemediationHeading))
+	localRemediationMatchedPattern = regexp.MustCompile(`該当箇所: 「([^」]+)」`)
+	localRemediationTempPattern    = regexp.MustCompile(`(?m)^- ([^\n:]+): 中心温度`)
+)
+
+// localDonenessCue is added to recipes a remediation prompt says lack one
+const localDonenessCue = "中まで火が通るまで加熱する"
+
+// NewLocalProvider creates a new offline provider
+func NewLocalProvider() *LocalProvider {
+	return &LocalProvider{
+		files:   make(map[string][]byte),
+		batches: make(map[string]openai.Batch),
+	}
+}
+
+// Name returns the provider name
+func (p *LocalProvider) Name() string {
+	return config.ProviderLocal
+}
+
+// SupportsStructuredOutputs reports JSON-schema support. Local answers always
+// follow the recipe schema, so structured requests are safe to send.
+func (p *LocalProvider) SupportsStructuredOutputs() bool {
+	return true
+}
+
+// CreateChatCompletion answers a recipe prompt with templated recipe JSON
+func (p *LocalProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	var systemPrompt, userPrompt strings.Builder
+	for _, msg := range req.Messages {
+		if msg.Role == openai.ChatMessageRoleSystem {
+			systemPrompt.WriteString(msg.Content)
+		} else {
+			userPrompt.WriteString(msg.Content)
+		}
+	}
+
+	answer := p.answerRecipePrompt
+	if strings.Contains(userPrompt.String(), safetyRemediationHeading) {
+		answer = p.answerRemediationPrompt
+	}
+	content, err := answer(userPrompt.String())
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	promptTokens := estimateLocalTokens(systemPrompt.String()) + estimateLocalTokens(userPrompt.String())
+	completionTokens := estimateLocalTokens(content)
+
+	return openai.ChatCompletionResponse{
+		ID:                fmt.Sprintf("chatcmpl-local-%d", p.hash(userPrompt.String())),
+		Object:            "chat.completion",
+		Created:           time.Now().Unix(),
+		Model:             req.Model,
+		SystemFingerprint: "local",
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Index:        0,
+				Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+				FinishReason: openai.FinishReasonStop,
+			},
+		},
+		Usage: openai.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}, nil
+}
+
+// CreateEmbeddings returns normalized character-bigram hash vectors, so
+// recipes sharing words and ingredients land close together
+func (p *LocalProvider) CreateEmbeddings(ctx context.Context, req openai.EmbeddingRequest) (openai.EmbeddingResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return openai.EmbeddingResponse{}, err
+	}
+
+	var inputs []string
+	switch input := req.Input.(type) {
+	case string:
+		inputs = []string{input}
+	case []string:
+		inputs = input
+	default:
+		return openai.EmbeddingResponse{}, fmt.Errorf("unsupported embedding input type %T", req.Input)
+	}
+
+	resp := openai.EmbeddingResponse{
+		Object: "list",
+		Model:  req.Model,
+		Data:   make([]openai.Embedding, len(inputs)),
+	}
+	for i, text := range inputs {
+		resp.Data[i] = openai.Embedding{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: localEmbedding(text),
+		}
+		resp.Usage.PromptTokens += estimateLocalTokens(text)
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+
+	return resp, nil
+}
+
+// CreateFile stores an uploaded file in memory
+func (p *LocalProvider) CreateFile(ctx context.Context, req openai.FileRequest) (openai.File, error) {
+	data, err := os.ReadFile(req.FilePath)
+	if err != nil {
+		return openai.File{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := p.newID("file")
+	p.files[id] = data
+
+	return openai.File{
+		ID:        id,
+		Object:    "file",
+		Bytes:     len(data),
+		CreatedAt: time.Now().Unix(),
+		FileName:  req.FileName,
+		Purpose:   req.Purpose,
+		Status:    "processed",
+	}, nil
+}
+
+// GetFileContent returns a stored file
+func (p *LocalProvider) GetFileContent(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, ok := p.files[fileID]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", fileID)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// CreateBatch runs every request in the input file and completes the batch immediately
+func (p *LocalProvider) CreateBatch(ctx context.Context, req openai.CreateBatchRequest) (openai.Batch, error) {
+	p.mu.Lock()
+	input, ok := p.files[req.InputFileID]
+	p.mu.Unlock()
+	if !ok {
+		return openai.Batch{}, fmt.Errorf("input file %s not found", req.InputFileID)
+	}
+
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	counts := openai.BatchRequestCounts{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var batchReq BatchRequest
+		if err := json.Unmarshal(scanner.Bytes(), &batchReq); err != nil {
+			return openai.Batch{}, fmt.Errorf("invalid batch input line: %w", err)
+		}
+		counts.Total++
+
+		line := BatchResponse{ID: fmt.Sprintf("batch_req_local_%d", counts.Total), CustomID: batchReq.CustomID}
+		resp, err := p.CreateChatCompletion(ctx, batchReq.Body)
+		if err != nil {
+			counts.Failed++
+			line.Error = &BatchError{Code: "local_error", Message: err.Error()}
+		} else {
+			counts.Completed++
+			line.Response = &resp
+		}
+		if err := encoder.Encode(line); err != nil {
+			return openai.Batch{}, fmt.Errorf("failed to encode batch output: %w", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return openai.Batch{}, fmt.Errorf("failed to read batch input: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	outputID := p.newID("file")
+	p.files[outputID] = output.Bytes()
+
+	now := int(time.Now().Unix())
+	batch := openai.Batch{
+		ID:               p.newID("batch"),
+		Object:           "batch",
+		Endpoint:         req.Endpoint,
+		InputFileID:      req.InputFileID,
+		CompletionWindow: req.CompletionWindow,
+		Status:           "completed",
+		OutputFileID:     &outputID,
+		CreatedAt:        now,
+		CompletedAt:      &now,
+		RequestCounts:    counts,
+		Metadata:         req.Metadata,
+	}
+	p.batches[batch.ID] = batch
+
+	return batch, nil
+}
+
+// RetrieveBatch returns a stored batch
+func (p *LocalProvider) RetrieveBatch(ctx context.Context, batchID string) (openai.Batch, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	batch, ok := p.batches[batchID]
+	if !ok {
+		return openai.Batch{}, fmt.Errorf("batch %s not found", batchID)
+	}
+	return batch, nil
+}
+
+// CancelBatch marks a stored batch as cancelled
+func (p *LocalProvider) CancelBatch(ctx context.Context, batchID string) (openai.Batch, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	batch, ok := p.batches[batchID]
+	if !ok {
+		return openai.Batch{}, fmt.Errorf("batch %s not found", batchID)
+	}
+	now := int(time.Now().Unix())
+	batch.Status = "cancelled"
+	batch.CancelledAt = &now
+	p.batches[batchID] = batch
+
+	return batch, nil
+}
+
+// answerRecipePrompt builds the JSON answer for a single or batch recipe prompt
+func (p *LocalProvider) answerRecipePrompt(prompt string) (string, error) {
+	ingredients := localDefaultIngredients
+	if match := localIngredientsPattern.FindStringSubmatch(prompt); match != nil {
+		ingredients = splitLocalIngredients(match[1])
+	}
+
+	season := "all"
+	if match := localSeasonPattern.FindStringSubmatch(prompt); match != nil {
+		if mapped, ok := localSeasonFromJapanese[strings.TrimSpace(match[1])]; ok {
+			season = mapped
+		}
+	}
+
+	maxTime := 15
+	if match := localMaxTimePattern.FindStringSubmatch(prompt); match != nil {
+		if minutes, err := strconv.Atoi(match[1]); err == nil && minutes > 0 {
+			maxTime = minutes
+		}
+	}
+
+	servings := 1
+	if match := localServingsPattern.FindStringSubmatch(prompt); match != nil {
+		if n, err := strconv.Atoi(match[1]); err == nil && n > 0 {
+			servings = n
+		}
+	}
+
+	seed := p.hash(prompt)
+
+	if match := localBatchCountPattern.FindStringSubmatch(prompt); match != nil {
+		count, _ := strconv.Atoi(match[1])
+		if count < 1 {
+			count = 1
+		}
+		if count > localMaxRecipesPerAnswer {
+			count = localMaxRecipesPerAnswer
+		}
+
+		recipes := make([]localRecipeAnswer, count)
+		for i := range recipes {
+			recipes[i] = buildLocalRecipe(ingredients, season, maxTime, servings, seed+uint32(i))
+		}
+		data, err := json.Marshal(map[string]interface{}{"recipes": recipes})
+		return string(data), err
+	}
+
+	data, err := json.Marshal(buildLocalRecipe(ingredients, season, maxTime, servings, seed))
+	return string(data), err
+}
+
+// answerRemediationPrompt corrects the recipe in a safety remediation prompt
+// the way a careful editor would without understanding it: steps containing
+// flagged text are dropped, and a doneness cue is added when one is missing.
+// Problems outside the steps are left as they are.
+func (p *LocalProvider) answerRemediationPrompt(prompt string) (string, error) {
+	match := localRemediationRecipePattern.FindStringSubmatch(prompt)
+	if match == nil {
+		return "", fmt.Errorf("remediation prompt has no recipe")
+	}
+	var recipe models.RecipeData
+	if err := json.Unmarshal([]byte(match[1]), &recipe); err != nil {
+		return "", fmt.Errorf("failed to parse recipe in remediation prompt: %w", err)
+	}
+
+	steps := []string{}
+	flagged := localRemediationMatchedPattern.FindAllStringSubmatch(prompt, -1)
+	for _, step := range recipe.Steps {
+		keep := true
+		for _, text := range flagged {
+			if strings.Contains(step, text[1]) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			steps = append(steps, step)
+		}
+	}
+
+	// Doneness cues only count in steps that name the ingredient, so the cue does
+	if missing := localRemediationTempPattern.FindAllStringSubmatch(prompt, -1); len(missing) > 0 {
+		names := make([]string, len(missing))
+		for i, m := range missing {
+			names[i] = m[1]
+		}
+		cue := strings.Join(names, "と") + "は" + localDonenessCue
+
+		last := -1
+		for i, step := range steps {
+			if donenessHeatPattern.MatchString(step) {
+				last = i
+			}
+		}
+		if last >= 0 {
+			steps[last] = strings.TrimSuffix(steps[last], "。") + "。" + cue
+		} else {
+			steps = append(steps, cue)
+		}
+	}
+	if len(steps) > 0 {
+		recipe.Steps = models.FlexibleSteps(steps)
+	}
+
+	data, err := json.Marshal(recipe)
+	return string(data), err
+}
+
+// localRecipeAnswer mirrors the structured-output recipe schema
+type localRecipeAnswer struct {
+	models.RecipeData
+	SafetyCompliance localSafetyCompliance `json:"safety_compliance"`
+}
+
+type localSafetyCompliance struct {
+	SafeTempCheck    bool          `json:"safe_temp_check"`
+	TempInstructions []interface{} `json:"temp_instructions"`
+	AllergenWarnings []string      `json:"allergen_warnings"`
+}
+
+// buildLocalRecipe fills a template chosen deterministically from seed
+func buildLocalRecipe(ingredients []string, season string, maxTime, servings int, seed uint32) localRecipeAnswer {
+	tmpl := localRecipeTemplates[seed%uint32(len(localRecipeTemplates))]
+
+	joined := strings.Join(ingredients, "と")
+	steps := make([]string, len(tmpl.steps))
+	for i, step := range tmpl.steps {
+		if strings.Contains(step, "%s") {
+			step = fmt.Sprintf(step, joined)
+		}
+		steps[i] = step
+	}
+
+	recipeIngredients := make([]models.Ingredient, len(ingredients))
+	for i, name := range ingredients {
+		recipeIngredients[i] = models.Ingredient{Name: name, Amount: fmt.Sprintf("%d人分", servings)}
+	}
+
+	cookingTime := 10
+	if maxTime < cookingTime {
+		cookingTime = maxTime
+	}
+
+	return localRecipeAnswer{
+		RecipeData: models.RecipeData{
+			Title:         ingredients[0] + tmpl.suffix,
+			CookingTime:   cookingTime,
+			Ingredients:   recipeIngredients,
+			Steps:         models.FlexibleSteps(steps),
+			Tags:          append([]string(nil), tmpl.tags...),
+			Season:        season,
+			LazinessScore: 8.5,
+			NutritionInfo: &models.NutritionInfo{Calories: 450, Protein: 20},
+			ServingSize:   models.FlexibleInt(servings),
+			Difficulty:    "easy",
+			TotalCost:     300 * servings,
+		},
+		SafetyCompliance: localSafetyCompliance{
+			SafeTempCheck:    true,
+			TempInstructions: []interface{}{},
+			AllergenWarnings: []string{},
+		},
+	}
+}
+
+// splitLocalIngredients splits a prompt ingredient list on the separators prompts use
+func splitLocalIngredients(list string) []string {
+	fields := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '、'
+	})
+
+	var ingredients []string
+	for _, field := range fields {
+		if name := strings.TrimSpace(field); name != "" {
+			ingredients = append(ingredients, name)
+		}
+	}
+	if len(ingredients) == 0 {
+		return localDefaultIngredients
+	}
+	return ingredients
+}
+
+// localEmbedding hashes character bigrams into a unit-length vector
+func localEmbedding(text string) []float32 {
+	vector := make([]float32, localEmbeddingDimensions)
+	runes := []rune(strings.ToLower(text))
+
+	for i := 0; i < len(runes); i++ {
+		end := i + 2
+		if end > len(runes) {
+			end = len(runes)
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(string(runes[i:end])))
+		vector[h.Sum32()%localEmbeddingDimensions]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector
+}
+
+// estimateLocalTokens approximates token counts for usage reporting
+func estimateLocalTokens(text string) int {
+	return utf8.RuneCountInString(text)/2 + 1
+}
+
+func (p *LocalProvider) hash(text string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(text))
+	return h.Sum32()
+}
+
+// newID returns a unique local object ID; callers must hold p.mu
+func (p *LocalProvider) newID(prefix string) string {
+	p.nextID++
+	return fmt.Sprintf("%s-local-%d", prefix, p.nextID)
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/config"
+	"lazychef/internal/models"
+)
+
+func TestLocalProvider_ChatCompletionFillsRecipeFromPrompt(t *testing.T) {
+	provider := NewLocalProvider()
+	prompt := GetRecipeGenerationPrompt(RecipeGenerationRequest{
+		Ingredients:    []string{"鶏肉", "キャベツ"},
+		Season:         "winter",
+		MaxCookingTime: 8,
+		Servings:       2,
+	})
+
+	resp, err := provider.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Model: "gpt-5",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: prompt.SystemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: prompt.UserPrompt},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 1)
+	assert.Greater(t, resp.Usage.TotalTokens, 0)
+
+	var recipe models.RecipeData
+	require.NoError(t, json.Unmarshal([]byte(resp.Choices[0].Message.Content), &recipe))
+	require.NoError(t, recipe.Validate())
+	assert.Equal(t, []string{"鶏肉", "キャベツ"}, recipe.GetIngredientNames())
+	assert.Equal(t, "winter", recipe.Season)
+	assert.Equal(t, 8, recipe.CookingTime)
+	assert.Equal(t, 2, recipe.ServingSize.Int())
+
+	// Same prompt, same answer
+	again, err := provider.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: prompt.UserPrompt}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resp.Choices[0].Message.Content, again.Choices[0].Message.Content)
+}
+
+func TestLocalProvider_ChatCompletionBatchPrompt(t *testing.T) {
+	provider := NewLocalProvider()
+	prompt := GetBatchRecipeGenerationPrompt(RecipeGenerationRequest{
+		Ingredients:    []string{"豚肉"},
+		Season:         "summer",
+		MaxCookingTime: 15,
+	}, 3)
+
+	resp, err := provider.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: prompt.UserPrompt}},
+	})
+	require.NoError(t, err)
+
+	var batch struct {
+		Recipes []models.RecipeData `json:"recipes"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resp.Choices[0].Message.Content), &batch))
+	assert.Len(t, batch.Recipes, 3)
+	for _, recipe := range batch.Recipes {
+		assert.Equal(t, "summer", recipe.Season)
+		assert.Equal(t, []string{"豚肉"}, recipe.GetIngredientNames())
+	}
+}
+
+func TestLocalProvider_EmbeddingsAreDeterministicAndNormalized(t *testing.T) {
+	provider := NewLocalProvider()
+
+	resp, err := provider.CreateEmbeddings(context.Background(), openai.EmbeddingRequest{
+		Input: []string{"鶏肉とキャベツのレンジ蒸し", "鶏肉とキャベツのレンジ蒸し", "チョコレートケーキ"},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 3)
+
+	dedup := NewEmbeddingDeduplicator(provider, nil)
+
+	assert.Len(t, resp.Data[0].Embedding, localEmbeddingDimensions)
+	assert.Equal(t, resp.Data[0].Embedding, resp.Data[1].Embedding)
+	assert.InDelta(t, 1.0, dedup.cosineSimilarity(resp.Data[0].Embedding, resp.Data[1].Embedding), 1e-5)
+	assert.Less(t, dedup.cosineSimilarity(resp.Data[0].Embedding, resp.Data[2].Embedding), 0.5)
+}
+
+func TestLocalProvider_BatchRoundTrip(t *testing.T) {
+	provider := NewLocalProvider()
+	ctx := context.Background()
+
+	prompt := GetRecipeGenerationPrompt(RecipeGenerationRequest{Ingredients: []string{"豆腐"}, Season: "all", MaxCookingTime: 10})
+	line, err := json.Marshal(BatchRequest{
+		CustomID: "req_1",
+		Method:   "POST",
+		URL:      "/v1/chat/completions",
+		Body: openai.ChatCompletionRequest{
+			Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: prompt.UserPrompt}},
+		},
+	})
+	require.NoError(t, err)
+
+	inputPath := filepath.Join(t.TempDir(), "input.jsonl")
+	require.NoError(t, os.WriteFile(inputPath, append(line, '\n'), 0644))
+
+	file, err := provider.CreateFile(ctx, openai.FileRequest{FileName: "input.jsonl", FilePath: inputPath, Purpose: "batch"})
+	require.NoError(t, err)
+
+	batch, err := provider.CreateBatch(ctx, openai.CreateBatchRequest{InputFileID: file.ID, CompletionWindow: "24h"})
+	require.NoError(t, err)
+	assert.Equal(t, "completed", batch.Status)
+	assert.Equal(t, 1, batch.RequestCounts.Completed)
+	require.NotNil(t, batch.OutputFileID)
+
+	retrieved, err := provider.RetrieveBatch(ctx, batch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, batch.ID, retrieved.ID)
+
+	content, err := provider.GetFileContent(ctx, *batch.OutputFileID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+
+	var out BatchResponse
+	require.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, "req_1", out.CustomID)
+	require.NotNil(t, out.Response)
+	assert.Contains(t, out.Response.Choices[0].Message.Content, "豆腐")
+}
+
+func TestRecipeGeneratorService_LocalProviderWithoutAPIKey(t *testing.T) {
+	service, err := NewRecipeGeneratorService(&config.OpenAIConfig{
+		Provider:          config.ProviderLocal,
+		Model:             "local",
+		MaxTokens:         1000,
+		RequestsPerMinute: 600,
+		RequestTimeout:    5 * time.Second,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, config.ProviderLocal, service.GetProvider().Name())
+
+	result, err := service.GenerateRecipe(context.Background(), RecipeGenerationRequest{
+		Ingredients:    []string{"卵", "ごはん"},
+		Season:         "all",
+		MaxCookingTime: 10,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.Recipe)
+	assert.Equal(t, []string{"卵", "ごはん"}, result.Recipe.GetIngredientNames())
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"lazychef/internal/models"
+)
+
+// maxBudgetSwaps caps how many substitutions an over-budget plan suggests
+const maxBudgetSwaps = 3
+
+// householdScale returns how much a recipe's quantities must be multiplied
+// by to feed the household. A household size of 0 keeps the recipe as written.
+func householdScale(recipe models.RecipeData, householdSize int) float64 {
+	if householdSize <= 0 {
+		return 1
+	}
+	servings := int(recipe.ServingSize)
+	if servings <= 0 {
+		servings = 1
+	}
+	return float64(householdSize) / float64(servings)
+}
+
+// recipeBudget keeps a selection of candidate recipes completable within a
+// budget. A nil budget allows every selection.
+type recipeBudget struct {
+	costs  []int // costs[i] is the cost of candidate i
+	byCost []int // Candidate indexes from cheapest to most expensive
+	limit  int
+}
+
+// newRecipeBudget creates a budget over candidates with the given costs
+func newRecipeBudget(costs []int, limit int) *recipeBudget {
+	byCost := make([]int, len(costs))
+	for i := range byCost {
+		byCost[i] = i
+	}
+	sort.SliceStable(byCost, func(a, b int) bool {
+		return costs[byCost[a]] < costs[byCost[b]]
+	})
+	return &recipeBudget{costs: costs, byCost: byCost, limit: limit}
+}
+
+// cost returns the cost of candidate i
+func (b *recipeBudget) cost(i int) int {
+	if b == nil {
+		return 0
+	}
+	return b.costs[i]
+}
+
+// allows reports whether candidate i can join a selection that has spent
+// spent on the used candidates and needs remaining more recipes after i,
+// paying for those with the cheapest unused candidates
+func (b *recipeBudget) allows(i, spent, remaining int, used map[int]bool) bool {
+	if b == nil {
+		return true
+	}
+	total := spent + b.costs[i]
+	for _, idx := range b.byCost {
+		if remaining == 0 {
+			break
+		}
+		if used[idx] || idx == i {
+			continue
+		}
+		total += b.costs[idx]
+		remaining--
+	}
+	return total <= b.limit
+}
+
+// cheapest returns the count cheapest candidates
+func (b *recipeBudget) cheapest(candidates []*models.Recipe, count int) []*models.Recipe {
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+	selected := make([]*models.Recipe, 0, count)
+	for _, idx := range b.byCost[:count] {
+		selected = append(selected, candidates[idx])
+	}
+	return selected
+}
+
+// selectWithinBudget picks count recipes in ranked order, skipping any recipe
+// that would leave too little budget for the cheapest remaining candidates.
+// costs[i] is the cost of ranked[i]. When even the cheapest combination is
+// over budget it returns the cheapest combination and fits is false.
+func selectWithinBudget(ranked []*models.Recipe, costs []int, count, budget int) (selected []*models.Recipe, fits bool) {
+	if count > len(ranked) {
+		count = len(ranked)
+	}
+
+	limits := newRecipeBudget(costs, budget)
+	used := make(map[int]bool)
+	spent := 0
+	for i, recipe := range ranked {
+		if len(selected) == count {
+			break
+		}
+		if !limits.allows(i, spent, count-len(selected)-1, used) {
+			continue
+		}
+		used[i] = true
+		spent += costs[i]
+		selected = append(selected, recipe)
+	}
+	if len(selected) == count {
+		return selected, true
+	}
+
+	// Nothing fits: fall back to the cheapest combination
+	return limits.cheapest(ranked, count), false
+}
+
+// explainOverBudget fills in how far the plan is over budget and which
+// ingredient swaps would bring the cost down. cheapest reports that the
+// recipes are already the cheapest combination available.
+func (s *MealPlannerService) explainOverBudget(check *models.BudgetCheck, recipes []models.RecipeData, shoppingList []models.ShoppingItem, householdSize int, cheapest bool) {
+	if check.WithinBudget {
+		return
+	}
+
+	usedIn := make(map[string][]string)
+	for _, recipe := range recipes {
+		for _, ingredient := range recipe.Ingredients {
+			usedIn[ingredient.Name] = append(usedIn[ingredient.Name], recipe.Title)
+		}
+	}
+
+	swaps := make([]models.BudgetSwap, 0)
+	if s.db != nil {
+		for _, item := range shoppingList {
+			if item.CostEstimated || item.Cost <= 0 {
+				continue
+			}
+			swap, err := s.cheaperSwap(item)
+			if err != nil {
+				log.Printf("Warning: failed to look up cheaper alternatives for %s: %v", item.Item, err)
+				continue
+			}
+			if swap != nil {
+				swap.Recipes = removeDuplicates(usedIn[item.Item])
+				swaps = append(swaps, *swap)
+			}
+		}
+	}
+	sort.SliceStable(swaps, func(i, j int) bool {
+		return swaps[i].Savings > swaps[j].Savings
+	})
+	if len(swaps) > maxBudgetSwaps {
+		swaps = swaps[:maxBudgetSwaps]
+	}
+	check.Swaps = swaps
+
+	servings := ""
+	if householdSize > 0 {
+		servings = fmt.Sprintf(" for %d people", householdSize)
+	}
+	explanation := fmt.Sprintf("Estimated cost ¥%d%s is ¥%d over the weekly budget of ¥%d",
+		check.TotalCost, servings, check.OverBy, check.BudgetPerWeek)
+	if cheapest {
+		explanation += ", even with the cheapest combination of matching recipes."
+	} else {
+		explanation += "."
+	}
+
+	if len(swaps) > 0 {
+		savings := 0
+		suggestions := make([]string, 0, len(swaps))
+		for _, swap := range swaps {
+			savings += swap.Savings
+			suggestions = append(suggestions, fmt.Sprintf("%s for %s (¥%d)", swap.Ingredient, swap.Substitute, swap.Savings))
+		}
+		explanation += fmt.Sprintf(" Swapping %s would save about ¥%d", strings.Join(suggestions, ", "), savings)
+		if savings >= check.OverBy {
+			explanation += " and bring the plan within budget."
+		} else {
+			explanation += "."
+		}
+	}
+	check.Explanation = explanation
+}
+
+// cheaperSwap finds the cheapest same-group substitute for a shopping item
+func (s *MealPlannerService) cheaperSwap(item models.ShoppingItem) (*models.BudgetSwap, error) {
+	alternatives, err := s.prices.CheaperAlternatives(item.Item)
+	if err != nil || len(alternatives) == 0 {
+		return nil, err
+	}
+
+	qty, err := s.ingredientAggregator.ParseQuantity(item.Amount)
+	if err != nil {
+		return nil, nil
+	}
+	base, err := s.ingredientAggregator.ConvertToBaseUnit(qty)
+	if err != nil || base.Unit != alternatives[0].BaseUnit {
+		return nil, nil
+	}
+
+	substituteCost := int(math.Round(base.Amount * alternatives[0].PricePerUnit))
+	if substituteCost >= item.Cost {
+		return nil, nil
+	}
+
+	return &models.BudgetSwap{
+		Ingredient: item.Item,
+		Substitute: alternatives[0].Ingredient,
+		Savings:    item.Cost - substituteCost,
+	}, nil
+}
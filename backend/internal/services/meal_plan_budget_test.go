@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func TestHouseholdScale(t *testing.T) {
+	recipe := models.RecipeData{ServingSize: models.FlexibleInt(2)}
+
+	assert.Equal(t, 1.0, householdScale(recipe, 0))
+	assert.Equal(t, 2.0, householdScale(recipe, 4))
+	assert.Equal(t, 0.5, householdScale(recipe, 1))
+	assert.Equal(t, 3.0, householdScale(models.RecipeData{}, 3))
+}
+
+func TestSelectWithinBudget(t *testing.T) {
+	ranked := []*models.Recipe{
+		{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4},
+	}
+	costs := []int{500, 300, 200, 100}
+
+	t.Run("keeps ranking when it fits", func(t *testing.T) {
+		selected, fits := selectWithinBudget(ranked, costs, 2, 1000)
+		assert.True(t, fits)
+		assert.Equal(t, []int{1, 2}, recipeIDs(selected))
+	})
+
+	t.Run("skips recipes that would break the budget", func(t *testing.T) {
+		selected, fits := selectWithinBudget(ranked, costs, 3, 800)
+		assert.True(t, fits)
+		assert.Equal(t, []int{1, 3, 4}, recipeIDs(selected))
+
+		selected, fits = selectWithinBudget(ranked, costs, 3, 600)
+		assert.True(t, fits)
+		assert.Equal(t, []int{2, 3, 4}, recipeIDs(selected))
+	})
+
+	t.Run("falls back to the cheapest combination", func(t *testing.T) {
+		selected, fits := selectWithinBudget(ranked, costs, 2, 200)
+		assert.False(t, fits)
+		assert.Equal(t, []int{4, 3}, recipeIDs(selected))
+	})
+}
+
+func recipeIDs(recipes []*models.Recipe) []int {
+	ids := make([]int, 0, len(recipes))
+	for _, recipe := range recipes {
+		ids = append(ids, recipe.ID)
+	}
+	return ids
+}
+
+func TestMealPlannerService_ShoppingListScalesToHousehold(t *testing.T) {
+	service := NewMealPlannerService(nil, nil)
+	recipes := []models.RecipeData{{
+		Title:       "豚キャベツ",
+		ServingSize: models.FlexibleInt(2),
+		Ingredients: []models.Ingredient{
+			{Name: "豚こま肉", Amount: "200g"},
+			{Name: "塩", Amount: "少々"},
+		},
+	}}
+
+	byName := make(map[string]models.ShoppingItem)
+	for _, item := range service.createShoppingListWithPantry(recipes, nil, 5) {
+		byName[item.Item] = item
+	}
+	assert.Equal(t, "500g", byName["豚こま肉"].Amount)
+	assert.Equal(t, "適量", byName["塩"].Amount)
+}
+
+func TestMealPlannerService_BudgetIsHardConstraint(t *testing.T) {
+	for _, reuse := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ingredient reuse %t", reuse), func(t *testing.T) {
+			testBudgetIsHardConstraint(t, reuse)
+		})
+	}
+}
+
+func testBudgetIsHardConstraint(t *testing.T, optimizeReuse bool) {
+	db := setupHierarchyDatabase(t)
+
+	// Beef is the top-ranked recipe but only chicken recipes fit the budget
+	insertTestRecipe(t, db, models.RecipeData{
+		Title:         "牛丼",
+		CookingTime:   10,
+		Ingredients:   []models.Ingredient{{Name: "牛切り落とし", Amount: "200g"}},
+		Steps:         []string{"煮る"},
+		Season:        "all",
+		Tags:          []string{"丼"},
+		LazinessScore: 9.0,
+		ServingSize:   models.FlexibleInt(2),
+	})
+	for i, title := range []string{"鶏むね焼き", "鶏むね蒸し", "鶏むね炒め", "鶏むね煮", "鶏むね揚げ"} {
+		insertTestRecipe(t, db, models.RecipeData{
+			Title:         title,
+			CookingTime:   10 + i,
+			Ingredients:   []models.Ingredient{{Name: "鶏胸肉", Amount: "200g"}},
+			Steps:         []string{"焼く"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(2),
+		})
+	}
+
+	service := NewMealPlannerService(db, nil)
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate: "2025-01-27",
+		Preferences: models.MealPlanPreferences{
+			BudgetPerWeek: 1000,
+			HouseholdSize: 2,
+			PreferredTags: []string{"丼"},
+
+			OptimizeIngredientReuse: optimizeReuse,
+		},
+	})
+	require.NoError(t, err)
+
+	for _, daily := range plan.WeekData.DailyRecipes {
+		assert.NotEqual(t, "牛丼", daily.Title)
+	}
+	require.NotNil(t, plan.WeekData.Budget)
+	assert.True(t, plan.WeekData.Budget.WithinBudget)
+	assert.Equal(t, 900, plan.WeekData.TotalCostEstimate)
+	assert.Empty(t, plan.WeekData.Budget.Explanation)
+}
+
+func TestMealPlannerService_FillInRecipesFitRemainingBudget(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	for _, title := range []string{"鶏むね焼き", "鶏むね蒸し", "鶏むね炒め"} {
+		insertTestRecipe(t, db, models.RecipeData{
+			Title:         title,
+			CookingTime:   10,
+			Ingredients:   []models.Ingredient{{Name: "鶏胸肉", Amount: "200g"}},
+			Steps:         []string{"焼く"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(2),
+		})
+	}
+
+	// ¥540 of chicken leaves ¥360 for two fallback days, which rules out the
+	// first fallback (豚キャベツ炒め, ¥752 for two)
+	service := NewMealPlannerService(db, nil)
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate:   "2025-01-27",
+		Preferences: models.MealPlanPreferences{BudgetPerWeek: 900, HouseholdSize: 2},
+	})
+	require.NoError(t, err)
+
+	titles := make([]string, 0, len(plan.WeekData.DailyRecipes))
+	for _, daily := range plan.WeekData.DailyRecipes {
+		titles = append(titles, daily.Title)
+	}
+	assert.ElementsMatch(t, []string{"鶏むね焼き", "鶏むね蒸し", "鶏むね炒め", "もやしと卵の炒め物", "野菜炒め"}, titles)
+	require.NotNil(t, plan.WeekData.Budget)
+	assert.True(t, plan.WeekData.Budget.WithinBudget)
+	assert.Equal(t, 840, plan.WeekData.TotalCostEstimate)
+}
+
+func TestIngredientReuseOptimizer_SelectRecipesWithinBudget(t *testing.T) {
+	optimizer := NewIngredientReuseOptimizer(NewIngredientAggregator())
+	days := []string{"monday", "tuesday"}
+	candidates := []*models.Recipe{
+		reuseTestRecipe(1, "キャベツ炒め", 9.0, models.Ingredient{Name: "キャベツ", Amount: "1/2個"}),
+		reuseTestRecipe(2, "コールスロー", 8.0, models.Ingredient{Name: "キャベツ", Amount: "1/2個"}),
+		reuseTestRecipe(3, "冷奴", 7.0, models.Ingredient{Name: "豆腐", Amount: "1丁"}),
+	}
+
+	// The cabbage pair reuses best but only one cabbage recipe fits the budget
+	selected, fits := optimizer.SelectRecipesWithinBudget(candidates, []int{300, 300, 100}, days, 0, 450)
+	assert.True(t, fits)
+	assert.Len(t, selected, 2)
+	assert.Contains(t, recipeIDs(selected), 3)
+
+	selected, fits = optimizer.SelectRecipesWithinBudget(candidates, []int{300, 300, 100}, days, 0, 300)
+	assert.False(t, fits)
+	assert.Equal(t, []int{3, 1}, recipeIDs(selected))
+}
+
+func TestMealPlannerService_OverBudgetExplainsSwaps(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	for _, title := range []string{"牛丼", "牛焼肉", "牛炒め", "牛煮込み", "牛しゃぶ"} {
+		insertTestRecipe(t, db, models.RecipeData{
+			Title:         title,
+			CookingTime:   10,
+			Ingredients:   []models.Ingredient{{Name: "牛切り落とし", Amount: "100g"}},
+			Steps:         []string{"焼く"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		})
+	}
+
+	service := NewMealPlannerService(db, nil)
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate: "2025-01-27",
+		Preferences: models.MealPlanPreferences{
+			BudgetPerWeek: 2000,
+			HouseholdSize: 2,
+		},
+	})
+	require.NoError(t, err)
+
+	// 1kg of beef at ¥3/g
+	budget := plan.WeekData.Budget
+	require.NotNil(t, budget)
+	assert.Equal(t, 3000, plan.WeekData.TotalCostEstimate)
+	assert.False(t, budget.WithinBudget)
+	assert.Equal(t, 1000, budget.OverBy)
+	for _, daily := range plan.WeekData.DailyRecipes {
+		assert.Equal(t, 600, daily.Cost)
+	}
+
+	require.Len(t, budget.Swaps, 1)
+	assert.Equal(t, "牛切り落とし", budget.Swaps[0].Ingredient)
+	assert.Equal(t, "鶏胸肉", budget.Swaps[0].Substitute)
+	assert.Equal(t, 2100, budget.Swaps[0].Savings)
+	assert.Len(t, budget.Swaps[0].Recipes, 5)
+	assert.Contains(t, budget.Explanation, "¥1000 over the weekly budget")
+	assert.Contains(t, budget.Explanation, "cheapest combination")
+	assert.Contains(t, budget.Explanation, "bring the plan within budget")
+}
@@ -1,9 +1,15 @@
 package services
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
 	"strings"
+	"time"
 
 	"lazychef/internal/database"
 	"lazychef/internal/models"
@@ -14,41 +20,101 @@ type MealPlannerService struct {
 	db                   *database.Database
 	generator            *RecipeGeneratorService
 	ingredientAggregator *IngredientAggregator
+	reuseOptimizer       *IngredientReuseOptimizer
 	recipeRepo           *RecipeRepository
+	preferencesRepo      *UserPreferencesRepository
+	pantry               *PantryService
+	prices               *IngredientPriceService
+	allergens            *AllergenService
+	dietary              *DietaryRestrictionService
 }
 
 // NewMealPlannerService creates a new meal planner service
 func NewMealPlannerService(db *database.Database, generator *RecipeGeneratorService) *MealPlannerService {
+	ingredientAggregator := NewIngredientAggregator()
 	return &MealPlannerService{
 		db:                   db,
 		generator:            generator,
-		ingredientAggregator: NewIngredientAggregator(),
+		ingredientAggregator: ingredientAggregator,
+		reuseOptimizer:       NewIngredientReuseOptimizer(ingredientAggregator),
 		recipeRepo:           NewRecipeRepository(db),
+		preferencesRepo:      NewUserPreferencesRepository(db),
+		pantry:               NewPantryService(db, ingredientAggregator),
+		prices:               NewIngredientPriceService(db, ingredientAggregator),
+		allergens:            NewAllergenService(db),
+		dietary:              NewDietaryRestrictionService(db),
 	}
 }
 
+// mealPlanDays lists the days covered by a weekly meal plan
+var mealPlanDays = []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
+
+// mealPlanCandidateLimit caps how many library recipes are considered per plan
+const mealPlanCandidateLimit = 200
+
+// fallbackGenerationIngredients seeds AI generation when the library runs short
+var fallbackGenerationIngredients = []string{"豚こま肉", "鶏もも肉", "卵", "豆腐", "キャベツ", "もやし", "ツナ缶"}
+
 // CreateWeeklyPlan creates a weekly meal plan
-func (s *MealPlannerService) CreateWeeklyPlan(req models.CreateMealPlanRequest) (*models.MealPlan, error) {
-	// Generate recipes for the week
-	recipes := make([]models.RecipeData, 0, 5)
-
-	// Generate 5 recipes for weekdays
-	days := []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
-	for i := 0; i < 5; i++ {
-		// Use fallback recipes for now (AI generation will be enhanced later)
-		recipe := s.getFallbackRecipe(i)
-		recipes = append(recipes, *recipe)
+func (s *MealPlannerService) CreateWeeklyPlan(ctx context.Context, req models.CreateMealPlanRequest) (*models.MealPlan, error) {
+	// Fill in the user's stored preferences when the request names a user
+	if req.UserID != "" && s.db != nil {
+		stored, err := s.preferencesRepo.GetPreferences(req.UserID)
+		switch {
+		case err == nil:
+			req.Preferences = stored.Preferences.ApplyToMealPlan(req.Preferences)
+		case errors.Is(err, models.ErrUserNotFound):
+			log.Printf("No stored preferences for user %s, using request preferences", req.UserID)
+		default:
+			return nil, fmt.Errorf("failed to load user preferences: %w", err)
+		}
+	}
+	if req.Preferences.MaxCookingTime <= 0 {
+		req.Preferences.MaxCookingTime = 15
+	}
+
+	// Select recipes for the week from the library, generating when needed
+	prices := s.prices.loadPriceTable()
+	recipes, cheapest, err := s.selectRecipes(ctx, req, len(mealPlanDays), prices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select recipes: %w", err)
+	}
+
+	// Price each recipe from the ingredient price table, scaled to the household
+	householdSize := req.Preferences.HouseholdSize
+	dailyCosts := make([]int, len(recipes))
+	for i, recipe := range recipes {
+		recipe.Data.TotalCost = prices.RecipeCost(s.ingredientAggregator, &recipe.Data)
+		dailyCosts[i] = prices.ScaledRecipeCost(s.ingredientAggregator, &recipe.Data, householdScale(recipe.Data, householdSize))
+	}
+
+	recipeData := recipeDataOf(recipes)
+
+	// Create shopping list, leaving out what the user's pantry already holds
+	stock, err := s.pantryStock(req.UserID)
+	if err != nil {
+		return nil, err
 	}
+	shoppingList := s.createShoppingListWithPantry(recipeData, stock, householdSize)
 
-	// Create shopping list
-	shoppingList := s.createShoppingList(recipes)
+	// Evaluate how well perishables are used up across the week
+	reuse := s.reuseOptimizer.Analyze(recipeData, mealPlanDays, householdSize)
 
 	// Build meal plan data
+	totalCost := int(s.estimateTotalCost(shoppingList))
 	mealPlanData := models.MealPlanData{
 		StartDate:         req.StartDate,
+		UserID:            req.UserID,
+		HouseholdSize:     householdSize,
 		ShoppingList:      shoppingList,
 		DailyRecipes:      make(map[string]models.DailyRecipe),
-		TotalCostEstimate: int(s.estimateTotalCost(shoppingList)),
+		TotalCostEstimate: totalCost,
+		IngredientReuse:   reuse.IngredientReuse,
+		WasteScore:        reuse.WasteScore,
+	}
+	if req.Preferences.BudgetPerWeek > 0 {
+		mealPlanData.Budget = models.NewBudgetCheck(req.Preferences.BudgetPerWeek, totalCost)
+		s.explainOverBudget(mealPlanData.Budget, recipeData, shoppingList, householdSize, cheapest)
 	}
 
 	// Create meal plan
@@ -57,11 +123,13 @@ func (s *MealPlannerService) CreateWeeklyPlan(req models.CreateMealPlanRequest)
 	}
 
 	// Assign recipes to days
-	for i, day := range days {
+	for i, day := range mealPlanDays {
 		if i < len(recipes) {
 			mealPlan.WeekData.DailyRecipes[day] = models.DailyRecipe{
-				RecipeID: i + 1,
-				Title:    recipes[i].Title,
+				RecipeID: recipes[i].ID,
+				Title:    recipes[i].Data.Title,
+				Day:      day,
+				Cost:     dailyCosts[i],
 			}
 		}
 	}
@@ -76,19 +144,321 @@ func (s *MealPlannerService) CreateWeeklyPlan(req models.CreateMealPlanRequest)
 	return mealPlan, nil
 }
 
+// selectRecipes picks count recipes matching the plan preferences.
+// Library recipes are preferred; the generator only fills the remaining slots.
+// With a weekly budget, library recipes are chosen to fit it and fill-in
+// recipes must fit what is left; cheapest reports that no library combination
+// fit and the cheapest one was used instead.
+func (s *MealPlannerService) selectRecipes(ctx context.Context, req models.CreateMealPlanRequest, count int, prices PriceTable) (selected []*models.Recipe, cheapest bool, err error) {
+	prefs := req.Preferences
+	season := seasonForDate(req.StartDate)
+
+	selected = make([]*models.Recipe, 0, count)
+	usedTitles := make(map[string]bool)
+
+	// Allergies and dietary restrictions are checked against each recipe's
+	// resolved ingredients, so マヨネーズ counts as 卵 and だし as fish even
+	// though the recipe never names them
+	allergenTable := &AllergenTable{}
+	if len(prefs.Allergies) > 0 {
+		allergenTable = s.allergens.loadTable()
+	}
+	dietaryTable := &DietaryTable{}
+	if len(prefs.DietaryRestrictions) > 0 {
+		dietaryTable = s.dietary.loadTable()
+	}
+	fitsPlan := func(recipe *models.RecipeData) bool {
+		return !recipeContainsAny(*recipe, prefs.ExcludeIngredients) &&
+			len(allergenTable.Conflicts(recipe, prefs.Allergies)) == 0 &&
+			len(dietaryTable.Violations(recipe, prefs.DietaryRestrictions)) == 0
+	}
+	recipeCost := func(recipe *models.RecipeData) int {
+		return prices.ScaledRecipeCost(s.ingredientAggregator, recipe, householdScale(*recipe, prefs.HouseholdSize))
+	}
+	spent := 0
+
+	if s.db != nil {
+		candidates, err := s.recipeRepo.SearchRecipes(models.SearchCriteria{
+			MaxCookingTime: prefs.MaxCookingTime,
+			Season:         season,
+			Limit:          mealPlanCandidateLimit,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+
+		allowed := make([]*models.Recipe, 0, len(candidates))
+		for _, recipe := range filterRecipesForPlan(candidates, prefs) {
+			if fitsPlan(&recipe.Data) {
+				allowed = append(allowed, recipe)
+			}
+		}
+		ranked := uniqueRecipesByTitle(rankRecipesForPlan(allowed, prefs.PreferredTags))
+
+		// Budget is a hard constraint: the combination is chosen from the whole
+		// candidate pool so that it fits
+		var costs []int
+		if prefs.BudgetPerWeek > 0 {
+			costs = make([]int, len(ranked))
+			for i, recipe := range ranked {
+				costs[i] = recipeCost(&recipe.Data)
+			}
+		}
+		fits := true
+		switch {
+		case prefs.OptimizeIngredientReuse && costs != nil:
+			ranked, fits = s.reuseOptimizer.SelectRecipesWithinBudget(ranked, costs, mealPlanDays[:count], prefs.HouseholdSize, prefs.BudgetPerWeek)
+		case prefs.OptimizeIngredientReuse:
+			ranked = s.reuseOptimizer.SelectRecipes(ranked, mealPlanDays[:count], prefs.HouseholdSize)
+		case costs != nil:
+			ranked, fits = selectWithinBudget(ranked, costs, count, prefs.BudgetPerWeek)
+		}
+		cheapest = !fits
+
+		for _, recipe := range ranked {
+			if len(selected) >= count {
+				break
+			}
+			if usedTitles[recipe.Data.Title] {
+				continue
+			}
+			usedTitles[recipe.Data.Title] = true
+			selected = append(selected, recipe)
+			spent += recipeCost(&recipe.Data)
+		}
+	}
+
+	// Recipes filling the remaining days must fit what is left of the budget
+	fitsRemainingBudget := func(recipe *models.RecipeData) bool {
+		return fitsPlan(recipe) && (prefs.BudgetPerWeek <= 0 || spent+recipeCost(recipe) <= prefs.BudgetPerWeek)
+	}
+
+	// Fill the remaining days with generated recipes, then static fallbacks
+	canGenerate := s.generator != nil
+	for i := 0; len(selected) < count; i++ {
+		var recipe *models.Recipe
+		if canGenerate {
+			seed := fallbackGenerationIngredients[i%len(fallbackGenerationIngredients)]
+			if prefs.OptimizeIngredientReuse {
+				// Build the missing day around leftovers from earlier days
+				if leftovers := s.reuseOptimizer.LeftoverIngredients(recipeDataOf(selected), prefs.HouseholdSize); len(leftovers) > 0 {
+					seed = leftovers[0]
+				}
+			}
+			recipe = s.generateRecipeForPlan(ctx, prefs, season, seed)
+			if recipe != nil && !fitsRemainingBudget(&recipe.Data) {
+				recipe = nil
+			}
+			// Stop calling the generator once it fails to avoid repeated retries
+			canGenerate = recipe != nil
+		}
+		if recipe == nil || usedTitles[recipe.Data.Title] {
+			fallback := s.nextFallbackRecipe(usedTitles, fitsRemainingBudget)
+			if fallback == nil && prefs.BudgetPerWeek > 0 {
+				// Over budget beats an empty day; the plan reports the overrun
+				fallback = s.nextFallbackRecipe(usedTitles, fitsPlan)
+			}
+			if fallback == nil {
+				log.Printf("Warning: no fallback recipe fits the plan's allergies and dietary restrictions, planning %d of %d days", len(selected), count)
+				break
+			}
+			recipe = &models.Recipe{Data: *fallback}
+		}
+		usedTitles[recipe.Data.Title] = true
+		spent += recipeCost(&recipe.Data)
+
+		if s.db != nil {
+			if err := s.ensureRecipeSaved(recipe); err != nil {
+				return nil, false, err
+			}
+		}
+		selected = append(selected, recipe)
+	}
+
+	return selected, cheapest, nil
+}
+
+// generateRecipeForPlan asks the AI generator for a recipe matching the preferences.
+// It returns nil when generation is unavailable or fails.
+func (s *MealPlannerService) generateRecipeForPlan(ctx context.Context, prefs models.MealPlanPreferences, season string, ingredient string) *models.Recipe {
+	maxCookingTime := prefs.MaxCookingTime
+	if maxCookingTime <= 0 {
+		maxCookingTime = 15
+	}
+
+	constraints := make([]string, 0, len(prefs.ExcludeIngredients)+len(prefs.Allergies))
+	for _, excluded := range prefs.ExcludeIngredients {
+		constraints = append(constraints, excluded+"を使わない")
+	}
+	for _, allergy := range prefs.Allergies {
+		constraints = append(constraints, allergy+"アレルギー対応（"+allergy+"を含む調味料・加工品も使わない）")
+	}
+
+	genReq := RecipeGenerationRequest{
+		Ingredients:    []string{ingredient},
+		Season:         season,
+		MaxCookingTime: maxCookingTime,
+		Servings:       prefs.HouseholdSize,
+		Constraints:    constraints,
+		Preferences:    prefs.PreferredTags,
+
+		DietaryRestrictions: prefs.DietaryRestrictions,
+	}
+
+	result, err := s.generator.GenerateRecipe(ctx, genReq)
+	if err != nil || result == nil || result.Recipe == nil {
+		log.Printf("Warning: meal plan recipe generation failed: %v", err)
+		return nil
+	}
+
+	// Generated recipes must still honour the exclusions
+	recipe := &models.Recipe{Data: *result.Recipe}
+	if len(filterRecipesForPlan([]*models.Recipe{recipe}, prefs)) == 0 {
+		return nil
+	}
+
+	return recipe
+}
+
+// ensureRecipeSaved makes sure the recipe has a real database ID,
+// reusing an existing row with the same title when there is one
+func (s *MealPlannerService) ensureRecipeSaved(recipe *models.Recipe) error {
+	if recipe.ID > 0 {
+		return nil
+	}
+
+	existing, err := s.recipeRepo.FindRecipeByTitle(recipe.Data.Title)
+	if err != nil {
+		return fmt.Errorf("failed to look up recipe: %w", err)
+	}
+	if existing != nil {
+		recipe.ID = existing.ID
+		return nil
+	}
+
+	if recipe.Data.Season == "" {
+		recipe.Data.Season = "all"
+	}
+	if err := s.recipeRepo.SaveRecipe(recipe); err != nil {
+		return fmt.Errorf("failed to save recipe: %w", err)
+	}
+	return nil
+}
+
+// filterRecipesForPlan drops recipes containing excluded ingredients.
+// Allergies and dietary restrictions are checked in selectRecipes.
+func filterRecipesForPlan(recipes []*models.Recipe, prefs models.MealPlanPreferences) []*models.Recipe {
+	if len(prefs.ExcludeIngredients) == 0 {
+		return recipes
+	}
+
+	filtered := make([]*models.Recipe, 0, len(recipes))
+	for _, recipe := range recipes {
+		if !recipeContainsAny(recipe.Data, prefs.ExcludeIngredients) {
+			filtered = append(filtered, recipe)
+		}
+	}
+	return filtered
+}
+
+// recipeContainsAny reports whether any ingredient name contains one of the keywords
+func recipeContainsAny(recipe models.RecipeData, keywords []string) bool {
+	for _, ingredient := range recipe.Ingredients {
+		for _, keyword := range keywords {
+			if keyword != "" && strings.Contains(ingredient.Name, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rankRecipesForPlan orders recipes by preferred tag matches, then laziness score
+func rankRecipesForPlan(recipes []*models.Recipe, preferredTags []string) []*models.Recipe {
+	tagMatches := func(recipe *models.Recipe) int {
+		matches := 0
+		for _, tag := range preferredTags {
+			if recipe.Data.HasTag(tag) {
+				matches++
+			}
+		}
+		return matches
+	}
+
+	ranked := make([]*models.Recipe, len(recipes))
+	copy(ranked, recipes)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		mi, mj := tagMatches(ranked[i]), tagMatches(ranked[j])
+		if mi != mj {
+			return mi > mj
+		}
+		return ranked[i].Data.LazinessScore > ranked[j].Data.LazinessScore
+	})
+	return ranked
+}
+
+// recipeDataOf extracts the recipe data from stored recipes
+func recipeDataOf(recipes []*models.Recipe) []models.RecipeData {
+	data := make([]models.RecipeData, 0, len(recipes))
+	for _, recipe := range recipes {
+		data = append(data, recipe.Data)
+	}
+	return data
+}
+
+// seasonForDate returns the season of a YYYY-MM-DD date, defaulting to today
+func seasonForDate(date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		t = time.Now()
+	}
+
+	switch t.Month() {
+	case time.March, time.April, time.May:
+		return "spring"
+	case time.June, time.July, time.August:
+		return "summer"
+	case time.September, time.October, time.November:
+		return "fall"
+	default:
+		return "winter"
+	}
+}
+
+// pantryStock loads the user's pantry, or returns nil when there is no user
+func (s *MealPlannerService) pantryStock(userID string) (PantryStock, error) {
+	if userID == "" || s.db == nil {
+		return nil, nil
+	}
+
+	stock, err := s.pantry.Stock(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pantry: %w", err)
+	}
+	return stock, nil
+}
+
 // createShoppingList creates a shopping list from recipes
 func (s *MealPlannerService) createShoppingList(recipes []models.RecipeData) []models.ShoppingItem {
+	return s.createShoppingListWithPantry(recipes, nil, 0)
+}
+
+// createShoppingListWithPantry creates a shopping list from recipes, subtracting
+// pantry stock. Quantities are scaled to the household size unless it is 0.
+func (s *MealPlannerService) createShoppingListWithPantry(recipes []models.RecipeData, stock PantryStock, householdSize int) []models.ShoppingItem {
 	// Map to collect quantities for each ingredient
 	ingredientQuantitiesMap := make(map[string][]*IngredientQuantity)
 
 	// Collect all ingredient quantities
 	for _, recipe := range recipes {
+		scale := householdScale(recipe, householdSize)
 		for _, ingredient := range recipe.Ingredients {
 			qty, err := s.ingredientAggregator.ParseQuantity(ingredient.Amount)
 			if err != nil {
 				// If parsing fails, use "適量"
 				qty = &IngredientQuantity{Amount: 0, Unit: "適量"}
 			}
+			qty.Amount *= scale
 
 			ingredientQuantitiesMap[ingredient.Name] = append(
 				ingredientQuantitiesMap[ingredient.Name],
@@ -97,6 +467,8 @@ func (s *MealPlannerService) createShoppingList(recipes []models.RecipeData) []m
 		}
 	}
 
+	prices := s.prices.loadPriceTable()
+
 	// Aggregate quantities for each ingredient
 	shoppingList := make([]models.ShoppingItem, 0, len(ingredientQuantitiesMap))
 	for ingredientName, quantities := range ingredientQuantitiesMap {
@@ -106,13 +478,27 @@ func (s *MealPlannerService) createShoppingList(recipes []models.RecipeData) []m
 			aggregatedQty = &IngredientQuantity{Amount: 0, Unit: "適量"}
 		}
 
+		// Subtract what the pantry already holds
+		var covered *IngredientQuantity
+		if stock != nil {
+			aggregatedQty, covered = stock.Cover(s.ingredientAggregator, ingredientName, aggregatedQty)
+			if aggregatedQty == nil {
+				continue
+			}
+		}
+
 		// Format the aggregated quantity
 		amountStr := s.ingredientAggregator.FormatQuantity(aggregatedQty)
 
-		shoppingList = append(shoppingList, models.ShoppingItem{
+		item := models.ShoppingItem{
 			Item:   ingredientName,
 			Amount: amountStr,
-		})
+		}
+		item.Cost, item.CostEstimated = prices.ItemCost(s.ingredientAggregator, ingredientName, aggregatedQty)
+		if covered != nil {
+			item.PantryCovered = s.ingredientAggregator.FormatQuantity(covered)
+		}
+		shoppingList = append(shoppingList, item)
 	}
 
 	return shoppingList
@@ -120,12 +506,16 @@ func (s *MealPlannerService) createShoppingList(recipes []models.RecipeData) []m
 
 // estimateTotalCost estimates the total cost of shopping
 func (s *MealPlannerService) estimateTotalCost(items []models.ShoppingItem) float64 {
-	// Simple estimation: 200 yen per item average
-	return float64(len(items)) * 200
+	total := 0
+	for _, item := range items {
+		total += item.Cost
+	}
+	return float64(total)
 }
 
-// GenerateShoppingListFromRecipeIDs generates shopping list from recipe IDs
-func (s *MealPlannerService) GenerateShoppingListFromRecipeIDs(recipeIDs []int) ([]models.ShoppingItem, error) {
+// GenerateShoppingListFromRecipeIDs generates shopping list from recipe IDs.
+// When userID is set, items already in that user's pantry are subtracted.
+func (s *MealPlannerService) GenerateShoppingListFromRecipeIDs(recipeIDs []int, userID string) ([]models.ShoppingItem, error) {
 	// Get recipes by IDs
 	recipes, err := s.recipeRepo.GetRecipesByIDs(recipeIDs)
 	if err != nil {
@@ -138,8 +528,13 @@ func (s *MealPlannerService) GenerateShoppingListFromRecipeIDs(recipeIDs []int)
 		recipeDataList = append(recipeDataList, recipe.Data)
 	}
 
+	stock, err := s.pantryStock(userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate shopping list using existing logic
-	shoppingList := s.createShoppingList(recipeDataList)
+	shoppingList := s.createShoppingListWithPantry(recipeDataList, stock, 0)
 
 	// Add categories to shopping items
 	for i := range shoppingList {
@@ -219,6 +614,7 @@ func (s *MealPlannerService) getFallbackRecipe(index int) *models.RecipeData {
 				"豚肉を炒める",
 				"キャベツを加えて醤油で味付け",
 			},
+			Season:        "all",
 			LazinessScore: 9.0,
 		},
 		{
@@ -234,6 +630,7 @@ func (s *MealPlannerService) getFallbackRecipe(index int) *models.RecipeData {
 				"フライパンで炒める",
 				"卵を加えて塩コショウで味付け",
 			},
+			Season:        "all",
 			LazinessScore: 9.5,
 		},
 		{
@@ -249,6 +646,7 @@ func (s *MealPlannerService) getFallbackRecipe(index int) *models.RecipeData {
 				"鍋でめんつゆと煮る",
 				"ネギを散らす",
 			},
+			Season:        "all",
 			LazinessScore: 8.5,
 		},
 		{
@@ -264,6 +662,7 @@ func (s *MealPlannerService) getFallbackRecipe(index int) *models.RecipeData {
 				"フライパンで焼く",
 				"醤油とみりんで照り焼きにする",
 			},
+			Season:        "all",
 			LazinessScore: 8.0,
 		},
 		{
@@ -279,6 +678,7 @@ func (s *MealPlannerService) getFallbackRecipe(index int) *models.RecipeData {
 				"フライパンで炒める",
 				"塩コショウで味付け",
 			},
+			Season:        "all",
 			LazinessScore: 9.0,
 		},
 	}
@@ -289,6 +689,26 @@ func (s *MealPlannerService) getFallbackRecipe(index int) *models.RecipeData {
 	return &fallbackRecipes[0]
 }
 
+// nextFallbackRecipe returns the first allowed fallback recipe whose title is
+// not used yet, repeating an allowed one when all are used. It returns nil
+// when no fallback recipe is allowed.
+func (s *MealPlannerService) nextFallbackRecipe(usedTitles map[string]bool, allowed func(*models.RecipeData) bool) *models.RecipeData {
+	var repeat *models.RecipeData
+	for i := 0; i < len(mealPlanDays); i++ {
+		recipe := s.getFallbackRecipe(i)
+		if !allowed(recipe) {
+			continue
+		}
+		if !usedTitles[recipe.Title] {
+			return recipe
+		}
+		if repeat == nil {
+			repeat = recipe
+		}
+	}
+	return repeat
+}
+
 // saveMealPlan saves a meal plan to the database
 func (s *MealPlannerService) saveMealPlan(plan *models.MealPlan) error {
 	// Convert meal plan data to JSON for storage
@@ -339,7 +759,7 @@ func (s *MealPlannerService) GetMealPlan(id int) (*models.MealPlan, error) {
 	}()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("meal plan with id %d not found", id)
+		return nil, fmt.Errorf("%w: id %d", models.ErrMealPlanNotFound, id)
 	}
 
 	var mealPlan models.MealPlan
@@ -358,6 +778,73 @@ func (s *MealPlannerService) GetMealPlan(id int) (*models.MealPlan, error) {
 	return &mealPlan, nil
 }
 
+// MarkMealCooked records that the meal planned for a day was cooked and takes
+// its ingredients out of the pantry. userID overrides the plan's owner. The day
+// is claimed and the pantry decremented in one transaction, so a concurrent or
+// retried request cannot consume the ingredients twice.
+func (s *MealPlannerService) MarkMealCooked(planID int, day, userID string) (*models.MealPlan, []models.PantryConsumption, error) {
+	plan, err := s.GetMealPlan(planID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	daily, exists := plan.WeekData.DailyRecipes[day]
+	if !exists {
+		return nil, nil, models.ErrMealDayNotPlanned
+	}
+	if daily.CookedAt != nil {
+		return nil, nil, models.ErrMealAlreadyCooked
+	}
+
+	if userID == "" {
+		userID = plan.WeekData.UserID
+	}
+
+	var ingredients []models.Ingredient
+	var scale float64
+	if userID != "" {
+		recipe, err := s.recipeRepo.GetRecipe(daily.RecipeID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load recipe %d: %w", daily.RecipeID, err)
+		}
+		ingredients = recipe.Data.Ingredients
+		scale = householdScale(recipe.Data, plan.WeekData.HouseholdSize)
+	}
+
+	cookedAt := time.Now()
+	consumptions := []models.PantryConsumption{}
+	err = s.db.ExecuteInTx(func(tx *sql.Tx) error {
+		// Only the request that flips cooked_at from null goes on to the pantry
+		path := fmt.Sprintf(`$.daily_recipes."%s".cooked_at`, day)
+		result, err := tx.Exec(`
+			UPDATE meal_plans SET week_data = json_set(week_data, ?, ?)
+			WHERE id = ? AND json_extract(week_data, ?) IS NULL
+		`, path, cookedAt.Format(time.RFC3339Nano), planID, path)
+		if err != nil {
+			return fmt.Errorf("failed to update meal plan: %w", err)
+		}
+		claimed, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to update meal plan: %w", err)
+		}
+		if claimed == 0 {
+			return models.ErrMealAlreadyCooked
+		}
+
+		if userID != "" {
+			consumptions, err = s.pantry.consumeInTx(tx, userID, ingredients, scale)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	daily.CookedAt = &cookedAt
+	plan.WeekData.DailyRecipes[day] = daily
+	return plan, consumptions, nil
+}
+
 // ListMealPlans lists meal plans with pagination
 func (s *MealPlannerService) ListMealPlans(limit, offset int) ([]*models.MealPlan, error) {
 	// Set reasonable limits
@@ -1,12 +1,20 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"lazychef/internal/config"
 	"lazychef/internal/database"
+	"lazychef/internal/models"
 )
 
 func TestNewMealPlannerService(t *testing.T) {
@@ -443,3 +451,439 @@ func TestMealPlannerService_CreateMealPlan_Structure(t *testing.T) {
 	}
 }
 */
+
+// setupSchemaDatabase creates a temporary database initialised with scripts/init_db.sql
+func setupSchemaDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "scripts", "init_db.sql"))
+	require.NoError(t, err)
+
+	db, err := database.New(database.Config{Path: filepath.Join(t.TempDir(), "recipes.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+
+	return db
+}
+
+// insertTestRecipe stores recipe data and returns its ID
+func insertTestRecipe(t *testing.T, db *database.Database, recipe models.RecipeData) int {
+	t.Helper()
+
+	repo := NewRecipeRepository(db)
+	stored := &models.Recipe{Data: recipe}
+	require.NoError(t, repo.SaveRecipe(stored))
+	return stored.ID
+}
+
+func TestMealPlannerService_CreateWeeklyPlan_UsesLibraryRecipes(t *testing.T) {
+	db := setupSchemaDatabase(t)
+
+	newRecipe := func(title string, cookingTime int, laziness float64, tags []string, ingredients ...string) models.RecipeData {
+		recipe := models.RecipeData{
+			Title:         title,
+			CookingTime:   cookingTime,
+			Steps:         []string{"作る"},
+			Tags:          tags,
+			Season:        "all",
+			LazinessScore: laziness,
+			ServingSize:   models.FlexibleInt(1),
+		}
+		for _, name := range ingredients {
+			recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Amount: "100g"})
+		}
+		return recipe
+	}
+
+	allowed := map[int]string{
+		insertTestRecipe(t, db, newRecipe("鶏むね肉のレンジ蒸し", 10, 9.0, []string{"レンジ"}, "鶏むね肉")):     "鶏むね肉のレンジ蒸し",
+		insertTestRecipe(t, db, newRecipe("豚こまキャベツ", 10, 8.0, []string{"簡単"}, "豚こま肉", "キャベツ")): "豚こまキャベツ",
+		insertTestRecipe(t, db, newRecipe("冷奴", 3, 7.0, nil, "豆腐")):                            "冷奴",
+		insertTestRecipe(t, db, newRecipe("もやしナムル", 5, 6.5, nil, "もやし", "ごま油")):                "もやしナムル",
+		insertTestRecipe(t, db, newRecipe("卵かけご飯", 2, 6.0, []string{"簡単"}, "卵", "ご飯")):         "卵かけご飯",
+		insertTestRecipe(t, db, newRecipe("トマトサラダ", 5, 5.0, nil, "トマト")):                       "トマトサラダ",
+	}
+	excludedID := insertTestRecipe(t, db, newRecipe("パクチーサラダ", 5, 10.0, nil, "パクチー"))
+	slowID := insertTestRecipe(t, db, newRecipe("煮込みカレー", 90, 10.0, nil, "玉ねぎ"))
+
+	service := NewMealPlannerService(db, nil)
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate: "2025-01-27",
+		Preferences: models.MealPlanPreferences{
+			MaxCookingTime:     15,
+			ExcludeIngredients: []string{"パクチー"},
+			PreferredTags:      []string{"簡単"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, plan.WeekData.DailyRecipes, len(mealPlanDays))
+
+	for day, daily := range plan.WeekData.DailyRecipes {
+		assert.NotEqual(t, excludedID, daily.RecipeID, "excluded ingredient selected on %s", day)
+		assert.NotEqual(t, slowID, daily.RecipeID, "slow recipe selected on %s", day)
+		assert.Equal(t, allowed[daily.RecipeID], daily.Title)
+	}
+
+	// Recipes with preferred tags come first
+	assert.Equal(t, "豚こまキャベツ", plan.WeekData.DailyRecipes["monday"].Title)
+	assert.Equal(t, "卵かけご飯", plan.WeekData.DailyRecipes["tuesday"].Title)
+}
+
+func TestMealPlannerService_CreateWeeklyPlan_FallbackRecipesAreSaved(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	service := NewMealPlannerService(db, nil)
+
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{StartDate: "2025-07-01"})
+	require.NoError(t, err)
+	require.Len(t, plan.WeekData.DailyRecipes, len(mealPlanDays))
+
+	repo := NewRecipeRepository(db)
+	for _, daily := range plan.WeekData.DailyRecipes {
+		recipe, err := repo.GetRecipe(daily.RecipeID)
+		require.NoError(t, err)
+		assert.Equal(t, daily.Title, recipe.Data.Title)
+	}
+
+	// A second plan reuses the stored fallbacks instead of duplicating them
+	_, err = service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{StartDate: "2025-07-08"})
+	require.NoError(t, err)
+
+	count, err := repo.CountRecipes()
+	require.NoError(t, err)
+	assert.Equal(t, len(mealPlanDays), count)
+}
+
+func TestSeasonForDate(t *testing.T) {
+	tests := map[string]string{
+		"2025-01-27": "winter",
+		"2025-04-10": "spring",
+		"2025-07-01": "summer",
+		"2025-10-16": "fall",
+		"2025-12-31": "winter",
+	}
+
+	for date, expected := range tests {
+		assert.Equal(t, expected, seasonForDate(date), date)
+	}
+}
+
+func TestMealPlannerService_CreateWeeklyPlan_UsesStoredPreferences(t *testing.T) {
+	db := setupSchemaDatabase(t)
+
+	stored := models.GetDefaultPreferences()
+	stored.MaxCookingTime = 20
+	stored.CookingSkillLevel = "intermediate"
+	stored.AllergyInfo = []string{"えび"}
+	_, err := NewUserPreferencesRepository(db).SavePreferences("alice", stored)
+	require.NoError(t, err)
+
+	newRecipe := func(title string, cookingTime int, ingredient string) models.RecipeData {
+		return models.RecipeData{
+			Title:         title,
+			CookingTime:   cookingTime,
+			Ingredients:   []models.Ingredient{{Name: ingredient, Amount: "100g"}},
+			Steps:         []string{"作る"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		}
+	}
+	shrimpID := insertTestRecipe(t, db, newRecipe("えびマヨ", 10, "えび"))
+	slowID := insertTestRecipe(t, db, newRecipe("肉じゃが", 30, "じゃがいも"))
+	quickID := insertTestRecipe(t, db, newRecipe("豚しゃぶ", 15, "豚肉"))
+
+	service := NewMealPlannerService(db, nil)
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate: "2025-01-27",
+		UserID:    "alice",
+	})
+	require.NoError(t, err)
+
+	ids := make(map[int]bool)
+	for _, daily := range plan.WeekData.DailyRecipes {
+		ids[daily.RecipeID] = true
+	}
+	assert.True(t, ids[quickID], "recipes within the stored time limit should be used")
+	assert.False(t, ids[shrimpID], "allergens from stored preferences must be excluded")
+	assert.False(t, ids[slowID], "stored max cooking time should apply")
+}
+
+func TestMealPlannerService_ShoppingListSubtractsPantry(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	pantry := NewPantryService(db, NewIngredientAggregator())
+	for _, req := range []models.PantryItemRequest{
+		{Ingredient: "豚こま肉", Quantity: "150g"},
+		{Ingredient: "卵", Quantity: "6個"},
+	} {
+		_, err := pantry.AddItem("alice", req)
+		require.NoError(t, err)
+	}
+
+	recipeID := insertTestRecipe(t, db, models.RecipeData{
+		Title:       "豚玉炒め",
+		CookingTime: 10,
+		Ingredients: []models.Ingredient{
+			{Name: "豚こま肉", Amount: "200g"},
+			{Name: "卵", Amount: "2個"},
+			{Name: "キャベツ", Amount: "1/4個"},
+		},
+		Steps:         []string{"炒める"},
+		LazinessScore: 8.0,
+		ServingSize:   models.FlexibleInt(1),
+	})
+
+	service := NewMealPlannerService(db, nil)
+
+	items, err := service.GenerateShoppingListFromRecipeIDs([]int{recipeID}, "alice")
+	require.NoError(t, err)
+
+	byName := make(map[string]models.ShoppingItem)
+	for _, item := range items {
+		byName[item.Item] = item
+	}
+	assert.NotContains(t, byName, "卵", "fully stocked items are not bought")
+	assert.Equal(t, "50g", byName["豚こま肉"].Amount)
+	assert.Equal(t, "150g", byName["豚こま肉"].PantryCovered)
+	assert.Empty(t, byName["キャベツ"].PantryCovered)
+
+	// Without a user the pantry is ignored
+	items, err = service.GenerateShoppingListFromRecipeIDs([]int{recipeID}, "")
+	require.NoError(t, err)
+	assert.Len(t, items, 3)
+}
+
+func TestMealPlannerService_MarkMealCooked(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	pantry := NewPantryService(db, NewIngredientAggregator())
+	porkItem, err := pantry.AddItem("alice", models.PantryItemRequest{Ingredient: "豚こま肉", Quantity: "500g"})
+	require.NoError(t, err)
+
+	service := NewMealPlannerService(db, nil)
+	for i := 0; i < len(mealPlanDays); i++ {
+		insertTestRecipe(t, db, models.RecipeData{
+			Title:         fmt.Sprintf("豚こま炒め%d", i),
+			CookingTime:   10,
+			Ingredients:   []models.Ingredient{{Name: "豚こま肉", Amount: "100g"}},
+			Steps:         []string{"炒める"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		})
+	}
+
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate: "2025-01-27",
+		UserID:    "alice",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", plan.WeekData.UserID)
+
+	day := mealPlanDays[0]
+	cooked, consumed, err := service.MarkMealCooked(plan.ID, day, "")
+	require.NoError(t, err)
+	assert.NotNil(t, cooked.WeekData.DailyRecipes[day].CookedAt)
+	require.Len(t, consumed, 1)
+	assert.Equal(t, "100g", consumed[0].Consumed)
+
+	remaining, err := pantry.GetItem("alice", porkItem.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 400.0, remaining.Amount)
+
+	stored, err := service.GetMealPlan(plan.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.WeekData.DailyRecipes[day].CookedAt)
+
+	_, _, err = service.MarkMealCooked(plan.ID, day, "")
+	assert.ErrorIs(t, err, models.ErrMealAlreadyCooked)
+
+	_, _, err = service.MarkMealCooked(plan.ID, "someday", "")
+	assert.ErrorIs(t, err, models.ErrMealDayNotPlanned)
+
+	_, _, err = service.MarkMealCooked(plan.ID+100, day, "")
+	assert.ErrorIs(t, err, models.ErrMealPlanNotFound)
+}
+
+func TestMealPlannerService_MarkMealCooked_ConsumesOnce(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	pantry := NewPantryService(db, NewIngredientAggregator())
+	porkItem, err := pantry.AddItem("alice", models.PantryItemRequest{Ingredient: "豚こま肉", Quantity: "500g"})
+	require.NoError(t, err)
+
+	service := NewMealPlannerService(db, nil)
+	for i := 0; i < len(mealPlanDays); i++ {
+		insertTestRecipe(t, db, models.RecipeData{
+			Title:         fmt.Sprintf("豚こま炒め%d", i),
+			CookingTime:   10,
+			Ingredients:   []models.Ingredient{{Name: "豚こま肉", Amount: "100g"}},
+			Steps:         []string{"炒める"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		})
+	}
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{StartDate: "2025-01-27", UserID: "alice"})
+	require.NoError(t, err)
+	day := mealPlanDays[0]
+
+	// A failed pantry update leaves the day uncooked
+	_, err = db.Exec(`ALTER TABLE pantry_items RENAME TO pantry_items_hidden`)
+	require.NoError(t, err)
+	_, _, err = service.MarkMealCooked(plan.ID, day, "")
+	require.Error(t, err)
+	stored, err := service.GetMealPlan(plan.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.WeekData.DailyRecipes[day].CookedAt)
+	_, err = db.Exec(`ALTER TABLE pantry_items_hidden RENAME TO pantry_items`)
+	require.NoError(t, err)
+
+	// Concurrent requests decrement the pantry once between them
+	var wg sync.WaitGroup
+	var succeeded int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := service.MarkMealCooked(plan.ID, day, ""); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), succeeded)
+
+	remaining, err := pantry.GetItem("alice", porkItem.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 400.0, remaining.Amount)
+
+	stored, err = service.GetMealPlan(plan.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.WeekData.DailyRecipes[day].CookedAt)
+	_, _, err = service.MarkMealCooked(plan.ID, day, "")
+	assert.ErrorIs(t, err, models.ErrMealAlreadyCooked)
+}
+
+func TestMealPlannerService_MarkMealCooked_ScalesToHousehold(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	pantry := NewPantryService(db, NewIngredientAggregator())
+	porkItem, err := pantry.AddItem("alice", models.PantryItemRequest{Ingredient: "豚こま肉", Quantity: "500g"})
+	require.NoError(t, err)
+
+	service := NewMealPlannerService(db, nil)
+	for i := 0; i < len(mealPlanDays); i++ {
+		insertTestRecipe(t, db, models.RecipeData{
+			Title:         fmt.Sprintf("豚こま炒め%d", i),
+			CookingTime:   10,
+			Ingredients:   []models.Ingredient{{Name: "豚こま肉", Amount: "100g"}},
+			Steps:         []string{"炒める"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		})
+	}
+
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate:   "2025-01-27",
+		UserID:      "alice",
+		Preferences: models.MealPlanPreferences{HouseholdSize: 3},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, plan.WeekData.HouseholdSize)
+
+	// The shopping list and the pantry both count 300g for three people
+	_, consumed, err := service.MarkMealCooked(plan.ID, mealPlanDays[0], "")
+	require.NoError(t, err)
+	require.Len(t, consumed, 1)
+	assert.Equal(t, "300g", consumed[0].Consumed)
+
+	remaining, err := pantry.GetItem("alice", porkItem.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 200.0, remaining.Amount)
+}
+
+func TestMealPlannerService_CreateWeeklyPlan_FallbackRecipesHonourExclusions(t *testing.T) {
+	// An empty library and no generator leave only the static fallbacks
+	service := NewMealPlannerService(setupSchemaDatabase(t), nil)
+
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate:   "2025-07-01",
+		Preferences: models.MealPlanPreferences{ExcludeIngredients: []string{"豚"}},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, plan.WeekData.DailyRecipes)
+
+	repo := NewRecipeRepository(service.db)
+	for day, daily := range plan.WeekData.DailyRecipes {
+		assert.NotEqual(t, "豚キャベツ炒め", daily.Title, day)
+		recipe, err := repo.GetRecipe(daily.RecipeID)
+		require.NoError(t, err)
+		for _, ingredient := range recipe.Data.Ingredients {
+			assert.NotContains(t, ingredient.Name, "豚", day)
+		}
+	}
+}
+
+func TestMealPlannerService_CreateWeeklyPlan_ExcludesHiddenAllergens(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+
+	newRecipe := func(title string, ingredients ...string) models.RecipeData {
+		recipe := models.RecipeData{
+			Title:         title,
+			CookingTime:   10,
+			Steps:         []string{"作る"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		}
+		for _, name := range ingredients {
+			recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Amount: "100g"})
+		}
+		return recipe
+	}
+	mayoID := insertTestRecipe(t, db, newRecipe("ブロッコリーのマヨ和え", "ブロッコリー", "マヨネーズ"))
+	safeID := insertTestRecipe(t, db, newRecipe("豚こまキャベツ", "豚こま肉", "キャベツ"))
+
+	service := NewMealPlannerService(db, nil)
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate:   "2025-01-27",
+		Preferences: models.MealPlanPreferences{Allergies: []string{"たまご"}},
+	})
+	require.NoError(t, err)
+
+	ids := make(map[int]bool)
+	for _, daily := range plan.WeekData.DailyRecipes {
+		ids[daily.RecipeID] = true
+	}
+	assert.True(t, ids[safeID])
+	assert.False(t, ids[mayoID], "マヨネーズ contains 卵 through the ingredient hierarchy")
+}
+
+func TestMealPlannerService_CreateWeeklyPlan_EnforcesDietaryRestrictions(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+
+	dashiID := insertTestRecipe(t, db, dietaryRecipe("ほうれん草のおひたし", "ほうれん草", "ほんだし"))
+	porkID := insertTestRecipe(t, db, dietaryRecipe("豚こまキャベツ", "豚こま肉", "キャベツ"))
+	tofuID := insertTestRecipe(t, db, dietaryRecipe("冷奴", "豆腐", "ねぎ"))
+
+	stored := models.GetDefaultPreferences()
+	stored.DietaryRestrictions = []string{"ベジタリアン"}
+	_, err := NewUserPreferencesRepository(db).SavePreferences("alice", stored)
+	require.NoError(t, err)
+
+	service := NewMealPlannerService(db, nil)
+	plan, err := service.CreateWeeklyPlan(context.Background(), models.CreateMealPlanRequest{
+		StartDate: "2025-01-27",
+		UserID:    "alice",
+	})
+	require.NoError(t, err)
+
+	ids := make(map[int]bool)
+	for _, daily := range plan.WeekData.DailyRecipes {
+		ids[daily.RecipeID] = true
+	}
+	assert.True(t, ids[tofuID])
+	assert.False(t, ids[porkID])
+	assert.False(t, ids[dashiID], "ほんだし is fish stock")
+}
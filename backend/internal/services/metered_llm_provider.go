@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// MeteredProvider wraps an LLMProvider so every chat completion and
+// embedding call passes the TokenRateLimiter budget gate first and has its
+// token usage and cost recorded afterwards
+type MeteredProvider struct {
+	LLMProvider
+	limiter *TokenRateLimiter
+}
+
+// NewMeteredProvider wraps provider so its calls are gated and metered by limiter
+func NewMeteredProvider(provider LLMProvider, limiter *TokenRateLimiter) *MeteredProvider {
+	return &MeteredProvider{LLMProvider: provider, limiter: limiter}
+}
+
+// CreateChatCompletion admits the call through the budget gate, calls the
+// wrapped provider and records usage on success
+func (p *MeteredProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	maxTokens := req.MaxCompletionTokens
+	if maxTokens == 0 {
+		maxTokens = req.MaxTokens
+	}
+	if err := p.limiter.Admit(ctx, p.limiter.EstimateTokenUsage(req.Messages, req.Model, maxTokens)); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	resp, err := p.LLMProvider.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	model := req.Model
+	if model == "" {
+		model = resp.Model
+	}
+	p.limiter.RecordCall(ctx, p.Name(), model, "chat", resp.Usage)
+	return resp, nil
+}
+
+// CreateEmbeddings admits the call through the budget gate, calls the
+// wrapped provider and records usage on success
+func (p *MeteredProvider) CreateEmbeddings(ctx context.Context, req openai.EmbeddingRequest) (openai.EmbeddingResponse, error) {
+	if err := p.limiter.Admit(ctx, p.estimateEmbedding(req)); err != nil {
+		return openai.EmbeddingResponse{}, err
+	}
+
+	resp, err := p.LLMProvider.CreateEmbeddings(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	model := string(req.Model)
+	if model == "" {
+		model = string(resp.Model)
+	}
+	p.limiter.RecordCall(ctx, p.Name(), model, "embedding", resp.Usage)
+	return resp, nil
+}
+
+// estimateEmbedding approximates embedding input tokens at ~4 characters per token
+func (p *MeteredProvider) estimateEmbedding(req openai.EmbeddingRequest) TokenUsageEstimate {
+	chars := 0
+	switch input := req.Input.(type) {
+	case string:
+		chars = len(input)
+	case []string:
+		chars = len(strings.Join(input, ""))
+	}
+	tokens := chars / 4
+	return TokenUsageEstimate{
+		EstimatedPromptTokens: tokens,
+		EstimatedTotalTokens:  tokens,
+		EstimatedCostUSD:      p.limiter.estimateCost(string(req.Model), tokens, 0),
+		Model:                 string(req.Model),
+	}
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+
+	"lazychef/internal/database"
+)
+
+// ErrInvalidModelPrice is returned when a price entry is incomplete or negative
+var ErrInvalidModelPrice = errors.New("invalid model price")
+
+// Price sources, in increasing order of precedence
+const (
+	PriceSourceDefault = "default"
+	PriceSourceFile    = "file"
+	PriceSourceAdmin   = "admin"
+)
+
+// pricingDateFormat is accepted for effective dates alongside RFC3339
+const pricingDateFormat = "2006-01-02"
+
+// ModelPrice is one version of a model's per-token prices. Model is either an
+// exact model name or a prefix ending in "*"; the most specific match wins.
+type ModelPrice struct {
+	Model            string    `json:"model"`
+	EffectiveFrom    time.Time `json:"effective_from"`
+	InputPer1M       float64   `json:"input_per_1m"`        // USD per 1M uncached prompt tokens
+	CachedInputPer1M float64   `json:"cached_input_per_1m"` // USD per 1M cached prompt tokens; 0 bills them as input
+	OutputPer1M      float64   `json:"output_per_1m"`       // USD per 1M completion tokens
+	BatchDiscount    float64   `json:"batch_discount"`      // Fraction taken off for Batch API requests, e.g. 0.5
+	Source           string    `json:"source"`
+}
+
+// ModelPriceSpec is a price entry as written in a pricing file or sent to the
+// admin API. EffectiveFrom is RFC3339 or YYYY-MM-DD.
+type ModelPriceSpec struct {
+	Model            string  `json:"model" yaml:"model"`
+	EffectiveFrom    string  `json:"effective_from" yaml:"effective_from"`
+	InputPer1M       float64 `json:"input_per_1m" yaml:"input_per_1m"`
+	CachedInputPer1M float64 `json:"cached_input_per_1m" yaml:"cached_input_per_1m"`
+	OutputPer1M      float64 `json:"output_per_1m" yaml:"output_per_1m"`
+	BatchDiscount    float64 `json:"batch_discount" yaml:"batch_discount"`
+}
+
+// pricingFile is the layout of a YAML or JSON pricing file
+type pricingFile struct {
+	Prices []ModelPriceSpec `json:"prices" yaml:"prices"`
+}
+
+// ToModelPrice validates the spec and converts it to a ModelPrice
+func (s ModelPriceSpec) ToModelPrice(source string) (ModelPrice, error) {
+	price := ModelPrice{
+		Model:            strings.TrimSpace(s.Model),
+		InputPer1M:       s.InputPer1M,
+		CachedInputPer1M: s.CachedInputPer1M,
+		OutputPer1M:      s.OutputPer1M,
+		BatchDiscount:    s.BatchDiscount,
+		Source:           source,
+	}
+	if price.Model == "" {
+		return price, fmt.Errorf("%w: model is required", ErrInvalidModelPrice)
+	}
+	if s.EffectiveFrom == "" {
+		return price, fmt.Errorf("%w: effective_from is required for %s", ErrInvalidModelPrice, price.Model)
+	}
+
+	effective, err := time.Parse(time.RFC3339, s.EffectiveFrom)
+	if err != nil {
+		if effective, err = time.Parse(pricingDateFormat, s.EffectiveFrom); err != nil {
+			return price, fmt.Errorf("%w: effective_from %q is not RFC3339 or YYYY-MM-DD", ErrInvalidModelPrice, s.EffectiveFrom)
+		}
+	}
+	price.EffectiveFrom = effective.UTC()
+
+	if price.InputPer1M < 0 || price.CachedInputPer1M < 0 || price.OutputPer1M < 0 {
+		return price, fmt.Errorf("%w: prices for %s must not be negative", ErrInvalidModelPrice, price.Model)
+	}
+	if price.BatchDiscount < 0 || price.BatchDiscount >= 1 {
+		return price, fmt.Errorf("%w: batch_discount for %s must be in [0, 1)", ErrInvalidModelPrice, price.Model)
+	}
+	return price, nil
+}
+
+// Cost returns the USD cost of usage at these prices. Cached prompt tokens
+// are taken from usage.PromptTokensDetails when the provider reports them.
+func (p ModelPrice) Cost(usage openai.Usage, batch bool) float64 {
+	cached := 0
+	if usage.PromptTokensDetails != nil {
+		cached = min(usage.PromptTokensDetails.CachedTokens, usage.PromptTokens)
+	}
+	cachedPrice := p.CachedInputPer1M
+	if cachedPrice == 0 {
+		cachedPrice = p.InputPer1M
+	}
+
+	cost := (float64(usage.PromptTokens-cached)*p.InputPer1M +
+		float64(cached)*cachedPrice +
+		float64(usage.CompletionTokens)*p.OutputPer1M) / 1_000_000
+	if batch {
+		cost *= 1 - p.BatchDiscount
+	}
+	return cost
+}
+
+// matches reports whether the entry applies to model
+func (p ModelPrice) matches(model string) bool {
+	if prefix, ok := strings.CutSuffix(p.Model, "*"); ok {
+		return strings.HasPrefix(model, prefix)
+	}
+	return p.Model == model
+}
+
+// specificity ranks exact names above prefixes, and longer prefixes above shorter ones
+func (p ModelPrice) specificity() int {
+	if strings.HasSuffix(p.Model, "*") {
+		return len(p.Model) - 1
+	}
+	return 1 << 16
+}
+
+// DefaultModelPrices returns the built-in list prices in USD per 1M tokens.
+// Unknown models fall back to the "*" entry so usage is never free by accident.
+func DefaultModelPrices() []ModelPrice {
+	launch := func(date string) time.Time {
+		t, _ := time.Parse(pricingDateFormat, date)
+		return t
+	}
+	prices := []ModelPrice{
+		{Model: "*", EffectiveFrom: launch("2023-01-01"), InputPer1M: 0.5, OutputPer1M: 1.5},
+		{Model: "gpt-3.5-turbo*", EffectiveFrom: launch("2023-01-01"), InputPer1M: 0.5, OutputPer1M: 1.5},
+		{Model: "gpt-4*", EffectiveFrom: launch("2023-03-14"), InputPer1M: 30, OutputPer1M: 60},
+		{Model: "gpt-4-turbo*", EffectiveFrom: launch("2023-11-06"), InputPer1M: 10, OutputPer1M: 30},
+		{Model: "gpt-4o*", EffectiveFrom: launch("2024-05-13"), InputPer1M: 5, OutputPer1M: 15},
+		{Model: "gpt-4o*", EffectiveFrom: launch("2024-10-01"), InputPer1M: 2.5, CachedInputPer1M: 1.25, OutputPer1M: 10},
+		{Model: "gpt-4o-mini*", EffectiveFrom: launch("2024-07-18"), InputPer1M: 0.15, CachedInputPer1M: 0.075, OutputPer1M: 0.6},
+		{Model: "gpt-5*", EffectiveFrom: launch("2025-08-07"), InputPer1M: 1.25, CachedInputPer1M: 0.125, OutputPer1M: 10},
+		{Model: "gpt-5-mini*", EffectiveFrom: launch("2025-08-07"), InputPer1M: 0.25, CachedInputPer1M: 0.025, OutputPer1M: 2},
+		{Model: "gpt-5-nano*", EffectiveFrom: launch("2025-08-07"), InputPer1M: 0.05, CachedInputPer1M: 0.005, OutputPer1M: 0.4},
+		{Model: "text-embedding-3-small", EffectiveFrom: launch("2024-01-25"), InputPer1M: 0.02},
+		{Model: "text-embedding-3-large", EffectiveFrom: launch("2024-01-25"), InputPer1M: 0.13},
+		{Model: "text-embedding-ada-002", EffectiveFrom: launch("2022-12-15"), InputPer1M: 0.1},
+	}
+	for i := range prices {
+		prices[i].BatchDiscount = 0.5
+		prices[i].Source = PriceSourceDefault
+	}
+	return prices
+}
+
+// PricingTable holds versioned model prices. Entries are keyed by model and
+// effective date, so adding a new version never changes what earlier calls
+// cost. Entries added through the admin API are persisted when a database is
+// attached.
+type PricingTable struct {
+	mu     sync.RWMutex
+	prices []ModelPrice
+	db     *database.Database
+}
+
+// NewPricingTable creates a table seeded with DefaultModelPrices. db may be
+// nil, in which case admin edits last until restart.
+func NewPricingTable(db *database.Database) *PricingTable {
+	p := &PricingTable{db: db}
+	for _, price := range DefaultModelPrices() {
+		p.put(price)
+	}
+	return p
+}
+
+// EnsureSchema creates the model_prices table if it doesn't exist
+func (p *PricingTable) EnsureSchema() error {
+	if p.db == nil {
+		return nil
+	}
+	_, err := p.db.Exec(`
+		CREATE TABLE IF NOT EXISTS model_prices (
+			model TEXT NOT NULL,
+			effective_from TEXT NOT NULL, -- RFC3339, UTC
+			input_per_1m REAL NOT NULL,
+			cached_input_per_1m REAL NOT NULL DEFAULT 0,
+			output_per_1m REAL NOT NULL DEFAULT 0,
+			batch_discount REAL NOT NULL DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (model, effective_from)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create model_prices: %w", err)
+	}
+	return nil
+}
+
+// LoadSaved loads prices previously set through the admin API
+func (p *PricingTable) LoadSaved() error {
+	if p.db == nil {
+		return nil
+	}
+	rows, err := p.db.Query(`
+		SELECT model, effective_from, input_per_1m, cached_input_per_1m, output_per_1m, batch_discount
+		FROM model_prices
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load model prices: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	var loaded []ModelPrice
+	for rows.Next() {
+		var spec ModelPriceSpec
+		if err := rows.Scan(&spec.Model, &spec.EffectiveFrom, &spec.InputPer1M, &spec.CachedInputPer1M,
+			&spec.OutputPer1M, &spec.BatchDiscount); err != nil {
+			return fmt.Errorf("failed to scan model price: %w", err)
+		}
+		price, err := spec.ToModelPrice(PriceSourceAdmin)
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, price)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load model prices: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, price := range loaded {
+		p.put(price)
+	}
+	return nil
+}
+
+// LoadFile adds the prices in a YAML (.yaml, .yml) or JSON file, returning how
+// many entries it contained. File entries are not persisted.
+func (p *PricingTable) LoadFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read pricing file: %w", err)
+	}
+
+	var file pricingFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse pricing file %s: %w", path, err)
+	}
+
+	prices := make([]ModelPrice, 0, len(file.Prices))
+	for _, spec := range file.Prices {
+		price, err := spec.ToModelPrice(PriceSourceFile)
+		if err != nil {
+			return 0, err
+		}
+		prices = append(prices, price)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, price := range prices {
+		p.put(price)
+	}
+	return len(prices), nil
+}
+
+// SetPrice adds or replaces the price version for spec's model and
+// effective date, persisting it when a database is attached
+func (p *PricingTable) SetPrice(spec ModelPriceSpec) (*ModelPrice, error) {
+	price, err := spec.ToModelPrice(PriceSourceAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.db != nil {
+		_, err := p.db.Exec(`
+			INSERT OR REPLACE INTO model_prices
+			(model, effective_from, input_per_1m, cached_input_per_1m, output_per_1m, batch_discount, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, price.Model, price.EffectiveFrom.Format(time.RFC3339), price.InputPer1M, price.CachedInputPer1M,
+			price.OutputPer1M, price.BatchDiscount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save model price: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	p.put(price)
+	p.mu.Unlock()
+	return &price, nil
+}
+
+// Prices returns every price version, ordered by model then effective date
+func (p *PricingTable) Prices() []ModelPrice {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]ModelPrice(nil), p.prices...)
+}
+
+// Lookup returns the price in effect for model at the given time
+func (p *PricingTable) Lookup(model string, at time.Time) (ModelPrice, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var best ModelPrice
+	found := false
+	for _, price := range p.prices {
+		if !price.matches(model) || price.EffectiveFrom.After(at) {
+			continue
+		}
+		if !found || price.specificity() > best.specificity() ||
+			(price.specificity() == best.specificity() && price.EffectiveFrom.After(best.EffectiveFrom)) {
+			best, found = price, true
+		}
+	}
+	return best, found
+}
+
+// Cost prices usage of model at the given time; models with no matching
+// entry cost nothing
+func (p *PricingTable) Cost(model string, at time.Time, usage openai.Usage, batch bool) float64 {
+	price, ok := p.Lookup(model, at)
+	if !ok {
+		return 0
+	}
+	return price.Cost(usage, batch)
+}
+
+// put inserts price, replacing any entry with the same model and effective
+// date. Callers hold p.mu, except during construction.
+func (p *PricingTable) put(price ModelPrice) {
+	for i, existing := range p.prices {
+		if existing.Model == price.Model && existing.EffectiveFrom.Equal(price.EffectiveFrom) {
+			p.prices[i] = price
+			return
+		}
+	}
+	p.prices = append(p.prices, price)
+	sort.SliceStable(p.prices, func(i, j int) bool {
+		if p.prices[i].Model != p.prices[j].Model {
+			return p.prices[i].Model < p.prices[j].Model
+		}
+		return p.prices[i].EffectiveFrom.Before(p.prices[j].EffectiveFrom)
+	})
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricingTable_LookupPicksVersionInEffect(t *testing.T) {
+	pricing := NewPricingTable(nil)
+
+	before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	price, ok := pricing.Lookup("gpt-4o-2024-08-06", before)
+	require.True(t, ok)
+	assert.Equal(t, 5.0, price.InputPer1M)
+	price, ok = pricing.Lookup("gpt-4o-2024-08-06", after)
+	require.True(t, ok)
+	assert.Equal(t, 2.5, price.InputPer1M)
+
+	// The longest matching prefix wins over shorter ones
+	price, ok = pricing.Lookup("gpt-4o-mini", after)
+	require.True(t, ok)
+	assert.Equal(t, "gpt-4o-mini*", price.Model)
+
+	price, ok = pricing.Lookup("some-unknown-model", after)
+	require.True(t, ok)
+	assert.Equal(t, "*", price.Model)
+}
+
+func TestModelPrice_CostSplitsCachedInputAndAppliesBatchDiscount(t *testing.T) {
+	price := ModelPrice{InputPer1M: 1.0, CachedInputPer1M: 0.1, OutputPer1M: 4.0, BatchDiscount: 0.5}
+	usage := openai.Usage{
+		PromptTokens:        1_000_000,
+		CompletionTokens:    500_000,
+		PromptTokensDetails: &openai.PromptTokensDetails{CachedTokens: 400_000},
+	}
+
+	assert.InDelta(t, 0.6+0.04+2.0, price.Cost(usage, false), 1e-9)
+	assert.InDelta(t, (0.6+0.04+2.0)*0.5, price.Cost(usage, true), 1e-9)
+
+	// Without a cached price, cached tokens are billed as input
+	price.CachedInputPer1M = 0
+	assert.InDelta(t, 1.0+2.0, price.Cost(usage, false), 1e-9)
+}
+
+func TestPricingTable_AdminPricesPersistAndKeepHistory(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	pricing := NewPricingTable(db)
+	require.NoError(t, pricing.EnsureSchema())
+
+	usage := openai.Usage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000}
+	august := time.Date(2025, 8, 20, 0, 0, 0, 0, time.UTC)
+	original := pricing.Cost("gpt-5-mini", august, usage, false)
+
+	_, err := pricing.SetPrice(ModelPriceSpec{
+		Model: "gpt-5-mini*", EffectiveFrom: "2025-10-01", InputPer1M: 0.2, OutputPer1M: 1.6, BatchDiscount: 0.5,
+	})
+	require.NoError(t, err)
+
+	october := time.Date(2025, 10, 2, 0, 0, 0, 0, time.UTC)
+	assert.InDelta(t, 1.8, pricing.Cost("gpt-5-mini", october, usage, false), 1e-9)
+	assert.Equal(t, original, pricing.Cost("gpt-5-mini", august, usage, false), "earlier calls keep their price")
+
+	// A new table over the same database sees the admin price
+	reloaded := NewPricingTable(db)
+	require.NoError(t, reloaded.LoadSaved())
+	price, ok := reloaded.Lookup("gpt-5-mini", october)
+	require.True(t, ok)
+	assert.Equal(t, PriceSourceAdmin, price.Source)
+	assert.Equal(t, 0.2, price.InputPer1M)
+
+	_, err = pricing.SetPrice(ModelPriceSpec{Model: "gpt-5", EffectiveFrom: "soon", InputPer1M: 1})
+	assert.ErrorIs(t, err, ErrInvalidModelPrice)
+	_, err = pricing.SetPrice(ModelPriceSpec{Model: "gpt-5", EffectiveFrom: "2025-10-01", InputPer1M: -1})
+	assert.ErrorIs(t, err, ErrInvalidModelPrice)
+}
+
+func TestPricingTable_LoadFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "pricing.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`prices:
+  - model: "my-model"
+    effective_from: 2025-01-01
+    input_per_1m: 2
+    output_per_1m: 8
+    batch_discount: 0.5
+`), 0o600))
+	jsonPath := filepath.Join(dir, "pricing.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"prices": [
+		{"model": "my-model", "effective_from": "2025-06-01T00:00:00Z", "input_per_1m": 1, "output_per_1m": 4}
+	]}`), 0o600))
+
+	pricing := NewPricingTable(nil)
+	n, err := pricing.LoadFile(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = pricing.LoadFile(jsonPath)
+	require.NoError(t, err)
+
+	price, ok := pricing.Lookup("my-model", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, 2.0, price.InputPer1M)
+	assert.Equal(t, PriceSourceFile, price.Source)
+
+	price, ok = pricing.Lookup("my-model", time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, 1.0, price.InputPer1M)
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// pantryEpsilon absorbs float rounding when comparing stock amounts
+const pantryEpsilon = 1e-9
+
+// PantryService manages each user's pantry inventory
+type PantryService struct {
+	db         *database.Database
+	aggregator *IngredientAggregator
+	now        func() time.Time
+}
+
+// NewPantryService creates a new pantry service
+func NewPantryService(db *database.Database, aggregator *IngredientAggregator) *PantryService {
+	return &PantryService{
+		db:         db,
+		aggregator: aggregator,
+		now:        time.Now,
+	}
+}
+
+// EnsureSchema creates pantry_items, its indexes and its updated_at trigger
+// on databases initialized from an init_db.sql that predates the pantry
+func (s *PantryService) EnsureSchema() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS pantry_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL DEFAULT 'default_user',
+			ingredient TEXT NOT NULL,
+			amount REAL NOT NULL DEFAULT 0,
+			unit TEXT NOT NULL,
+			purchase_date DATE,
+			expiry_date DATE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			CHECK (ingredient != ''),
+			CHECK (amount >= 0)
+		);
+		CREATE INDEX IF NOT EXISTS idx_pantry_items_user_ingredient ON pantry_items(user_id, ingredient);
+		CREATE INDEX IF NOT EXISTS idx_pantry_items_expiry_date ON pantry_items(expiry_date);
+		CREATE TRIGGER IF NOT EXISTS update_pantry_items_timestamp
+			AFTER UPDATE ON pantry_items
+			FOR EACH ROW
+		BEGIN
+			UPDATE pantry_items SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+		END;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create pantry_items: %w", err)
+	}
+	return nil
+}
+
+// normalizeQuantity parses an amount string into the base unit used for storage
+func (s *PantryService) normalizeQuantity(amount string) (*IngredientQuantity, error) {
+	qty, err := s.aggregator.ParseQuantity(amount)
+	if err != nil {
+		return nil, err
+	}
+	return s.aggregator.ConvertToBaseUnit(qty)
+}
+
+// ListItems returns the user's pantry items, soonest to expire first
+func (s *PantryService) ListItems(userID string) ([]models.PantryItem, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, ingredient, amount, unit, purchase_date, expiry_date, created_at, updated_at
+		FROM pantry_items
+		WHERE user_id = ?
+		ORDER BY expiry_date IS NULL, expiry_date, ingredient, id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pantry items: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	items := make([]models.PantryItem, 0)
+	for rows.Next() {
+		item, err := s.scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetItem returns a single pantry item of the user
+func (s *PantryService) GetItem(userID string, id int) (*models.PantryItem, error) {
+	row := s.db.QueryRow(`
+		SELECT id, user_id, ingredient, amount, unit, purchase_date, expiry_date, created_at, updated_at
+		FROM pantry_items
+		WHERE user_id = ? AND id = ?
+	`, userID, id)
+
+	item, err := s.scanItem(row)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrPantryItemNotFound
+	}
+	return item, err
+}
+
+// AddItem stores a newly bought ingredient
+func (s *PantryService) AddItem(userID string, req models.PantryItemRequest) (*models.PantryItem, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	qty, err := s.normalizeQuantity(req.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quantity: %w", err)
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO pantry_items (user_id, ingredient, amount, unit, purchase_date, expiry_date)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, req.Ingredient, qty.Amount, qty.Unit, nullableDate(req.PurchaseDate), nullableDate(req.ExpiryDate))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert pantry item: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	return s.GetItem(userID, int(id))
+}
+
+// UpdateItem replaces a pantry item of the user
+func (s *PantryService) UpdateItem(userID string, id int, req models.PantryItemRequest) (*models.PantryItem, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	qty, err := s.normalizeQuantity(req.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quantity: %w", err)
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE pantry_items
+		SET ingredient = ?, amount = ?, unit = ?, purchase_date = ?, expiry_date = ?
+		WHERE user_id = ? AND id = ?
+	`, req.Ingredient, qty.Amount, qty.Unit, nullableDate(req.PurchaseDate), nullableDate(req.ExpiryDate), userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update pantry item: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check updated rows: %w", err)
+	}
+	if affected == 0 {
+		return nil, models.ErrPantryItemNotFound
+	}
+
+	return s.GetItem(userID, id)
+}
+
+// DeleteItem removes a pantry item of the user
+func (s *PantryService) DeleteItem(userID string, id int) error {
+	result, err := s.db.Exec(`DELETE FROM pantry_items WHERE user_id = ? AND id = ?`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete pantry item: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check deleted rows: %w", err)
+	}
+	if affected == 0 {
+		return models.ErrPantryItemNotFound
+	}
+	return nil
+}
+
+// PantryStock holds usable (unexpired) pantry quantities by ingredient name
+type PantryStock map[string][]*IngredientQuantity
+
+// Stock returns the user's unexpired pantry contents
+func (s *PantryService) Stock(userID string) (PantryStock, error) {
+	items, err := s.ListItems(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := make(PantryStock)
+	today := s.now()
+	for _, item := range items {
+		if item.IsExpired(today) {
+			continue
+		}
+		stock[item.Ingredient] = append(stock[item.Ingredient], &IngredientQuantity{Amount: item.Amount, Unit: item.Unit})
+	}
+	return stock, nil
+}
+
+// Cover works out how much of a needed quantity the stock already holds.
+// It returns the quantity still to buy (nil when fully covered) and the
+// quantity taken from the pantry (nil when nothing was available). A "適量"
+// need, or "適量" stock, counts as covered by any stock of the ingredient.
+func (stock PantryStock) Cover(aggregator *IngredientAggregator, ingredient string, need *IngredientQuantity) (remaining, covered *IngredientQuantity) {
+	held := stock[ingredient]
+	if len(held) == 0 {
+		return need, nil
+	}
+
+	if need.Unit == "適量" {
+		return nil, need
+	}
+	for _, qty := range held {
+		if qty.Unit == "適量" {
+			return nil, need
+		}
+	}
+
+	base, err := aggregator.ConvertToBaseUnit(need)
+	if err != nil {
+		return need, nil
+	}
+
+	available := 0.0
+	for _, qty := range held {
+		if qty.Unit == base.Unit {
+			available += qty.Amount
+		}
+	}
+	if available <= pantryEpsilon {
+		return need, nil
+	}
+
+	if available+pantryEpsilon >= base.Amount {
+		return nil, need
+	}
+
+	remaining = aggregator.ConvertToDisplayUnit(&IngredientQuantity{Amount: base.Amount - available, Unit: base.Unit})
+	covered = aggregator.ConvertToDisplayUnit(&IngredientQuantity{Amount: available, Unit: base.Unit})
+	return remaining, covered
+}
+
+// Consume takes the ingredients of a cooked recipe out of the user's pantry,
+// using the items that expire soonest first. Items that run out are removed.
+// Amounts are multiplied by scale, the household scale the recipe was cooked at.
+func (s *PantryService) Consume(userID string, ingredients []models.Ingredient, scale float64) ([]models.PantryConsumption, error) {
+	var consumptions []models.PantryConsumption
+	err := s.db.ExecuteInTx(func(tx *sql.Tx) error {
+		var err error
+		consumptions, err = s.consumeInTx(tx, userID, ingredients, scale)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return consumptions, nil
+}
+
+// consumeInTx is Consume within the caller's transaction
+func (s *PantryService) consumeInTx(tx *sql.Tx, userID string, ingredients []models.Ingredient, scale float64) ([]models.PantryConsumption, error) {
+	consumptions := make([]models.PantryConsumption, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		need, err := s.normalizeQuantity(ingredient.Amount)
+		if err != nil || need.Unit == "適量" {
+			// Unmeasured amounts can't be subtracted
+			continue
+		}
+		need.Amount *= scale
+
+		consumption, err := s.consumeIngredient(tx, userID, ingredient.Name, need)
+		if err != nil {
+			return nil, fmt.Errorf("failed to consume pantry items: %w", err)
+		}
+		if consumption != nil {
+			consumptions = append(consumptions, *consumption)
+		}
+	}
+	return consumptions, nil
+}
+
+// consumeIngredient decrements stock of one ingredient within a transaction
+func (s *PantryService) consumeIngredient(tx *sql.Tx, userID, name string, need *IngredientQuantity) (*models.PantryConsumption, error) {
+	rows, err := tx.Query(`
+		SELECT id, amount FROM pantry_items
+		WHERE user_id = ? AND ingredient = ? AND unit = ?
+		  AND (expiry_date IS NULL OR expiry_date >= ?)
+		ORDER BY expiry_date IS NULL, expiry_date, id
+	`, userID, name, need.Unit, s.now().Format(models.PantryDateFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pantry stock: %w", err)
+	}
+
+	type stockRow struct {
+		id     int
+		amount float64
+	}
+	var stockRows []stockRow
+	for rows.Next() {
+		var row stockRow
+		if err := rows.Scan(&row.id, &row.amount); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan pantry stock: %w", err)
+		}
+		stockRows = append(stockRows, row)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read pantry stock: %w", err)
+	}
+	if len(stockRows) == 0 {
+		return nil, nil
+	}
+
+	left := need.Amount
+	for _, row := range stockRows {
+		if left <= pantryEpsilon {
+			break
+		}
+
+		take := row.amount
+		if take > left {
+			take = left
+		}
+		left -= take
+
+		if row.amount-take <= pantryEpsilon {
+			_, err = tx.Exec(`DELETE FROM pantry_items WHERE id = ?`, row.id)
+		} else {
+			_, err = tx.Exec(`UPDATE pantry_items SET amount = ? WHERE id = ?`, row.amount-take, row.id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to update pantry stock: %w", err)
+		}
+	}
+
+	consumed := need.Amount - left
+	consumption := &models.PantryConsumption{
+		Ingredient: name,
+		Consumed:   s.aggregator.FormatQuantity(s.aggregator.ConvertToDisplayUnit(&IngredientQuantity{Amount: consumed, Unit: need.Unit})),
+	}
+	if left > pantryEpsilon {
+		consumption.Shortfall = s.aggregator.FormatQuantity(s.aggregator.ConvertToDisplayUnit(&IngredientQuantity{Amount: left, Unit: need.Unit}))
+	}
+	return consumption, nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanItem reads a pantry_items row
+func (s *PantryService) scanItem(row rowScanner) (*models.PantryItem, error) {
+	var item models.PantryItem
+	var purchaseDate, expiryDate sql.NullString
+	var createdAt, updatedAt sql.NullTime
+
+	err := row.Scan(&item.ID, &item.UserID, &item.Ingredient, &item.Amount, &item.Unit,
+		&purchaseDate, &expiryDate, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan pantry item: %w", err)
+	}
+
+	item.PurchaseDate = dateOnly(purchaseDate.String)
+	item.ExpiryDate = dateOnly(expiryDate.String)
+	item.CreatedAt = createdAt.Time
+	item.UpdatedAt = updatedAt.Time
+	item.Quantity = s.aggregator.FormatQuantity(s.aggregator.ConvertToDisplayUnit(&IngredientQuantity{Amount: item.Amount, Unit: item.Unit}))
+	return &item, nil
+}
+
+// nullableDate stores empty dates as NULL
+func nullableDate(date string) interface{} {
+	if date == "" {
+		return nil
+	}
+	return date
+}
+
+// dateOnly trims a stored DATE value to YYYY-MM-DD
+func dateOnly(value string) string {
+	if len(value) > len(models.PantryDateFormat) {
+		return value[:len(models.PantryDateFormat)]
+	}
+	return value
+}
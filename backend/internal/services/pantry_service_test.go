@@ -0,0 +1,150 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func setupPantryService(t *testing.T) *PantryService {
+	t.Helper()
+
+	service := NewPantryService(setupSchemaDatabase(t), NewIngredientAggregator())
+	service.now = func() time.Time { return time.Date(2025, 1, 27, 12, 0, 0, 0, time.UTC) }
+	return service
+}
+
+func TestPantryService_CRUD(t *testing.T) {
+	service := setupPantryService(t)
+
+	item, err := service.AddItem("alice", models.PantryItemRequest{
+		Ingredient:   "豚こま肉",
+		Quantity:     "0.5kg",
+		PurchaseDate: "2025-01-25",
+		ExpiryDate:   "2025-01-30",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 500.0, item.Amount)
+	assert.Equal(t, "g", item.Unit)
+	assert.Equal(t, "500g", item.Quantity)
+	assert.Equal(t, "2025-01-30", item.ExpiryDate)
+
+	_, err = service.AddItem("alice", models.PantryItemRequest{Ingredient: "卵", Quantity: "6個"})
+	require.NoError(t, err)
+
+	items, err := service.ListItems("alice")
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "豚こま肉", items[0].Ingredient, "items with an expiry date come first")
+
+	others, err := service.ListItems("bob")
+	require.NoError(t, err)
+	assert.Empty(t, others)
+
+	updated, err := service.UpdateItem("alice", item.ID, models.PantryItemRequest{Ingredient: "豚こま肉", Quantity: "200g"})
+	require.NoError(t, err)
+	assert.Equal(t, 200.0, updated.Amount)
+	assert.Empty(t, updated.ExpiryDate)
+
+	_, err = service.UpdateItem("bob", item.ID, models.PantryItemRequest{Ingredient: "豚こま肉", Quantity: "200g"})
+	assert.ErrorIs(t, err, models.ErrPantryItemNotFound)
+
+	require.NoError(t, service.DeleteItem("alice", item.ID))
+	assert.ErrorIs(t, service.DeleteItem("alice", item.ID), models.ErrPantryItemNotFound)
+
+	_, err = service.AddItem("alice", models.PantryItemRequest{Ingredient: "卵", Quantity: "6個", PurchaseDate: "2025-01-30", ExpiryDate: "2025-01-20"})
+	assert.ErrorIs(t, err, models.ErrInvalidPantryDate)
+}
+
+func TestPantryStock_Cover(t *testing.T) {
+	service := setupPantryService(t)
+	aggregator := service.aggregator
+
+	for _, req := range []models.PantryItemRequest{
+		{Ingredient: "豚こま肉", Quantity: "300g"},
+		{Ingredient: "玉ねぎ", Quantity: "2個"},
+		{Ingredient: "醤油", Quantity: "適量"},
+		{Ingredient: "牛乳", Quantity: "1L", ExpiryDate: "2025-01-20"},
+	} {
+		_, err := service.AddItem("alice", req)
+		require.NoError(t, err)
+	}
+
+	stock, err := service.Stock("alice")
+	require.NoError(t, err)
+	assert.NotContains(t, stock, "牛乳", "expired items are not usable stock")
+
+	remaining, covered := stock.Cover(aggregator, "豚こま肉", &IngredientQuantity{Amount: 500, Unit: "g"})
+	require.NotNil(t, remaining)
+	assert.Equal(t, "200g", aggregator.FormatQuantity(remaining))
+	assert.Equal(t, "300g", aggregator.FormatQuantity(covered))
+
+	remaining, covered = stock.Cover(aggregator, "玉ねぎ", &IngredientQuantity{Amount: 1, Unit: "個"})
+	assert.Nil(t, remaining)
+	assert.NotNil(t, covered)
+
+	remaining, _ = stock.Cover(aggregator, "醤油", &IngredientQuantity{Amount: 2, Unit: "大さじ"})
+	assert.Nil(t, remaining, "適量 stock covers any amount")
+
+	remaining, covered = stock.Cover(aggregator, "キャベツ", &IngredientQuantity{Amount: 1, Unit: "個"})
+	assert.Equal(t, 1.0, remaining.Amount)
+	assert.Nil(t, covered)
+}
+
+func TestPantryService_Consume(t *testing.T) {
+	service := setupPantryService(t)
+
+	older, err := service.AddItem("alice", models.PantryItemRequest{Ingredient: "豚こま肉", Quantity: "100g", ExpiryDate: "2025-01-28"})
+	require.NoError(t, err)
+	newer, err := service.AddItem("alice", models.PantryItemRequest{Ingredient: "豚こま肉", Quantity: "300g", ExpiryDate: "2025-02-05"})
+	require.NoError(t, err)
+	_, err = service.AddItem("alice", models.PantryItemRequest{Ingredient: "卵", Quantity: "1個"})
+	require.NoError(t, err)
+
+	consumed, err := service.Consume("alice", []models.Ingredient{
+		{Name: "豚こま肉", Amount: "250g"},
+		{Name: "卵", Amount: "2個"},
+		{Name: "塩", Amount: "少々"},
+		{Name: "キャベツ", Amount: "1/4個"},
+	}, 1)
+	require.NoError(t, err)
+	require.Len(t, consumed, 2)
+	assert.Equal(t, models.PantryConsumption{Ingredient: "豚こま肉", Consumed: "250g"}, consumed[0])
+	assert.Equal(t, models.PantryConsumption{Ingredient: "卵", Consumed: "1個", Shortfall: "1個"}, consumed[1])
+
+	_, err = service.GetItem("alice", older.ID)
+	assert.ErrorIs(t, err, models.ErrPantryItemNotFound, "the soonest-expiring item is used up first")
+
+	remaining, err := service.GetItem("alice", newer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 150.0, remaining.Amount)
+
+	items, err := service.ListItems("alice")
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+}
+
+func TestPantryService_EnsureSchema(t *testing.T) {
+	service := setupPantryService(t)
+
+	// Databases initialized before the pantry existed have no pantry_items
+	_, err := service.db.Exec("DROP TABLE pantry_items")
+	require.NoError(t, err)
+
+	require.NoError(t, service.EnsureSchema())
+	require.NoError(t, service.EnsureSchema(), "EnsureSchema is idempotent")
+
+	item, err := service.AddItem("alice", models.PantryItemRequest{Ingredient: "卵", Quantity: "6個"})
+	require.NoError(t, err)
+	_, err = service.UpdateItem("alice", item.ID, models.PantryItemRequest{Ingredient: "卵", Quantity: "4個"})
+	require.NoError(t, err)
+
+	items, err := service.ListItems("alice")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, 4.0, items[0].Amount)
+}
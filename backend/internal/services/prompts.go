@@ -1,8 +1,11 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
+
+	"lazychef/internal/models"
 )
 
 // RecipeGenerationRequest represents a request to generate recipes
@@ -13,6 +16,36 @@ type RecipeGenerationRequest struct {
 	Servings       int      `json:"servings,omitempty"`
 	Constraints    []string `json:"constraints,omitempty"`
 	Preferences    []string `json:"preferences,omitempty"`
+	UserID         string   `json:"user_id,omitempty"` // Loads stored user preferences when set
+
+	// Dietary restrictions (vegetarian, vegan, halal, ...) are spelled out in
+	// the prompt and checked again on the generated recipe
+	DietaryRestrictions []string `json:"dietary_restrictions,omitempty"`
+}
+
+// ApplyUserPreferences folds stored user preferences into the request as
+// constraints and preferences the prompt already understands. The stored
+// cooking time limit is scaled by skill level, as for meal plans.
+func (r *RecipeGenerationRequest) ApplyUserPreferences(prefs models.UserPreferencesData) {
+	for _, ingredient := range models.MergeUnique(prefs.ExcludeIngredients, prefs.AllergyInfo) {
+		r.Constraints = append(r.Constraints, fmt.Sprintf("%sを使わない", ingredient))
+	}
+	r.DietaryRestrictions = models.MergeUnique(r.DietaryRestrictions, prefs.DietaryRestrictions)
+	if len(prefs.KitchenEquipment) > 0 {
+		r.Constraints = append(r.Constraints, fmt.Sprintf("使える調理器具: %s", strings.Join(prefs.KitchenEquipment, "、")))
+	}
+
+	r.Preferences = append(r.Preferences, prefs.PreferredTags...)
+	for _, ingredient := range prefs.FavoriteIngredients {
+		r.Preferences = append(r.Preferences, fmt.Sprintf("%sを使うと嬉しい", ingredient))
+	}
+
+	if r.Servings <= 0 && prefs.HouseholdSize > 0 {
+		r.Servings = prefs.HouseholdSize
+	}
+	if limit := prefs.AdjustedMaxCookingTime(); limit > 0 && r.MaxCookingTime > limit {
+		r.MaxCookingTime = limit
+	}
 }
 
 // PromptTemplate holds template configurations for recipe generation
@@ -130,6 +163,15 @@ func formatUserPrompt(req RecipeGenerationRequest) string {
 		prompt.WriteString(fmt.Sprintf("## 制約条件\n%s\n\n", strings.Join(req.Constraints, ", ")))
 	}
 
+	// Dietary restrictions, including the hidden ingredients they rule out
+	if len(req.DietaryRestrictions) > 0 {
+		prompt.WriteString("## 食事制限（厳守）\n")
+		for _, guidance := range DietaryGuidance(req.DietaryRestrictions) {
+			prompt.WriteString(fmt.Sprintf("- %s\n", guidance))
+		}
+		prompt.WriteString("\n")
+	}
+
 	// Preferences
 	if len(req.Preferences) > 0 {
 		prompt.WriteString(fmt.Sprintf("## 好み・要望\n%s\n\n", strings.Join(req.Preferences, ", ")))
@@ -233,6 +275,12 @@ func formatBatchUserPrompt(req RecipeGenerationRequest, count int) string {
 	if len(req.Constraints) > 0 {
 		prompt.WriteString(fmt.Sprintf("\n制約: %s\n", strings.Join(req.Constraints, ", ")))
 	}
+	if len(req.DietaryRestrictions) > 0 {
+		prompt.WriteString("\n食事制限（すべてのレシピで厳守）:\n")
+		for _, guidance := range DietaryGuidance(req.DietaryRestrictions) {
+			prompt.WriteString(fmt.Sprintf("- %s\n", guidance))
+		}
+	}
 
 	return prompt.String()
 }
@@ -280,3 +328,52 @@ JSON形式で日別のレシピ提案を出力：
 		UserPrompt:   userPrompt,
 	}
 }
+
+// safetyRemediationHeading starts the list of problems in a remediation prompt
+const safetyRemediationHeading = "## 食品安全の指摘"
+
+// GetSafetyRemediationPrompt asks the critique model to fix the food safety
+// problems a validation found in a recipe, returning the whole recipe again
+func GetSafetyRemediationPrompt(recipe *models.RecipeData, safety *SafetyCheckResult) (PromptTemplate, error) {
+	systemPrompt := `あなたは食品安全の専門家で、ずぼらレシピの校閲者です。指摘された食品安全上の問題をすべて解消するようにレシピを修正してください。
+
+## 修正のルール
+- 指摘された問題だけを直し、料理の内容・材料・ずぼらさはできるだけ保つ
+- 肉・魚・卵は「中まで火が通るまで」「肉汁が透明になるまで」などの目安か、中心温度（℃）を、その食材を加熱する手順に食材名と一緒に書く
+- 生食・生焼け・常温解凍・漬けだれの使い回しなどの危険な手順は安全な方法に置き換える
+- 手順は3ステップ以内を保つ
+
+## レスポンス形式
+修正後のレシピを、元のレシピと同じJSON形式で1つだけ出力してください。説明文は不要です。`
+
+	data, err := json.MarshalIndent(recipe, "", "  ")
+	if err != nil {
+		return PromptTemplate{}, fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("## 修正するレシピ\n")
+	prompt.Write(data)
+	prompt.WriteString("\n\n" + safetyRemediationHeading + "\n")
+	for _, match := range safety.RuleMatches {
+		if match.Severity == SafetySeverityWarning {
+			continue
+		}
+		prompt.WriteString("- " + match.Text())
+		if match.Matched != "" {
+			prompt.WriteString(fmt.Sprintf("（該当箇所: 「%s」）", match.Matched))
+		}
+		prompt.WriteString("\n")
+	}
+	for _, temp := range safety.RequiredTemps {
+		if temp.SatisfiedBy == "" {
+			prompt.WriteString(fmt.Sprintf("- %s: 中心温度%s以上まで加熱する指示、または「中まで火が通るまで」などの加熱の目安を、この食材を加熱する手順に書く\n",
+				temp.Ingredient, safety.TemperatureUnit.Format(temp.MinTempF)))
+		}
+	}
+
+	return PromptTemplate{
+		SystemPrompt: systemPrompt,
+		UserPrompt:   prompt.String(),
+	}, nil
+}
@@ -108,15 +108,11 @@ func (q *QualityCheckService) calculateFeasibilityScore(recipe *models.RecipeDat
 		score -= 0.2
 	}
 
-	// Check for temperature/time contradictions
-	tempRegex := regexp.MustCompile(`(\d+)°?[fF]`)
-	matches := tempRegex.FindAllStringSubmatch(stepsText, -1)
-	for _, match := range matches {
-		if temp, err := strconv.ParseFloat(match[1], 64); err == nil {
-			if temp > 500 {
-				result.Violations = append(result.Violations, fmt.Sprintf("Temperature %s°F seems too high for home cooking", match[1]))
-				score -= 0.2
-			}
+	// Check for temperature/time contradictions (500°F is 260°C)
+	for _, mention := range findTemperatureMentions(stepsText) {
+		if mention.fahrenheit() > 500 {
+			result.Violations = append(result.Violations, fmt.Sprintf("Temperature %s seems too high for home cooking", mention.text))
+			score -= 0.2
 		}
 	}
 
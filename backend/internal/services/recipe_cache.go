@@ -11,7 +11,8 @@ type CacheEntry struct {
 	ExpiresAt time.Time
 }
 
-// RecipeCache implements an in-memory cache for recipe generation results
+// RecipeCache is the in-memory GenerationCache tier. Entries expire after
+// the TTL and are lost on restart.
 type RecipeCache struct {
 	mu      sync.RWMutex
 	data    map[string]*CacheEntry
@@ -35,6 +36,11 @@ func NewRecipeCache(maxSize int, ttl time.Duration) *RecipeCache {
 	return cache
 }
 
+// Name returns the tier name used in cache metrics
+func (c *RecipeCache) Name() string {
+	return "memory"
+}
+
 // Get retrieves a value from the cache
 func (c *RecipeCache) Get(key string) *GenerationResult {
 	c.mu.RLock()
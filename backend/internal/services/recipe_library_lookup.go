@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// libraryCandidates is how many nearest recipes are checked against a request
+const libraryCandidates = 10
+
+// RecipeLibraryLookup finds an existing recipe close enough to a generation
+// request to be served instead of paying for a new generation
+type RecipeLibraryLookup struct {
+	embeddings *EmbeddingDeduplicator
+	repository *RecipeRepository
+	resolver   *IngredientResolver
+	threshold  float64
+}
+
+// NewRecipeLibraryLookup creates a library lookup that accepts recipes whose
+// embedding similarity to the request is at least threshold
+func NewRecipeLibraryLookup(embeddings *EmbeddingDeduplicator, db *database.Database, threshold float64) *RecipeLibraryLookup {
+	return &RecipeLibraryLookup{
+		embeddings: embeddings,
+		repository: NewRecipeRepository(db),
+		resolver:   NewIngredientResolver(db),
+		threshold:  threshold,
+	}
+}
+
+// Find returns the most similar library recipe that uses every requested
+// ingredient, fits the season and cooking time limit, and meets the
+// similarity threshold. It returns nil when nothing qualifies. Requests with
+// constraints or with dietary restrictions outside the catalogue are never
+// served from the library, since library recipes were not checked against them.
+func (l *RecipeLibraryLookup) Find(ctx context.Context, req RecipeGenerationRequest) (*GenerationResult, error) {
+	if len(req.Constraints) > 0 || len(req.Ingredients) == 0 {
+		return nil, nil
+	}
+	if _, unknown := models.SplitDietaryRestrictions(req.DietaryRestrictions); len(unknown) > 0 {
+		return nil, nil
+	}
+
+	ctx = WithUsageSource(ctx, UsageSource{Stage: "library-lookup"})
+	matches, err := l.embeddings.SearchByText(ctx, l.requestText(req), libraryCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("library lookup failed: %w", err)
+	}
+
+	ids := make([]int, 0, len(matches))
+	for _, match := range matches {
+		if match.Similarity >= l.threshold {
+			ids = append(ids, match.ID)
+		}
+	}
+	recipes, err := l.repository.GetRecipesByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("library lookup failed: %w", err)
+	}
+	byID := make(map[int]*models.Recipe, len(recipes))
+	for _, recipe := range recipes {
+		byID[recipe.ID] = recipe
+	}
+
+	terms := l.resolver.ResolveTerms(req.Ingredients)
+	for _, match := range matches {
+		recipe, ok := byID[match.ID]
+		if !ok || match.Similarity < l.threshold || !l.satisfies(recipe.Data, req, terms) {
+			continue
+		}
+
+		data := recipe.Data
+		return &GenerationResult{
+			Recipe: &data,
+			Metadata: GenerationMetadata{
+				RequestID:         generateRequestID(),
+				Model:             "library",
+				GeneratedAt:       time.Now(),
+				ServedFromLibrary: true,
+				LibraryRecipeID:   recipe.ID,
+				LibrarySimilarity: match.Similarity,
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+// requestText describes the request in the same shape recipes are embedded in
+func (l *RecipeLibraryLookup) requestText(req RecipeGenerationRequest) string {
+	parts := []string{fmt.Sprintf("Ingredients: %s", strings.Join(req.Ingredients, ", "))}
+	if len(req.Preferences) > 0 {
+		parts = append(parts, fmt.Sprintf("Tags: %s", strings.Join(req.Preferences, ", ")))
+	}
+	parts = append(parts, fmt.Sprintf("Season: %s", req.Season))
+	return strings.Join(parts, "\n")
+}
+
+// satisfies checks the request's hard limits: cooking time, season, dietary
+// restrictions (by the recipe's stored compliance flags) and every requested
+// ingredient (by name, alias or group member)
+func (l *RecipeLibraryLookup) satisfies(recipe models.RecipeData, req RecipeGenerationRequest, terms []IngredientTermMatch) bool {
+	if req.MaxCookingTime > 0 && recipe.CookingTime > req.MaxCookingTime {
+		return false
+	}
+	if req.Season != "" && req.Season != "all" && recipe.Season != "all" && recipe.Season != req.Season {
+		return false
+	}
+	known, _ := models.SplitDietaryRestrictions(req.DietaryRestrictions)
+	for _, restriction := range known {
+		if !slices.Contains(recipe.DietaryFlags, restriction) {
+			return false
+		}
+	}
+
+	for _, term := range terms {
+		if !recipeUsesAny(recipe, term.Ingredients) {
+			return false
+		}
+	}
+	return true
+}
+
+func recipeUsesAny(recipe models.RecipeData, names []string) bool {
+	for _, ingredient := range recipe.Ingredients {
+		for _, name := range names {
+			if name != "" && strings.Contains(ingredient.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func TestRecipeLibraryLookup_ServesRecipeWithinRequestLimits(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+	embeddings := NewEmbeddingDeduplicator(NewLocalProvider(), db.DB)
+
+	newRecipe := func(title string, minutes int, ingredients ...string) models.RecipeData {
+		recipe := models.RecipeData{
+			Title:         title,
+			CookingTime:   minutes,
+			Steps:         []string{"炒める"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		}
+		for _, name := range ingredients {
+			recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Amount: "100g"})
+		}
+		return recipe
+	}
+	ctx := context.Background()
+	porkID := insertTestRecipe(t, db, newRecipe("豚キャベツ炒め", 10, "豚こま肉", "キャベツ"))
+	chickenID := insertTestRecipe(t, db, newRecipe("鶏むね肉のソテー", 20, "鶏むね肉", "塩"))
+	for _, id := range []int{porkID, chickenID} {
+		require.NoError(t, embeddings.IndexRecipe(ctx, id))
+	}
+
+	lookup := NewRecipeLibraryLookup(embeddings, db, 0.1)
+
+	// 豚肉 is a group, satisfied by 豚こま肉
+	result, err := lookup.Find(ctx, RecipeGenerationRequest{Ingredients: []string{"キャベツ", "豚肉"}, Season: "all", MaxCookingTime: 15})
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.True(t, result.Metadata.ServedFromLibrary)
+	assert.Equal(t, porkID, result.Metadata.LibraryRecipeID)
+	assert.Equal(t, "豚キャベツ炒め", result.Recipe.Title)
+	assert.GreaterOrEqual(t, result.Metadata.LibrarySimilarity, 0.1)
+
+	// The chicken recipe takes longer than allowed
+	result, err = lookup.Find(ctx, RecipeGenerationRequest{Ingredients: []string{"鶏胸肉"}, Season: "all", MaxCookingTime: 15})
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	// Nothing uses every requested ingredient
+	result, err = lookup.Find(ctx, RecipeGenerationRequest{Ingredients: []string{"キャベツ", "鶏胸肉"}, Season: "all", MaxCookingTime: 30})
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	// Library recipes were not written to constraints
+	result, err = lookup.Find(ctx, RecipeGenerationRequest{
+		Ingredients: []string{"キャベツ", "豚肉"}, Season: "all", MaxCookingTime: 15, Constraints: []string{"卵を使わない"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	// Library recipes carry flags for catalogue restrictions only
+	result, err = lookup.Find(ctx, RecipeGenerationRequest{
+		Ingredients: []string{"キャベツ", "豚肉"}, Season: "all", MaxCookingTime: 15, DietaryRestrictions: []string{"ケトジェニック"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	strict := NewRecipeLibraryLookup(embeddings, db, 1.01)
+	result, err = strict.Find(ctx, RecipeGenerationRequest{Ingredients: []string{"キャベツ", "豚肉"}, Season: "all", MaxCookingTime: 15})
+	require.NoError(t, err)
+	assert.Nil(t, result)
+}
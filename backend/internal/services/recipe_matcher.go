@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// DefaultMatchFallbackThreshold is the coverage (in percent) below which the
+// matcher may generate a new recipe from the on-hand ingredients
+const DefaultMatchFallbackThreshold = 80.0
+
+// matchCandidateLimit caps how many library recipes are scored per request
+const matchCandidateLimit = 500
+
+// stapleGroups are the ingredient groups assumed to be in every kitchen
+var stapleGroups = []string{"基本調味料", "油類"}
+
+// defaultStaples is used alongside stapleGroups so staples are still ignored
+// when the ingredient hierarchy is not loaded
+var defaultStaples = []string{
+	"塩", "砂糖", "醤油", "しょうゆ", "こしょう", "胡椒", "塩こしょう", "塩コショウ",
+	"油", "サラダ油", "ごま油", "オリーブオイル", "酢", "みりん", "酒", "料理酒", "水",
+}
+
+// RecipeMatchRequest asks for recipes that can be cooked from on-hand ingredients
+type RecipeMatchRequest struct {
+	Ingredients       []string `json:"ingredients"`
+	UserID            string   `json:"user_id,omitempty"`            // Adds the user's unexpired pantry items
+	Limit             int      `json:"limit,omitempty"`              // Default 10
+	FallbackThreshold float64  `json:"fallback_threshold,omitempty"` // Percent, default 80
+	GenerateFallback  bool     `json:"generate_fallback,omitempty"`  // Generate a recipe when nothing reaches the threshold
+}
+
+// RecipeMatch is a library recipe scored against the on-hand ingredients
+type RecipeMatch struct {
+	Recipe   *models.Recipe `json:"recipe"`
+	Coverage float64        `json:"coverage"` // Percent of non-staple ingredients already held
+	Matched  []string       `json:"matched_ingredients"`
+	Missing  []string       `json:"missing_ingredients"`
+	Staples  []string       `json:"ignored_staples"`
+}
+
+// RecipeMatchResult holds the ranked matches and any fallback recipe
+type RecipeMatchResult struct {
+	Matches           []RecipeMatch         `json:"matches"`
+	OnHand            []string              `json:"on_hand"`
+	IngredientMatches []IngredientTermMatch `json:"ingredient_matches"`
+	FallbackThreshold float64               `json:"fallback_threshold"`
+	GeneratedRecipe   *models.Recipe        `json:"generated_recipe,omitempty"`
+	FallbackError     string                `json:"fallback_error,omitempty"`
+}
+
+// RecipeMatcher ranks library recipes by how much of them can be made from
+// ingredients the user already has
+type RecipeMatcher struct {
+	db        *database.Database
+	repo      *RecipeRepository
+	resolver  *IngredientResolver
+	pantry    *PantryService
+	generator *RecipeGeneratorService
+}
+
+// NewRecipeMatcher creates a new recipe matcher. generator may be nil, in
+// which case no fallback recipe is generated.
+func NewRecipeMatcher(db *database.Database, generator *RecipeGeneratorService) *RecipeMatcher {
+	return &RecipeMatcher{
+		db:        db,
+		repo:      NewRecipeRepository(db),
+		resolver:  NewIngredientResolver(db),
+		pantry:    NewPantryService(db, NewIngredientAggregator()),
+		generator: generator,
+	}
+}
+
+// Match ranks recipes by coverage of the on-hand ingredients, best first
+func (m *RecipeMatcher) Match(ctx context.Context, req RecipeMatchRequest) (*RecipeMatchResult, error) {
+	onHand, err := m.onHandIngredients(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(onHand) == 0 {
+		return nil, models.ErrMissingParameters
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	threshold := req.FallbackThreshold
+	if threshold <= 0 {
+		threshold = DefaultMatchFallbackThreshold
+	}
+
+	termMatches := m.resolver.ResolveTerms(onHand)
+	held := toSet(IngredientNames(termMatches))
+	staples := m.staples()
+
+	candidates, err := m.candidateRecipes(held, staples)
+	if err != nil {
+		return nil, err
+	}
+
+	expansions := make(map[string][]string)
+	matches := make([]RecipeMatch, 0, len(candidates))
+	for _, recipe := range candidates {
+		matches = append(matches, m.score(recipe, held, staples, expansions))
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Coverage != matches[j].Coverage {
+			return matches[i].Coverage > matches[j].Coverage
+		}
+		if len(matches[i].Missing) != len(matches[j].Missing) {
+			return len(matches[i].Missing) < len(matches[j].Missing)
+		}
+		return matches[i].Recipe.Data.LazinessScore > matches[j].Recipe.Data.LazinessScore
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	result := &RecipeMatchResult{
+		Matches:           matches,
+		OnHand:            onHand,
+		IngredientMatches: termMatches,
+		FallbackThreshold: threshold,
+	}
+
+	if req.GenerateFallback && (len(matches) == 0 || matches[0].Coverage < threshold) {
+		recipe, err := m.generateFromOnHand(ctx, onHand)
+		if err != nil {
+			log.Printf("Warning: cook-from-pantry fallback generation failed: %v", err)
+			result.FallbackError = err.Error()
+		} else {
+			result.GeneratedRecipe = recipe
+		}
+	}
+
+	return result, nil
+}
+
+// onHandIngredients combines the requested ingredients with the user's pantry
+func (m *RecipeMatcher) onHandIngredients(req RecipeMatchRequest) ([]string, error) {
+	var onHand []string
+	for _, ingredient := range req.Ingredients {
+		if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+			onHand = append(onHand, ingredient)
+		}
+	}
+
+	if req.UserID != "" && m.db != nil {
+		stock, err := m.pantry.Stock(req.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load pantry: %w", err)
+		}
+		names := make([]string, 0, len(stock))
+		for name := range stock {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		onHand = append(onHand, names...)
+	}
+
+	return removeDuplicates(onHand), nil
+}
+
+// staples returns the names of pantry staples, including their aliases
+func (m *RecipeMatcher) staples() map[string]bool {
+	staples := toSet(defaultStaples)
+	for _, match := range m.resolver.ResolveTerms(stapleGroups) {
+		if match.Source == IngredientMatchSourceGroup {
+			for _, name := range match.Ingredients {
+				staples[name] = true
+			}
+		}
+	}
+	return staples
+}
+
+// candidateRecipes loads recipes using at least one of the held ingredient
+// names. They are ranked in SQL the way score ranks them, by the share of
+// non-staple ingredients held and then by how few are missing, so the limit
+// keeps the best-covered recipes rather than the newest.
+func (m *RecipeMatcher) candidateRecipes(held, staples map[string]bool) ([]*models.Recipe, error) {
+	if len(held) == 0 {
+		return []*models.Recipe{}, nil
+	}
+
+	heldNames := sortedKeys(held)
+	stapleNames := sortedKeys(staples)
+	heldPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(heldNames)), ",")
+	staplePlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(stapleNames)), ",")
+
+	args := make([]interface{}, 0, 2*len(heldNames)+2*len(stapleNames)+1)
+	for _, names := range [][]string{heldNames, stapleNames, stapleNames, heldNames} {
+		for _, name := range names {
+			args = append(args, name)
+		}
+	}
+	args = append(args, matchCandidateLimit)
+
+	rows, err := m.db.Query(`
+		WITH scored AS (
+			SELECT id, data, created_at,
+				(SELECT COUNT(*) FROM json_each(COALESCE(json_extract(data, '$.data'), data), '$.ingredients')
+				 WHERE json_extract(value, '$.name') IN (`+heldPlaceholders+`)
+				   AND json_extract(value, '$.name') NOT IN (`+staplePlaceholders+`)) AS matched,
+				(SELECT COUNT(*) FROM json_each(COALESCE(json_extract(data, '$.data'), data), '$.ingredients')
+				 WHERE json_extract(value, '$.name') NOT IN (`+staplePlaceholders+`)) AS needed
+			FROM recipes
+			WHERE EXISTS (
+				SELECT 1 FROM json_each(COALESCE(json_extract(data, '$.data'), data), '$.ingredients')
+				WHERE json_extract(value, '$.name') IN (`+heldPlaceholders+`)
+			)
+		)
+		SELECT id, data FROM scored
+		ORDER BY CASE WHEN needed = 0 THEN 1.0 ELSE CAST(matched AS REAL) / needed END DESC,
+			needed - matched, created_at DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query candidate recipes: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	recipes := make([]*models.Recipe, 0)
+	for rows.Next() {
+		var id int
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		recipe, err := decodeStoredRecipe(id, data)
+		if err != nil {
+			continue // Skip invalid recipes
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	return recipes, rows.Err()
+}
+
+// score works out which of a recipe's ingredients are held, missing or staples.
+// expansions memoizes name resolution across recipes.
+func (m *RecipeMatcher) score(recipe *models.Recipe, held, staples map[string]bool, expansions map[string][]string) RecipeMatch {
+	match := RecipeMatch{
+		Recipe:  recipe,
+		Matched: []string{},
+		Missing: []string{},
+		Staples: []string{},
+	}
+
+	for _, ingredient := range recipe.Data.Ingredients {
+		names, ok := expansions[ingredient.Name]
+		if !ok {
+			names = []string{ingredient.Name}
+			if resolved := m.resolver.ResolveTerms([]string{ingredient.Name}); len(resolved) > 0 {
+				names = resolved[0].Ingredients
+			}
+			expansions[ingredient.Name] = names
+		}
+
+		switch {
+		case containsAny(staples, names):
+			match.Staples = append(match.Staples, ingredient.Name)
+		case containsAny(held, names):
+			match.Matched = append(match.Matched, ingredient.Name)
+		default:
+			match.Missing = append(match.Missing, ingredient.Name)
+		}
+	}
+
+	needed := len(match.Matched) + len(match.Missing)
+	if needed == 0 {
+		match.Coverage = 100
+	} else {
+		match.Coverage = math.Round(float64(len(match.Matched))/float64(needed)*1000) / 10
+	}
+	return match
+}
+
+// generateFromOnHand asks the generator for a recipe using the on-hand ingredients
+func (m *RecipeMatcher) generateFromOnHand(ctx context.Context, onHand []string) (*models.Recipe, error) {
+	if m.generator == nil {
+		return nil, fmt.Errorf("recipe generation is not configured")
+	}
+
+	result, err := m.generator.GenerateRecipe(ctx, RecipeGenerationRequest{
+		Ingredients:    onHand,
+		Season:         seasonForDate(""),
+		MaxCookingTime: 15,
+		Constraints:    []string{"手持ちの材料と基本調味料だけで作れること"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != "" || result.Recipe == nil {
+		return nil, fmt.Errorf("recipe generation error: %s", result.Error)
+	}
+
+	recipe := &models.Recipe{Data: *result.Recipe}
+	if recipe.Data.Season == "" {
+		recipe.Data.Season = "all"
+	}
+	if err := m.repo.SaveRecipe(recipe); err != nil {
+		return nil, fmt.Errorf("failed to save generated recipe: %w", err)
+	}
+	return recipe, nil
+}
+
+// toSet builds a lookup set from names
+// sortedKeys returns the names in a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// containsAny reports whether any of the names is in the set
+func containsAny(set map[string]bool, names []string) bool {
+	for _, name := range names {
+		if set[name] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func TestRecipeMatcher_RanksByCoverage(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+
+	newRecipe := func(title string, ingredients ...string) models.RecipeData {
+		recipe := models.RecipeData{
+			Title:         title,
+			CookingTime:   10,
+			Steps:         []string{"作る"},
+			Season:        "all",
+			LazinessScore: 8.0,
+			ServingSize:   models.FlexibleInt(1),
+		}
+		for _, name := range ingredients {
+			recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Amount: "100g"})
+		}
+		return recipe
+	}
+	porkID := insertTestRecipe(t, db, newRecipe("豚キャベツ炒め", "豚こま肉", "キャベツ", "食塩", "ごま油"))
+	eggID := insertTestRecipe(t, db, newRecipe("玉子丼", "玉子", "ご飯", "玉ねぎ", "醤油"))
+	insertTestRecipe(t, db, newRecipe("鮭のムニエル", "鮭", "バター"))
+
+	matcher := NewRecipeMatcher(db, nil)
+	result, err := matcher.Match(context.Background(), RecipeMatchRequest{
+		Ingredients: []string{"豚肉", "キャベツ", "たまご", "ライス"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Matches, 2, "recipes sharing no on-hand ingredient are not candidates")
+
+	best := result.Matches[0]
+	assert.Equal(t, porkID, best.Recipe.ID)
+	assert.Equal(t, 100.0, best.Coverage)
+	assert.Equal(t, []string{"豚こま肉", "キャベツ"}, best.Matched)
+	assert.Equal(t, []string{"食塩", "ごま油"}, best.Staples, "staples resolve through aliases")
+
+	second := result.Matches[1]
+	assert.Equal(t, eggID, second.Recipe.ID)
+	assert.Equal(t, 66.7, second.Coverage)
+	assert.Equal(t, []string{"玉子", "ご飯"}, second.Matched)
+	assert.Equal(t, []string{"玉ねぎ"}, second.Missing)
+
+	assert.Nil(t, result.GeneratedRecipe)
+	assert.Equal(t, DefaultMatchFallbackThreshold, result.FallbackThreshold)
+}
+
+func TestRecipeMatcher_FallbackWithoutGenerator(t *testing.T) {
+	db := setupSchemaDatabase(t)
+
+	matcher := NewRecipeMatcher(db, nil)
+	result, err := matcher.Match(context.Background(), RecipeMatchRequest{
+		Ingredients:      []string{"キャベツ"},
+		GenerateFallback: true,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, result.Matches)
+	assert.Nil(t, result.GeneratedRecipe)
+	assert.NotEmpty(t, result.FallbackError)
+
+	_, err = matcher.Match(context.Background(), RecipeMatchRequest{Ingredients: []string{" "}})
+	assert.ErrorIs(t, err, models.ErrMissingParameters)
+}
+
+func TestRecipeMatcher_IncludesPantry(t *testing.T) {
+	db := setupSchemaDatabase(t)
+
+	_, err := NewPantryService(db, NewIngredientAggregator()).AddItem("alice", models.PantryItemRequest{Ingredient: "もやし", Quantity: "1袋"})
+	require.NoError(t, err)
+
+	matcher := NewRecipeMatcher(db, nil)
+	result, err := matcher.Match(context.Background(), RecipeMatchRequest{
+		Ingredients: []string{"豆腐"},
+		UserID:      "alice",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"豆腐", "もやし"}, result.OnHand)
+}
+
+func TestRecipeMatcher_RanksBeforeCandidateLimit(t *testing.T) {
+	db := setupHierarchyDatabase(t)
+
+	newRecipe := func(title string, ingredients ...string) models.RecipeData {
+		recipe := models.RecipeData{Title: title, CookingTime: 10, Steps: []string{"作る"}, Season: "all", LazinessScore: 8.0}
+		for _, name := range ingredients {
+			recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Amount: "100g"})
+		}
+		return recipe
+	}
+
+	// The best-covered recipe is older than a full candidate list of weaker ones
+	bestID := insertTestRecipe(t, db, newRecipe("豚キャベツ炒め", "豚こま肉", "キャベツ"))
+	_, err := db.Exec(`UPDATE recipes SET created_at = '2020-01-01 00:00:00' WHERE id = ?`, bestID)
+	require.NoError(t, err)
+
+	require.NoError(t, db.ExecuteInTx(func(tx *sql.Tx) error {
+		for i := 0; i <= matchCandidateLimit; i++ {
+			data, err := json.Marshal(newRecipe(fmt.Sprintf("キャベツの小鉢%d", i), "キャベツ", "トマト", "きゅうり"))
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO recipes (data) VALUES (?)`, string(data)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	result, err := NewRecipeMatcher(db, nil).Match(context.Background(), RecipeMatchRequest{
+		Ingredients: []string{"豚肉", "キャベツ"},
+		Limit:       1,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Matches, 1)
+	assert.Equal(t, bestID, result.Matches[0].Recipe.ID)
+	assert.Equal(t, 100.0, result.Matches[0].Coverage)
+}
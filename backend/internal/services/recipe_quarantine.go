@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// RecipeQuarantine holds generated recipes that still failed food safety
+// validation after remediation, so they can be reviewed instead of saved
+type RecipeQuarantine struct {
+	db *database.Database
+}
+
+// QuarantinedRecipe is a recipe held back by the safety gate
+type QuarantinedRecipe struct {
+	ID         int               `json:"id"`
+	Recipe     models.RecipeData `json:"recipe"`
+	Violations []string          `json:"violations"`
+	Stage      string            `json:"stage"`              // Generation path, e.g. 'generation', 'batch', 'enhanced'
+	Endpoint   string            `json:"endpoint,omitempty"` // Originating route, if known
+	Rounds     int               `json:"rounds"`             // Remediation rounds attempted
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// NewRecipeQuarantine creates a quarantine store
+func NewRecipeQuarantine(db *database.Database) *RecipeQuarantine {
+	return &RecipeQuarantine{db: db}
+}
+
+// EnsureSchema creates the quarantined_recipes table if it doesn't exist
+func (q *RecipeQuarantine) EnsureSchema() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS quarantined_recipes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			data JSON NOT NULL,
+			violations JSON NOT NULL,
+			stage TEXT NOT NULL DEFAULT '',
+			endpoint TEXT NOT NULL DEFAULT '',
+			rounds INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			CHECK (json_valid(data)),
+			CHECK (json_valid(violations))
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_quarantined_recipes_created_at ON quarantined_recipes(created_at)`,
+	}
+	for _, stmt := range statements {
+		if _, err := q.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create quarantined_recipes: %w", err)
+		}
+	}
+	return nil
+}
+
+// Add stores a quarantined recipe and returns its ID
+func (q *RecipeQuarantine) Add(entry QuarantinedRecipe) (int, error) {
+	data, err := json.Marshal(entry.Recipe)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+	if entry.Violations == nil {
+		entry.Violations = []string{}
+	}
+	violations, err := json.Marshal(entry.Violations)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal violations: %w", err)
+	}
+
+	var id int
+	err = q.db.QueryRow(`
+		INSERT INTO quarantined_recipes (data, violations, stage, endpoint, rounds, created_at)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING id`,
+		string(data), string(violations), entry.Stage, entry.Endpoint, entry.Rounds, time.Now().UTC(),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to quarantine recipe: %w", err)
+	}
+	return id, nil
+}
+
+// List returns the most recently quarantined recipes, newest first
+func (q *RecipeQuarantine) List(limit int) ([]QuarantinedRecipe, error) {
+	rows, err := q.db.Query(`
+		SELECT id, data, violations, stage, endpoint, rounds, created_at
+		FROM quarantined_recipes ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quarantined recipes: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	entries := []QuarantinedRecipe{}
+	for rows.Next() {
+		var entry QuarantinedRecipe
+		var data, violations string
+		if err := rows.Scan(&entry.ID, &data, &violations, &entry.Stage, &entry.Endpoint, &entry.Rounds, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan quarantined recipe: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &entry.Recipe); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal quarantined recipe %d: %w", entry.ID, err)
+		}
+		if err := json.Unmarshal([]byte(violations), &entry.Violations); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal violations of quarantined recipe %d: %w", entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
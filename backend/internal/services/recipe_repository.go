@@ -24,7 +24,8 @@ func NewRecipeRepository(db *database.Database) *RecipeRepository {
 
 // SaveRecipe saves a recipe to the database
 func (r *RecipeRepository) SaveRecipe(recipe *models.Recipe) error {
-	data, err := json.Marshal(recipe)
+	// The data column holds RecipeData so the generated columns can index it
+	data, err := json.Marshal(recipe.Data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal recipe: %w", err)
 	}
@@ -46,31 +47,107 @@ func (r *RecipeRepository) SaveRecipe(recipe *models.Recipe) error {
 	}
 
 	recipe.ID = int(id)
+	r.db.NotifyRecipeSaved(recipe.ID)
 	return nil
 }
 
 // GetRecipe retrieves a recipe by ID
 func (r *RecipeRepository) GetRecipe(id int) (*models.Recipe, error) {
 	query := `
-		SELECT data FROM recipes WHERE id = ?
+		SELECT data, created_at, updated_at FROM recipes WHERE id = ?
 	`
 
 	var data string
+	var createdAt, updatedAt sql.NullTime
 	row := r.db.QueryRow(query, id)
-	if err := row.Scan(&data); err != nil {
+	if err := row.Scan(&data, &createdAt, &updatedAt); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("recipe not found")
+			return nil, models.ErrRecipeNotFound
 		}
 		return nil, fmt.Errorf("failed to query recipe: %w", err)
 	}
 
-	var recipe models.Recipe
-	if err := json.Unmarshal([]byte(data), &recipe); err != nil {
+	recipe, err := decodeStoredRecipe(id, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recipe: %w", err)
+	}
+
+	recipe.CreatedAt = createdAt.Time
+	recipe.UpdatedAt = updatedAt.Time
+	return recipe, nil
+}
+
+// UpdateRecipe replaces the stored data of an existing recipe
+func (r *RecipeRepository) UpdateRecipe(recipe *models.Recipe) error {
+	data, err := json.Marshal(recipe.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+
+	query := `
+		UPDATE recipes SET data = ?
+		WHERE id = ?
+	`
+
+	result, err := r.db.Exec(query, string(data), recipe.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update recipe: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check updated rows: %w", err)
+	}
+	if affected == 0 {
+		return models.ErrRecipeNotFound
+	}
+
+	r.db.NotifyRecipeSaved(recipe.ID)
+	return nil
+}
+
+// DeleteRecipe deletes a recipe by ID
+func (r *RecipeRepository) DeleteRecipe(id int) error {
+	result, err := r.db.Exec(`DELETE FROM recipes WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recipe: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check deleted rows: %w", err)
+	}
+	if affected == 0 {
+		return models.ErrRecipeNotFound
+	}
+
+	return nil
+}
+
+// FindRecipeByTitle returns the most recent recipe with the given title, or nil if none exists
+func (r *RecipeRepository) FindRecipeByTitle(title string) (*models.Recipe, error) {
+	query := `
+		SELECT id, data FROM recipes
+		WHERE title = ?
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	var id int
+	var data string
+	if err := r.db.QueryRow(query, title).Scan(&id, &data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query recipe by title: %w", err)
+	}
+
+	recipe, err := decodeStoredRecipe(id, data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal recipe: %w", err)
 	}
 
-	recipe.ID = id
-	return &recipe, nil
+	return recipe, nil
 }
 
 // SearchRecipes searches for recipes based on criteria
@@ -89,7 +166,11 @@ func (r *RecipeRepository) SearchRecipes(criteria models.SearchCriteria) ([]*mod
 	}
 
 	if criteria.Ingredient != "" {
-		query += ` AND json_extract(data, '$.ingredients') LIKE ?`
+		// Match ingredient names only, not notes or amounts
+		query += ` AND EXISTS (
+			SELECT 1 FROM json_each(data, '$.ingredients')
+			WHERE json_extract(value, '$.name') LIKE ?
+		)`
 		args = append(args, "%"+criteria.Ingredient+"%")
 	}
 
@@ -142,13 +223,12 @@ func (r *RecipeRepository) SearchRecipes(criteria models.SearchCriteria) ([]*mod
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		var recipe models.Recipe
-		if err := json.Unmarshal([]byte(data), &recipe); err != nil {
+		recipe, err := decodeStoredRecipe(id, data)
+		if err != nil {
 			continue // Skip invalid recipes
 		}
 
-		recipe.ID = id
-		recipes = append(recipes, &recipe)
+		recipes = append(recipes, recipe)
 	}
 
 	return recipes, nil
@@ -181,13 +261,12 @@ func (r *RecipeRepository) GetRandomRecipes(count int) ([]*models.Recipe, error)
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		var recipe models.Recipe
-		if err := json.Unmarshal([]byte(data), &recipe); err != nil {
+		recipe, err := decodeStoredRecipe(id, data)
+		if err != nil {
 			continue
 		}
 
-		recipe.ID = id
-		recipes = append(recipes, &recipe)
+		recipes = append(recipes, recipe)
 	}
 
 	return recipes, nil
@@ -244,13 +323,12 @@ func (r *RecipeRepository) GetRecipesByIDs(ids []int) ([]*models.Recipe, error)
 			return nil, fmt.Errorf("failed to scan recipe row: %w", err)
 		}
 
-		var recipe models.Recipe
-		if err := json.Unmarshal([]byte(data), &recipe); err != nil {
+		recipe, err := decodeStoredRecipe(id, data)
+		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal recipe: %w", err)
 		}
 
-		recipe.ID = id
-		recipes = append(recipes, &recipe)
+		recipes = append(recipes, recipe)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -259,3 +337,21 @@ func (r *RecipeRepository) GetRecipesByIDs(ids []int) ([]*models.Recipe, error)
 
 	return recipes, nil
 }
+
+// decodeStoredRecipe decodes the data column of a recipes row.
+// Older rows stored the whole Recipe wrapper, so both layouts are accepted.
+func decodeStoredRecipe(id int, data string) (*models.Recipe, error) {
+	var legacy struct {
+		Data *models.RecipeData `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(data), &legacy); err == nil && legacy.Data != nil {
+		return &models.Recipe{ID: id, Data: *legacy.Data}, nil
+	}
+
+	var recipeData models.RecipeData
+	if err := json.Unmarshal([]byte(data), &recipeData); err != nil {
+		return nil, err
+	}
+
+	return &models.Recipe{ID: id, Data: recipeData}, nil
+}
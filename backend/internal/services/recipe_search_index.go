@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"lazychef/internal/database"
+)
+
+// ErrFullTextSearchUnavailable is returned when SQLite was built without FTS5
+// (build with -tags sqlite_fts5 to enable it)
+var ErrFullTextSearchUnavailable = errors.New("full-text search is not available in this build")
+
+// ftsMinTermLength is the shortest term the trigram tokenizer can MATCH
+const ftsMinTermLength = 3
+
+// ftsColumnWeights are the bm25 weights for title, ingredients, steps and tags
+var ftsColumnWeights = []float64{10.0, 5.0, 1.0, 3.0}
+
+// ftsColumns lists the indexed columns in table order
+var ftsColumns = []string{"title", "ingredients", "steps", "tags"}
+
+// RecipeSearchIndex maintains the recipes_fts virtual table used for full-text search
+type RecipeSearchIndex struct {
+	db        *database.Database
+	available atomic.Bool
+}
+
+// NewRecipeSearchIndex creates a new recipe search index
+func NewRecipeSearchIndex(db *database.Database) *RecipeSearchIndex {
+	return &RecipeSearchIndex{
+		db: db,
+	}
+}
+
+// ftsDocumentSQL selects the indexed columns from a stored recipe JSON expression.
+// Legacy rows wrap the recipe in {"data": {...}}, so both layouts are handled.
+func ftsDocumentSQL(dataExpr string) string {
+	doc := fmt.Sprintf("COALESCE(json_extract(%s, '$.data'), %s)", dataExpr, dataExpr)
+	return fmt.Sprintf(`
+		json_extract(%[1]s, '$.title'),
+		(SELECT group_concat(json_extract(value, '$.name'), ' ') FROM json_each(%[1]s, '$.ingredients')),
+		(SELECT group_concat(value, ' ') FROM json_each(%[1]s, '$.steps')),
+		(SELECT group_concat(value, ' ') FROM json_each(%[1]s, '$.tags'))`, doc)
+}
+
+// EnsureSchema creates the FTS5 table and sync triggers, then indexes any
+// recipes that are missing from it
+func (i *RecipeSearchIndex) EnsureSchema() error {
+	_, err := i.db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS recipes_fts USING fts5(
+			title, ingredients, steps, tags,
+			tokenize = 'trigram'
+		)
+	`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			return ErrFullTextSearchUnavailable
+		}
+		return fmt.Errorf("failed to create recipes_fts: %w", err)
+	}
+
+	// Resetting the recipes table drops the triggers, so the index may be stale
+	var triggerCount int
+	if err := i.db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'recipes_fts_insert'
+	`).Scan(&triggerCount); err != nil {
+		return fmt.Errorf("failed to inspect recipes_fts triggers: %w", err)
+	}
+
+	staleRows := `DELETE FROM recipes_fts WHERE rowid NOT IN (SELECT id FROM recipes)`
+	if triggerCount == 0 {
+		staleRows = `DELETE FROM recipes_fts`
+	}
+
+	document := ftsDocumentSQL("NEW.data")
+	statements := []string{
+		staleRows,
+		`CREATE TRIGGER IF NOT EXISTS recipes_fts_insert AFTER INSERT ON recipes BEGIN
+			INSERT INTO recipes_fts(rowid, title, ingredients, steps, tags)
+			SELECT NEW.id, ` + document + `;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS recipes_fts_update AFTER UPDATE OF data ON recipes BEGIN
+			DELETE FROM recipes_fts WHERE rowid = OLD.id;
+			INSERT INTO recipes_fts(rowid, title, ingredients, steps, tags)
+			SELECT NEW.id, ` + document + `;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS recipes_fts_delete AFTER DELETE ON recipes BEGIN
+			DELETE FROM recipes_fts WHERE rowid = OLD.id;
+		END`,
+		`INSERT INTO recipes_fts(rowid, title, ingredients, steps, tags)
+			SELECT r.id, ` + ftsDocumentSQL("r.data") + `
+			FROM recipes r
+			WHERE r.id NOT IN (SELECT rowid FROM recipes_fts)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := i.db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to set up recipes_fts: %w", err)
+		}
+	}
+
+	i.available.Store(true)
+	return nil
+}
+
+// Available reports whether the recipes_fts table can be queried
+func (i *RecipeSearchIndex) Available() bool {
+	if i.available.Load() {
+		return true
+	}
+	if i.db == nil {
+		return false
+	}
+
+	rows, err := i.db.Query(`SELECT rowid FROM recipes_fts LIMIT 0`)
+	if err != nil {
+		return false
+	}
+	_ = rows.Close()
+
+	i.available.Store(true)
+	return true
+}
+
+// FullTextQuery holds SQL fragments for searching the recipes_fts table
+type FullTextQuery struct {
+	Where    string        // Conditions every result must satisfy
+	Args     []interface{} // Arguments for Where
+	Rank     string        // Rank expression, lower is better
+	RankArgs []interface{} // Arguments for Rank
+	Terms    []string      // Search terms, used for highlighting
+}
+
+// BuildFullTextQuery turns a search string into FTS conditions. Terms long
+// enough for the trigram tokenizer use MATCH and bm25 ranking; shorter terms
+// such as 卵 fall back to LIKE over the indexed columns. Returns nil when the
+// query has no terms.
+func BuildFullTextQuery(query string) *FullTextQuery {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	ftq := &FullTextQuery{Terms: terms}
+	var conditions []string
+	var phrases []string
+	var shortTerms []string
+
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= ftsMinTermLength {
+			phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+			continue
+		}
+
+		shortTerms = append(shortTerms, term)
+		var columnMatches []string
+		for _, column := range ftsColumns {
+			columnMatches = append(columnMatches, "recipes_fts."+column+" LIKE ?")
+			ftq.Args = append(ftq.Args, "%"+term+"%")
+		}
+		conditions = append(conditions, "("+strings.Join(columnMatches, " OR ")+")")
+	}
+
+	if len(phrases) > 0 {
+		conditions = append([]string{"recipes_fts MATCH ?"}, conditions...)
+		ftq.Args = append([]interface{}{strings.Join(phrases, " AND ")}, ftq.Args...)
+
+		weights := make([]string, len(ftsColumnWeights))
+		for idx, weight := range ftsColumnWeights {
+			weights[idx] = fmt.Sprintf("%.1f", weight)
+		}
+		ftq.Rank = "bm25(recipes_fts, " + strings.Join(weights, ", ") + ")"
+	} else {
+		// bm25 needs MATCH, so weight the columns that contain each short term
+		var scores []string
+		for _, term := range shortTerms {
+			for idx, column := range ftsColumns {
+				scores = append(scores, fmt.Sprintf("(CASE WHEN recipes_fts.%s LIKE ? THEN %.1f ELSE 0 END)", column, ftsColumnWeights[idx]))
+				ftq.RankArgs = append(ftq.RankArgs, "%"+term+"%")
+			}
+		}
+		ftq.Rank = "-(" + strings.Join(scores, " + ") + ")"
+	}
+
+	ftq.Where = strings.Join(conditions, " AND ")
+	return ftq
+}
+
+// HighlightSnippet marks every occurrence of the terms in text with <mark> tags,
+// trimming the text to about width runes around the first hit. The text is
+// HTML-escaped so only the <mark> tags are markup. Returns an empty string
+// when no term occurs.
+func HighlightSnippet(text string, terms []string, width int) string {
+	runes := []rune(text)
+	marked := make([]bool, len(runes))
+	first := -1
+
+	for _, term := range terms {
+		termRunes := []rune(term)
+		if len(termRunes) == 0 {
+			continue
+		}
+		for start := 0; start+len(termRunes) <= len(runes); start++ {
+			if string(runes[start:start+len(termRunes)]) != term {
+				continue
+			}
+			for k := start; k < start+len(termRunes); k++ {
+				marked[k] = true
+			}
+			if first == -1 || start < first {
+				first = start
+			}
+		}
+	}
+
+	if first == -1 {
+		return ""
+	}
+
+	from, to := 0, len(runes)
+	if width > 0 && len(runes) > width {
+		from = first - width/4
+		if from < 0 {
+			from = 0
+		}
+		to = from + width
+		if to > len(runes) {
+			to = len(runes)
+			from = to - width
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	for k := from; k < to; k++ {
+		if marked[k] && (k == from || !marked[k-1]) {
+			b.WriteString("<mark>")
+		}
+		b.WriteString(html.EscapeString(string(runes[k])))
+		if marked[k] && (k == to-1 || !marked[k+1]) {
+			b.WriteString("</mark>")
+		}
+	}
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
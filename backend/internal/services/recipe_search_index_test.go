@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/models"
+)
+
+func TestBuildFullTextQuery(t *testing.T) {
+	assert.Nil(t, BuildFullTextQuery("   "))
+
+	// Long terms use MATCH with bm25 ranking
+	ftq := BuildFullTextQuery("キャベツ 豚こま肉")
+	require.NotNil(t, ftq)
+	assert.Equal(t, "recipes_fts MATCH ?", ftq.Where)
+	assert.Equal(t, []interface{}{`"キャベツ" AND "豚こま肉"`}, ftq.Args)
+	assert.Contains(t, ftq.Rank, "bm25(recipes_fts")
+	assert.Empty(t, ftq.RankArgs)
+
+	// Short terms cannot MATCH a trigram index and use LIKE instead
+	ftq = BuildFullTextQuery("卵　キャベツ")
+	require.NotNil(t, ftq)
+	assert.Equal(t, []string{"卵", "キャベツ"}, ftq.Terms)
+	assert.Contains(t, ftq.Where, "recipes_fts MATCH ?")
+	assert.Contains(t, ftq.Where, "recipes_fts.ingredients LIKE ?")
+	assert.Len(t, ftq.Args, 1+len(ftsColumns))
+
+	ftq = BuildFullTextQuery("卵")
+	require.NotNil(t, ftq)
+	assert.NotContains(t, ftq.Where, "MATCH")
+	assert.NotContains(t, ftq.Rank, "bm25")
+	assert.Len(t, ftq.RankArgs, len(ftsColumns))
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	assert.Equal(t, "豚こま肉 <mark>キャベツ</mark> <mark>卵</mark>",
+		HighlightSnippet("豚こま肉 キャベツ 卵", []string{"キャベツ", "卵"}, 0))
+	assert.Equal(t, "", HighlightSnippet("豚こま肉", []string{"卵"}, 0))
+
+	long := "材料を切る。フライパンで炒める。最後に卵を落として蓋をして、弱火で三分ほど蒸し焼きにする。器に盛り付けて完成。"
+	snippet := HighlightSnippet(long, []string{"卵"}, 12)
+	assert.Contains(t, snippet, "<mark>卵</mark>")
+	assert.True(t, len([]rune(snippet)) < len([]rune(long)))
+
+	// Recipe text is escaped so only the highlight tags are markup
+	assert.Equal(t, "&lt;b&gt;<mark>卵&amp;ご飯</mark>&lt;/b&gt;",
+		HighlightSnippet("<b>卵&ご飯</b>", []string{"卵&ご飯"}, 0))
+}
+
+func TestRecipeSearchIndex_TriggersKeepIndexInSync(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	existingID := insertTestRecipe(t, db, models.RecipeData{
+		Title: "キャベツ炒め", CookingTime: 5, Season: "all", LazinessScore: 8,
+		Ingredients: []models.Ingredient{{Name: "キャベツ", Amount: "1/4個"}},
+		Steps:       []string{"炒める"},
+	})
+
+	index := NewRecipeSearchIndex(db)
+	if err := index.EnsureSchema(); errors.Is(err, ErrFullTextSearchUnavailable) {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5")
+	} else {
+		require.NoError(t, err)
+	}
+	assert.True(t, index.Available())
+
+	countMatches := func(term string) int {
+		var count int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM recipes_fts WHERE recipes_fts MATCH ?`, term).Scan(&count))
+		return count
+	}
+
+	// Existing rows are backfilled
+	assert.Equal(t, 1, countMatches(`ingredients:"キャベツ"`))
+
+	repo := NewRecipeRepository(db)
+	recipe, err := repo.GetRecipe(existingID)
+	require.NoError(t, err)
+	recipe.Data.Ingredients = []models.Ingredient{{Name: "ほうれん草", Amount: "1束"}}
+	require.NoError(t, repo.UpdateRecipe(recipe))
+	assert.Equal(t, 0, countMatches(`ingredients:"キャベツ"`))
+	assert.Equal(t, 1, countMatches(`ingredients:"ほうれん草"`))
+
+	require.NoError(t, repo.DeleteRecipe(existingID))
+	assert.Equal(t, 0, countMatches(`ingredients:"ほうれん草"`))
+}
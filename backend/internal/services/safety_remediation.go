@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+
+	"lazychef/internal/models"
+)
+
+// ErrRecipeQuarantined is returned when a generated recipe still fails food
+// safety validation after remediation and was quarantined instead of returned
+var ErrRecipeQuarantined = errors.New("recipe quarantined after failing food safety validation")
+
+// RecipeSafetyGate checks generated recipes before anything can save them.
+// It returns the recipe to use, which may be a remediated copy, or an error
+// wrapping ErrRecipeQuarantined when the recipe must not be saved.
+type RecipeSafetyGate interface {
+	GateRecipe(ctx context.Context, recipe *models.RecipeData, stage string) (*SafetyGateResult, error)
+}
+
+// SafetyGateResult is the outcome of passing a recipe through the safety gate
+type SafetyGateResult struct {
+	Recipe       *models.RecipeData `json:"recipe"`                  // The original or remediated recipe
+	Safety       *SafetyCheckResult `json:"safety"`                  // Validation of Recipe
+	Remediated   bool               `json:"remediated"`              // Whether the critique model replaced the recipe
+	Rounds       []RemediationRound `json:"rounds,omitempty"`        // Remediation attempts, in order
+	QuarantineID int                `json:"quarantine_id,omitempty"` // Set when the recipe was quarantined
+}
+
+// RemediationRound records one critique-model attempt to fix a recipe
+type RemediationRound struct {
+	Round      int      `json:"round"`
+	Violations []string `json:"violations"` // The violations the critique model was asked to fix
+	TokensUsed int      `json:"tokens_used"`
+	Passed     bool     `json:"passed"` // Whether the corrected recipe passed validation
+	Error      string   `json:"error,omitempty"`
+}
+
+// SetRecipeQuarantine sets where recipes that fail the safety gate are kept.
+// Without one they are only logged.
+func (s *EnhancedRecipeGeneratorService) SetRecipeQuarantine(quarantine *RecipeQuarantine) {
+	s.quarantine = quarantine
+}
+
+// GateRecipe validates a generated recipe for food safety. A failing recipe
+// is sent with its violations to the critique model for a corrected version,
+// which is validated again, for up to SafetyRemediationRounds rounds. A recipe
+// that still fails is quarantined and an error wrapping ErrRecipeQuarantined
+// is returned. Outside strict mode recipes always pass.
+func (s *EnhancedRecipeGeneratorService) GateRecipe(ctx context.Context, recipe *models.RecipeData, stage string) (*SafetyGateResult, error) {
+	safety, err := s.foodSafetyValidator.ValidateRecipe(recipe)
+	if err != nil {
+		return nil, fmt.Errorf("safety validation failed: %w", err)
+	}
+	result := &SafetyGateResult{Recipe: recipe, Safety: safety}
+
+	var stopErr error
+	for round := 1; !result.Safety.Passed && round <= s.config.SafetyRemediationRounds; round++ {
+		attempt := RemediationRound{Round: round, Violations: result.Safety.Violations}
+		corrected, tokensUsed, err := s.remediateRecipe(ctx, result.Recipe, result.Safety)
+		attempt.TokensUsed = tokensUsed
+		if err == nil {
+			var recheck *SafetyCheckResult
+			if recheck, err = s.foodSafetyValidator.ValidateRecipe(corrected); err == nil {
+				result.Recipe, result.Safety, result.Remediated = corrected, recheck, true
+				attempt.Passed = recheck.Passed
+			}
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+			log.Printf("Warning: safety remediation round %d for '%s' failed: %v", round, recipe.Title, err)
+		}
+		result.Rounds = append(result.Rounds, attempt)
+
+		// Budget refusals and cancellations end remediation; other failures use up a round
+		if errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrRateLimited) || ctx.Err() != nil {
+			stopErr = err
+			break
+		}
+	}
+
+	if result.Safety.Passed {
+		if result.Remediated {
+			log.Printf("Recipe '%s' passed food safety validation after %d remediation round(s)", result.Recipe.Title, len(result.Rounds))
+		}
+		return result, nil
+	}
+
+	s.quarantineRecipe(ctx, result, stage)
+	err = fmt.Errorf("%w: %s", ErrRecipeQuarantined, strings.Join(result.Safety.Violations, "; "))
+	if stopErr != nil {
+		err = fmt.Errorf("%w (remediation stopped: %w)", err, stopErr)
+	}
+	return result, err
+}
+
+// quarantineRecipe stores a recipe that failed the safety gate
+func (s *EnhancedRecipeGeneratorService) quarantineRecipe(ctx context.Context, result *SafetyGateResult, stage string) {
+	if s.quarantine == nil {
+		log.Printf("Warning: dropping recipe '%s' that failed food safety validation: %v", result.Recipe.Title, result.Safety.Violations)
+		return
+	}
+
+	id, err := s.quarantine.Add(QuarantinedRecipe{
+		Recipe:     *result.Recipe,
+		Violations: result.Safety.Violations,
+		Stage:      stage,
+		Endpoint:   UsageSourceFromContext(ctx).Endpoint,
+		Rounds:     len(result.Rounds),
+	})
+	if err != nil {
+		log.Printf("Warning: failed to quarantine recipe '%s': %v", result.Recipe.Title, err)
+		return
+	}
+	result.QuarantineID = id
+	log.Printf("Quarantined recipe '%s' as %d after %d remediation round(s): %v",
+		result.Recipe.Title, id, len(result.Rounds), result.Safety.Violations)
+}
+
+// remediateRecipe asks the critique model to fix a recipe's safety violations
+func (s *EnhancedRecipeGeneratorService) remediateRecipe(ctx context.Context, recipe *models.RecipeData, safety *SafetyCheckResult) (*models.RecipeData, int, error) {
+	prompt, err := GetSafetyRemediationPrompt(recipe, safety)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.rateLimiter.Wait(ctx); err != nil {
+		return nil, 0, fmt.Errorf("rate limiting error: %w", err)
+	}
+
+	model := s.selectModelForStage(StageCritique)
+	chatReq := openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: prompt.SystemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: prompt.UserPrompt},
+		},
+	}
+	if !s.isGPT5Model(model) {
+		chatReq.Temperature = s.config.Temperature
+		chatReq.MaxTokens = s.config.MaxTokens
+	}
+
+	ctx = WithUsageSource(ctx, UsageSource{Stage: string(StageCritique)})
+	timeoutCtx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
+	defer cancel()
+
+	resp, err := s.provider.CreateChatCompletion(timeoutCtx, chatReq)
+	if err != nil {
+		return nil, 0, fmt.Errorf("critique model call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, resp.Usage.TotalTokens, errors.New("no choices returned from critique model")
+	}
+
+	content := extractJSONContent(resp.Choices[0].Message.Content)
+	var corrected models.RecipeData
+	if err := json.Unmarshal([]byte(content), &corrected); err != nil {
+		return nil, resp.Usage.TotalTokens, fmt.Errorf("failed to parse remediated recipe JSON: %w", err)
+	}
+	if err := fixRecipeInconsistencies(&corrected, content); err != nil {
+		return nil, resp.Usage.TotalTokens, fmt.Errorf("failed to fix remediated recipe: %w", err)
+	}
+	if err := corrected.Validate(); err != nil {
+		return nil, resp.Usage.TotalTokens, fmt.Errorf("remediated recipe is invalid: %w", err)
+	}
+
+	return &corrected, resp.Usage.TotalTokens, nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"lazychef/internal/config"
+	"lazychef/internal/models"
+)
+
+// newLocalSafetyGate creates an enhanced generator in strict mode whose
+// critique model is the local provider
+func newLocalSafetyGate(t *testing.T, rounds int) *EnhancedRecipeGeneratorService {
+	t.Helper()
+
+	cfg := &config.OpenAIConfig{
+		Provider:                config.ProviderLocal,
+		Model:                   "local",
+		CritiqueModel:           "local",
+		MaxTokens:               1000,
+		RequestsPerMinute:       600,
+		RequestTimeout:          5 * time.Second,
+		FoodSafetyStrictMode:    true,
+		SafetyRemediationRounds: rounds,
+	}
+	provider, err := NewLLMProvider(cfg)
+	require.NoError(t, err)
+	return NewEnhancedRecipeGeneratorService(provider, cfg, NewRateLimiter(600), NewRecipeCache(10, time.Minute))
+}
+
+func TestGateRecipe_RemediatesMissingDonenessCue(t *testing.T) {
+	gate := newLocalSafetyGate(t, 2)
+	recipe := &models.RecipeData{
+		Title:         "鶏もも肉の照り焼き",
+		CookingTime:   15,
+		LazinessScore: 8,
+		Ingredients:   []models.Ingredient{{Name: "鶏もも肉", Amount: "200g"}, {Name: "醤油", Amount: "大さじ2"}},
+		Steps:         []string{"鶏もも肉を一口大に切る", "フライパンで焼く", "醤油を絡める"},
+	}
+
+	result, err := gate.GateRecipe(context.Background(), recipe, "generation")
+	require.NoError(t, err)
+
+	assert.True(t, result.Safety.Passed)
+	assert.True(t, result.Remediated)
+	require.Len(t, result.Rounds, 1)
+	assert.True(t, result.Rounds[0].Passed)
+	assert.NotEmpty(t, result.Rounds[0].Violations)
+	assert.Contains(t, result.Recipe.Steps[1], "鶏もも肉は中まで火が通るまで")
+	assert.Equal(t, "鶏もも肉の照り焼き", result.Recipe.Title)
+	assert.Zero(t, result.QuarantineID)
+
+	// The caller's recipe is left as generated
+	assert.Equal(t, "フライパンで焼く", recipe.Steps[1])
+}
+
+func TestGateRecipe_QuarantinesRecipesThatStillFail(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	quarantine := NewRecipeQuarantine(db)
+	require.NoError(t, quarantine.EnsureSchema())
+
+	gate := newLocalSafetyGate(t, 1)
+	gate.SetRecipeQuarantine(quarantine)
+
+	// The unsafe dish is in the title, which remediation does not rewrite
+	recipe := &models.RecipeData{
+		Title:         "鶏むね肉のたたき",
+		CookingTime:   10,
+		LazinessScore: 8,
+		Ingredients:   []models.Ingredient{{Name: "鶏むね肉", Amount: "1枚"}},
+		Steps:         []string{"鶏むね肉の表面だけさっと焼く", "薄く切る"},
+	}
+
+	ctx := WithUsageSource(context.Background(), UsageSource{Endpoint: "POST /api/recipes/generate"})
+	result, err := gate.GateRecipe(ctx, recipe, "generation")
+	require.ErrorIs(t, err, ErrRecipeQuarantined)
+	require.NotNil(t, result)
+	assert.False(t, result.Safety.Passed)
+	assert.Len(t, result.Rounds, 1)
+	assert.Positive(t, result.QuarantineID)
+
+	entries, err := quarantine.List(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, result.QuarantineID, entries[0].ID)
+	assert.Equal(t, "鶏むね肉のたたき", entries[0].Recipe.Title)
+	assert.Equal(t, result.Safety.Violations, entries[0].Violations)
+	assert.Equal(t, "generation", entries[0].Stage)
+	assert.Equal(t, "POST /api/recipes/generate", entries[0].Endpoint)
+	assert.Equal(t, 1, entries[0].Rounds)
+}
+
+func TestGateRecipe_ZeroRoundsQuarantinesImmediately(t *testing.T) {
+	gate := newLocalSafetyGate(t, 0)
+	recipe := &models.RecipeData{
+		Title:         "豚こまの炒め物",
+		CookingTime:   10,
+		LazinessScore: 8,
+		Ingredients:   []models.Ingredient{{Name: "豚こま切れ肉", Amount: "200g"}},
+		Steps:         []string{"豚肉を炒める"},
+	}
+
+	result, err := gate.GateRecipe(context.Background(), recipe, "batch")
+	require.ErrorIs(t, err, ErrRecipeQuarantined)
+	assert.Empty(t, result.Rounds)
+	assert.False(t, result.Remediated)
+}
+
+func TestRecipeGeneratorService_SafetyGateAppliesToGeneratedRecipes(t *testing.T) {
+	service, err := NewRecipeGeneratorService(&config.OpenAIConfig{
+		Provider:          config.ProviderLocal,
+		Model:             "local",
+		MaxTokens:         1000,
+		RequestsPerMinute: 600,
+		RequestTimeout:    5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	gate := newLocalSafetyGate(t, 0)
+	service.SetSafetyGate(gate)
+
+	// The local templates include a doneness cue, so chicken passes
+	result, err := service.GenerateRecipe(context.Background(), RecipeGenerationRequest{Ingredients: []string{"鶏もも肉"}, Season: "all", MaxCookingTime: 15})
+	require.NoError(t, err)
+	require.NotNil(t, result.Recipe)
+	assert.Empty(t, result.Error)
+
+	// A recipe the gate quarantines is reported as an error, not returned for saving
+	service.SetSafetyGate(quarantiningGate{})
+	result, err = service.GenerateRecipe(context.Background(), RecipeGenerationRequest{Ingredients: []string{"豚肉"}, Season: "all", MaxCookingTime: 15})
+	require.ErrorIs(t, err, ErrRecipeQuarantined)
+	assert.Contains(t, result.Error, ErrRecipeQuarantined.Error())
+
+	_, err = service.GenerateBatchRecipes(context.Background(), BatchGenerationRequest{
+		RecipeGenerationRequest: RecipeGenerationRequest{Ingredients: []string{"鮭"}, Season: "all", MaxCookingTime: 15},
+		Count:                   2,
+	})
+	require.ErrorIs(t, err, ErrRecipeQuarantined)
+}
+
+// quarantiningGate quarantines every recipe
+type quarantiningGate struct{}
+
+func (quarantiningGate) GateRecipe(ctx context.Context, recipe *models.RecipeData, stage string) (*SafetyGateResult, error) {
+	return &SafetyGateResult{Recipe: recipe}, ErrRecipeQuarantined
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"lazychef/internal/database"
+)
+
+// SQLiteRecipeCache is the persistent GenerationCache tier. Entries survive
+// restarts and are shared by every replica using the same database.
+type SQLiteRecipeCache struct {
+	db  *database.Database
+	ttl time.Duration
+}
+
+// NewSQLiteRecipeCache creates a SQLite-backed cache whose entries expire after ttl
+func NewSQLiteRecipeCache(db *database.Database, ttl time.Duration) *SQLiteRecipeCache {
+	return &SQLiteRecipeCache{db: db, ttl: ttl}
+}
+
+// EnsureSchema creates the generation_cache table if it doesn't exist
+func (c *SQLiteRecipeCache) EnsureSchema() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS generation_cache (
+			cache_key TEXT PRIMARY KEY,
+			result JSON NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			CHECK (json_valid(result))
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_generation_cache_expires_at ON generation_cache(expires_at)`,
+	}
+	for _, stmt := range statements {
+		if _, err := c.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create generation_cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// Name returns the tier name used in cache metrics
+func (c *SQLiteRecipeCache) Name() string {
+	return "sqlite"
+}
+
+// Get returns the unexpired result stored under key, or nil
+func (c *SQLiteRecipeCache) Get(key string) *GenerationResult {
+	var data string
+	err := c.db.QueryRow(`SELECT result FROM generation_cache WHERE cache_key = ? AND expires_at > ?`,
+		key, c.now()).Scan(&data)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Warning: generation cache read failed: %v", err)
+		}
+		return nil
+	}
+
+	var result GenerationResult
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		log.Printf("Warning: discarding unreadable generation cache entry %q: %v", key, err)
+		c.Delete(key)
+		return nil
+	}
+	return &result
+}
+
+// Set stores value under key, replacing any existing entry
+func (c *SQLiteRecipeCache) Set(key string, value *GenerationResult) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Warning: generation cache entry %q not stored: %v", key, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	_, err = c.db.Exec(`
+		INSERT INTO generation_cache (cache_key, result, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(cache_key) DO UPDATE SET
+			result = excluded.result,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+	`, key, string(data), now.Format(ledgerTimeFormat), now.Add(c.ttl).Format(ledgerTimeFormat))
+	if err != nil {
+		log.Printf("Warning: generation cache write failed: %v", err)
+	}
+}
+
+// Delete removes key from the cache
+func (c *SQLiteRecipeCache) Delete(key string) {
+	if _, err := c.db.Exec(`DELETE FROM generation_cache WHERE cache_key = ?`, key); err != nil {
+		log.Printf("Warning: generation cache delete failed: %v", err)
+	}
+}
+
+// Clear removes every entry
+func (c *SQLiteRecipeCache) Clear() {
+	if _, err := c.db.Exec(`DELETE FROM generation_cache`); err != nil {
+		log.Printf("Warning: generation cache clear failed: %v", err)
+	}
+}
+
+// Size returns the number of unexpired entries
+func (c *SQLiteRecipeCache) Size() int {
+	var count int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM generation_cache WHERE expires_at > ?`, c.now()).Scan(&count); err != nil {
+		log.Printf("Warning: generation cache count failed: %v", err)
+	}
+	return count
+}
+
+// Keys returns the keys of unexpired entries
+func (c *SQLiteRecipeCache) Keys() []string {
+	rows, err := c.db.Query(`SELECT cache_key FROM generation_cache WHERE expires_at > ? ORDER BY cache_key`, c.now())
+	if err != nil {
+		log.Printf("Warning: generation cache key listing failed: %v", err)
+		return nil
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			log.Printf("Warning: generation cache key listing failed: %v", err)
+			return keys
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Warning: generation cache key listing failed: %v", err)
+	}
+	return keys
+}
+
+// PurgeExpired deletes expired entries and returns how many were removed
+func (c *SQLiteRecipeCache) PurgeExpired() (int64, error) {
+	result, err := c.db.Exec(`DELETE FROM generation_cache WHERE expires_at <= ?`, c.now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge generation cache: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (c *SQLiteRecipeCache) now() string {
+	return time.Now().UTC().Format(ledgerTimeFormat)
+}
@@ -22,7 +22,19 @@ type TokenRateLimiter struct {
 	backoffConfig  *BackoffConfig
 	costTracker    *CostTracker
 	costTrackerMu  sync.RWMutex
+	zeroCostModels map[string]bool // Self-hosted models with no per-token price
+	pricing        *PricingTable   // Versioned per-token prices
+	ledger         *UsageLedger    // Optional persistent record of every call
 	mu             sync.RWMutex
+
+	// Daily sub-budgets and alerting, guarded by costTrackerMu
+	endpointBudgets   map[string]float64
+	userBudgets       map[string]float64
+	defaultUserBudget float64
+	endpointSpent     map[string]float64
+	userSpent         map[string]float64
+	alertsFired       map[string]bool
+	alertHooks        []func(BudgetAlert)
 }
 
 // TokenBucket implements token bucket algorithm for token-level rate limiting
@@ -110,6 +122,12 @@ func NewTokenRateLimiter(requestsPerMinute int, tokensPerSecond int, dailyBudget
 			LastResetMonthly: time.Date(time.Now().Year(), time.Now().Month(), 1, 0, 0, 0, 0, time.UTC),
 			AlertThreshold:   0.8,
 		},
+		pricing:         NewPricingTable(nil),
+		endpointBudgets: make(map[string]float64),
+		userBudgets:     make(map[string]float64),
+		endpointSpent:   make(map[string]float64),
+		userSpent:       make(map[string]float64),
+		alertsFired:     make(map[string]bool),
 	}
 }
 
@@ -222,6 +240,8 @@ func (t *TokenRateLimiter) ReserveTokens(estimate TokenUsageEstimate) bool {
 
 // RecordUsage records actual token usage and cost after a successful request
 func (t *TokenRateLimiter) RecordUsage(usage openai.Usage, actualCostUSD float64) {
+	t.updateCostTracker()
+
 	t.metricsMu.Lock()
 	t.metrics.TotalRequests++
 	t.metrics.TotalTokensUsed += int64(usage.TotalTokens)
@@ -237,6 +257,113 @@ func (t *TokenRateLimiter) RecordUsage(usage openai.Usage, actualCostUSD float64
 	t.costTrackerMu.Unlock()
 }
 
+// RecordCall prices a completed call, adds it to the metrics and budgets and
+// writes it to the ledger, attributed to the UsageSource on ctx. stage is
+// used when the context doesn't name one. Returns the cost in USD.
+func (t *TokenRateLimiter) RecordCall(ctx context.Context, provider, model, stage string, usage openai.Usage) float64 {
+	return t.recordCall(ctx, provider, model, stage, usage, time.Now(), false)
+}
+
+// RecordBatchCall is RecordCall for Batch API results, which are discounted.
+// They are priced at submittedAt, since results may be retrieved after a
+// price change.
+func (t *TokenRateLimiter) RecordBatchCall(ctx context.Context, provider, model string, submittedAt time.Time, usage openai.Usage) float64 {
+	return t.recordCall(ctx, provider, model, "batch", usage, submittedAt, true)
+}
+
+func (t *TokenRateLimiter) recordCall(ctx context.Context, provider, model, stage string, usage openai.Usage, pricedAt time.Time, batch bool) float64 {
+	cost := t.priceUsage(model, pricedAt, usage, batch)
+	t.RecordUsage(usage, cost)
+
+	source := UsageSourceFromContext(ctx)
+	t.recordSubSpend(source, cost)
+
+	t.mu.RLock()
+	ledger := t.ledger
+	t.mu.RUnlock()
+	if ledger == nil {
+		return cost
+	}
+
+	if source.Stage != "" {
+		stage = source.Stage
+	}
+	entry := &UsageEntry{
+		Provider:         provider,
+		Model:            model,
+		Stage:            stage,
+		Endpoint:         source.Endpoint,
+		JobID:            source.JobID,
+		UserID:           source.UserID,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		CostUSD:          cost,
+	}
+	if err := ledger.Record(entry); err != nil {
+		log.Printf("Warning: failed to write token usage ledger: %v", err)
+	}
+
+	return cost
+}
+
+// SetLedger attaches a usage ledger and rebuilds daily, monthly, endpoint and
+// user spend from it, so budgets hold across restarts
+func (t *TokenRateLimiter) SetLedger(ledger *UsageLedger) error {
+	t.mu.Lock()
+	t.ledger = ledger
+	t.mu.Unlock()
+
+	t.updateCostTracker()
+
+	t.costTrackerMu.RLock()
+	dailyStart := t.costTracker.LastResetDaily
+	monthlyStart := t.costTracker.LastResetMonthly
+	t.costTrackerMu.RUnlock()
+
+	dailySpent, err := ledger.SpentSince(dailyStart)
+	if err != nil {
+		return err
+	}
+	monthlySpent, err := ledger.SpentSince(monthlyStart)
+	if err != nil {
+		return err
+	}
+	byEndpoint, err := ledger.Query(UsageQuery{From: dailyStart, GroupBy: "endpoint"})
+	if err != nil {
+		return err
+	}
+	byUser, err := ledger.Query(UsageQuery{From: dailyStart, GroupBy: "user"})
+	if err != nil {
+		return err
+	}
+
+	t.costTrackerMu.Lock()
+	t.costTracker.DailySpentUSD = dailySpent
+	t.costTracker.MonthlySpentUSD = monthlySpent
+	t.resetSubBudgets(false)
+	for _, group := range byEndpoint.Groups {
+		if group.Key != "" {
+			t.endpointSpent[group.Key] = group.CostUSD
+		}
+	}
+	for _, group := range byUser.Groups {
+		if group.Key != "" {
+			t.userSpent[group.Key] = group.CostUSD
+		}
+	}
+	t.costTrackerMu.Unlock()
+
+	return nil
+}
+
+// GetLedger returns the attached usage ledger, or nil
+func (t *TokenRateLimiter) GetLedger() *UsageLedger {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.ledger
+}
+
 // RecordRateLimitHit records when a 429 error occurs
 func (t *TokenRateLimiter) RecordRateLimitHit() {
 	t.metricsMu.Lock()
@@ -321,6 +448,33 @@ func (t *TokenRateLimiter) SetBudgets(dailyUSD, monthlyUSD float64) {
 	t.costTrackerMu.Unlock()
 }
 
+// SetZeroCostModels marks models served by self-hosted backends, which are
+// tracked for token usage but never count against the budgets
+func (t *TokenRateLimiter) SetZeroCostModels(models []string) {
+	zeroCost := make(map[string]bool, len(models))
+	for _, model := range models {
+		zeroCost[model] = true
+	}
+
+	t.costTrackerMu.Lock()
+	t.zeroCostModels = zeroCost
+	t.costTrackerMu.Unlock()
+}
+
+// SetPricingTable replaces the price table used for estimates and recorded costs
+func (t *TokenRateLimiter) SetPricingTable(pricing *PricingTable) {
+	t.costTrackerMu.Lock()
+	t.pricing = pricing
+	t.costTrackerMu.Unlock()
+}
+
+// GetPricingTable returns the price table used for estimates and recorded costs
+func (t *TokenRateLimiter) GetPricingTable() *PricingTable {
+	t.costTrackerMu.RLock()
+	defer t.costTrackerMu.RUnlock()
+	return t.pricing
+}
+
 // ResetDailyUsage manually resets daily usage (for testing)
 func (t *TokenRateLimiter) ResetDailyUsage() {
 	t.costTrackerMu.Lock()
@@ -366,6 +520,7 @@ func (t *TokenRateLimiter) updateCostTracker() {
 	if now.Truncate(24 * time.Hour).After(t.costTracker.LastResetDaily) {
 		t.costTracker.DailySpentUSD = 0
 		t.costTracker.LastResetDaily = now.Truncate(24 * time.Hour)
+		t.resetSubBudgets(false)
 	}
 
 	// Check if we need to reset monthly usage
@@ -373,39 +528,30 @@ func (t *TokenRateLimiter) updateCostTracker() {
 	if monthStart.After(t.costTracker.LastResetMonthly) {
 		t.costTracker.MonthlySpentUSD = 0
 		t.costTracker.LastResetMonthly = monthStart
+		t.resetSubBudgets(true)
 	}
 }
 
 func (t *TokenRateLimiter) estimateCost(model string, promptTokens, completionTokens int) float64 {
-	// Cost estimation based on OpenAI pricing (as of 2024)
-	// These would need to be updated with current pricing
-
-	var promptCostPer1k, completionCostPer1k float64
-
-	switch {
-	case strings.Contains(model, "gpt-4-turbo"):
-		promptCostPer1k = 0.01     // $0.01 per 1K prompt tokens
-		completionCostPer1k = 0.03 // $0.03 per 1K completion tokens
-	case strings.Contains(model, "gpt-4"):
-		promptCostPer1k = 0.03     // $0.03 per 1K prompt tokens
-		completionCostPer1k = 0.06 // $0.06 per 1K completion tokens
-	case strings.Contains(model, "gpt-3.5-turbo"):
-		promptCostPer1k = 0.001     // $0.001 per 1K prompt tokens
-		completionCostPer1k = 0.002 // $0.002 per 1K completion tokens
-	case strings.HasPrefix(model, "gpt-5"):
-		// GPT-5 pricing - estimated
-		promptCostPer1k = 0.05     // $0.05 per 1K prompt tokens
-		completionCostPer1k = 0.15 // $0.15 per 1K completion tokens
-	default:
-		// Default to GPT-3.5-turbo pricing
-		promptCostPer1k = 0.001
-		completionCostPer1k = 0.002
-	}
-
-	promptCost := (float64(promptTokens) / 1000) * promptCostPer1k
-	completionCost := (float64(completionTokens) / 1000) * completionCostPer1k
-
-	return promptCost + completionCost
+	return t.priceUsage(model, time.Now(), openai.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}, false)
+}
+
+// priceUsage prices usage with the version of the model's price in effect at
+// the given time. Self-hosted models are always free.
+func (t *TokenRateLimiter) priceUsage(model string, at time.Time, usage openai.Usage, batch bool) float64 {
+	t.costTrackerMu.RLock()
+	zeroCost := t.zeroCostModels[model]
+	pricing := t.pricing
+	t.costTrackerMu.RUnlock()
+	if zeroCost {
+		return 0
+	}
+
+	return pricing.Cost(model, at, usage, batch)
 }
 
 // HandleRateLimitError implements exponential backoff for 429 errors
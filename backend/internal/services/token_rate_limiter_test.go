@@ -0,0 +1,24 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenRateLimiter_ZeroCostModels(t *testing.T) {
+	limiter := NewTokenRateLimiter(60, 1000, 1.0, 10.0)
+	defer limiter.Stop()
+
+	assert.Greater(t, limiter.estimateCost("gpt-4", 1000, 1000), 0.0)
+	assert.Greater(t, limiter.estimateCost("llama3.1:8b", 1000, 1000), 0.0) // Unknown models default to paid pricing
+
+	limiter.SetZeroCostModels([]string{"llama3.1:8b", "nomic-embed-text"})
+
+	assert.Equal(t, 0.0, limiter.estimateCost("llama3.1:8b", 1000, 1000))
+	assert.Greater(t, limiter.estimateCost("gpt-4", 1000, 1000), 0.0)
+
+	estimate := limiter.EstimateTokenUsage(nil, "llama3.1:8b", 500)
+	assert.Equal(t, 0.0, estimate.EstimatedCostUSD)
+	assert.Equal(t, 500, estimate.EstimatedTotalTokens)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"lazychef/internal/database"
+)
+
+// ledgerTimeFormat stores timestamps as sortable UTC text
+const ledgerTimeFormat = "2006-01-02 15:04:05"
+
+// ErrInvalidUsageGroupBy is returned when a ledger query groups by an unknown field
+var ErrInvalidUsageGroupBy = errors.New("invalid usage group_by")
+
+// usageGroupExpressions maps group_by values to SQL expressions
+var usageGroupExpressions = map[string]string{
+	"model":    "model",
+	"stage":    "stage",
+	"endpoint": "endpoint",
+	"job":      "job_id",
+	"user":     "user_id",
+	"provider": "provider",
+	"hour":     "strftime('%Y-%m-%d %H:00', created_at)",
+	"day":      "strftime('%Y-%m-%d', created_at)",
+	"month":    "strftime('%Y-%m', created_at)",
+}
+
+// UsageSource identifies what triggered an LLM call. It travels on the
+// request context so the provider layer can attribute usage.
+type UsageSource struct {
+	Endpoint string `json:"endpoint,omitempty"` // API route or background task
+	JobID    string `json:"job_id,omitempty"`   // Batch or generation job
+	Stage    string `json:"stage,omitempty"`    // ideation, authoring, critique, embedding...
+	UserID   string `json:"user_id,omitempty"`  // Requesting user, for per-user budgets
+}
+
+type usageSourceKey struct{}
+
+// WithUsageSource returns a context carrying source, keeping any fields it
+// leaves empty from the source already on ctx
+func WithUsageSource(ctx context.Context, source UsageSource) context.Context {
+	current := UsageSourceFromContext(ctx)
+	if source.Endpoint == "" {
+		source.Endpoint = current.Endpoint
+	}
+	if source.JobID == "" {
+		source.JobID = current.JobID
+	}
+	if source.Stage == "" {
+		source.Stage = current.Stage
+	}
+	if source.UserID == "" {
+		source.UserID = current.UserID
+	}
+	return context.WithValue(ctx, usageSourceKey{}, source)
+}
+
+// UsageSourceFromContext returns the usage source on ctx, if any
+func UsageSourceFromContext(ctx context.Context) UsageSource {
+	if ctx == nil {
+		return UsageSource{}
+	}
+	source, _ := ctx.Value(usageSourceKey{}).(UsageSource)
+	return source
+}
+
+// UsageEntry is one LLM call in the usage ledger
+type UsageEntry struct {
+	ID               int64     `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Stage            string    `json:"stage"`
+	Endpoint         string    `json:"endpoint,omitempty"`
+	JobID            string    `json:"job_id,omitempty"`
+	UserID           string    `json:"user_id,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+}
+
+// UsageQuery selects and groups ledger entries
+type UsageQuery struct {
+	From    time.Time // Inclusive; zero means no lower bound
+	To      time.Time // Exclusive; zero means no upper bound
+	GroupBy string    // One of model, stage, endpoint, job, user, provider, hour, day, month; empty for totals only
+}
+
+// UsageSummary aggregates ledger entries
+type UsageSummary struct {
+	Key              string  `json:"key,omitempty"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// UsageReport is the result of a ledger query
+type UsageReport struct {
+	From    *time.Time     `json:"from,omitempty"`
+	To      *time.Time     `json:"to,omitempty"`
+	GroupBy string         `json:"group_by,omitempty"`
+	Totals  UsageSummary   `json:"totals"`
+	Groups  []UsageSummary `json:"groups,omitempty"`
+}
+
+// UsageLedger persists token usage and cost for every LLM call
+type UsageLedger struct {
+	db *database.Database
+}
+
+// NewUsageLedger creates a new usage ledger
+func NewUsageLedger(db *database.Database) *UsageLedger {
+	return &UsageLedger{db: db}
+}
+
+// EnsureSchema creates the token_usage_ledger table if it doesn't exist
+func (l *UsageLedger) EnsureSchema() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS token_usage_ledger (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at DATETIME NOT NULL,
+			provider TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL,
+			stage TEXT NOT NULL DEFAULT '',
+			endpoint TEXT NOT NULL DEFAULT '',
+			job_id TEXT NOT NULL DEFAULT '',
+			user_id TEXT NOT NULL DEFAULT '',
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			total_tokens INTEGER NOT NULL DEFAULT 0,
+			cost_usd REAL NOT NULL DEFAULT 0,
+			CHECK (cost_usd >= 0)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_token_usage_created_at ON token_usage_ledger(created_at)`,
+	}
+	for _, stmt := range statements {
+		if _, err := l.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create token_usage_ledger: %w", err)
+		}
+	}
+
+	// Ledgers created before per-user budgets lack user_id
+	var hasUserID int
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('token_usage_ledger') WHERE name = 'user_id'`).Scan(&hasUserID); err != nil {
+		return fmt.Errorf("failed to inspect token_usage_ledger: %w", err)
+	}
+	if hasUserID == 0 {
+		if _, err := l.db.Exec(`ALTER TABLE token_usage_ledger ADD COLUMN user_id TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("failed to add user_id to token_usage_ledger: %w", err)
+		}
+	}
+	return nil
+}
+
+// Record appends an entry to the ledger
+func (l *UsageLedger) Record(entry *UsageEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	result, err := l.db.Exec(`
+		INSERT INTO token_usage_ledger
+		(created_at, provider, model, stage, endpoint, job_id, user_id, prompt_tokens, completion_tokens, total_tokens, cost_usd)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.CreatedAt.UTC().Format(ledgerTimeFormat), entry.Provider, entry.Model, entry.Stage, entry.Endpoint,
+		entry.JobID, entry.UserID, entry.PromptTokens, entry.CompletionTokens, entry.TotalTokens, entry.CostUSD)
+	if err != nil {
+		return fmt.Errorf("failed to record token usage: %w", err)
+	}
+
+	if entry.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get ledger entry ID: %w", err)
+	}
+	return nil
+}
+
+// SpentSince returns the total cost recorded at or after since
+func (l *UsageLedger) SpentSince(since time.Time) (float64, error) {
+	var spent float64
+	err := l.db.QueryRow(`SELECT COALESCE(SUM(cost_usd), 0) FROM token_usage_ledger WHERE created_at >= ?`,
+		since.UTC().Format(ledgerTimeFormat)).Scan(&spent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum token usage cost: %w", err)
+	}
+	return spent, nil
+}
+
+// Query aggregates ledger entries in a time range, optionally grouped
+func (l *UsageLedger) Query(q UsageQuery) (*UsageReport, error) {
+	groupExpr := ""
+	if q.GroupBy != "" {
+		var ok bool
+		if groupExpr, ok = usageGroupExpressions[q.GroupBy]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidUsageGroupBy, q.GroupBy)
+		}
+	}
+
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	report := &UsageReport{GroupBy: q.GroupBy}
+	if !q.From.IsZero() {
+		where += ` AND created_at >= ?`
+		args = append(args, q.From.UTC().Format(ledgerTimeFormat))
+		report.From = &q.From
+	}
+	if !q.To.IsZero() {
+		where += ` AND created_at < ?`
+		args = append(args, q.To.UTC().Format(ledgerTimeFormat))
+		report.To = &q.To
+	}
+
+	const aggregates = `COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+		COALESCE(SUM(total_tokens), 0), COALESCE(SUM(cost_usd), 0)`
+
+	totals := &report.Totals
+	if err := l.db.QueryRow(`SELECT `+aggregates+` FROM token_usage_ledger`+where, args...).Scan(
+		&totals.Requests, &totals.PromptTokens, &totals.CompletionTokens, &totals.TotalTokens, &totals.CostUSD); err != nil {
+		return nil, fmt.Errorf("failed to query token usage totals: %w", err)
+	}
+
+	if groupExpr == "" {
+		return report, nil
+	}
+
+	rows, err := l.db.Query(`SELECT `+groupExpr+` AS group_key, `+aggregates+` FROM token_usage_ledger`+where+
+		` GROUP BY group_key ORDER BY group_key`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query grouped token usage: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Warning: failed to close rows: %v", err)
+		}
+	}()
+
+	report.Groups = []UsageSummary{}
+	for rows.Next() {
+		var s UsageSummary
+		if err := rows.Scan(&s.Key, &s.Requests, &s.PromptTokens, &s.CompletionTokens, &s.TotalTokens, &s.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan grouped token usage: %w", err)
+		}
+		report.Groups = append(report.Groups, s)
+	}
+
+	return report, rows.Err()
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageLedger_QueryGroupsByField(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	ledger := NewUsageLedger(db)
+
+	day := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	entries := []UsageEntry{
+		{CreatedAt: day, Model: "gpt-5", Stage: "authoring", PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150, CostUSD: 0.01},
+		{CreatedAt: day.Add(time.Hour), Model: "gpt-5-mini", Stage: "ideation", PromptTokens: 40, CompletionTokens: 10, TotalTokens: 50, CostUSD: 0.001},
+		{CreatedAt: day.Add(24 * time.Hour), Model: "gpt-5", Stage: "critique", PromptTokens: 200, CompletionTokens: 20, TotalTokens: 220, CostUSD: 0.02},
+	}
+	for i := range entries {
+		require.NoError(t, ledger.Record(&entries[i]))
+		assert.NotZero(t, entries[i].ID)
+	}
+
+	report, err := ledger.Query(UsageQuery{GroupBy: "model"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), report.Totals.Requests)
+	assert.Equal(t, int64(420), report.Totals.TotalTokens)
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, "gpt-5", report.Groups[0].Key)
+	assert.Equal(t, int64(2), report.Groups[0].Requests)
+	assert.InDelta(t, 0.03, report.Groups[0].CostUSD, 1e-9)
+
+	// The range is inclusive of From and exclusive of To
+	report, err = ledger.Query(UsageQuery{From: day, To: day.Add(24 * time.Hour), GroupBy: "stage"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), report.Totals.Requests)
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, "authoring", report.Groups[0].Key)
+	assert.Equal(t, "ideation", report.Groups[1].Key)
+
+	report, err = ledger.Query(UsageQuery{GroupBy: "day"})
+	require.NoError(t, err)
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, "2025-03-10", report.Groups[0].Key)
+
+	_, err = ledger.Query(UsageQuery{GroupBy: "colour"})
+	assert.ErrorIs(t, err, ErrInvalidUsageGroupBy)
+}
+
+func TestTokenRateLimiter_SpendSurvivesRestart(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	ledger := NewUsageLedger(db)
+
+	limiter := NewTokenRateLimiter(60, 1000, 100.0, 1000.0)
+	require.NoError(t, limiter.SetLedger(ledger))
+
+	ctx := WithUsageSource(context.Background(), UsageSource{Endpoint: "POST /api/recipes/generate"})
+	usage := openai.Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}
+	cost := limiter.RecordCall(ctx, "openai", "gpt-4", "generation", usage)
+	assert.Greater(t, cost, 0.0)
+	batchCost := limiter.RecordBatchCall(WithUsageSource(ctx, UsageSource{JobID: "job-1"}), "openai", "gpt-4", time.Now(), usage)
+	assert.InDelta(t, cost*0.5, batchCost, 1e-9)
+	limiter.Stop()
+
+	// A fresh limiter starts from what the ledger has recorded this month
+	restarted := NewTokenRateLimiter(60, 1000, 100.0, 1000.0)
+	defer restarted.Stop()
+	require.NoError(t, restarted.SetLedger(ledger))
+
+	status := restarted.GetCostStatus()
+	assert.InDelta(t, cost+batchCost, status.DailySpentUSD, 1e-9)
+	assert.InDelta(t, cost+batchCost, status.MonthlySpentUSD, 1e-9)
+
+	report, err := ledger.Query(UsageQuery{GroupBy: "job"})
+	require.NoError(t, err)
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, "", report.Groups[0].Key)
+	assert.Equal(t, "job-1", report.Groups[1].Key)
+
+	report, err = ledger.Query(UsageQuery{GroupBy: "endpoint"})
+	require.NoError(t, err)
+	require.Len(t, report.Groups, 1)
+	assert.Equal(t, "POST /api/recipes/generate", report.Groups[0].Key)
+	assert.Equal(t, int64(2), report.Groups[0].Requests)
+}
+
+func TestMeteredProvider_RecordsEveryCall(t *testing.T) {
+	db := setupSchemaDatabase(t)
+	ledger := NewUsageLedger(db)
+	limiter := NewTokenRateLimiter(60, 1000, 100.0, 1000.0)
+	defer limiter.Stop()
+	require.NoError(t, limiter.SetLedger(ledger))
+
+	provider := NewMeteredProvider(NewLocalProvider(), limiter)
+	ctx := WithUsageSource(context.Background(), UsageSource{Stage: "ideation"})
+
+	_, err := provider.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:    "gpt-5-mini",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "鶏肉を使ったレシピ"}},
+	})
+	require.NoError(t, err)
+	_, err = provider.CreateEmbeddings(context.Background(), openai.EmbeddingRequest{
+		Model: openai.SmallEmbedding3,
+		Input: []string{"鶏肉のソテー"},
+	})
+	require.NoError(t, err)
+
+	report, err := ledger.Query(UsageQuery{GroupBy: "stage"})
+	require.NoError(t, err)
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, "embedding", report.Groups[0].Key)
+	assert.Equal(t, "ideation", report.Groups[1].Key)
+	assert.Greater(t, report.Totals.TotalTokens, int64(0))
+	assert.Equal(t, int64(2), limiter.GetMetrics().TotalRequests)
+}
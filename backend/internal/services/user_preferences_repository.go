@@ -0,0 +1,73 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"lazychef/internal/database"
+	"lazychef/internal/models"
+)
+
+// UserPreferencesRepository handles user preference database operations
+type UserPreferencesRepository struct {
+	db *database.Database
+}
+
+// NewUserPreferencesRepository creates a new user preferences repository
+func NewUserPreferencesRepository(db *database.Database) *UserPreferencesRepository {
+	return &UserPreferencesRepository{
+		db: db,
+	}
+}
+
+// GetPreferences retrieves the stored preferences of a user
+func (r *UserPreferencesRepository) GetPreferences(userID string) (*models.UserPreferences, error) {
+	query := `
+		SELECT id, preferences, updated_at FROM user_preferences WHERE user_id = ?
+	`
+
+	var id int
+	var data string
+	var updatedAt sql.NullTime
+	row := r.db.QueryRow(query, userID)
+	if err := row.Scan(&id, &data, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to query user preferences: %w", err)
+	}
+
+	var prefs models.UserPreferencesData
+	if err := json.Unmarshal([]byte(data), &prefs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user preferences: %w", err)
+	}
+
+	return &models.UserPreferences{
+		ID:          id,
+		UserID:      userID,
+		Preferences: prefs,
+		UpdatedAt:   updatedAt.Time,
+	}, nil
+}
+
+// SavePreferences creates or replaces the preferences of a user
+func (r *UserPreferencesRepository) SavePreferences(userID string, prefs models.UserPreferencesData) (*models.UserPreferences, error) {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user preferences: %w", err)
+	}
+
+	query := `
+		INSERT INTO user_preferences (user_id, preferences, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET
+			preferences = excluded.preferences,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	if err := r.db.Execute(query, userID, string(data)); err != nil {
+		return nil, fmt.Errorf("failed to save user preferences: %w", err)
+	}
+
+	return r.GetPreferences(userID)
+}
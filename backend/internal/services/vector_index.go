@@ -0,0 +1,388 @@
+package services
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// HNSW parameters tuned for recipe embeddings (1536 dims, tens of thousands of vectors)
+const (
+	hnswM              = 16  // Neighbours per node on upper layers
+	hnswMaxNeighbours0 = 32  // Neighbours per node on layer 0
+	hnswEfConstruction = 100 // Candidate list size while inserting
+	hnswEfSearch       = 64  // Minimum candidate list size while querying
+
+	// hnswMaxTombstoneRatio is the share of tombstoned nodes at which the
+	// graph is rebuilt from the live vectors
+	hnswMaxTombstoneRatio = 0.25
+)
+
+// VectorMatch is a single nearest-neighbour result
+type VectorMatch struct {
+	ID         int     `json:"id"`
+	Similarity float64 `json:"similarity"` // Cosine similarity
+}
+
+// VectorIndex is an in-process approximate nearest-neighbour index over unit
+// vectors using a Hierarchical Navigable Small World graph. Replaced or
+// removed vectors are tombstoned and skipped in results until tombstones
+// pass hnswMaxTombstoneRatio, when the graph is rebuilt without them.
+type VectorIndex struct {
+	mu         sync.RWMutex
+	dimensions int
+	nodes      []*hnswNode
+	byID       map[int]int32
+	entry      int32
+	maxLevel   int
+	live       int
+	levelMult  float64
+	rng        *rand.Rand
+}
+
+type hnswNode struct {
+	id         int
+	vector     []float32
+	neighbours [][]int32 // Per layer
+	deleted    bool
+}
+
+// NewVectorIndex creates an empty index
+func NewVectorIndex() *VectorIndex {
+	return &VectorIndex{
+		byID:      make(map[int]int32),
+		entry:     -1,
+		levelMult: 1 / math.Log(float64(hnswM)),
+		rng:       rand.New(rand.NewSource(1)),
+	}
+}
+
+// Len returns the number of live vectors
+func (x *VectorIndex) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.live
+}
+
+// Dimensions returns the vector size, or 0 while the index is empty
+func (x *VectorIndex) Dimensions() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.dimensions
+}
+
+// Add inserts or replaces the vector stored for id
+func (x *VectorIndex) Add(id int, vector []float32) error {
+	if len(vector) == 0 {
+		return fmt.Errorf("empty vector for id %d", id)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.dimensions == 0 {
+		x.dimensions = len(vector)
+	} else if len(vector) != x.dimensions {
+		return fmt.Errorf("vector for id %d has %d dimensions, index has %d", id, len(vector), x.dimensions)
+	}
+
+	if existing, ok := x.byID[id]; ok && !x.nodes[existing].deleted {
+		x.nodes[existing].deleted = true
+		x.live--
+	}
+
+	x.insert(id, normalizeVector(vector))
+	x.compactIfNeeded()
+	return nil
+}
+
+// insert links a unit vector into the graph; the caller holds the write lock
+func (x *VectorIndex) insert(id int, vector []float32) {
+	level := int(math.Floor(-math.Log(1-x.rng.Float64()) * x.levelMult))
+	node := &hnswNode{
+		id:         id,
+		vector:     vector,
+		neighbours: make([][]int32, level+1),
+	}
+	nodeIdx := int32(len(x.nodes))
+	x.nodes = append(x.nodes, node)
+	x.byID[id] = nodeIdx
+	x.live++
+
+	if x.entry < 0 {
+		x.entry = nodeIdx
+		x.maxLevel = level
+		return
+	}
+
+	// Greedy descent through the layers above the new node
+	current := x.entry
+	for l := x.maxLevel; l > level; l-- {
+		current = x.greedyClosest(node.vector, current, l)
+	}
+
+	// Link the node on every layer it lives on
+	entryPoints := []int32{current}
+	for l := min(level, x.maxLevel); l >= 0; l-- {
+		candidates := x.searchLayer(node.vector, entryPoints, hnswEfConstruction, l)
+		maxNeighbours := hnswM
+		if l == 0 {
+			maxNeighbours = hnswMaxNeighbours0
+		}
+
+		selected := candidates
+		if len(selected) > hnswM {
+			selected = selected[:hnswM]
+		}
+		node.neighbours[l] = make([]int32, 0, len(selected))
+		for _, c := range selected {
+			node.neighbours[l] = append(node.neighbours[l], c.node)
+			x.link(c.node, nodeIdx, l, maxNeighbours)
+		}
+
+		entryPoints = entryPoints[:0]
+		for _, c := range candidates {
+			entryPoints = append(entryPoints, c.node)
+		}
+	}
+
+	if level > x.maxLevel {
+		x.maxLevel = level
+		x.entry = nodeIdx
+	}
+}
+
+// compactIfNeeded rebuilds the graph from the live vectors once tombstones
+// pass hnswMaxTombstoneRatio, so they stop costing memory and search width
+func (x *VectorIndex) compactIfNeeded() {
+	tombstones := len(x.nodes) - x.live
+	if tombstones == 0 || float64(tombstones) < hnswMaxTombstoneRatio*float64(len(x.nodes)) {
+		return
+	}
+
+	nodes := x.nodes
+	x.nodes = make([]*hnswNode, 0, x.live)
+	x.byID = make(map[int]int32, x.live)
+	x.entry = -1
+	x.maxLevel = 0
+	x.live = 0
+	for _, node := range nodes {
+		if !node.deleted {
+			x.insert(node.id, node.vector)
+		}
+	}
+}
+
+// Remove tombstones the vector stored for id
+func (x *VectorIndex) Remove(id int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if idx, ok := x.byID[id]; ok {
+		if !x.nodes[idx].deleted {
+			x.nodes[idx].deleted = true
+			x.live--
+		}
+		delete(x.byID, id)
+		x.compactIfNeeded()
+	}
+}
+
+// Search returns up to k live vectors most similar to query, best first
+func (x *VectorIndex) Search(query []float32, k int) []VectorMatch {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if x.entry < 0 || k <= 0 || len(query) != x.dimensions {
+		return nil
+	}
+
+	q := normalizeVector(query)
+	current := x.entry
+	for l := x.maxLevel; l > 0; l-- {
+		current = x.greedyClosest(q, current, l)
+	}
+
+	// Tombstones occupy candidate slots, so widen the search to compensate;
+	// compaction keeps them under hnswMaxTombstoneRatio of the nodes
+	ef := hnswEfSearch
+	if k > ef {
+		ef = k
+	}
+	ef += len(x.nodes) - x.live
+	if ef > len(x.nodes) {
+		ef = len(x.nodes)
+	}
+
+	candidates := x.searchLayer(q, []int32{current}, ef, 0)
+	matches := make([]VectorMatch, 0, k)
+	for _, c := range candidates {
+		node := x.nodes[c.node]
+		if node.deleted {
+			continue
+		}
+		// Rounding can still leave identical vectors a hair outside [-1, 1]
+		similarity := math.Max(-1, math.Min(1, c.similarity))
+		matches = append(matches, VectorMatch{ID: node.id, Similarity: similarity})
+		if len(matches) == k {
+			break
+		}
+	}
+	return matches
+}
+
+// link adds a directed edge from -> to on layer l, pruning to the closest maxNeighbours
+func (x *VectorIndex) link(from, to int32, l, maxNeighbours int) {
+	node := x.nodes[from]
+	node.neighbours[l] = append(node.neighbours[l], to)
+	if len(node.neighbours[l]) <= maxNeighbours {
+		return
+	}
+
+	ranked := make([]hnswCandidate, len(node.neighbours[l]))
+	for i, n := range node.neighbours[l] {
+		ranked[i] = hnswCandidate{node: n, similarity: dotProduct(node.vector, x.nodes[n].vector)}
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].similarity > ranked[j].similarity })
+
+	node.neighbours[l] = node.neighbours[l][:0]
+	for _, c := range ranked[:maxNeighbours] {
+		node.neighbours[l] = append(node.neighbours[l], c.node)
+	}
+}
+
+// greedyClosest walks layer l towards the node most similar to q
+func (x *VectorIndex) greedyClosest(q []float32, start int32, l int) int32 {
+	current := start
+	best := dotProduct(q, x.nodes[current].vector)
+	for improved := true; improved; {
+		improved = false
+		node := x.nodes[current]
+		if l >= len(node.neighbours) {
+			break
+		}
+		for _, n := range node.neighbours[l] {
+			if sim := dotProduct(q, x.nodes[n].vector); sim > best {
+				best, current, improved = sim, n, true
+			}
+		}
+	}
+	return current
+}
+
+// searchLayer is the HNSW beam search; it returns up to ef candidates, best first
+func (x *VectorIndex) searchLayer(q []float32, entryPoints []int32, ef, l int) []hnswCandidate {
+	visited := make(map[int32]bool, ef*4)
+	frontier := &hnswMaxHeap{}
+	results := &hnswMinHeap{}
+
+	for _, ep := range entryPoints {
+		if visited[ep] {
+			continue
+		}
+		visited[ep] = true
+		c := hnswCandidate{node: ep, similarity: dotProduct(q, x.nodes[ep].vector)}
+		heap.Push(frontier, c)
+		heap.Push(results, c)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for frontier.Len() > 0 {
+		closest := heap.Pop(frontier).(hnswCandidate)
+		if results.Len() >= ef && closest.similarity < (*results)[0].similarity {
+			break
+		}
+
+		node := x.nodes[closest.node]
+		if l >= len(node.neighbours) {
+			continue
+		}
+		for _, n := range node.neighbours[l] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			sim := dotProduct(q, x.nodes[n].vector)
+			if results.Len() < ef || sim > (*results)[0].similarity {
+				c := hnswCandidate{node: n, similarity: sim}
+				heap.Push(frontier, c)
+				heap.Push(results, c)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := make([]hnswCandidate, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return sorted
+}
+
+type hnswCandidate struct {
+	node       int32
+	similarity float64
+}
+
+// hnswMaxHeap pops the most similar candidate first
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int            { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool  { return h[i].similarity > h[j].similarity }
+func (h hnswMaxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(v interface{}) { *h = append(*h, v.(hnswCandidate)) }
+func (h *hnswMaxHeap) Pop() interface{} {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// hnswMinHeap pops the least similar candidate first
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int            { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool  { return h[i].similarity < h[j].similarity }
+func (h hnswMinHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(v interface{}) { *h = append(*h, v.(hnswCandidate)) }
+func (h *hnswMinHeap) Pop() interface{} {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// normalizeVector returns a unit-length copy so dot product equals cosine similarity
+func normalizeVector(v []float32) []float32 {
+	var norm float64
+	for _, f := range v {
+		norm += float64(f) * float64(f)
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		return out
+	}
+	scale := 1 / math.Sqrt(norm)
+	for i, f := range v {
+		out[i] = float32(float64(f) * scale)
+	}
+	return out
+}
+
+// dotProduct sums in float64; a float32 sum drifts above 1 for identical
+// unit vectors of embedding size
+func dotProduct(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomVector(rng *rand.Rand, dims int) []float32 {
+	v := make([]float32, dims)
+	for i := range v {
+		v[i] = float32(rng.NormFloat64())
+	}
+	return v
+}
+
+func TestVectorIndex_RecallAgainstBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	const dims, count, k = 64, 2000, 10
+
+	index := NewVectorIndex()
+	vectors := make([][]float32, count)
+	for i := range vectors {
+		vectors[i] = randomVector(rng, dims)
+		require.NoError(t, index.Add(i, vectors[i]))
+	}
+	assert.Equal(t, count, index.Len())
+	assert.Equal(t, dims, index.Dimensions())
+
+	hits, total := 0, 0
+	for q := 0; q < 50; q++ {
+		query := randomVector(rng, dims)
+		normalized := normalizeVector(query)
+
+		exact := make([]VectorMatch, count)
+		for i, v := range vectors {
+			exact[i] = VectorMatch{ID: i, Similarity: dotProduct(normalized, normalizeVector(v))}
+		}
+		sort.Slice(exact, func(i, j int) bool { return exact[i].Similarity > exact[j].Similarity })
+
+		want := make(map[int]bool, k)
+		for _, m := range exact[:k] {
+			want[m.ID] = true
+		}
+
+		got := index.Search(query, k)
+		require.Len(t, got, k)
+		for i, m := range got {
+			if i > 0 {
+				assert.GreaterOrEqual(t, got[i-1].Similarity, m.Similarity)
+			}
+			if want[m.ID] {
+				hits++
+			}
+		}
+		total += k
+	}
+
+	assert.GreaterOrEqual(t, float64(hits)/float64(total), 0.95)
+}
+
+func TestVectorIndex_ReplaceAndRemove(t *testing.T) {
+	index := NewVectorIndex()
+	require.NoError(t, index.Add(1, []float32{1, 0, 0}))
+	require.NoError(t, index.Add(2, []float32{0, 1, 0}))
+	require.NoError(t, index.Add(3, []float32{0, 0, 1}))
+
+	got := index.Search([]float32{2, 0, 0}, 1)
+	require.Len(t, got, 1)
+	assert.Equal(t, 1, got[0].ID)
+	assert.InDelta(t, 1.0, got[0].Similarity, 1e-6)
+
+	// Replacing moves the vector; the old position no longer matches
+	require.NoError(t, index.Add(1, []float32{0, 1, 0.1}))
+	assert.Equal(t, 3, index.Len())
+	got = index.Search([]float32{1, 0, 0}, 3)
+	require.Len(t, got, 3)
+	for _, m := range got {
+		assert.Less(t, m.Similarity, 0.5)
+	}
+
+	index.Remove(2)
+	assert.Equal(t, 2, index.Len())
+	got = index.Search([]float32{0, 1, 0}, 3)
+	require.Len(t, got, 2)
+	assert.Equal(t, 1, got[0].ID)
+}
+
+func TestVectorIndex_RejectsDimensionMismatch(t *testing.T) {
+	index := NewVectorIndex()
+	require.NoError(t, index.Add(1, []float32{1, 0}))
+	assert.Error(t, index.Add(2, []float32{1, 0, 0}))
+	assert.Error(t, index.Add(3, nil))
+	assert.Nil(t, index.Search([]float32{1, 0, 0}, 1))
+}
+
+func TestVectorIndex_CompactsTombstones(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	const dims, count = 16, 200
+
+	index := NewVectorIndex()
+	vectors := make([][]float32, count)
+	for i := range vectors {
+		vectors[i] = randomVector(rng, dims)
+		require.NoError(t, index.Add(i, vectors[i]))
+	}
+
+	// Re-embedding every recipe several times must not grow the graph
+	for round := 0; round < 5; round++ {
+		for i := range vectors {
+			vectors[i] = randomVector(rng, dims)
+			require.NoError(t, index.Add(i, vectors[i]))
+		}
+	}
+	for i := 0; i < count/2; i++ {
+		index.Remove(i)
+	}
+
+	assert.Equal(t, count/2, index.Len())
+	assert.LessOrEqual(t, float64(len(index.nodes)-index.live), hnswMaxTombstoneRatio*float64(len(index.nodes)))
+
+	for i := count / 2; i < count; i += 10 {
+		got := index.Search(vectors[i], 1)
+		require.Len(t, got, 1)
+		assert.Equal(t, i, got[0].ID)
+	}
+}
+
+func TestVectorIndex_IdenticalVectorsStayWithinUnitSimilarity(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for trial := 0; trial < 50; trial++ {
+		vector := randomVector(rng, 1536)
+		index := NewVectorIndex()
+		require.NoError(t, index.Add(1, vector))
+		require.NoError(t, index.Add(2, vector))
+
+		got := index.Search(vector, 2)
+		require.Len(t, got, 2)
+		for _, m := range got {
+			assert.LessOrEqual(t, m.Similarity, 1.0)
+			assert.InDelta(t, 1.0, m.Similarity, 1e-6)
+		}
+	}
+}
@@ -84,5 +84,5 @@ if command -v air &> /dev/null; then
 else
   echo -e "${YELLOW}💡 Install 'air' for live reload: go install github.com/air-verse/air@latest${NC}"
   echo -e "${BLUE}🔄 Starting server...${NC}"
-  go run cmd/api/main.go
+  go run -tags sqlite_fts5 cmd/api/main.go
 fi
\ No newline at end of file
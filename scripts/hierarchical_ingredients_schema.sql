@@ -291,4 +291,271 @@ INSERT INTO ingredient_group_mappings (ingredient_id, group_id, primary_group) V
     ((SELECT id FROM specific_ingredients WHERE name = 'ごま油'), 
      (SELECT id FROM ingredient_groups WHERE name = 'oils'), TRUE),
     ((SELECT id FROM specific_ingredients WHERE name = 'ごま油'), 
-     (SELECT id FROM ingredient_groups WHERE name = 'seasonings'), FALSE);
\ No newline at end of file
+     (SELECT id FROM ingredient_groups WHERE name = 'seasonings'), FALSE);
+-- 材料価格テーブル（基本単位あたりの価格）
+-- base_unit は IngredientAggregator.ConvertToBaseUnit の単位（g, ml, 個 など）
+CREATE TABLE IF NOT EXISTS ingredient_prices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ingredient_id INTEGER NOT NULL UNIQUE, -- specific_ingredients.id
+    base_unit TEXT NOT NULL,               -- 基本単位
+    price_per_unit REAL NOT NULL,          -- 基本単位あたりの価格（円）
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (ingredient_id) REFERENCES specific_ingredients(id) ON DELETE CASCADE,
+
+    CHECK (base_unit != ''),
+    CHECK (price_per_unit >= 0)
+);
+
+-- 初期価格データ（目安）
+INSERT INTO ingredient_prices (ingredient_id, base_unit, price_per_unit)
+SELECT si.id, p.base_unit, p.price_per_unit
+FROM specific_ingredients si
+JOIN (
+    SELECT '牛切り落とし' AS name, 'g' AS base_unit, 3.0 AS price_per_unit
+    UNION ALL SELECT '豚こま肉', 'g', 1.6
+    UNION ALL SELECT '鶏胸肉', 'g', 0.9
+    UNION ALL SELECT '玉ねぎ', '個', 50
+    UNION ALL SELECT '人参', '個', 40
+    UNION ALL SELECT 'じゃがいも', '個', 40
+    UNION ALL SELECT 'もやし', '個', 30
+    UNION ALL SELECT 'キャベツ', '個', 200
+    UNION ALL SELECT '白菜', '個', 300
+    UNION ALL SELECT 'レタス', '個', 180
+    UNION ALL SELECT 'きゅうり', '個', 50
+    UNION ALL SELECT 'トマト', '個', 80
+    UNION ALL SELECT 'ピーマン', '個', 40
+    UNION ALL SELECT 'ねぎ', '個', 100
+    UNION ALL SELECT 'にんにく', '個', 30
+    UNION ALL SELECT '鮭', '個', 150
+    UNION ALL SELECT 'ツナ缶', '個', 120
+    UNION ALL SELECT 'わかめ', 'g', 5.0
+    UNION ALL SELECT 'ご飯', 'g', 0.3
+    UNION ALL SELECT 'パスタ', 'g', 0.4
+    UNION ALL SELECT 'うどん', '玉', 40
+    UNION ALL SELECT '卵', '個', 25
+    UNION ALL SELECT '豆腐', '丁', 60
+    UNION ALL SELECT '塩', 'g', 0.2
+    UNION ALL SELECT '胡椒', 'g', 5.0
+    UNION ALL SELECT 'しょうゆ', 'ml', 0.4
+    UNION ALL SELECT '味噌', 'g', 0.8
+    UNION ALL SELECT '砂糖', 'g', 0.25
+    UNION ALL SELECT 'みりん', 'ml', 0.6
+    UNION ALL SELECT '酒', 'ml', 0.5
+    UNION ALL SELECT 'サラダ油', 'ml', 0.5
+    UNION ALL SELECT 'ごま油', 'ml', 1.5
+) p ON si.name = p.name;
+
+-- アレルゲンを含む加工品・調味料など
+INSERT INTO specific_ingredients (name, display_name, aliases) VALUES
+    ('マヨネーズ', 'マヨネーズ', '["マヨ"]'),
+    ('めんつゆ', 'めんつゆ', '["麺つゆ"]'),
+    ('バター', 'バター', '["有塩バター", "無塩バター"]'),
+    ('牛乳', '牛乳', '["ミルク"]'),
+    ('チーズ', 'チーズ', '["ピザ用チーズ", "とろけるチーズ", "粉チーズ"]'),
+    ('小麦粉', '小麦粉', '["薄力粉", "強力粉"]'),
+    ('パン粉', 'パン粉', '["パンこ"]'),
+    ('そば', 'そば', '["蕎麦", "日本そば"]'),
+    ('むきえび', 'むきえび', '["えび", "エビ", "海老", "むきエビ"]'),
+    ('かにかま', 'かにかま', '["カニカマ", "かに風味かまぼこ"]'),
+    ('ウインナー', 'ウインナー', '["ソーセージ", "ウィンナー"]'),
+    ('ベーコン', 'ベーコン', '["ハーフベーコン"]');
+
+INSERT INTO ingredient_group_mappings (ingredient_id, group_id, primary_group)
+SELECT si.id, g.id, m.primary_group
+FROM (
+    SELECT 'マヨネーズ' AS ingredient, 'basic_seasonings' AS grp, TRUE AS primary_group
+    UNION ALL SELECT 'マヨネーズ', 'seasonings', FALSE
+    UNION ALL SELECT 'めんつゆ', 'basic_seasonings', TRUE
+    UNION ALL SELECT 'めんつゆ', 'seasonings', FALSE
+    UNION ALL SELECT 'バター', 'dairy_eggs', TRUE
+    UNION ALL SELECT '牛乳', 'dairy_eggs', TRUE
+    UNION ALL SELECT 'チーズ', 'dairy_eggs', TRUE
+    UNION ALL SELECT '小麦粉', 'grains', TRUE
+    UNION ALL SELECT 'パン粉', 'grains', TRUE
+    UNION ALL SELECT 'そば', 'noodles', TRUE
+    UNION ALL SELECT 'そば', 'grains', FALSE
+    UNION ALL SELECT 'むきえび', 'seafood', TRUE
+    UNION ALL SELECT 'かにかま', 'canned_seafood', TRUE
+    UNION ALL SELECT 'かにかま', 'seafood', FALSE
+    UNION ALL SELECT 'ウインナー', 'pork', TRUE
+    UNION ALL SELECT 'ウインナー', 'meat', FALSE
+    UNION ALL SELECT 'ベーコン', 'pork', TRUE
+    UNION ALL SELECT 'ベーコン', 'meat', FALSE
+) m
+JOIN specific_ingredients si ON si.name = m.ingredient
+JOIN ingredient_groups g ON g.name = m.grp;
+
+-- 材料ごとのアレルゲン（特定原材料・特定原材料に準ずるもの）
+-- 材料かグループのどちらか一方に紐づく。グループのアレルゲンは下位グループの材料にも適用される
+CREATE TABLE IF NOT EXISTS ingredient_allergens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ingredient_id INTEGER,                 -- specific_ingredients.id
+    group_id INTEGER,                      -- ingredient_groups.id
+    allergen TEXT NOT NULL,                -- アレルゲン名（例：卵、小麦）
+
+    FOREIGN KEY (ingredient_id) REFERENCES specific_ingredients(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES ingredient_groups(id) ON DELETE CASCADE,
+
+    CHECK ((ingredient_id IS NULL) != (group_id IS NULL)),
+    CHECK (allergen != '')
+);
+
+-- NULL同士は重複とみなされないため、材料とグループで別々の部分ユニークインデックスを張る
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_allergens_ingredient
+    ON ingredient_allergens(ingredient_id, allergen) WHERE ingredient_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_allergens_group
+    ON ingredient_allergens(group_id, allergen) WHERE group_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ingredient_allergens_allergen ON ingredient_allergens(allergen);
+
+-- グループ単位のアレルゲン
+INSERT INTO ingredient_allergens (group_id, allergen)
+SELECT g.id, a.allergen
+FROM ingredient_groups g
+JOIN (
+    SELECT 'beef' AS name, '牛肉' AS allergen
+    UNION ALL SELECT 'pork', '豚肉'
+    UNION ALL SELECT 'chicken', '鶏肉'
+) a ON g.name = a.name;
+
+-- 材料単位のアレルゲン（原材料として含むものを含む）
+INSERT INTO ingredient_allergens (ingredient_id, allergen)
+SELECT si.id, a.allergen
+FROM specific_ingredients si
+JOIN (
+    SELECT '卵' AS name, '卵' AS allergen
+    UNION ALL SELECT 'マヨネーズ', '卵'
+    UNION ALL SELECT 'マヨネーズ', '大豆'
+    UNION ALL SELECT 'しょうゆ', '小麦'
+    UNION ALL SELECT 'しょうゆ', '大豆'
+    UNION ALL SELECT '味噌', '大豆'
+    UNION ALL SELECT 'めんつゆ', '小麦'
+    UNION ALL SELECT 'めんつゆ', '大豆'
+    UNION ALL SELECT '豆腐', '大豆'
+    UNION ALL SELECT 'パスタ', '小麦'
+    UNION ALL SELECT 'うどん', '小麦'
+    UNION ALL SELECT '小麦粉', '小麦'
+    UNION ALL SELECT 'パン粉', '小麦'
+    UNION ALL SELECT 'そば', 'そば'
+    UNION ALL SELECT 'そば', '小麦'
+    UNION ALL SELECT 'バター', '乳'
+    UNION ALL SELECT '牛乳', '乳'
+    UNION ALL SELECT 'チーズ', '乳'
+    UNION ALL SELECT 'むきえび', 'えび'
+    UNION ALL SELECT 'かにかま', 'かに'
+    UNION ALL SELECT 'かにかま', '卵'
+    UNION ALL SELECT 'かにかま', '小麦'
+    UNION ALL SELECT '鮭', 'さけ'
+    UNION ALL SELECT 'ごま油', 'ごま'
+) a ON si.name = a.name;
+
+-- 食事制限に関わる隠れた原材料（だしは魚、コンソメは肉を含むなど）
+INSERT INTO specific_ingredients (name, display_name, aliases) VALUES
+    ('だし', 'だし', '["和風だし", "かつおだし", "顆粒だし", "ほんだし", "だしの素"]'),
+    ('コンソメ', 'コンソメ', '["固形コンソメ", "顆粒コンソメ", "ブイヨン"]'),
+    ('鶏がらスープの素', '鶏がらスープの素', '["鶏ガラスープの素", "中華スープの素", "ガラスープ"]'),
+    ('オイスターソース', 'オイスターソース', '["かき油", "牡蠣油"]'),
+    ('ナンプラー', 'ナンプラー', '["魚醤", "フィッシュソース"]'),
+    ('カレールウ', 'カレールウ', '["カレールー"]'),
+    ('はちみつ', 'はちみつ', '["蜂蜜", "ハチミツ"]'),
+    ('ゼラチン', 'ゼラチン', '["粉ゼラチン", "板ゼラチン"]');
+
+INSERT INTO ingredient_group_mappings (ingredient_id, group_id, primary_group)
+SELECT si.id, g.id, m.primary_group
+FROM (
+    SELECT 'だし' AS ingredient, 'basic_seasonings' AS grp, TRUE AS primary_group
+    UNION ALL SELECT 'だし', 'seasonings', FALSE
+    UNION ALL SELECT 'コンソメ', 'basic_seasonings', TRUE
+    UNION ALL SELECT 'コンソメ', 'seasonings', FALSE
+    UNION ALL SELECT '鶏がらスープの素', 'basic_seasonings', TRUE
+    UNION ALL SELECT '鶏がらスープの素', 'seasonings', FALSE
+    UNION ALL SELECT 'オイスターソース', 'seasonings', TRUE
+    UNION ALL SELECT 'ナンプラー', 'seasonings', TRUE
+    UNION ALL SELECT 'カレールウ', 'seasonings', TRUE
+    UNION ALL SELECT 'はちみつ', 'seasonings', TRUE
+    UNION ALL SELECT 'ゼラチン', 'others', TRUE
+) m
+JOIN specific_ingredients si ON si.name = m.ingredient
+JOIN ingredient_groups g ON g.name = m.grp;
+
+-- 材料ごとの食事制限成分（meat, fish, egg, dairy, honey, pork, alcohol, gluten, salt）
+-- 材料かグループのどちらか一方に紐づく。グループの成分は下位グループの材料にも適用される
+CREATE TABLE IF NOT EXISTS ingredient_dietary_components (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ingredient_id INTEGER,                 -- specific_ingredients.id
+    group_id INTEGER,                      -- ingredient_groups.id
+    component TEXT NOT NULL,               -- 成分名（models.DietaryComponents）
+
+    FOREIGN KEY (ingredient_id) REFERENCES specific_ingredients(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES ingredient_groups(id) ON DELETE CASCADE,
+
+    CHECK ((ingredient_id IS NULL) != (group_id IS NULL)),
+    CHECK (component != '')
+);
+
+-- ingredient_allergens と同じく、材料とグループで別々の部分ユニークインデックスを張る
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_dietary_components_ingredient
+    ON ingredient_dietary_components(ingredient_id, component) WHERE ingredient_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_dietary_components_group
+    ON ingredient_dietary_components(group_id, component) WHERE group_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ingredient_dietary_components_component ON ingredient_dietary_components(component);
+
+-- グループ単位の成分
+-- 魚介類にはわかめなどの海藻も入るため、fish は下位グループと個別の材料に付ける
+INSERT INTO ingredient_dietary_components (group_id, component)
+SELECT g.id, c.component
+FROM ingredient_groups g
+JOIN (
+    SELECT 'meat' AS name, 'meat' AS component
+    UNION ALL SELECT 'pork', 'pork'
+    UNION ALL SELECT 'fish', 'fish'
+    UNION ALL SELECT 'canned_seafood', 'fish'
+) c ON g.name = c.name;
+
+-- 材料単位の成分（名前からは分からない原材料を含む）
+INSERT INTO ingredient_dietary_components (ingredient_id, component)
+SELECT si.id, c.component
+FROM specific_ingredients si
+JOIN (
+    SELECT 'だし' AS name, 'fish' AS component
+    UNION ALL SELECT 'だし', 'salt'
+    UNION ALL SELECT 'コンソメ', 'meat'
+    UNION ALL SELECT 'コンソメ', 'salt'
+    UNION ALL SELECT '鶏がらスープの素', 'meat'
+    UNION ALL SELECT '鶏がらスープの素', 'salt'
+    UNION ALL SELECT 'オイスターソース', 'fish'
+    UNION ALL SELECT 'オイスターソース', 'salt'
+    UNION ALL SELECT 'ナンプラー', 'fish'
+    UNION ALL SELECT 'ナンプラー', 'salt'
+    UNION ALL SELECT 'むきえび', 'fish'
+    UNION ALL SELECT 'カレールウ', 'gluten'
+    UNION ALL SELECT 'カレールウ', 'meat'
+    UNION ALL SELECT 'カレールウ', 'salt'
+    UNION ALL SELECT 'ゼラチン', 'meat'
+    UNION ALL SELECT 'ゼラチン', 'pork'
+    UNION ALL SELECT 'はちみつ', 'honey'
+    UNION ALL SELECT 'めんつゆ', 'fish'
+    UNION ALL SELECT 'めんつゆ', 'gluten'
+    UNION ALL SELECT 'めんつゆ', 'salt'
+    UNION ALL SELECT 'しょうゆ', 'gluten'
+    UNION ALL SELECT 'しょうゆ', 'salt'
+    UNION ALL SELECT '味噌', 'salt'
+    UNION ALL SELECT '塩', 'salt'
+    UNION ALL SELECT 'マヨネーズ', 'egg'
+    UNION ALL SELECT '卵', 'egg'
+    UNION ALL SELECT 'バター', 'dairy'
+    UNION ALL SELECT '牛乳', 'dairy'
+    UNION ALL SELECT 'チーズ', 'dairy'
+    UNION ALL SELECT 'みりん', 'alcohol'
+    UNION ALL SELECT '酒', 'alcohol'
+    UNION ALL SELECT 'パスタ', 'gluten'
+    UNION ALL SELECT 'うどん', 'gluten'
+    UNION ALL SELECT '小麦粉', 'gluten'
+    UNION ALL SELECT 'パン粉', 'gluten'
+    UNION ALL SELECT 'そば', 'gluten'
+    UNION ALL SELECT 'かにかま', 'egg'
+    UNION ALL SELECT 'かにかま', 'gluten'
+    UNION ALL SELECT 'かにかま', 'salt'
+    UNION ALL SELECT 'ベーコン', 'salt'
+    UNION ALL SELECT 'ウインナー', 'salt'
+) c ON si.name = c.name;
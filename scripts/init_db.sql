@@ -5,10 +5,17 @@
 PRAGMA foreign_keys = ON;
 
 -- Drop tables if they exist (for development)
+DROP TABLE IF EXISTS quarantined_recipes;
+DROP TABLE IF EXISTS generation_cache;
+DROP TABLE IF EXISTS model_prices;
+DROP TABLE IF EXISTS token_usage_ledger;
+DROP TABLE IF EXISTS duplicate_resolutions;
+DROP TABLE IF EXISTS recipe_dimension_mappings;
 DROP TABLE IF EXISTS duplicate_detection_results;
 DROP TABLE IF EXISTS recipe_embeddings;
 DROP TABLE IF EXISTS recipe_generation_jobs;
 DROP TABLE IF EXISTS meal_plans;
+DROP TABLE IF EXISTS pantry_items;
 DROP TABLE IF EXISTS user_preferences; 
 DROP TABLE IF EXISTS recipes;
 
@@ -62,6 +69,23 @@ CREATE TABLE user_preferences (
     UNIQUE(user_id)
 );
 
+-- Pantry inventory (what each user already has at home)
+CREATE TABLE pantry_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL DEFAULT 'default_user',
+    ingredient TEXT NOT NULL,
+    amount REAL NOT NULL DEFAULT 0,         -- normalized to g, ml or 個 where possible
+    unit TEXT NOT NULL,                     -- '適量' when the amount is unknown
+    purchase_date DATE,
+    expiry_date DATE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    -- Constraints
+    CHECK (ingredient != ''),
+    CHECK (amount >= 0)
+);
+
 -- Indexes for performance
 -- Recipe indexes
 CREATE INDEX idx_recipes_title ON recipes(title);
@@ -80,6 +104,10 @@ CREATE INDEX idx_meal_plans_created_at ON meal_plans(created_at);
 -- User preferences index
 CREATE INDEX idx_user_preferences_user_id ON user_preferences(user_id);
 
+-- Pantry indexes
+CREATE INDEX idx_pantry_items_user_ingredient ON pantry_items(user_id, ingredient);
+CREATE INDEX idx_pantry_items_expiry_date ON pantry_items(expiry_date);
+
 -- Phase 1: Batch API & Embedding Tables
 
 -- Batch job management table
@@ -134,6 +162,85 @@ CREATE TABLE duplicate_detection_results (
     CHECK (recipe_id != similar_recipe_id)
 );
 
+-- Duplicate resolution audit trail (pairs stored as recipe_id < similar_recipe_id;
+-- no foreign keys so entries outlive merged recipes)
+CREATE TABLE duplicate_resolutions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipe_id INTEGER NOT NULL,
+    similar_recipe_id INTEGER NOT NULL,
+    action TEXT NOT NULL,                   -- 'merge', 'keep_both', 'dismiss'
+    kept_recipe_id INTEGER,                 -- merge only
+    removed_recipe_id INTEGER,              -- merge only
+    removed_title TEXT,
+    similarity_score REAL,
+    jaccard_score REAL,
+    meal_plans_updated INTEGER DEFAULT 0,
+    coverage_adjusted INTEGER DEFAULT 0,
+    automatic BOOLEAN DEFAULT 0,            -- bulk auto-merge
+    resolved_by TEXT,
+    note TEXT,
+    resolved_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    
+    CHECK (action IN ('merge', 'keep_both', 'dismiss')),
+    CHECK (recipe_id < similar_recipe_id)
+);
+
+-- Token usage ledger: one row per LLM call, used to rebuild budgets at startup
+CREATE TABLE token_usage_ledger (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,           -- UTC
+    provider TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL,
+    stage TEXT NOT NULL DEFAULT '',         -- 'ideation', 'authoring', 'critique', 'generation', 'embedding', 'batch'
+    endpoint TEXT NOT NULL DEFAULT '',      -- Originating route, e.g. 'POST /api/recipes/generate'
+    job_id TEXT NOT NULL DEFAULT '',        -- Batch job, if any
+    user_id TEXT NOT NULL DEFAULT '',       -- Requesting user, if known
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens INTEGER NOT NULL DEFAULT 0,
+    cost_usd REAL NOT NULL DEFAULT 0,
+
+    CHECK (cost_usd >= 0)
+);
+
+-- Model price versions set through the admin API (built-in defaults and
+-- MODEL_PRICING_FILE entries are loaded at startup and not stored here)
+CREATE TABLE model_prices (
+    model TEXT NOT NULL,                    -- Exact name or prefix ending in '*'
+    effective_from TEXT NOT NULL,           -- RFC3339, UTC
+    input_per_1m REAL NOT NULL,
+    cached_input_per_1m REAL NOT NULL DEFAULT 0,
+    output_per_1m REAL NOT NULL DEFAULT 0,
+    batch_discount REAL NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (model, effective_from)
+);
+
+-- Persistent generation cache tier, shared by replicas and kept across restarts
+CREATE TABLE generation_cache (
+    cache_key TEXT PRIMARY KEY,             -- Normalized generation request
+    result JSON NOT NULL,                   -- Serialized GenerationResult
+    created_at DATETIME NOT NULL,           -- UTC
+    expires_at DATETIME NOT NULL,           -- UTC
+
+    CHECK (json_valid(result))
+);
+
+-- Generated recipes that still failed food safety validation after remediation
+CREATE TABLE quarantined_recipes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    data JSON NOT NULL,                     -- Serialized RecipeData as last validated
+    violations JSON NOT NULL,               -- Remaining safety violations
+    stage TEXT NOT NULL DEFAULT '',         -- 'generation', 'batch', 'enhanced'
+    endpoint TEXT NOT NULL DEFAULT '',      -- Originating route, if known
+    rounds INTEGER NOT NULL DEFAULT 0,      -- Remediation rounds attempted
+    created_at DATETIME NOT NULL,           -- UTC
+
+    CHECK (json_valid(data)),
+    CHECK (json_valid(violations))
+);
+
 -- Phase 1: Indexes for new tables
 
 -- Batch job indexes
@@ -154,6 +261,18 @@ CREATE INDEX idx_duplicates_similarity_score ON duplicate_detection_results(simi
 CREATE INDEX idx_duplicates_method ON duplicate_detection_results(detection_method);
 CREATE INDEX idx_duplicates_detected_at ON duplicate_detection_results(detected_at);
 
+-- Duplicate resolution indexes
+CREATE INDEX idx_duplicate_resolutions_pair ON duplicate_resolutions(recipe_id, similar_recipe_id);
+
+-- Token usage ledger indexes
+CREATE INDEX idx_token_usage_created_at ON token_usage_ledger(created_at);
+
+-- Generation cache indexes
+CREATE INDEX idx_generation_cache_expires_at ON generation_cache(expires_at);
+
+-- Recipe quarantine indexes
+CREATE INDEX idx_quarantined_recipes_created_at ON quarantined_recipes(created_at);
+
 -- Phase 2: Recipe Diversity System Tables (Issue #65)
 
 -- レシピ次元定義テーブル
@@ -170,6 +289,18 @@ CREATE TABLE recipe_dimensions (
     CHECK (is_active IN (0, 1))
 );
 
+-- レシピと次元の対応テーブル
+CREATE TABLE recipe_dimension_mappings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipe_id INTEGER NOT NULL,
+    dimension_id INTEGER NOT NULL,
+    confidence_score REAL DEFAULT 1.0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    
+    FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE,
+    FOREIGN KEY (dimension_id) REFERENCES recipe_dimensions(id) ON DELETE CASCADE
+);
+
 -- カバレッジ追跡テーブル
 CREATE TABLE dimension_coverage (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -212,6 +343,9 @@ CREATE INDEX idx_dimensions_value ON recipe_dimensions(dimension_value);
 CREATE INDEX idx_dimensions_active ON recipe_dimensions(is_active);
 CREATE INDEX idx_dimensions_weight ON recipe_dimensions(weight);
 
+-- Mapping indexes
+CREATE INDEX idx_dimension_mappings_recipe ON recipe_dimension_mappings(recipe_id);
+
 -- Coverage indexes  
 CREATE INDEX idx_coverage_combo ON dimension_coverage(dimension_combo);
 CREATE INDEX idx_coverage_current_count ON dimension_coverage(current_count);
@@ -339,6 +473,13 @@ BEGIN
     UPDATE user_preferences SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
 END;
 
+CREATE TRIGGER update_pantry_items_timestamp 
+    AFTER UPDATE ON pantry_items
+    FOR EACH ROW
+BEGIN
+    UPDATE pantry_items SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
 -- Diversity system triggers (Issue #65)
 
 -- Update dimension_coverage timestamp